@@ -35,6 +35,7 @@ import (
 
 const (
 	namespaceFlag         = "namespace"
+	runFlag               = "run"
 	sonobuoyImageFlag     = "sonobuoy-image"
 	imagePullPolicyFlag   = "image-pull-policy"
 	pluginFlag            = "plugin"
@@ -72,7 +73,15 @@ const (
 func AddNamespaceFlag(str *string, flags *pflag.FlagSet) {
 	flags.StringVarP(
 		str, namespaceFlag, "n", config.DefaultNamespace,
-		"The namespace to run Sonobuoy in. Only one Sonobuoy run can exist per namespace simultaneously.",
+		"The namespace to run Sonobuoy in. Several runs can share a namespace; use --run to pick one of them.",
+	)
+}
+
+// AddRunFlag initialises the run flag, which picks one of several runs by its ID.
+func AddRunFlag(str *string, flags *pflag.FlagSet) {
+	flags.StringVar(
+		str, runFlag, "",
+		"The ID of the run, as shown by `sonobuoy list`. Any prefix which only matches one run can be used. If set, the namespace of the run is used instead of --namespace.",
 	)
 }
 
@@ -302,6 +311,55 @@ func AddTimeoutFlag(flag *int, flags *pflag.FlagSet) {
 	)
 }
 
+// AddResumableFlag adds a bool flag for running the aggregator in a way that lets it be restarted
+// without losing the run.
+func AddResumableFlag(flag *bool, flags *pflag.FlagSet) {
+	flags.BoolVar(
+		flag, "resumable", false,
+		"If true, the aggregator is run as a StatefulSet with persistent storage and checkpoints its state so it can resume the run if it is restarted.",
+	)
+}
+
+// AddStorageSizeFlag adds a string flag for the size of the volume of resumable runs.
+func AddStorageSizeFlag(flag *string, flags *pflag.FlagSet) {
+	flags.StringVar(
+		flag, "storage-size", "",
+		fmt.Sprintf("The size of the volume claimed for the results of resumable runs, e.g. 10Gi. Defaults to %v.", config.DefaultAggregationStorageSize),
+	)
+}
+
+// AddStorageClassFlag adds a string flag for the storage class of the volume of resumable runs.
+func AddStorageClassFlag(flag *string, flags *pflag.FlagSet) {
+	flags.StringVar(
+		flag, "storage-class", "",
+		"The storage class of the volume claimed for the results of resumable runs. If unset, the default storage class of the cluster is used.",
+	)
+}
+
+// AddMetricsPortFlag adds an int flag for the port on which the aggregator serves Prometheus metrics and events.
+func AddMetricsPortFlag(flag *int, flags *pflag.FlagSet) {
+	flags.IntVar(
+		flag, "metrics-port", 0,
+		"If set, the aggregator serves Prometheus metrics and a stream of events about the run over plain HTTP on this port, which is also added to the aggregator service. 0 indicates no metrics.",
+	)
+}
+
+// AddExpectationsFlag adds a flag for the file listing the expected outcome of tests.
+func AddExpectationsFlag(expectations *[]config.Expectation, flags *pflag.FlagSet) {
+	flags.Var(
+		&Expectations{expectations: expectations}, "expectations",
+		"Path to a YAML file listing tests which are expected to fail (or otherwise not pass), along with why. Matching tests do not fail their plugin.",
+	)
+}
+
+// AddSigningKeySecretFlag adds a string flag for the secret holding the key used to sign results.
+func AddSigningKeySecretFlag(flag *string, flags *pflag.FlagSet) {
+	flags.StringVar(
+		flag, "signing-key-secret", "",
+		fmt.Sprintf("The name of a secret in the Sonobuoy namespace whose %q key holds a PEM encoded private key with which to sign the results.", config.SigningKeySecretKey),
+	)
+}
+
 // AddShowDefaultPodSpecFlag adds an bool flag for determining whether or not to include the default pod spec
 // used by Sonobuoy in the output
 func AddShowDefaultPodSpecFlag(flag *bool, flags *pflag.FlagSet) {
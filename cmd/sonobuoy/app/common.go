@@ -27,3 +27,17 @@ func getSonobuoyClientFromKubecfg(kubecfg Kubeconfig) (*client.SonobuoyClient, e
 	}
 	return getSonobuoyClient(cfg)
 }
+
+// resolveRun looks up the run with the given ID, or unique prefix of one, and sets namespace to the
+// namespace of the run. It returns the full ID of the run, or "" if no ID was given.
+func resolveRun(sbc *client.SonobuoyClient, runID string, namespace *string) (string, error) {
+	if runID == "" {
+		return "", nil
+	}
+	run, err := sbc.FindRun(runID)
+	if err != nil {
+		return "", err
+	}
+	*namespace = run.Namespace
+	return run.ID, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/controller"
+	sonodynamic "github.com/vmware-tanzu/sonobuoy/pkg/dynamic"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+)
+
+type controllerInput struct {
+	kubecfg      Kubeconfig
+	workers      int
+	ttl          time.Duration
+	pollInterval time.Duration
+}
+
+// NewCmdController returns the command that runs the controller of SonobuoyRun resources.
+func NewCmdController() *cobra.Command {
+	input := controllerInput{}
+	cmd := &cobra.Command{
+		Use:   "controller",
+		Short: "Runs a controller which starts and tracks the runs described by SonobuoyRun resources",
+		Long: "Runs a controller which starts a run for each SonobuoyRun resource, creating the same resources as " +
+			"`sonobuoy run`, reflects the status of the run in the status of the resource and deletes finished runs " +
+			"after --ttl. The SonobuoyRun CRD is printed by `sonobuoy gen crd`.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runController(input); err != nil {
+				errlog.LogError(errors.Wrap(err, "controller failed"))
+				os.Exit(1)
+			}
+		},
+		Args: cobra.ExactArgs(0),
+	}
+
+	AddKubeconfigFlag(&input.kubecfg, cmd.Flags())
+	cmd.Flags().IntVar(
+		&input.workers, "workers", 2,
+		"The number of runs reconciled at the same time.",
+	)
+	cmd.Flags().DurationVar(
+		&input.ttl, "ttl", controller.DefaultTTL,
+		"How long finished runs are kept before they are deleted along with their resources and results, unless they set ttlSecondsAfterFinished. 0 keeps them forever.",
+	)
+	cmd.Flags().DurationVar(
+		&input.pollInterval, "poll-interval", controller.DefaultPollInterval,
+		"How often the status of unfinished runs is checked.",
+	)
+
+	return cmd
+}
+
+func runController(input controllerInput) error {
+	cfg, err := input.kubecfg.Get()
+	if err != nil {
+		return errors.Wrap(err, "failed to get rest config")
+	}
+	kube, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create kubernetes client")
+	}
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create dynamic client")
+	}
+	objects, err := sonodynamic.NewAPIHelperFromRESTConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get sonobuoy api helper")
+	}
+
+	c := controller.NewController(kube, dyn, objects)
+	c.TTL = input.ttl
+	c.PollInterval = input.pollInterval
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return c.Run(ctx, input.workers)
+}
+
+// NewCmdGenCRD returns the command printing the SonobuoyRun CRD.
+func NewCmdGenCRD() *cobra.Command {
+	return &cobra.Command{
+		Use:   "crd",
+		Short: "Generates the SonobuoyRun custom resource definition used by `sonobuoy controller`",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print(string(controller.CRD()))
+		},
+		Args: cobra.NoArgs,
+	}
+}
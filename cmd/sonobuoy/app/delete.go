@@ -28,6 +28,7 @@ import (
 
 type deleteFlags struct {
 	namespace  string
+	runID      string
 	rbacMode   RBACMode
 	deleteAll  bool
 	wait       int
@@ -46,6 +47,7 @@ func NewCmdDelete() *cobra.Command {
 
 	AddKubeconfigFlag(&f.kubeconfig, cmd.Flags())
 	AddNamespaceFlag(&f.namespace, cmd.Flags())
+	AddRunFlag(&f.runID, cmd.Flags())
 	AddRBACModeFlags(&f.rbacMode, cmd.Flags(), DetectRBACMode)
 	AddDeleteAllFlag(&f.deleteAll, cmd.Flags())
 	AddDeleteWaitFlag(&f.wait, cmd.Flags())
@@ -62,6 +64,12 @@ func deleteSonobuoyRun(f *deleteFlags) func(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 
+		runID, err := resolveRun(sbc, f.runID, &f.namespace)
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not find the run"))
+			os.Exit(1)
+		}
+
 		kc, err := sbc.Client()
 		if err != nil {
 			errlog.LogError(err)
@@ -76,6 +84,7 @@ func deleteSonobuoyRun(f *deleteFlags) func(cmd *cobra.Command, args []string) {
 
 		deleteCfg := &client.DeleteConfig{
 			Namespace:  f.namespace,
+			RunID:      runID,
 			EnableRBAC: rbacEnabled,
 			DeleteAll:  f.deleteAll,
 			Wait:       time.Duration(f.wait) * time.Minute,
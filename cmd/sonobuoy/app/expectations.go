@@ -0,0 +1,42 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+)
+
+// Expectations is a list of expectations that implements pflag.Value by reading them from a file.
+type Expectations struct {
+	expectations *[]config.Expectation
+	path         string
+}
+
+func (e *Expectations) String() string { return e.path }
+func (e *Expectations) Type() string   { return "expectationsFile" }
+
+// Set reads and validates the expectations in the file.
+func (e *Expectations) Set(str string) error {
+	expectations, err := results.LoadExpectations(str)
+	if err != nil {
+		return err
+	}
+	*e.expectations = expectations
+	e.path = str
+	return nil
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
 
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/client-go/discovery"
@@ -68,6 +69,12 @@ func GenFlagSet(cfg *genFlags, rbac RBACMode) *pflag.FlagSet {
 	AddRBACModeFlags(&cfg.rbacMode, genset, rbac)
 	AddImagePullPolicyFlag(&cfg.sonobuoyConfig.ImagePullPolicy, genset)
 	AddTimeoutFlag(&cfg.sonobuoyConfig.Aggregation.TimeoutSeconds, genset)
+	AddResumableFlag(&cfg.sonobuoyConfig.Aggregation.Resumable, genset)
+	AddStorageSizeFlag(&cfg.sonobuoyConfig.Aggregation.StorageSize, genset)
+	AddStorageClassFlag(&cfg.sonobuoyConfig.Aggregation.StorageClassName, genset)
+	AddMetricsPortFlag(&cfg.sonobuoyConfig.Aggregation.MetricsPort, genset)
+	AddSigningKeySecretFlag(&cfg.sonobuoyConfig.SigningKeySecret, genset)
+	AddExpectationsFlag(&cfg.sonobuoyConfig.Expectations, genset)
 	AddShowDefaultPodSpecFlag(&cfg.showDefaultPodSpec, genset)
 
 	AddNamespaceFlag(&cfg.sonobuoyConfig.Namespace, genset)
@@ -97,6 +104,16 @@ func (g *genFlags) Config() (*client.GenConfig, error) {
 		g.plugins.DynamicPlugins = []string{e2ePlugin, systemdLogsPlugin}
 	}
 
+	// Every run gets an ID so that it can be told apart from other runs in the cluster. It is
+	// generated here rather than by the aggregator so that its pod is labelled with the same ID.
+	if g.sonobuoyConfig.UUID == "" {
+		runUUID, err := uuid.NewV4()
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't generate run ID")
+		}
+		g.sonobuoyConfig.UUID = runUUID.String()
+	}
+
 	// In some configurations, the kube client isn't actually needed for correct executation
 	// Therefore, delay reporting the error until we're sure we need the client
 	kubeclient, kubeError := getClient(&g.kubecfg)
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/client"
 	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
@@ -33,6 +34,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kuberuntime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -64,6 +66,17 @@ type GenPluginDefConfig struct {
 
 	// configMapFiles is the list of files to read/store as configmaps for the plugin.
 	configMapFiles []string
+
+	// timeout is how long the aggregator should wait for the plugin. Zero means the
+	// aggregator's default timeout is used.
+	timeout time.Duration
+
+	// backoff is how long to wait before retrying a failed pod for the plugin.
+	backoff time.Duration
+
+	// resultProcessor is the command the aggregator runs to process the results of an
+	// external plugin.
+	resultProcessor []string
 }
 
 // NewCmdGenPluginDef ...
@@ -95,7 +108,32 @@ func NewCmdGenPluginDef() *cobra.Command {
 
 	genPluginSet.StringVarP(
 		&genPluginOpts.def.SonobuoyConfig.ResultFormat, "format", "f", results.ResultFormatRaw,
-		"Result format (junit or raw)",
+		"Result format (junit, gotest, tap, ginkgo-json, external or raw)",
+	)
+
+	genPluginSet.StringArrayVar(
+		&genPluginOpts.resultProcessor, "result-processor", nil,
+		`Command the aggregator runs to process the results of an external plugin. It is given the results directory as its last argument and must write the results to stdout. Can be set multiple times (e.g. --result-processor /bin/parse --result-processor --strict)`,
+	)
+
+	genPluginSet.StringSliceVar(
+		&genPluginOpts.def.SonobuoyConfig.DependsOn, "depends-on", nil,
+		"Names of plugins which must complete successfully before this plugin is run. Can be set multiple times.",
+	)
+
+	genPluginSet.DurationVar(
+		&genPluginOpts.timeout, "timeout", 0,
+		"How long the aggregator should wait for results from this plugin (e.g. 30m). Defaults to the aggregator's timeout.",
+	)
+
+	genPluginSet.IntVar(
+		&genPluginOpts.def.SonobuoyConfig.Retries, "retries", 0,
+		"Number of times to replace a failed pod for this plugin before reporting the failure.",
+	)
+
+	genPluginSet.DurationVar(
+		&genPluginOpts.backoff, "backoff", 0,
+		"How long to wait before replacing a failed pod (e.g. 30s). Doubles after each retry, up to an hour.",
 	)
 
 	genPluginSet.StringToStringVar(
@@ -195,6 +233,18 @@ func genPluginDef(cfg *GenPluginDefConfig) ([]byte, error) {
 		cfg.def.PodSpec.NodeSelector = cfg.nodeSelector
 	}
 
+	if cfg.timeout > 0 {
+		cfg.def.SonobuoyConfig.Timeout = &metav1.Duration{Duration: cfg.timeout}
+	}
+
+	if cfg.backoff > 0 {
+		cfg.def.SonobuoyConfig.Backoff = &metav1.Duration{Duration: cfg.backoff}
+	}
+
+	if len(cfg.resultProcessor) > 0 {
+		cfg.def.SonobuoyConfig.ResultProcessor = &manifest.ResultProcessor{Command: cfg.resultProcessor}
+	}
+
 	if len(cfg.configMapFiles) > 0 {
 		cfg.def.ConfigMap = map[string]string{}
 	}
@@ -20,6 +20,7 @@ import (
 	"flag"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
 	v1 "k8s.io/api/core/v1"
@@ -67,6 +68,44 @@ func TestPluginGenDef(t *testing.T) {
 				driver: "Job",
 			},
 			expectFile: "testdata/pluginDef-sonoconfig.golden",
+		}, {
+			desc: "Plugin timeout",
+			cfg: GenPluginDefConfig{
+				def: manifest.Manifest{
+					SonobuoyConfig: manifest.SonobuoyConfig{
+						PluginName: "n",
+					},
+				},
+				driver:  "Job",
+				timeout: 90 * time.Minute,
+			},
+			expectFile: "testdata/pluginDef-timeout.golden",
+		}, {
+			desc: "Plugin retries",
+			cfg: GenPluginDefConfig{
+				def: manifest.Manifest{
+					SonobuoyConfig: manifest.SonobuoyConfig{
+						PluginName: "n",
+						Retries:    3,
+					},
+				},
+				driver:  "Job",
+				backoff: 30 * time.Second,
+			},
+			expectFile: "testdata/pluginDef-retries.golden",
+		}, {
+			desc: "External result processor",
+			cfg: GenPluginDefConfig{
+				def: manifest.Manifest{
+					SonobuoyConfig: manifest.SonobuoyConfig{
+						PluginName:   "n",
+						ResultFormat: "external",
+					},
+				},
+				driver:          "Job",
+				resultProcessor: []string{"/bin/parse", "--strict"},
+			},
+			expectFile: "testdata/pluginDef-result-processor.golden",
 		}, {
 			// The serialization is really handled by go-yaml/yaml so this
 			// test is mainly just for doc/sanity check. The rules for YAML
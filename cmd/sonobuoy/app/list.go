@@ -0,0 +1,91 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+)
+
+type listFlags struct {
+	kubecfg Kubeconfig
+}
+
+func NewCmdList() *cobra.Command {
+	var f listFlags
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the sonobuoy runs in all namespaces",
+		Run:   listRuns(&f),
+		Args:  cobra.ExactArgs(0),
+	}
+	AddKubeconfigFlag(&f.kubecfg, cmd.Flags())
+	return cmd
+}
+
+func listRuns(f *listFlags) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		sbc, err := getSonobuoyClientFromKubecfg(f.kubecfg)
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not create sonobuoy client"))
+			os.Exit(1)
+		}
+
+		runs, err := sbc.ListRuns()
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not list sonobuoy runs"))
+			os.Exit(1)
+		}
+
+		if err := printRuns(os.Stdout, runs, time.Now()); err != nil {
+			errlog.LogError(err)
+			os.Exit(1)
+		}
+	}
+}
+
+// printRuns writes a table of the runs, giving their age as of now. Runs started without an ID
+// are shown as <none>.
+func printRuns(w io.Writer, runs []client.RunSummary, now time.Time) error {
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "No sonobuoy runs found")
+		return nil
+	}
+
+	tw := defaultTabWriter(w)
+	fmt.Fprintf(tw, "RUN ID\tNAMESPACE\tSTATUS\tAGE\t\n")
+	for _, run := range runs {
+		id := run.ID
+		if id == "" {
+			id = "<none>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", id, run.Namespace, run.Phase, duration.HumanDuration(now.Sub(run.Created)))
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err, "couldn't write runs out")
+	}
+	return nil
+}
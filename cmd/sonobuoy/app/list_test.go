@@ -0,0 +1,64 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client"
+)
+
+func TestPrintRuns(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc     string
+		runs     []client.RunSummary
+		expected string
+	}{
+		{
+			desc:     "No runs",
+			expected: "No sonobuoy runs found\n",
+		}, {
+			desc: "Runs with and without an ID",
+			runs: []client.RunSummary{
+				{ID: "", Namespace: "sonobuoy", Phase: "complete", Created: now.Add(-50 * time.Hour)},
+				{ID: "3c5c6e3a-1b0c-4c3e-a2c7-3f5d7c9d2b1e", Namespace: "team-a", Phase: "running", Created: now.Add(-90 * time.Minute)},
+				{ID: "7d1f0b84-62e7-4d61-9b0e-0e3b9a6f4c21", Namespace: "team-a", Phase: "pending", Created: now.Add(-30 * time.Second)},
+			},
+			expected: `                                 RUN ID   NAMESPACE     STATUS    AGE
+                                 <none>    sonobuoy   complete   2d2h
+   3c5c6e3a-1b0c-4c3e-a2c7-3f5d7c9d2b1e      team-a    running    90m
+   7d1f0b84-62e7-4d61-9b0e-0e3b9a6f4c21      team-a    pending    30s
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := printRuns(&b, tc.runs, now); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if b.String() != tc.expected {
+				t.Errorf("Expected output:\n%q\ngot:\n%q", tc.expected, b.String())
+			}
+		})
+	}
+}
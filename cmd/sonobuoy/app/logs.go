@@ -34,6 +34,7 @@ const (
 
 type logFlags struct {
 	namespace  string
+	runID      string
 	follow     bool
 	plugin     string
 	kubeconfig Kubeconfig
@@ -54,6 +55,7 @@ func NewCmdLogs() *cobra.Command {
 	)
 	AddKubeconfigFlag(&f.kubeconfig, cmd.Flags())
 	AddNamespaceFlag(&f.namespace, cmd.Flags())
+	AddRunFlag(&f.runID, cmd.Flags())
 	cmd.Flags().StringVarP(&f.plugin, pluginFlag, "p", "", "Show logs for a specific plugin")
 	return cmd
 }
@@ -66,8 +68,15 @@ func getLogs(f *logFlags) func(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 
+		runID, err := resolveRun(sbc, f.runID, &f.namespace)
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not find the run"))
+			os.Exit(1)
+		}
+
 		logConfig := client.NewLogConfig()
 		logConfig.Namespace = f.namespace
+		logConfig.RunID = runID
 		logConfig.Follow = f.follow
 		logConfig.Plugin = f.plugin
 
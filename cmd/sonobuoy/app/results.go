@@ -18,19 +18,24 @@ package app
 
 import (
 	"compress/gzip"
+	"crypto"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
 	"github.com/vmware-tanzu/sonobuoy/pkg/discovery"
 	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -45,6 +50,13 @@ const (
 	// resultModeDump will just copy the post-processed yaml file to stdout.
 	resultModeDump = "dump"
 
+	// resultModeHTML renders a self-contained HTML page summarizing the results of every plugin.
+	resultModeHTML = "html"
+
+	// resultModeTiming shows the slowest tests and how long each suite and node took, as recorded
+	// by the results, and optionally which tests got slower than in a baseline archive.
+	resultModeTiming = "timing"
+
 	windowsSeperator = `\`
 )
 
@@ -54,6 +66,21 @@ type resultsInput struct {
 	mode       string
 	node       string
 	skipPrefix bool
+	verify     bool
+
+	// outputFormat, if set, is one of results.ExportFormats to convert the results into instead
+	// of printing them according to the mode.
+	outputFormat string
+
+	// filterExpr, if set, is parsed into filter to only show the matching tests of each plugin.
+	filterExpr string
+	filter     *results.Filter
+
+	// baseline is an archive to compare test durations against in timing mode.
+	baseline string
+
+	// expectations, if set, are applied to the results of each plugin before printing them.
+	expectations []config.Expectation
 }
 
 func NewCmdResults() *cobra.Command {
@@ -77,7 +104,7 @@ func NewCmdResults() *cobra.Command {
 	)
 	cmd.Flags().StringVarP(
 		&data.mode, "mode", "m", resultModeReport,
-		`Modifies the format of the output. Valid options are report, detailed, or dump.`,
+		`Modifies the format of the output. Valid options are report, detailed, dump, html, or timing.`,
 	)
 	cmd.Flags().StringVarP(
 		&data.node, "node", "n", "",
@@ -87,6 +114,28 @@ func NewCmdResults() *cobra.Command {
 		&data.skipPrefix, "skip-prefix", "s", false,
 		`When printing items linking to files, only print the file contents.`,
 	)
+	cmd.Flags().BoolVar(
+		&data.verify, "verify", false,
+		`Check the plugin results in the archive against the digests recorded by the aggregator before printing them.`,
+	)
+	cmd.Flags().StringVar(
+		&data.outputFormat, "output-format", "",
+		fmt.Sprintf("Convert the results of any plugin into a format for CI systems and test dashboards instead of printing them according to --mode. Valid options are %v.", strings.Join(results.ExportFormats, ", ")),
+	)
+	cmd.Flags().StringVar(
+		&data.filterExpr, "filter", "",
+		`Only show the tests matching the expression, e.g. 'status=failed and name~"\[sig-network\]" and node=worker-*'. See the docs for the fields and operators available.`,
+	)
+	cmd.Flags().StringVar(
+		&data.baseline, "baseline", "",
+		`In timing mode, an archive of an earlier run to list the tests which got slower since.`,
+	)
+	AddExpectationsFlag(&data.expectations, cmd.Flags())
+
+	cmd.AddCommand(NewCmdResultsVerify())
+	cmd.AddCommand(NewCmdResultsDiff())
+	cmd.AddCommand(NewCmdResultsHistory())
+	cmd.AddCommand(NewCmdResultsMerge())
 
 	return cmd
 }
@@ -112,6 +161,40 @@ func getReader(filepath string) (*results.Reader, func(), error) {
 // If there is an error printing any individual plugin, only the last error is printed and all plugins
 // continue to be processed.
 func result(input resultsInput) error {
+	if input.verify {
+		report, err := verifyDigests(input.archive, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Verified digests of %v plugin result files\n", report.Verified)
+	}
+
+	if err := warnIfPartial(input.archive); err != nil {
+		return err
+	}
+
+	if input.filterExpr != "" {
+		filter, err := results.ParseFilter(input.filterExpr)
+		if err != nil {
+			return err
+		}
+		input.filter = filter
+	}
+
+	if input.outputFormat != "" {
+		return exportResults(input, os.Stdout)
+	}
+
+	if input.mode == resultModeHTML {
+		if len(input.expectations) > 0 {
+			return errors.New("expectations cannot be applied in html mode")
+		}
+		if input.filter != nil {
+			return errors.New("filters cannot be applied in html mode")
+		}
+		return printHTMLReport(input, os.Stdout)
+	}
+
 	r, cleanup, err := getReader(input.archive)
 	defer cleanup()
 	if err != nil {
@@ -156,9 +239,109 @@ func result(input resultsInput) error {
 	return lastErr
 }
 
+// verifyDigests checks the plugin results in the archive against the manifest written by the
+// aggregator, returning an error describing any files which don't match. If a key is given, the
+// signature of the manifest is checked as well.
+func verifyDigests(archive string, key crypto.PublicKey) (*results.DigestReport, error) {
+	r, cleanup, err := getReader(archive)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	var report *results.DigestReport
+	if key != nil {
+		report, err = r.VerifySignedDigests(key)
+	} else {
+		report, err = r.VerifyDigests()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to verify archive")
+	}
+	if !report.OK() {
+		return nil, fmt.Errorf("archive failed verification:\n%v", report)
+	}
+	return report, nil
+}
+
+// printHTMLReport writes a single HTML page covering every plugin, or just the one specified.
+func printHTMLReport(input resultsInput, w io.Writer) error {
+	r, cleanup, err := getReader(input.archive)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	report, err := r.HTMLReport(input.plugin)
+	if err != nil {
+		return errors.Wrap(err, "unable to read archive for HTML report")
+	}
+	report.Archive = filepath.Base(input.archive)
+	return results.WriteHTML(w, report)
+}
+
+// exportResults writes the results of every plugin, or just the one specified, in the output format.
+func exportResults(input resultsInput, w io.Writer) error {
+	validFormat := false
+	for _, format := range results.ExportFormats {
+		validFormat = validFormat || format == input.outputFormat
+	}
+	if !validFormat {
+		return fmt.Errorf("unknown output format %q, valid options are %v", input.outputFormat, strings.Join(results.ExportFormats, ", "))
+	}
+
+	items, err := loadPluginResults(input.archive)
+	if err != nil {
+		return err
+	}
+	if input.plugin != "" {
+		item, ok := items[input.plugin]
+		if !ok {
+			return fmt.Errorf("no results found for plugin %q", input.plugin)
+		}
+		items = map[string]*results.Item{input.plugin: item}
+	}
+
+	for name, item := range items {
+		if err := results.ApplyExpectations(item, name, input.expectations, time.Now()); err != nil {
+			return errors.Wrap(err, "failed to apply expectations")
+		}
+		if input.filter != nil {
+			if item = input.filter.Apply(name, item); item == nil {
+				delete(items, name)
+				continue
+			}
+			items[name] = item
+		}
+		if input.node == "" {
+			continue
+		}
+		if item = nodeSubTree(item, input.node); item == nil {
+			delete(items, name)
+			continue
+		}
+		items[name] = item
+	}
+	if len(items) == 0 && input.node != "" && input.filter == nil {
+		return fmt.Errorf("node named %q not found", input.node)
+	}
+
+	return results.Export(w, input.outputFormat, items)
+}
+
+// nodeSubTree returns the results rooted at the item with the given name, like GetSubTreeByName,
+// but still under an item for the plugin so that tests are reported as running on the node.
+func nodeSubTree(item *results.Item, node string) *results.Item {
+	subTree := item.GetSubTreeByName(node)
+	if subTree == nil || subTree == item {
+		return subTree
+	}
+	return &results.Item{Name: item.Name, Status: subTree.Status, Items: []results.Item{*subTree}}
+}
+
 func printSinglePlugin(input resultsInput, r *results.Reader) error {
-	// If we want to dump the whole file, don't decode to an Item object first.
-	if input.mode == resultModeDump {
+	// If we want to dump the whole file, don't decode to an Item object first unless it will be changed.
+	if input.mode == resultModeDump && len(input.expectations) == 0 && input.filter == nil {
 		fReader, err := r.PluginResultsReader(input.plugin)
 		if err != nil {
 			return errors.Wrapf(err, "failed to get results reader for plugin %v", input.plugin)
@@ -173,12 +356,47 @@ func printSinglePlugin(input resultsInput, r *results.Reader) error {
 		return err
 	}
 
+	if err := results.ApplyExpectations(obj, input.plugin, input.expectations, time.Now()); err != nil {
+		return errors.Wrap(err, "failed to apply expectations")
+	}
+
+	if input.filter != nil {
+		filtered := input.filter.Apply(input.plugin, obj)
+		if filtered == nil {
+			if input.mode == resultModeReport {
+				fmt.Printf("Plugin: %v\nNo tests match the filter %v\n", input.plugin, input.filter)
+			}
+			return nil
+		}
+		obj = filtered
+	}
+
+	if input.mode == resultModeTiming {
+		var baseline *results.Item
+		if input.baseline != "" {
+			if baseline, err = loadBaselineResults(input.baseline, input.plugin); err != nil {
+				return err
+			}
+		}
+		if obj = nodeSubTree(obj, input.node); obj == nil {
+			return fmt.Errorf("node named %q not found", input.node)
+		}
+		return printTiming(os.Stdout, input.plugin, obj, baseline, input.baseline)
+	}
+
 	obj = obj.GetSubTreeByName(input.node)
 	if obj == nil {
 		return fmt.Errorf("node named %q not found", input.node)
 	}
 
 	switch input.mode {
+	case resultModeDump:
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return errors.Wrap(err, "marshalling results to yaml")
+		}
+		_, err = os.Stdout.Write(b)
+		return err
 	case resultModeDetailed:
 		return printResultsDetails([]string{}, obj, input)
 	default:
@@ -186,6 +404,27 @@ func printSinglePlugin(input resultsInput, r *results.Reader) error {
 	}
 }
 
+// warnIfPartial warns if the archive holds the partial results of a run which was in progress,
+// naming the plugins which were still running since they have no post-processed results.
+func warnIfPartial(archive string) error {
+	r, cleanup, err := getReader(archive)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	info, err := r.PartialInfo()
+	if err != nil || info == nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: these are partial results, retrieved at %v while the run was in progress.\n", info.CreatedAt.Format(time.RFC3339))
+	if incomplete := info.IncompletePlugins(); len(incomplete) > 0 {
+		fmt.Fprintf(os.Stderr, "Plugins still running, which are missing from the report: %v\n", strings.Join(incomplete, ", "))
+	}
+	return nil
+}
+
 func getPluginList(r *results.Reader) ([]string, error) {
 	runInfo := discovery.RunInfo{}
 	err := r.WalkFiles(func(path string, info os.FileInfo, err error) error {
@@ -0,0 +1,165 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+)
+
+const (
+	diffFormatText  = "text"
+	diffFormatJSON  = "json"
+	diffFormatJUnit = "junit"
+
+	// diffExitRegressions is the exit code when the new run has regressions. As with diff(1),
+	// errors use a different code so that scripts can tell them apart.
+	diffExitRegressions = 1
+	diffExitError       = 2
+)
+
+type resultsDiffInput struct {
+	oldArchive string
+	newArchive string
+	format     string
+	plugin     string
+}
+
+func NewCmdResultsDiff() *cobra.Command {
+	input := resultsDiffInput{}
+	cmd := &cobra.Command{
+		Use:   "diff old.tar.gz new.tar.gz",
+		Short: "Compares the plugin results of two runs.",
+		Long: "Reports the tests which newly failed, newly passed, appeared, disappeared or otherwise changed status " +
+			"between two runs, per plugin and node. Exits with 1 if any test newly failed and 2 on errors.",
+		Run: func(cmd *cobra.Command, args []string) {
+			input.oldArchive, input.newArchive = args[0], args[1]
+			diff, err := diffResults(input)
+			if err == nil {
+				err = printDiff(os.Stdout, diff, input.format)
+			}
+			if err != nil {
+				errlog.LogError(errors.Wrap(err, "could not compare archives"))
+				os.Exit(diffExitError)
+			}
+			if len(diff.Regressions()) > 0 {
+				os.Exit(diffExitRegressions)
+			}
+		},
+		Args: cobra.ExactArgs(2),
+	}
+
+	cmd.Flags().StringVar(
+		&input.format, "output-format", diffFormatText,
+		fmt.Sprintf("The format of the output. Valid options are %v, %v or %v.", diffFormatText, diffFormatJSON, diffFormatJUnit),
+	)
+	cmd.Flags().StringVarP(
+		&input.plugin, "plugin", "p", "",
+		"Which plugin to compare results for. Defaults to comparing them all.",
+	)
+
+	return cmd
+}
+
+// diffResults loads the results of every plugin from both archives and compares them.
+func diffResults(input resultsDiffInput) (*results.Diff, error) {
+	switch input.format {
+	case diffFormatText, diffFormatJSON, diffFormatJUnit:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", input.format)
+	}
+
+	oldItems, err := loadPluginResults(input.oldArchive)
+	if err != nil {
+		return nil, err
+	}
+	newItems, err := loadPluginResults(input.newArchive)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.plugin != "" {
+		if oldItems[input.plugin] == nil && newItems[input.plugin] == nil {
+			return nil, fmt.Errorf("plugin %q not found in either archive", input.plugin)
+		}
+		return &results.Diff{Tests: results.DiffItems(input.plugin, oldItems[input.plugin], newItems[input.plugin])}, nil
+	}
+	return results.DiffResults(oldItems, newItems), nil
+}
+
+func loadPluginResults(archive string) (map[string]*results.Item, error) {
+	r, cleanup, err := getReader(archive)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.PluginResultsItems()
+	return items, errors.Wrapf(err, "could not read plugin results from %v", archive)
+}
+
+func printDiff(w io.Writer, diff *results.Diff, format string) error {
+	switch format {
+	case diffFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(diff), "could not encode diff")
+	case diffFormatJUnit:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(diff.JUnit()); err != nil {
+			return errors.Wrap(err, "could not encode diff")
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	default:
+		printDiffText(w, diff)
+		return nil
+	}
+}
+
+func printDiffText(w io.Writer, diff *results.Diff) {
+	if len(diff.Tests) == 0 {
+		fmt.Fprintln(w, "No tests changed")
+		return
+	}
+
+	var plugin, node string
+	for i, t := range diff.Tests {
+		if i == 0 || t.Plugin != plugin || t.Node != node {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			plugin, node = t.Plugin, t.Node
+			fmt.Fprintf(w, "Plugin: %v\nNode: %v\n", plugin, node)
+		}
+		fmt.Fprintf(w, "  %v: %v\n", t, t.Name)
+	}
+
+	fmt.Fprintf(w, "\n%v tests changed, %v regressions\n", len(diff.Tests), len(diff.Regressions()))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+)
+
+func TestPrintDiff(t *testing.T) {
+	diff := &results.Diff{Tests: []results.TestDiff{
+		{Plugin: "e2e", Node: "global", Name: "a", Kind: results.DiffNewlyFailed, OldStatus: "passed", NewStatus: "failed"},
+		{Plugin: "e2e", Node: "global", Name: "b", Kind: results.DiffAppeared, NewStatus: "passed"},
+		{Plugin: "systemd-logs", Node: "node1", Name: "out.json", Kind: results.DiffDisappeared, OldStatus: "passed"},
+	}}
+
+	testCases := []struct {
+		desc     string
+		diff     *results.Diff
+		format   string
+		expected string
+	}{
+		{
+			desc:   "Text",
+			diff:   diff,
+			format: diffFormatText,
+			expected: `Plugin: e2e
+Node: global
+  newly-failed (passed -> failed): a
+  appeared (passed): b
+
+Plugin: systemd-logs
+Node: node1
+  disappeared (was passed): out.json
+
+3 tests changed, 1 regressions
+`,
+		}, {
+			desc:     "No changes",
+			diff:     &results.Diff{},
+			format:   diffFormatText,
+			expected: "No tests changed\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var out strings.Builder
+			if err := printDiff(&out, tc.diff, tc.format); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Expected output:\n%v\nbut got:\n%v", tc.expected, out.String())
+			}
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var out strings.Builder
+		if err := printDiff(&out, diff, diffFormatJSON); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		decoded := &results.Diff{}
+		if err := json.Unmarshal([]byte(out.String()), decoded); err != nil {
+			t.Fatalf("Expected valid JSON but got %v", err)
+		}
+		if len(decoded.Tests) != 3 || decoded.Tests[0].Kind != results.DiffNewlyFailed {
+			t.Errorf("Expected JSON to round trip but got %+v", decoded)
+		}
+	})
+}
+
+func TestDiffResults(t *testing.T) {
+	archive := filepath.Join("testdata", "testResultsOutput.tar.gz")
+
+	diff, err := diffResults(resultsDiffInput{oldArchive: archive, newArchive: archive, format: diffFormatText})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(diff.Tests) != 0 {
+		t.Errorf("Expected no changes comparing an archive to itself but got %+v", diff.Tests)
+	}
+
+	if _, err := diffResults(resultsDiffInput{oldArchive: archive, newArchive: archive, format: "yaml"}); err == nil {
+		t.Error("Expected an error for an unknown output format")
+	}
+	if _, err := diffResults(resultsDiffInput{oldArchive: archive, newArchive: archive, format: diffFormatText, plugin: "missing"}); err == nil {
+		t.Error("Expected an error for a plugin in neither archive")
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results/history"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+)
+
+const (
+	historyFormatText = "text"
+	historyFormatJSON = "json"
+
+	// defaultHistoryStore is the name of the store created in the directory of archives if
+	// no other location is given.
+	defaultHistoryStore = "sonobuoy-history.db"
+)
+
+type resultsHistoryInput struct {
+	dir            string
+	store          string
+	window         time.Duration
+	clusterVersion string
+	plugin         string
+	flipThreshold  int
+	all            bool
+	format         string
+}
+
+func NewCmdResultsHistory() *cobra.Command {
+	input := resultsHistoryInput{}
+	cmd := &cobra.Command{
+		Use:   "history DIR",
+		Short: "Reports how each test behaved over many runs.",
+		Long: "Adds every results archive in the directory to a local store, keyed by run UUID, then reports the " +
+			"pass rate of each test, how often it flipped between passing and failing, and whether it is flaky, " +
+			"over the runs in the time window. Archives already in the store are not read again.",
+		Run: func(cmd *cobra.Command, args []string) {
+			input.dir = args[0]
+			if err := resultsHistory(input, os.Stdout); err != nil {
+				errlog.LogError(errors.Wrap(err, "could not report results history"))
+				os.Exit(1)
+			}
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.Flags().StringVar(
+		&input.store, "store", "",
+		fmt.Sprintf("Path to the store of results. Defaults to %v in the directory of archives.", defaultHistoryStore),
+	)
+	cmd.Flags().DurationVar(
+		&input.window, "window", 30*24*time.Hour,
+		"Only report on runs within this long before now. 0 includes every run.",
+	)
+	cmd.Flags().StringVar(
+		&input.clusterVersion, "cluster-version", "",
+		"Only report on runs against this Kubernetes version (e.g. v1.21.1).",
+	)
+	cmd.Flags().StringVarP(
+		&input.plugin, "plugin", "p", "",
+		"Which plugin to report on. Defaults to all of them.",
+	)
+	cmd.Flags().IntVar(
+		&input.flipThreshold, "flip-threshold", history.DefaultFlipThreshold,
+		"How many times a test has to flip between passing and failing to be considered flaky.",
+	)
+	cmd.Flags().BoolVar(
+		&input.all, "all", false,
+		"Report on every test rather than only those which failed in at least one run.",
+	)
+	cmd.Flags().StringVar(
+		&input.format, "output-format", historyFormatText,
+		fmt.Sprintf("The format of the output. Valid options are %v or %v.", historyFormatText, historyFormatJSON),
+	)
+
+	return cmd
+}
+
+// historyReport is the JSON form of the report.
+type historyReport struct {
+	Runs  []history.Run       `json:"runs"`
+	Tests []historyTestReport `json:"tests"`
+}
+
+type historyTestReport struct {
+	history.TestStats
+	PassRate float64 `json:"passRate"`
+}
+
+func resultsHistory(input resultsHistoryInput, w io.Writer) error {
+	if input.format != historyFormatText && input.format != historyFormatJSON {
+		return fmt.Errorf("unknown output format %q", input.format)
+	}
+	if input.store == "" {
+		input.store = filepath.Join(input.dir, defaultHistoryStore)
+	}
+
+	store, err := history.Open(input.store)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := ingestArchives(store, input.dir); err != nil {
+		return err
+	}
+
+	filter := history.Filter{ClusterVersion: input.clusterVersion, Plugin: input.plugin}
+	if input.window > 0 {
+		filter.Since = time.Now().Add(-input.window)
+	}
+	runs, err := store.Runs(filter)
+	if err != nil {
+		return err
+	}
+	stats, err := store.Stats(filter, input.flipThreshold)
+	if err != nil {
+		return err
+	}
+
+	report := historyReport{Runs: runs, Tests: []historyTestReport{}}
+	for _, t := range stats {
+		if input.all || t.Failed > 0 {
+			report.Tests = append(report.Tests, historyTestReport{TestStats: t, PassRate: t.PassRate()})
+		}
+	}
+
+	if input.format == historyFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(report), "could not encode results history")
+	}
+	printHistoryText(w, report)
+	return nil
+}
+
+// ingestArchives adds every archive in the directory to the store. Archives which can't be read
+// are logged and skipped so that one bad archive doesn't prevent reporting on the rest, as are
+// archives of partial results.
+func ingestArchives(store *history.Store, dir string) error {
+	archives, err := filepath.Glob(filepath.Join(dir, "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		run, added, err := store.Ingest(archive)
+		if errors.Cause(err) == history.ErrPartialResults {
+			logrus.Infof("Skipping %v since it holds partial results", archive)
+			continue
+		}
+		if err != nil {
+			errlog.LogError(err)
+			continue
+		}
+		if added {
+			logrus.Debugf("Added run %v from %v", run.UUID, archive)
+		}
+	}
+	return nil
+}
+
+func printHistoryText(w io.Writer, report historyReport) {
+	if len(report.Runs) == 0 {
+		fmt.Fprintln(w, "No runs found")
+		return
+	}
+	first, last := report.Runs[0], report.Runs[len(report.Runs)-1]
+	fmt.Fprintf(w, "Runs: %v (%v to %v)\n", len(report.Runs), first.Time.Format(time.RFC3339), last.Time.Format(time.RFC3339))
+
+	if len(report.Tests) == 0 {
+		fmt.Fprintln(w, "No tests failed")
+		return
+	}
+	fmt.Fprintln(w)
+
+	tw := defaultTabWriter(w)
+	fmt.Fprintf(tw, "PLUGIN\tNODE\tRUNS\tPASS RATE\tFLIPS\tCLASSIFICATION\tTEST\t\n")
+	for _, t := range report.Tests {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%.0f%%\t%v\t%v\t%v\t\n", t.Plugin, t.Node, t.Runs, t.PassRate*100, t.Flips, t.Classification, t.Name)
+	}
+	tw.Flush()
+}
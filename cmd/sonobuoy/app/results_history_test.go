@@ -0,0 +1,110 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results/history"
+)
+
+func TestResultsHistory(t *testing.T) {
+	dir := t.TempDir()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "testResultsOutput.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "testResultsOutput.tar.gz"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := resultsHistoryInput{dir: dir, plugin: "e2e", flipThreshold: history.DefaultFlipThreshold, format: historyFormatJSON}
+
+	// Running twice checks that the run is only added to the store once.
+	for i := 0; i < 2; i++ {
+		var out strings.Builder
+		if err := resultsHistory(input, &out); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		report := historyReport{}
+		if err := json.Unmarshal([]byte(out.String()), &report); err != nil {
+			t.Fatalf("Failed to decode output: %v", err)
+		}
+		if len(report.Runs) != 1 || report.Runs[0].ClusterVersion != "v1.14.2" {
+			t.Errorf("Expected a single run against v1.14.2 but got %+v", report.Runs)
+		}
+		if len(report.Tests) != 1 || report.Tests[0].Plugin != "e2e" || report.Tests[0].Classification != history.ClassFailing {
+			t.Errorf("Expected a single failing e2e test but got %+v", report.Tests)
+		}
+	}
+
+	input.format = "yaml"
+	if err := resultsHistory(input, &strings.Builder{}); err == nil {
+		t.Error("Expected error for unknown output format")
+	}
+}
+
+func TestPrintHistoryText(t *testing.T) {
+	runs := []history.Run{
+		{UUID: "a", Time: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{UUID: "b", Time: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	testCases := []struct {
+		desc     string
+		report   historyReport
+		expected string
+	}{
+		{
+			desc: "Tests",
+			report: historyReport{Runs: runs, Tests: []historyTestReport{
+				{
+					TestStats: history.TestStats{Plugin: "e2e", Node: "global", Name: "a", Runs: 2, Flips: 1, Classification: history.ClassBroken},
+					PassRate:  0.5,
+				},
+			}},
+			expected: `Runs: 2 (2021-03-01T00:00:00Z to 2021-03-02T00:00:00Z)
+
+   PLUGIN     NODE   RUNS   PASS RATE   FLIPS   CLASSIFICATION   TEST
+      e2e   global      2         50%       1           broken      a
+`,
+		}, {
+			desc:     "No failures",
+			report:   historyReport{Runs: runs},
+			expected: "Runs: 2 (2021-03-01T00:00:00Z to 2021-03-02T00:00:00Z)\nNo tests failed\n",
+		}, {
+			desc:     "No runs",
+			expected: "No runs found\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var out strings.Builder
+			printHistoryText(&out, tc.report)
+			if out.String() != tc.expected {
+				t.Errorf("Expected output:\n%q\nbut got:\n%q", tc.expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+)
+
+type resultsMergeInput struct {
+	archives []string
+	output   string
+	opts     results.MergeOptions
+}
+
+func NewCmdResultsMerge() *cobra.Command {
+	input := resultsMergeInput{}
+	cmd := &cobra.Command{
+		Use:   "merge a.tar.gz b.tar.gz...",
+		Short: "Combines the results of several runs into a single archive.",
+		Long: "Combines the results of runs which were split up, e.g. by focus or by node pool, into a single archive " +
+			"which can be inspected like that of any other run. Plugins which ran in more than one archive have their " +
+			"results combined, or are renamed with --rename-plugins. The config of each run is kept in meta/sources.",
+		Run: func(cmd *cobra.Command, args []string) {
+			input.archives = args
+			if err := mergeResults(input); err != nil {
+				errlog.LogError(errors.Wrap(err, "could not merge archives"))
+				os.Exit(1)
+			}
+		},
+		Args: cobra.MinimumNArgs(2),
+	}
+
+	cmd.Flags().StringVarP(
+		&input.output, "output", "o", "",
+		"The path to write the merged archive to.",
+	)
+	cmd.MarkFlagRequired("output")
+	cmd.Flags().BoolVar(
+		&input.opts.RenamePlugins, "rename-plugins", false,
+		"Keep the results of plugins which ran in more than one archive apart by renaming them after the first (e.g. e2e-2) rather than combining them.",
+	)
+
+	return cmd
+}
+
+// mergeResults writes the merged archive, removing it if anything goes wrong.
+func mergeResults(input resultsMergeInput) (err error) {
+	var sources []results.MergeSource
+	for _, archive := range input.archives {
+		if archive == input.output {
+			return fmt.Errorf("output %v is also one of the archives to merge", archive)
+		}
+		sources = append(sources, results.MergeSource{Name: archive, Open: openArchive(archive)})
+	}
+
+	f, err := os.Create(input.output)
+	if err != nil {
+		return errors.Wrap(err, "could not create merged archive")
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(input.output)
+		}
+	}()
+
+	gzw := gzip.NewWriter(f)
+	if err := results.Merge(gzw, sources, input.opts); err != nil {
+		return err
+	}
+	return errors.Wrap(gzw.Close(), "could not write merged archive")
+}
+
+// gzipFile is an open gzipped file which closes both the gzip reader and the file.
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// openArchive returns a function opening the tar stream of the gzipped archive.
+func openArchive(archive string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		f, err := os.Open(archive)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open sonobuoy archive: %v", archive)
+		}
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "could not make a gzip reader")
+		}
+		return gzipFile{Reader: gzr, f: f}, nil
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+)
+
+// timingSlowestTests is how many of the slowest tests are shown in timing mode.
+const timingSlowestTests = 10
+
+// loadBaselineResults reads the results of the plugin from the baseline archive. It returns nil,
+// rather than an error, if the plugin didn't run in the baseline.
+func loadBaselineResults(archive, plugin string) (*results.Item, error) {
+	r, cleanup, err := getReader(archive)
+	defer cleanup()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read baseline archive")
+	}
+
+	items, err := r.PluginResultsItems()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read plugin results from %v", archive)
+	}
+	return items[plugin], nil
+}
+
+// printTiming writes how long the tests of the plugin took and, if there is a baseline, which
+// tests got slower since.
+func printTiming(w io.Writer, plugin string, item *results.Item, baseline *results.Item, baselineArchive string) error {
+	report := results.Timing(plugin, item, timingSlowestTests)
+	fmt.Fprintf(w, "Plugin: %v\n", plugin)
+	if report.Tests == 0 {
+		fmt.Fprintln(w, "No test durations recorded")
+		return nil
+	}
+	fmt.Fprintf(w, "Tests: %v\n", report.Tests)
+	fmt.Fprintf(w, "Total: %v\n", formatDuration(report.Duration))
+
+	tw := tabwriter.NewWriter(w, 0, 2, 3, ' ', 0)
+	fmt.Fprintf(tw, "\nSlowest tests:\n")
+	fmt.Fprintf(tw, "DURATION\tNODE\tTEST\n")
+	for _, t := range report.Slowest {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", formatDuration(t.Duration), t.Node, t.Name)
+	}
+	printDurationTotals(tw, "Suites", "SUITE", report.Suites)
+	if len(report.Nodes) > 0 {
+		printDurationTotals(tw, "Nodes", "NODE", report.Nodes)
+	}
+
+	if baselineArchive != "" {
+		if baseline == nil {
+			fmt.Fprintf(tw, "\nPlugin did not run in baseline %v\n", baselineArchive)
+		} else {
+			changes := results.DurationRegressions(plugin, baseline, item)
+			fmt.Fprintf(tw, "\nSlower than baseline %v: %v\n", baselineArchive, len(changes))
+			if len(changes) > 0 {
+				fmt.Fprintf(tw, "OLD\tNEW\tINCREASE\tNODE\tTEST\n")
+			}
+			for _, c := range changes {
+				fmt.Fprintf(tw, "%v\t%v\t+%v\t%v\t%v\n", formatDuration(c.Old), formatDuration(c.New), formatDuration(c.Increase()), c.Node, c.Name)
+			}
+		}
+	}
+	return errors.Wrap(tw.Flush(), "couldn't write timing out")
+}
+
+func printDurationTotals(w io.Writer, title, column string, totals []results.DurationTotal) {
+	fmt.Fprintf(w, "\n%v:\n", title)
+	fmt.Fprintf(w, "DURATION\tTESTS\t%v\n", column)
+	for _, t := range totals {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", formatDuration(t.Duration), t.Tests, t.Name)
+	}
+}
+
+// formatDuration rounds durations of more than a millisecond to the millisecond so that they
+// stay readable.
+func formatDuration(d time.Duration) string {
+	if d > time.Millisecond {
+		d = d.Round(time.Millisecond)
+	}
+	return d.String()
+}
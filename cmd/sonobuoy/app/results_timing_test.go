@@ -0,0 +1,120 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+)
+
+func TestPrintTiming(t *testing.T) {
+	newItem := func(durations ...string) *results.Item {
+		item := &results.Item{Name: "e2e", Items: []results.Item{{Name: "suite"}}}
+		for i, d := range durations {
+			item.Items[0].Items = append(item.Items[0].Items, results.Item{
+				Name:     string(rune('a' + i)),
+				Status:   "passed",
+				Metadata: map[string]string{"duration": d},
+			})
+		}
+		return item
+	}
+
+	testCases := []struct {
+		desc     string
+		item     *results.Item
+		baseline *results.Item
+		archive  string
+		expected string
+	}{
+		{
+			desc: "Without baseline",
+			item: newItem("1.23456s", "250µs"),
+			expected: `Plugin: e2e
+Tests: 2
+Total: 1.235s
+
+Slowest tests:
+DURATION   NODE     TEST
+1.235s     global   suite|a
+250µs      global   suite|b
+
+Suites:
+DURATION   TESTS   SUITE
+1.235s     2       suite
+`,
+		}, {
+			desc:     "With baseline",
+			item:     newItem("30s", "1s"),
+			baseline: newItem("10s", "1s"),
+			archive:  "old.tar.gz",
+			expected: `Plugin: e2e
+Tests: 2
+Total: 31s
+
+Slowest tests:
+DURATION   NODE     TEST
+30s        global   suite|a
+1s         global   suite|b
+
+Suites:
+DURATION   TESTS   SUITE
+31s        2       suite
+
+Slower than baseline old.tar.gz: 1
+OLD   NEW   INCREASE   NODE     TEST
+10s   30s   +20s       global   suite|a
+`,
+		}, {
+			desc:    "Plugin missing from baseline",
+			item:    newItem("1s"),
+			archive: "old.tar.gz",
+			expected: `Plugin: e2e
+Tests: 1
+Total: 1s
+
+Slowest tests:
+DURATION   NODE     TEST
+1s         global   suite|a
+
+Suites:
+DURATION   TESTS   SUITE
+1s         1       suite
+
+Plugin did not run in baseline old.tar.gz
+`,
+		}, {
+			desc:     "No durations",
+			item:     &results.Item{Name: "e2e", Items: []results.Item{{Name: "a", Status: "passed"}}},
+			expected: "Plugin: e2e\nNo test durations recorded\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var out strings.Builder
+			if err := printTiming(&out, "e2e", tc.item, tc.baseline, tc.archive); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Expected output:\n%v\nbut got:\n%v", tc.expected, out.String())
+			}
+		})
+	}
+}
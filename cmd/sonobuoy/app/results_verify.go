@@ -0,0 +1,117 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+type resultsVerifyInput struct {
+	archive   string
+	key       string
+	signature string
+}
+
+func NewCmdResultsVerify() *cobra.Command {
+	input := resultsVerifyInput{}
+	cmd := &cobra.Command{
+		Use:   "verify archive.tar.gz",
+		Short: "Verifies the plugin results in an archive and, given a key, its signatures.",
+		Long: "Checks the plugin results in the archive against the digests recorded by the aggregator. " +
+			"If a public key is given, the detached signature of the archive and the signature of the " +
+			"digest manifest inside it are also checked.",
+		Run: func(cmd *cobra.Command, args []string) {
+			input.archive = args[0]
+			if err := verifyResults(input, os.Stdout); err != nil {
+				errlog.LogError(errors.Wrapf(err, "could not verify archive: %v", args[0]))
+				os.Exit(1)
+			}
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.Flags().StringVar(
+		&input.key, "key", "",
+		"Path to the PEM encoded public key (or certificate) to check the signatures with. If not set, only the digests are checked.",
+	)
+	cmd.Flags().StringVar(
+		&input.signature, "signature", "",
+		fmt.Sprintf("Path to the detached signature of the archive. Defaults to the archive path with a %q suffix.", signature.FileSuffix),
+	)
+
+	return cmd
+}
+
+// verifyResults checks the signatures, if a key is given, and the digests of the archive and
+// reports what was verified to w.
+func verifyResults(input resultsVerifyInput, w io.Writer) error {
+	var key crypto.PublicKey
+	if input.key != "" {
+		b, err := ioutil.ReadFile(input.key)
+		if err != nil {
+			return errors.Wrapf(err, "could not read public key %v", input.key)
+		}
+		key, err = signature.ParsePublicKey(b)
+		if err != nil {
+			return err
+		}
+
+		sigPath := input.signature
+		if sigPath == "" {
+			sigPath = input.archive + signature.FileSuffix
+		}
+		if err := verifyArchiveSignature(input.archive, sigPath, key); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "Verified signature of archive")
+	}
+
+	report, err := verifyDigests(input.archive, key)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		fmt.Fprintln(w, "Verified signature of results manifest")
+	}
+	fmt.Fprintf(w, "Verified digests of %v plugin result files\n", report.Verified)
+	return nil
+}
+
+// verifyArchiveSignature checks the detached signature of the archive.
+func verifyArchiveSignature(archive, sigPath string, key crypto.PublicKey) error {
+	sig, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return errors.Wrapf(err, "could not read archive signature %v", sigPath)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return errors.Wrapf(err, "could not open sonobuoy archive: %v", archive)
+	}
+	defer f.Close()
+
+	return errors.Wrap(signature.Verify(key, f, sig), "could not verify archive signature")
+}
@@ -0,0 +1,178 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+// writeSignedArchive writes a results archive whose manifest and detached signature are
+// signed with the given key.
+func writeSignedArchive(t *testing.T, dir string, key *ecdsa.PrivateKey, result string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte("junit"))
+	manifest, err := json.Marshal(aggregation.ResultsManifest{
+		Algorithm: aggregation.DigestAlgorithm,
+		Files:     map[string]string{"plugins/e2e/results/global/junit.xml": hex.EncodeToString(sum[:])},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestSig, err := signature.Sign(key, bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, f := range []struct{ name, data string }{
+		{"meta/manifest.json", string(manifest)},
+		{"meta/manifest.json.sig", string(manifestSig)},
+		{"plugins/e2e/results/global/junit.xml", result},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(dir, "results.tar.gz")
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signature.Sign(key, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(archive+signature.FileSuffix, sig, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func writePublicKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pub")
+	writePublicKey(t, keyPath, key)
+	otherPath := filepath.Join(dir, "other.pub")
+	writePublicKey(t, otherPath, other)
+
+	testCases := []struct {
+		desc      string
+		result    string
+		key       string
+		sigSuffix string
+		expectErr bool
+		expected  string
+	}{
+		{
+			desc:     "Digests only",
+			result:   "junit",
+			expected: "Verified digests of 1 plugin result files\n",
+		}, {
+			desc:     "Signed archive",
+			result:   "junit",
+			key:      keyPath,
+			expected: "Verified signature of archive\nVerified signature of results manifest\nVerified digests of 1 plugin result files\n",
+		}, {
+			desc:      "Wrong key",
+			result:    "junit",
+			key:       otherPath,
+			expectErr: true,
+		}, {
+			desc:      "Modified result",
+			result:    "tampered",
+			expectErr: true,
+		}, {
+			desc:      "Missing signature",
+			result:    "junit",
+			key:       keyPath,
+			sigSuffix: ".missing",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			archive := writeSignedArchive(t, dir, key, tc.result)
+			input := resultsVerifyInput{archive: archive, key: tc.key}
+			if tc.sigSuffix != "" {
+				input.signature = archive + tc.sigSuffix
+			}
+
+			var out strings.Builder
+			err := verifyResults(input, &out)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error %v but got %v", tc.expectErr, err)
+			}
+			if !tc.expectErr && out.String() != tc.expected {
+				t.Errorf("Expected output %q but got %q", tc.expected, out.String())
+			}
+		})
+	}
+}
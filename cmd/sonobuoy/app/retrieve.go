@@ -17,14 +17,18 @@ limitations under the License.
 package app
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/vmware-tanzu/sonobuoy/pkg/client"
 	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/client-go/util/exec"
 )
@@ -35,9 +39,12 @@ const (
 
 type retrieveFlags struct {
 	namespace      string
+	runID          string
 	kubecfg        Kubeconfig
 	extract        bool
 	outputLocation string
+	partial        bool
+	plugin         string
 }
 
 func NewCmdRetrieve() *cobra.Command {
@@ -51,7 +58,16 @@ func NewCmdRetrieve() *cobra.Command {
 
 	AddKubeconfigFlag(&rcvFlags.kubecfg, cmd.Flags())
 	AddNamespaceFlag(&rcvFlags.namespace, cmd.Flags())
+	AddRunFlag(&rcvFlags.runID, cmd.Flags())
 	AddExtractFlag(&rcvFlags.extract, cmd.Flags())
+	cmd.Flags().BoolVar(
+		&rcvFlags.partial, "partial", false,
+		"If true, retrieves the results received so far from a run which is still in progress. Only complete plugins are post-processed.",
+	)
+	cmd.Flags().StringVarP(
+		&rcvFlags.plugin, "plugin", "p", "",
+		"With --partial, only retrieves the results of this plugin.",
+	)
 	return cmd
 }
 
@@ -61,6 +77,10 @@ func retrieveResultsCmd(opts *retrieveFlags) func(cmd *cobra.Command, args []str
 		if len(args) > 0 {
 			opts.outputLocation = args[0]
 		}
+		if opts.plugin != "" && !opts.partial {
+			errlog.LogError(errors.New("--plugin can only be used with --partial"))
+			os.Exit(1)
+		}
 
 		sbc, err := getSonobuoyClientFromKubecfg(opts.kubecfg)
 		if err != nil {
@@ -68,8 +88,44 @@ func retrieveResultsCmd(opts *retrieveFlags) func(cmd *cobra.Command, args []str
 			os.Exit(1)
 		}
 
+		runID, err := resolveRun(sbc, opts.runID, &opts.namespace)
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not find the run"))
+			os.Exit(1)
+		}
+
+		retrieveCfg := &client.RetrieveConfig{Namespace: opts.namespace, RunID: runID}
+
+		if opts.partial {
+			if err := retrievePartialResults(sbc, retrieveCfg, *opts); err != nil {
+				errlog.LogError(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Download the results over HTTPS if the aggregator serves them, falling back to exec'ing
+		// into its pod for runs which don't.
+		filenames, err := sbc.DownloadResults(context.Background(), retrieveCfg, opts.outputLocation)
+		switch {
+		case errors.Cause(err) == client.ErrResultsNotServed:
+			logrus.Debugf("Falling back to retrieving results with exec: %v", err)
+		case errors.Cause(err) == client.ErrResultsNotReady:
+			fmt.Fprintln(os.Stderr, "Results not ready yet. Check `sonobuoy status` for status.")
+			os.Exit(1)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error retrieving results: %v\n", err)
+			os.Exit(2)
+		default:
+			if err := handleRetrievedFiles(*opts, filenames); err != nil {
+				fmt.Fprintf(os.Stderr, "error retrieving results: %v\n", err)
+				os.Exit(2)
+			}
+			return
+		}
+
 		// Get a reader that contains the tar output of the results directory.
-		reader, ec, err := sbc.RetrieveResults(&client.RetrieveConfig{Namespace: opts.namespace})
+		reader, ec, err := sbc.RetrieveResults(retrieveCfg)
 		if err != nil {
 			errlog.LogError(err)
 			os.Exit(1)
@@ -86,6 +142,21 @@ func retrieveResultsCmd(opts *retrieveFlags) func(cmd *cobra.Command, args []str
 	}
 }
 
+// retrievePartialResults downloads the results received so far by a run in progress and handles
+// the archive like any other.
+func retrievePartialResults(sbc client.Interface, cfg *client.RetrieveConfig, opts retrieveFlags) error {
+	filename, err := sbc.DownloadPartialResults(context.Background(), cfg, opts.outputLocation, opts.plugin)
+	switch {
+	case errors.Cause(err) == client.ErrResultsNotServed:
+		return errors.Errorf("%v; partial results can only be retrieved while the aggregator serves the results on resultsport", err)
+	case errors.Cause(err) == client.ErrPartialResultsUnavailable:
+		return errors.Errorf("%v; retrieve the full results once the run is complete", err)
+	case err != nil:
+		return errors.Wrap(err, "error retrieving partial results")
+	}
+	return handleRetrievedFiles(opts, []string{filename})
+}
+
 func retrieveResults(opts retrieveFlags, r io.Reader, ec <-chan error) error {
 	eg := &errgroup.Group{}
 	eg.Go(func() error { return <-ec })
@@ -95,29 +166,42 @@ func retrieveResults(opts retrieveFlags, r io.Reader, ec <-chan error) error {
 		if err != nil {
 			return err
 		}
-		if !opts.extract {
-			// Only print the filename if not extracting. Allows capturing the filename for scripting.
-			for _, name := range filesCreated {
-				fmt.Println(name)
-			}
-			return nil
-		} else {
-			for _, filename := range filesCreated {
-				err := client.UntarFile(filename, opts.outputLocation, true)
-				if err != nil {
-					// Just log errors if it is just not cleaning up the file.
-					re, ok := err.(*client.DeletionError)
-					if ok {
-						errlog.LogError(re)
-					} else {
-						return err
-					}
+		return handleRetrievedFiles(opts, filesCreated)
+	})
+
+	return eg.Wait()
+}
+
+// handleRetrievedFiles prints the names of the retrieved tarballs or, if extracting, extracts them.
+func handleRetrievedFiles(opts retrieveFlags, filesCreated []string) error {
+	// Detached signatures are saved next to the tarball they sign but are otherwise left alone.
+	tarballs := []string{}
+	for _, name := range filesCreated {
+		if !strings.HasSuffix(name, signature.FileSuffix) {
+			tarballs = append(tarballs, name)
+		}
+	}
+
+	if !opts.extract {
+		// Only print the filename if not extracting. Allows capturing the filename for scripting.
+		for _, name := range tarballs {
+			fmt.Println(name)
+		}
+		return nil
+	} else {
+		for _, filename := range tarballs {
+			err := client.UntarFile(filename, opts.outputLocation, true)
+			if err != nil {
+				// Just log errors if it is just not cleaning up the file.
+				re, ok := err.(*client.DeletionError)
+				if ok {
+					errlog.LogError(re)
+				} else {
+					return err
 				}
 			}
-
-			return err
 		}
-	})
 
-	return eg.Wait()
+		return nil
+	}
 }
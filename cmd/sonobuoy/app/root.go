@@ -39,6 +39,7 @@ func NewSonobuoyCommand() *cobra.Command {
 	cmds.ResetFlags()
 
 	cmds.AddCommand(NewCmdAggregator())
+	cmds.AddCommand(NewCmdController())
 	cmds.AddCommand(NewCmdDelete())
 	cmds.AddCommand(NewCmdE2E())
 
@@ -49,9 +50,11 @@ func NewSonobuoyCommand() *cobra.Command {
 	gen.AddCommand(genPlugin)
 	gen.AddCommand(NewCmdGenConfig())
 	gen.AddCommand(NewCmdGenImageRepoConfig())
+	gen.AddCommand(NewCmdGenCRD())
 
 	cmds.AddCommand(gen)
 
+	cmds.AddCommand(NewCmdList())
 	cmds.AddCommand(NewCmdLogs())
 	cmds.AddCommand(NewCmdVersion())
 	cmds.AddCommand(NewCmdStatus())
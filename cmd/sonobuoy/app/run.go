@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
@@ -144,6 +145,9 @@ func submitSonobuoyRun(f *runFlags) func(cmd *cobra.Command, args []string) {
 			errlog.LogError(errors.Wrap(err, "error attempting to run sonobuoy"))
 			os.Exit(1)
 		}
+		if runCfg.Config != nil && runCfg.Config.UUID != "" {
+			logrus.Infof("Started run %v in namespace %v", runCfg.Config.UUID, runCfg.Config.Namespace)
+		}
 	}
 }
 
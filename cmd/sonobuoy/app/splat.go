@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
 
 	"github.com/spf13/cobra"
 )
@@ -50,6 +51,13 @@ func runSplat(dirPath string) error {
 		return err
 	}
 
+	// Include the detached signatures of signed results.
+	signatures, err := filepath.Glob(filepath.Join(dirPath, "*.tar.gz"+signature.FileSuffix))
+	if err != nil {
+		return err
+	}
+	sonobuoyResults = append(sonobuoyResults, signatures...)
+
 	if err = loadResults(os.Stdout, sonobuoyResults); err != nil {
 		return err
 	}
@@ -17,6 +17,7 @@ limitations under the License.
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -36,9 +37,11 @@ import (
 
 type statusFlags struct {
 	namespace string
+	runID     string
 	kubecfg   Kubeconfig
 	showAll   bool
 	json      bool
+	watch     bool
 }
 
 type pluginSummaries []pluginSummary
@@ -74,6 +77,7 @@ func NewCmdStatus() *cobra.Command {
 	flags := cmd.Flags()
 
 	AddNamespaceFlag(&f.namespace, flags)
+	AddRunFlag(&f.runID, flags)
 	AddKubeconfigFlag(&f.kubecfg, flags)
 	flags.BoolVar(
 		&f.showAll, "show-all", false,
@@ -83,6 +87,10 @@ func NewCmdStatus() *cobra.Command {
 		&f.json, "json", false,
 		"Print the status object as json",
 	)
+	flags.BoolVar(
+		&f.watch, "watch", false,
+		"Stream the events of the run until its results are ready. Requires the run to have been started with Aggregation.ResultsPort set in the config, which is the default, or with --metrics-port. With --json, prints each event as json",
+	)
 
 	return cmd
 }
@@ -97,8 +105,29 @@ func getStatus(f *statusFlags) func(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 
+		runID, err := resolveRun(sbc, f.runID, &f.namespace)
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not find the run"))
+			os.Exit(1)
+		}
+
+		if f.watch {
+			events, err := sbc.WatchStatus(context.Background(), &client.StatusConfig{
+				Namespace: f.namespace,
+				RunID:     runID,
+			})
+			if err == nil {
+				err = printEvents(os.Stdout, events, f.json)
+			}
+			if err != nil {
+				errlog.LogError(errors.Wrap(err, "error watching sonobuoy status"))
+				os.Exit(1)
+			}
+		}
+
 		status, pod, err := sbc.GetStatusPod(&client.StatusConfig{
 			Namespace: f.namespace,
+			RunID:     runID,
 		})
 		switch {
 		case err != nil && pod == nil:
@@ -107,6 +136,8 @@ func getStatus(f *statusFlags) func(cmd *cobra.Command, args []string) {
 			err = printPodInfo(os.Stdout, pod)
 		case f.showAll:
 			err = printAll(os.Stdout, status)
+		case f.watch && f.json:
+			// The events were already printed as json; only the exit code is left.
 		case f.json:
 			err = printJSON(os.Stdout, status)
 		default:
@@ -179,6 +210,68 @@ func printPodInfo(w io.Writer, pod *corev1.Pod) error {
 	return nil
 }
 
+// printEvents prints each event as it is received, returning once the results are ready.
+func printEvents(w io.Writer, events <-chan aggregation.Event, asJSON bool) error {
+	enc := json.NewEncoder(w)
+	for ev := range events {
+		if asJSON {
+			if err := enc.Encode(ev); err != nil {
+				return errors.Wrap(err, "couldn't write event out")
+			}
+		} else {
+			fmt.Fprintln(w, formatEvent(ev))
+		}
+		if ev.Type == aggregation.EventTarballReady {
+			return nil
+		}
+	}
+	return errors.New("the stream of events ended before the results were ready")
+}
+
+// formatEvent returns a single line describing the event.
+func formatEvent(ev aggregation.Event) string {
+	source := ev.Plugin
+	if ev.Node != "" {
+		source = fmt.Sprintf("%v on %v", ev.Plugin, ev.Node)
+	}
+
+	var msg string
+	switch ev.Type {
+	case aggregation.EventPluginStarted:
+		msg = fmt.Sprintf("%v started", source)
+	case aggregation.EventProgress:
+		msg = fmt.Sprintf("%v progress", source)
+		if p := ev.Progress; p != nil {
+			if p.Total > 0 {
+				msg += fmt.Sprintf(": %v/%v completed", p.Completed, p.Total)
+			}
+			if len(p.Failures) > 0 {
+				msg += fmt.Sprintf(", %v failed", len(p.Failures))
+			}
+			if len(p.Errors) > 0 {
+				msg += fmt.Sprintf(", %v errors", len(p.Errors))
+			}
+			if p.Message != "" {
+				msg += fmt.Sprintf(" (%v)", p.Message)
+			}
+		}
+	case aggregation.EventResultReceived:
+		msg = fmt.Sprintf("%v sent results", source)
+	case aggregation.EventResultFailed:
+		msg = fmt.Sprintf("%v failed: %v", source, ev.Error)
+	case aggregation.EventPostProcessing:
+		msg = "Plugins have completed. Preparing results for download."
+	case aggregation.EventTarballReady:
+		msg = "Results are ready. Use `sonobuoy retrieve` to get them."
+		if ev.Tarball != nil {
+			msg = fmt.Sprintf("Results %v are ready. Use `sonobuoy retrieve` to get them.", ev.Tarball.Name)
+		}
+	default:
+		msg = fmt.Sprintf("%v %v", source, ev.Type)
+	}
+	return fmt.Sprintf("%v  %v", ev.Time.Format("15:04:05"), msg)
+}
+
 func printJSON(w io.Writer, status *aggregation.Status) error {
 	enc := json.NewEncoder(w)
 	return enc.Encode(status)
@@ -20,7 +20,9 @@ import (
 	"bytes"
 	"io"
 	"testing"
+	"time"
 
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
 )
 
@@ -100,3 +102,45 @@ func TestPrintStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestPrintEvents(t *testing.T) {
+	at := time.Date(2021, 3, 4, 15, 4, 5, 0, time.UTC)
+	events := make(chan aggregation.Event, 10)
+	for _, ev := range []aggregation.Event{
+		{Type: aggregation.EventPluginStarted, Plugin: "e2e"},
+		{Type: aggregation.EventProgress, Plugin: "e2e", Node: "global", Progress: &plugin.ProgressUpdate{
+			Message: "running tests", Total: 10, Completed: 4, Failures: []string{"a"},
+		}},
+		{Type: aggregation.EventResultFailed, Plugin: "systemd-logs", Node: "node1", Error: "timeout"},
+		{Type: aggregation.EventResultReceived, Plugin: "e2e", Node: "global"},
+		{Type: aggregation.EventPostProcessing},
+		{Type: aggregation.EventTarballReady, Tarball: &aggregation.TarInfo{Name: "results.tar.gz"}},
+		{Type: aggregation.EventProgress, Plugin: "ignored"},
+	} {
+		ev.Time = at
+		events <- ev
+	}
+	close(events)
+
+	expected := `15:04:05  e2e started
+15:04:05  e2e on global progress: 4/10 completed, 1 failed (running tests)
+15:04:05  systemd-logs on node1 failed: timeout
+15:04:05  e2e on global sent results
+15:04:05  Plugins have completed. Preparing results for download.
+15:04:05  Results results.tar.gz are ready. Use ` + "`sonobuoy retrieve`" + ` to get them.
+`
+	var b bytes.Buffer
+	if err := printEvents(&b, events, false); err != nil {
+		t.Fatalf("expected err to be nil, got %v", err)
+	}
+	if b.String() != expected {
+		t.Errorf("expected output to be \n%v, got \n%v", expected, b.String())
+	}
+
+	// A stream which ends before the results are ready is an error.
+	events = make(chan aggregation.Event)
+	close(events)
+	if err := printEvents(&b, events, true); err == nil {
+		t.Error("expected an error for a stream ending early")
+	}
+}
@@ -172,21 +172,27 @@ func runGather(global bool) error {
 	if err != nil {
 		return errors.Wrap(err, "parsing AggregatorURL")
 	}
+	uploadURL, err := url.Parse(cfg.AggregatorURL)
+	if err != nil {
+		return errors.Wrap(err, "parsing AggregatorURL")
+	}
 
 	if global {
 		// A global results URL looks like:
 		// http://sonobuoy-aggregator:8080/api/v1/results/global/systemd_logs
 		resultURL.Path = path.Join(aggregation.PathResultsGlobal, cfg.ResultType)
 		progressURL.Path = path.Join(aggregation.PathProgressGlobal, cfg.ResultType)
+		uploadURL.Path = path.Join(aggregation.PathUploadsGlobal, cfg.ResultType)
 	} else {
 		// A single-node results URL looks like:
 		// http://sonobuoy-aggregator:8080/api/v1/results/by-node/node1/systemd_logs
 		resultURL.Path = path.Join(aggregation.PathResultsByNode, cfg.NodeName, cfg.ResultType)
 		progressURL.Path = path.Join(aggregation.PathProgressByNode, cfg.NodeName, cfg.ResultType)
+		uploadURL.Path = path.Join(aggregation.PathUploadsByNode, cfg.NodeName, cfg.ResultType)
 	}
 
 	go worker.RelayProgressUpdates(cfg.ProgressUpdatesPort, progressURL.String(), client)
-	err = worker.GatherResults(filepath.Join(cfg.ResultsDir, "done"), resultURL.String(), client, sigHandler(plugin.GracefulShutdownPeriod*time.Second))
+	err = worker.GatherResults(filepath.Join(cfg.ResultsDir, "done"), resultURL.String(), uploadURL.String(), client, sigHandler(plugin.GracefulShutdownPeriod*time.Second))
 
 	return errors.Wrap(err, "gathering results")
 }
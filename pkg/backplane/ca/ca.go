@@ -24,6 +24,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"math/big"
 	"net"
 	"sync"
@@ -80,6 +81,49 @@ func NewAuthority() (*Authority, error) {
 	return auth, nil
 }
 
+// LoadAuthority recreates a certificate authority from the PEM encoded certificate and private
+// key previously returned by MarshalPEM. Certificates issued by the loaded authority are trusted
+// by anything which trusted the original one.
+func LoadAuthority(certPEM, keyPEM []byte) (*Authority, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, errors.New("couldn't decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse certificate")
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("couldn't decode private key PEM")
+	}
+	privKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse private key")
+	}
+
+	return &Authority{
+		privKey: privKey,
+		cert:    cert,
+		// Avoid reissuing serial numbers the original authority may have already used.
+		lastSerial: big.NewInt(time.Now().UnixNano()),
+	}, nil
+}
+
+// MarshalPEM returns the PEM encoded root certificate and private key of the authority so
+// that it can be restored with LoadAuthority.
+func (a *Authority) MarshalPEM() (certPEM, keyPEM []byte, err error) {
+	keyDER, err := x509.MarshalECPrivateKey(a.privKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "couldn't marshal private key")
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.cert.Raw})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
 // makeCert takes a public key and a function to mutate the certificate template with updated parameters
 func (a *Authority) makeCert(pub crypto.PublicKey, mut func(*x509.Certificate)) (*x509.Certificate, error) {
 	serialNumber := a.nextSerial()
@@ -140,3 +140,42 @@ func TestServer(t *testing.T) {
 		t.Errorf("expected %s, got %s", testString, respBody)
 	}
 }
+
+func TestLoadAuthority(t *testing.T) {
+	auth, err := NewAuthority()
+	if err != nil {
+		t.Fatalf("Couldn't create certificate authority")
+	}
+
+	certPEM, keyPEM, err := auth.MarshalPEM()
+	if err != nil {
+		t.Fatalf("couldn't marshal authority: %v", err)
+	}
+
+	loaded, err := LoadAuthority(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("couldn't load authority: %v", err)
+	}
+
+	// Certificates issued by the restored authority should be trusted by the original one.
+	clientName := "worker1.sonobuoy.local"
+	clientCert, err := loaded.ClientKeyPair(clientName)
+	if err != nil {
+		t.Fatalf("couldn't get client cert: %v", err)
+	}
+	_, err = clientCert.Leaf.Verify(x509.VerifyOptions{
+		Roots:     auth.CACertPool(),
+		DNSName:   clientName,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Errorf("Expected client key from loaded authority to verify, got error %v", err)
+	}
+
+	if _, err := LoadAuthority([]byte("not a cert"), keyPEM); err == nil {
+		t.Errorf("Expected error loading invalid certificate")
+	}
+	if _, err := LoadAuthority(certPEM, []byte("not a key")); err == nil {
+		t.Errorf("Expected error loading invalid key")
+	}
+}
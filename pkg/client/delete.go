@@ -30,6 +30,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 )
 
 const (
@@ -58,11 +60,19 @@ func (c *SonobuoyClient) Delete(cfg *DeleteConfig) error {
 	}
 
 	conditions := []wait.ConditionFunc{}
-	nsCondition, err := cleanupNamespace(cfg.Namespace, client)
-	if err != nil {
-		return err
+	if cfg.RunID != "" {
+		runCondition, err := cleanupRun(client, cfg.Namespace, cfg.RunID)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, runCondition)
+	} else {
+		nsCondition, err := cleanupNamespace(cfg.Namespace, client)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, nsCondition)
 	}
-	conditions = append(conditions, nsCondition)
 
 	if cfg.EnableRBAC {
 		rbacCondition, err := deleteRBAC(client, cfg)
@@ -128,6 +138,60 @@ func cleanupNamespace(namespace string, client kubernetes.Interface) (wait.Condi
 	return nsDeletedCondition, nil
 }
 
+// cleanupRun deletes the resources of a single run, which are labelled with its ID. The namespace,
+// and the objects shared with the other runs in it, are left in place.
+func cleanupRun(client kubernetes.Interface, namespace, runID string) (wait.ConditionFunc, error) {
+	log := logrus.WithFields(logrus.Fields{
+		"namespace": namespace,
+		"run":       runID,
+	})
+	listOpts := metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(metav1.AddLabelToSelector(&metav1.LabelSelector{}, plugin.RunIDLabel, runID)),
+	}
+	background := metav1.DeletePropagationBackground
+	deleteOpts := metav1.DeleteOptions{PropagationPolicy: &background}
+
+	runDeletedCondition := func() (bool, error) {
+		pods, err := client.CoreV1().Pods(namespace).List(context.TODO(), listOpts)
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	}
+
+	collections := []struct {
+		kind   string
+		delete func(context.Context, metav1.DeleteOptions, metav1.ListOptions) error
+	}{
+		{"statefulsets", client.AppsV1().StatefulSets(namespace).DeleteCollection},
+		{"daemonsets", client.AppsV1().DaemonSets(namespace).DeleteCollection},
+		{"pods", client.CoreV1().Pods(namespace).DeleteCollection},
+		{"configmaps", client.CoreV1().ConfigMaps(namespace).DeleteCollection},
+		{"secrets", client.CoreV1().Secrets(namespace).DeleteCollection},
+		{"persistentvolumeclaims", client.CoreV1().PersistentVolumeClaims(namespace).DeleteCollection},
+	}
+	for _, c := range collections {
+		err := c.delete(context.TODO(), deleteOpts, listOpts)
+		if err := logDelete(log.WithField("kind", c.kind), err); err != nil {
+			return runDeletedCondition, errors.Wrapf(err, "failed to delete %v", c.kind)
+		}
+	}
+
+	// Services can't be deleted as a collection.
+	services, err := client.CoreV1().Services(namespace).List(context.TODO(), listOpts)
+	if err != nil {
+		return runDeletedCondition, errors.Wrap(err, "failed to list services")
+	}
+	for _, svc := range services.Items {
+		err := client.CoreV1().Services(namespace).Delete(context.TODO(), svc.Name, deleteOpts)
+		if err := logDelete(log.WithField("kind", "services"), err); err != nil {
+			return runDeletedCondition, errors.Wrap(err, "failed to delete service")
+		}
+	}
+
+	return runDeletedCondition, nil
+}
+
 func deleteRBAC(client kubernetes.Interface, cfg *DeleteConfig) (wait.ConditionFunc, error) {
 	// ClusterRole and ClusterRoleBindings aren't namespaced, so delete them seperately
 	selector := metav1.AddLabelToSelector(
@@ -141,6 +205,9 @@ func deleteRBAC(client kubernetes.Interface, cfg *DeleteConfig) (wait.ConditionF
 			clusterRoleFieldNamespace,
 			cfg.Namespace,
 		)
+		if cfg.RunID != "" {
+			selector = metav1.AddLabelToSelector(selector, plugin.RunIDLabel, cfg.RunID)
+		}
 	}
 
 	deleteOpts := metav1.DeleteOptions{}
@@ -17,10 +17,15 @@ limitations under the License.
 package client
 
 import (
-	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"context"
+	"reflect"
 	"strings"
 	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestDeleteInvalidConfig(t *testing.T) {
@@ -135,3 +140,41 @@ func TestIsE2ENamespace(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupRun(t *testing.T) {
+	service := func(name string, labels map[string]string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "sonobuoy", Labels: labels}}
+	}
+	client := fake.NewSimpleClientset(
+		service("sonobuoy-aggregator-a", map[string]string{"sonobuoy-run-id": "a"}),
+		service("sonobuoy-aggregator-b", map[string]string{"sonobuoy-run-id": "b"}),
+	)
+
+	if _, err := cleanupRun(client, "sonobuoy", "a"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deleted := []string{}
+	for _, action := range client.Actions() {
+		switch action.GetVerb() {
+		case "delete-collection":
+			a := action.(k8stesting.DeleteCollectionAction)
+			if selector := a.GetListRestrictions().Labels.String(); selector != "sonobuoy-run-id=a" {
+				t.Errorf("Expected only the resources of the run to be deleted but %v were deleted with selector %q", a.GetResource().Resource, selector)
+			}
+			deleted = append(deleted, a.GetResource().Resource)
+		case "delete":
+			a := action.(k8stesting.DeleteAction)
+			deleted = append(deleted, a.GetResource().Resource+"/"+a.GetName())
+		}
+	}
+	expected := []string{"statefulsets", "daemonsets", "pods", "configmaps", "secrets", "persistentvolumeclaims", "services/sonobuoy-aggregator-a"}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("Expected %v to be deleted but got %v", expected, deleted)
+	}
+
+	// The namespace, and the other runs in it, are left in place.
+	if _, err := client.CoreV1().Services("sonobuoy").Get(context.TODO(), "sonobuoy-aggregator-b", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the service of the other run to be kept: %v", err)
+	}
+}
@@ -0,0 +1,440 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+const (
+	// defaultPartialResultsName is the name partial results are saved as if the aggregator doesn't
+	// name them.
+	defaultPartialResultsName = "sonobuoy_partial.tar.gz"
+
+	// PartialDownloadSuffix is appended to the name of a file while it is downloaded. An
+	// interrupted download is resumed from it by the next call to DownloadResults.
+	PartialDownloadSuffix = ".part"
+
+	// downloadAttempts is how many times the download of a file is tried, resuming from where
+	// the previous attempt stopped, before giving up.
+	downloadAttempts = 3
+)
+
+var (
+	// ErrResultsNotServed is returned by DownloadResults if the aggregator doesn't serve the
+	// results, e.g. because it was started by an older version of Sonobuoy. RetrieveResults can
+	// be used instead.
+	ErrResultsNotServed = errors.New("the aggregator doesn't serve the results")
+
+	// ErrResultsNotReady is returned by DownloadResults if the run isn't complete yet.
+	ErrResultsNotReady = errors.New("the results aren't ready yet")
+
+	// ErrPartialResultsUnavailable is returned by DownloadPartialResults once the plugins are done,
+	// at which point the full results should be retrieved instead.
+	ErrPartialResultsUnavailable = errors.New("partial results are only available while plugins are running")
+)
+
+// resultsGetter gets the path from the results server of the aggregator with the given headers.
+type resultsGetter func(ctx context.Context, path string, header http.Header) (*http.Response, error)
+
+// DownloadResults downloads the results of a complete run into dir over HTTPS, through the API
+// server's pod proxy, and returns the paths of the files it wrote. Unlike RetrieveResults, it
+// doesn't need to exec into the aggregator pod but does need to read the secret holding the
+// results token. Each file is written to a file with PartialDownloadSuffix until it is complete
+// so that interrupted downloads are resumed rather than started over, and the tarball is checked
+// against the SHA256 in the status of the run.
+func (c *SonobuoyClient) DownloadResults(ctx context.Context, cfg *RetrieveConfig, dir string) ([]string, error) {
+	if cfg == nil {
+		return nil, errors.New("nil RetrieveConfig provided")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "config validation failed")
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	status, pod, err := aggregation.GetStatus(client, cfg.Namespace, cfg.RunID)
+	switch {
+	case err != nil:
+		return nil, errors.Wrap(err, "couldn't get the status of the run")
+	case status.Status != aggregation.CompleteStatus:
+		return nil, ErrResultsNotReady
+	}
+
+	get, err := aggregatorResultsGetter(ctx, client, pod)
+	if err != nil {
+		return nil, err
+	}
+	return downloadResults(ctx, get, dir, status.Tarball)
+}
+
+// aggregatorResultsGetter returns a resultsGetter for the results server of the aggregator pod,
+// reached through the API server's pod proxy with the results token of the run. The path given
+// to it may have a query.
+func aggregatorResultsGetter(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) (resultsGetter, error) {
+	sonobuoyCfg, err := aggregatorConfig(ctx, client, pod)
+	if err != nil {
+		return nil, err
+	}
+	if sonobuoyCfg.Aggregation.ResultsPort == 0 {
+		return nil, ErrResultsNotServed
+	}
+
+	token, err := resultsToken(client, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, ok := client.CoreV1().RESTClient().(*rest.RESTClient)
+	if !ok || restClient == nil || restClient.Client == nil {
+		return nil, errors.New("couldn't get a client for the API server's pod proxy")
+	}
+
+	return func(ctx context.Context, p string, header http.Header) (*http.Response, error) {
+		ref, err := url.Parse(p)
+		if err != nil {
+			return nil, err
+		}
+		r := restClient.Get().
+			Namespace(pod.Namespace).
+			Resource("pods").
+			SubResource("proxy").
+			Name(net.JoinSchemeNamePort("https", pod.Name, strconv.Itoa(sonobuoyCfg.Aggregation.ResultsPort))).
+			Suffix(ref.Path)
+		for k, values := range ref.Query() {
+			for _, v := range values {
+				r = r.Param(k, v)
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL().String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set(aggregation.ResultsTokenHeader, token)
+		return restClient.Client.Do(req)
+	}, nil
+}
+
+// resultsToken returns the results token of the run of the aggregator pod. A token which is missing
+// or can't be read is reported as ErrResultsNotServed so that the results can still be retrieved
+// some other way, e.g. by those who may exec into the pod but not read secrets.
+func resultsToken(client kubernetes.Interface, pod *corev1.Pod) (string, error) {
+	token, err := aggregation.GetResultsToken(client, pod)
+	switch {
+	case errors.Cause(err) == aggregation.ErrNoResultsToken, kubeerror.IsNotFound(err):
+		return "", ErrResultsNotServed
+	case kubeerror.IsForbidden(err), kubeerror.IsUnauthorized(err):
+		return "", errors.Wrap(ErrResultsNotServed, err.Error())
+	case err != nil:
+		return "", errors.Wrap(err, "couldn't get the results token")
+	}
+	return token, nil
+}
+
+// downloadResults downloads each of the listed results files into dir, checking the tarball
+// against its info.
+func downloadResults(ctx context.Context, get resultsGetter, dir string, tarInfo aggregation.TarInfo) ([]string, error) {
+	files, err := listResults(ctx, get)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "couldn't create directory %v", dir)
+	}
+
+	filenames := []string{}
+	for _, f := range files {
+		checksum := ""
+		if f.Name == filepath.Base(tarInfo.Name) {
+			checksum = tarInfo.SHA256
+		}
+		filename, err := downloadFile(ctx, get, dir, f, checksum)
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+// listResults returns the files served by the aggregator. Errors other than a rejected token are
+// reported as ErrResultsNotServed since they mean the server isn't running.
+func listResults(ctx context.Context, get resultsGetter) ([]aggregation.ResultsFile, error) {
+	resp, err := get(ctx, aggregation.ResultsPath, nil)
+	if err != nil {
+		return nil, errors.Wrap(ErrResultsNotServed, err.Error())
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, errors.New("the aggregator rejected the results token")
+	default:
+		return nil, errors.Wrapf(ErrResultsNotServed, "listing results returned %v", resp.Status)
+	}
+
+	files := []aggregation.ResultsFile{}
+	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return nil, errors.Wrap(err, "couldn't decode the list of results")
+	}
+
+	// The names are used as paths on the client so anything but a plain file name is rejected
+	// before any file is downloaded.
+	for _, f := range files {
+		if f.Name != filepath.Base(f.Name) || f.Name == "." || f.Name == ".." || f.Name == "" {
+			return nil, fmt.Errorf("the aggregator listed a result with an invalid name %q", f.Name)
+		}
+	}
+	return files, nil
+}
+
+// downloadFile downloads the file into dir, resuming from what is already in its partial file,
+// and returns its path. If checksum is set, the SHA256 of the file has to match it.
+func downloadFile(ctx context.Context, get resultsGetter, dir string, f aggregation.ResultsFile, checksum string) (string, error) {
+	filename := filepath.Join(dir, f.Name)
+	partial := filename + PartialDownloadSuffix
+
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if err = fetchFile(ctx, get, partial, f); err == nil || ctx.Err() != nil {
+			break
+		}
+		logrus.Warningf("Download of %v interrupted (attempt %v of %v): %v", f.Name, attempt, downloadAttempts, err)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't download %v", f.Name)
+	}
+
+	if checksum != "" {
+		sum, err := fileSHA256(partial)
+		if err != nil {
+			return "", err
+		}
+		if sum != checksum {
+			// Start over next time rather than resuming a corrupt file.
+			os.Remove(partial)
+			return "", errors.Errorf("checksum of %v is %v but the run reported %v", f.Name, sum, checksum)
+		}
+	}
+
+	if err := os.Rename(partial, filename); err != nil {
+		return "", errors.Wrapf(err, "couldn't move %v into place", f.Name)
+	}
+	return filename, nil
+}
+
+// fetchFile appends the rest of the file to the partial file, requesting only the bytes it
+// doesn't have yet. The whole file is fetched again if it changed since it was listed.
+func fetchFile(ctx context.Context, get resultsGetter, partial string, f aggregation.ResultsFile) error {
+	out, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't open %v", partial)
+	}
+	defer out.Close()
+
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't read %v", partial)
+	}
+	if offset > f.Size {
+		offset = 0
+	}
+	if offset == f.Size && offset > 0 {
+		return nil
+	}
+
+	header := http.Header{}
+	if offset > 0 {
+		logrus.Infof("Resuming download of %v from byte %v of %v", f.Name, offset, f.Size)
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		header.Set("If-Range", f.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := get(ctx, path.Join(aggregation.ResultsPath, f.Name), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		offset = 0
+	default:
+		return errors.Errorf("downloading %v returned %v", f.Name, resp.Status)
+	}
+
+	if err := out.Truncate(offset); err != nil {
+		return errors.Wrapf(err, "couldn't truncate %v", partial)
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "couldn't seek in %v", partial)
+	}
+	n, err := io.Copy(out, resp.Body)
+	if err != nil {
+		return err
+	}
+	if offset+n != f.Size {
+		return errors.Errorf("got %v of %v bytes", offset+n, f.Size)
+	}
+	return out.Close()
+}
+
+// DownloadPartialResults has the aggregator of a run in progress archive the results received so
+// far, limited to the named plugin unless it is empty, and downloads the archive into dir. It
+// returns the path of the archive, which is marked as partial in its meta directory. Plugins which
+// are complete are post-processed as usual while only the raw results of the others are included.
+func (c *SonobuoyClient) DownloadPartialResults(ctx context.Context, cfg *RetrieveConfig, dir, pluginName string) (string, error) {
+	if cfg == nil {
+		return "", errors.New("nil RetrieveConfig provided")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return "", errors.Wrap(err, "config validation failed")
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return "", err
+	}
+
+	status, pod, err := aggregation.GetStatus(client, cfg.Namespace, cfg.RunID)
+	switch {
+	case err != nil:
+		return "", errors.Wrap(err, "couldn't get the status of the run")
+	case status.Status == aggregation.CompleteStatus:
+		return "", ErrPartialResultsUnavailable
+	}
+
+	get, err := aggregatorResultsGetter(ctx, client, pod)
+	if err != nil {
+		return "", err
+	}
+	return downloadPartialResults(ctx, get, dir, pluginName)
+}
+
+// downloadPartialResults downloads partial results into dir under the name the aggregator gives
+// them. They are built on request so, unlike the full results, they can't be resumed.
+func downloadPartialResults(ctx context.Context, get resultsGetter, dir, pluginName string) (string, error) {
+	p := aggregation.PartialResultsPath
+	if pluginName != "" {
+		p += "?" + url.Values{aggregation.PartialResultsPluginParam: []string{pluginName}}.Encode()
+	}
+
+	resp, err := get(ctx, p, nil)
+	if err != nil {
+		return "", errors.Wrap(ErrResultsNotServed, err.Error())
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", errors.New("the aggregator rejected the results token")
+	case http.StatusConflict:
+		return "", ErrPartialResultsUnavailable
+	case http.StatusNotFound:
+		if pluginName != "" {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+			return "", errors.New(strings.TrimSpace(string(msg)))
+		}
+		return "", errors.Wrap(ErrResultsNotServed, "the aggregator doesn't serve partial results")
+	default:
+		return "", errors.Wrapf(ErrResultsNotServed, "getting partial results returned %v", resp.Status)
+	}
+
+	name := defaultPartialResultsName
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = filepath.Base(params["filename"])
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "couldn't create directory %v", dir)
+	}
+	filename := filepath.Join(dir, name)
+	partial := filename + PartialDownloadSuffix
+	out, err := os.Create(partial)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't create %v", partial)
+	}
+	defer os.Remove(partial)
+
+	n, err := io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't download %v", name)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return "", errors.Errorf("couldn't download %v: got %v of %v bytes", name, n, resp.ContentLength)
+	}
+
+	if err := os.Rename(partial, filename); err != nil {
+		return "", errors.Wrapf(err, "couldn't move %v into place", name)
+	}
+	return filename, nil
+}
+
+// fileSHA256 returns the hex encoded SHA256 of the file.
+func fileSHA256(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't open %v", filename)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "couldn't read %v", filename)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,516 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+const testTarball = "202101010000_sonobuoy_abc.tar.gz"
+
+// testResultsServer serves the files from a results directory, recording the Range header of each
+// request for a file.
+type testResultsServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func newTestResultsServer(t *testing.T, files map[string]string, partial aggregation.PartialResultsFunc) *testResultsServer {
+	dir, err := ioutil.TempDir("", "sonobuoy_download_server")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %v: %v", name, err)
+		}
+	}
+
+	s := &testResultsServer{}
+	h := aggregation.NewResultsHandler(dir, "", "token", partial, nil)
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, aggregation.ResultsPath+"/") {
+			s.mu.Lock()
+			s.ranges = append(s.ranges, r.Header.Get("Range"))
+			s.mu.Unlock()
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// getter returns a resultsGetter for the server which gives the token.
+func (s *testResultsServer) getter(token string) resultsGetter {
+	return func(ctx context.Context, path string, header http.Header) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set(aggregation.ResultsTokenHeader, token)
+		return s.Client().Do(req)
+	}
+}
+
+// truncatingGetter cuts the body of the first n responses for files short after limit bytes, as
+// if the connection had been dropped.
+func truncatingGetter(get resultsGetter, n int, limit int64) resultsGetter {
+	return func(ctx context.Context, path string, header http.Header) (*http.Response, error) {
+		resp, err := get(ctx, path, header)
+		if err != nil || path == aggregation.ResultsPath || n == 0 {
+			return resp, err
+		}
+		n--
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, limit), resp.Body}
+		return resp, nil
+	}
+}
+
+func sha256Hex(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func TestDownloadResults(t *testing.T) {
+	tarball := strings.Repeat("0123456789", 100)
+	files := map[string]string{
+		testTarball:                         tarball,
+		testTarball + ".sig":                "signature",
+		"abc.checkpoint.json":               "not served",
+		"202101010000_sonobuoy_abc.tar.gz~": "not served",
+	}
+
+	testCases := []struct {
+		desc          string
+		partial       string
+		checksum      string
+		truncations   int
+		expectErr     string
+		expectRanges  []string
+		expectPartial bool
+	}{
+		{
+			desc:         "Fresh download",
+			checksum:     sha256Hex(tarball),
+			expectRanges: []string{"", ""},
+		}, {
+			desc:         "Resumes from a partial file",
+			partial:      tarball[:400],
+			checksum:     sha256Hex(tarball),
+			expectRanges: []string{"bytes=400-", ""},
+		}, {
+			desc:         "Partial file longer than the file is started over",
+			partial:      tarball + "extra",
+			checksum:     sha256Hex(tarball),
+			expectRanges: []string{"", ""},
+		}, {
+			desc:         "Complete partial file isn't downloaded again",
+			partial:      tarball,
+			checksum:     sha256Hex(tarball),
+			expectRanges: []string{""},
+		}, {
+			desc:         "Interrupted downloads are resumed",
+			checksum:     sha256Hex(tarball),
+			truncations:  2,
+			expectRanges: []string{"", "bytes=300-", "bytes=600-", ""},
+		}, {
+			desc:          "Gives up after too many interruptions",
+			checksum:      sha256Hex(tarball),
+			truncations:   downloadAttempts,
+			expectErr:     "couldn't download " + testTarball + ": got 900 of 1000 bytes",
+			expectRanges:  []string{"", "bytes=300-", "bytes=600-"},
+			expectPartial: true,
+		}, {
+			desc:         "Checksum mismatch",
+			partial:      strings.Repeat("x", 400),
+			checksum:     sha256Hex(tarball),
+			expectErr:    "checksum of " + testTarball + " is " + sha256Hex(strings.Repeat("x", 400)+tarball[400:]) + " but the run reported " + sha256Hex(tarball),
+			expectRanges: []string{"bytes=400-"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			srv := newTestResultsServer(t, files, nil)
+			dir, err := ioutil.TempDir("", "sonobuoy_download_test")
+			if err != nil {
+				t.Fatalf("Could not create temp directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			partial := filepath.Join(dir, testTarball+PartialDownloadSuffix)
+			if tc.partial != "" {
+				if err := ioutil.WriteFile(partial, []byte(tc.partial), 0644); err != nil {
+					t.Fatalf("Could not write partial file: %v", err)
+				}
+			}
+
+			get := truncatingGetter(srv.getter("token"), tc.truncations, 300)
+			filenames, err := downloadResults(context.Background(), get, dir, aggregation.TarInfo{Name: testTarball, SHA256: tc.checksum})
+			if len(srv.ranges) != len(tc.expectRanges) {
+				t.Errorf("Expected file requests with ranges %q, got %q", tc.expectRanges, srv.ranges)
+			} else {
+				for i := range srv.ranges {
+					if srv.ranges[i] != tc.expectRanges[i] {
+						t.Errorf("Expected file requests with ranges %q, got %q", tc.expectRanges, srv.ranges)
+						break
+					}
+				}
+			}
+
+			_, statErr := os.Stat(partial)
+			if tc.expectPartial != (statErr == nil) {
+				t.Errorf("Expected partial file to exist: %v, got stat error %v", tc.expectPartial, statErr)
+			}
+
+			if tc.expectErr != "" {
+				if err == nil || err.Error() != tc.expectErr {
+					t.Fatalf("Expected error %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := []string{filepath.Join(dir, testTarball), filepath.Join(dir, testTarball+".sig")}
+			if len(filenames) != len(expected) || filenames[0] != expected[0] || filenames[1] != expected[1] {
+				t.Fatalf("Expected files %v, got %v", expected, filenames)
+			}
+			for i, name := range []string{testTarball, testTarball + ".sig"} {
+				b, err := ioutil.ReadFile(filenames[i])
+				if err != nil {
+					t.Fatalf("Could not read %v: %v", filenames[i], err)
+				}
+				if string(b) != files[name] {
+					t.Errorf("Expected %v to have the contents of %v", filenames[i], name)
+				}
+			}
+		})
+	}
+}
+
+func TestListResultsErrors(t *testing.T) {
+	srv := newTestResultsServer(t, map[string]string{testTarball: "data"}, nil)
+
+	unavailable := func(ctx context.Context, path string, header http.Header) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Status:     "503 Service Unavailable",
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+	unreachable := func(ctx context.Context, path string, header http.Header) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	testCases := []struct {
+		desc            string
+		get             resultsGetter
+		expectNotServed bool
+		expectErr       string
+	}{
+		{
+			desc:      "Wrong token",
+			get:       srv.getter("wrong"),
+			expectErr: "the aggregator rejected the results token",
+		}, {
+			desc:            "Server not running",
+			get:             unavailable,
+			expectNotServed: true,
+		}, {
+			desc:            "Proxy unreachable",
+			get:             unreachable,
+			expectNotServed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := listResults(context.Background(), tc.get)
+			if err == nil {
+				t.Fatal("Expected an error, got nil")
+			}
+			if notServed := errors.Cause(err) == ErrResultsNotServed; notServed != tc.expectNotServed {
+				t.Errorf("Expected error to be ErrResultsNotServed: %v, got %v", tc.expectNotServed, err)
+			}
+			if tc.expectErr != "" && err.Error() != tc.expectErr {
+				t.Errorf("Expected error %q, got %q", tc.expectErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadResultsInvalidNames(t *testing.T) {
+	testCases := []string{"../../.bashrc", "plugins/e2e.tar.gz", "/etc/passwd", "..", ".", ""}
+
+	for _, name := range testCases {
+		t.Run(fmt.Sprintf("%q", name), func(t *testing.T) {
+			parent, err := ioutil.TempDir("", "sonobuoy_download_test")
+			if err != nil {
+				t.Fatalf("Could not create temp directory: %v", err)
+			}
+			defer os.RemoveAll(parent)
+			dir := filepath.Join(parent, "a", "b")
+
+			fetched := false
+			get := func(ctx context.Context, path string, header http.Header) (*http.Response, error) {
+				body := fmt.Sprintf(`[{"name":%q,"size":4}]`, name)
+				if path != aggregation.ResultsPath {
+					fetched = true
+					body = "evil"
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     "200 OK",
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			}
+
+			filenames, err := downloadResults(context.Background(), get, dir, aggregation.TarInfo{Name: testTarball})
+			if err == nil {
+				t.Fatalf("Expected an error for the invalid name, got files %v", filenames)
+			}
+			if fetched {
+				t.Error("Expected no file to be downloaded")
+			}
+			if _, err := os.Stat(filepath.Join(parent, ".bashrc")); !os.IsNotExist(err) {
+				t.Errorf("Expected no file to be written outside of the directory, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResultsToken(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "sonobuoy-abc",
+			Namespace:   "sonobuoy",
+			Labels:      map[string]string{"sonobuoy-component": "aggregator", "sonobuoy-run-id": "abc"},
+			Annotations: map[string]string{aggregation.ResultsTokenSecretAnnotationName: "sonobuoy-results-token-abc"},
+		},
+	}
+	// The aggregator names the secret after the ID in its config, which a hand-written manifest
+	// may not have labelled the pod with.
+	unlabelledPod := pod.DeepCopy()
+	unlabelledPod.Labels = map[string]string{"sonobuoy-component": "aggregator"}
+	oldPod := pod.DeepCopy()
+	oldPod.Annotations = nil
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "sonobuoy-results-token-abc", Namespace: "sonobuoy"},
+		Data:       map[string][]byte{aggregation.ResultsTokenKey: []byte("token")},
+	}
+	secretsResource := schema.GroupResource{Resource: "secrets"}
+
+	testCases := []struct {
+		desc            string
+		pod             *corev1.Pod
+		objects         []runtime.Object
+		getErr          error
+		expectToken     string
+		expectNotServed bool
+		expectErr       bool
+	}{
+		{
+			desc:        "Token is read from the secret named by the pod",
+			objects:     []runtime.Object{pod, secret},
+			expectToken: "token",
+		}, {
+			desc:        "Secret is found for a pod without a run ID label",
+			pod:         unlabelledPod,
+			objects:     []runtime.Object{unlabelledPod, secret},
+			expectToken: "token",
+		}, {
+			desc:            "Pod which doesn't name a secret doesn't serve the results",
+			pod:             oldPod,
+			objects:         []runtime.Object{oldPod, secret},
+			expectNotServed: true,
+		}, {
+			desc:            "Missing secret means the results aren't served",
+			objects:         []runtime.Object{pod},
+			expectNotServed: true,
+		}, {
+			desc:            "Not being allowed to read secrets falls back to other ways of retrieving",
+			objects:         []runtime.Object{pod, secret},
+			getErr:          kubeerror.NewForbidden(secretsResource, secret.Name, errors.New("no RBAC policy matched")),
+			expectNotServed: true,
+		}, {
+			desc:            "Unauthorized falls back to other ways of retrieving",
+			objects:         []runtime.Object{pod, secret},
+			getErr:          kubeerror.NewUnauthorized("token expired"),
+			expectNotServed: true,
+		}, {
+			desc:      "Other errors are reported",
+			objects:   []runtime.Object{pod, secret},
+			getErr:    kubeerror.NewInternalError(errors.New("etcd unavailable")),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tc.objects...)
+			if tc.getErr != nil {
+				client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tc.getErr
+				})
+			}
+
+			p := pod
+			if tc.pod != nil {
+				p = tc.pod
+			}
+			token, err := resultsToken(client, p)
+			if notServed := errors.Cause(err) == ErrResultsNotServed; notServed != tc.expectNotServed {
+				t.Errorf("Expected error to be ErrResultsNotServed: %v, got %v", tc.expectNotServed, err)
+			}
+			if gotErr := err != nil && !tc.expectNotServed; gotErr != tc.expectErr {
+				t.Errorf("Expected other error: %v, got %v", tc.expectErr, err)
+			}
+			if token != tc.expectToken {
+				t.Errorf("Expected token %q, got %q", tc.expectToken, token)
+			}
+		})
+	}
+}
+
+func TestDownloadPartialResults(t *testing.T) {
+	partial := func(dir, pluginName string) (string, error) {
+		switch pluginName {
+		case "missing":
+			return "", errors.Wrap(aggregation.ErrUnknownPlugin, `plugin "missing" isn't part of the run`)
+		case "late":
+			return "", aggregation.ErrPartialResultsUnavailable
+		}
+		tb := filepath.Join(dir, "202101010000_sonobuoy_abc_partial.tar.gz")
+		return tb, ioutil.WriteFile(tb, []byte("partial "+pluginName), 0644)
+	}
+	srv := newTestResultsServer(t, nil, partial)
+	withoutPartial := newTestResultsServer(t, nil, nil)
+
+	testCases := []struct {
+		desc            string
+		get             resultsGetter
+		plugin          string
+		expectFile      string
+		expectContents  string
+		expectErr       string
+		expectNotServed bool
+	}{
+		{
+			desc:           "All plugins",
+			get:            srv.getter("token"),
+			expectFile:     "202101010000_sonobuoy_abc_partial.tar.gz",
+			expectContents: "partial ",
+		}, {
+			desc:           "Single plugin",
+			get:            srv.getter("token"),
+			plugin:         "e2e",
+			expectFile:     "202101010000_sonobuoy_abc_partial.tar.gz",
+			expectContents: "partial e2e",
+		}, {
+			desc:      "Unknown plugin",
+			get:       srv.getter("token"),
+			plugin:    "missing",
+			expectErr: `plugin "missing" isn't part of the run: unknown plugin`,
+		}, {
+			desc:      "Plugins done",
+			get:       srv.getter("token"),
+			plugin:    "late",
+			expectErr: ErrPartialResultsUnavailable.Error(),
+		}, {
+			desc:      "Wrong token",
+			get:       srv.getter("wrong"),
+			expectErr: "the aggregator rejected the results token",
+		}, {
+			desc:            "Aggregator without partial results",
+			get:             withoutPartial.getter("token"),
+			expectNotServed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sonobuoy_download_test")
+			if err != nil {
+				t.Fatalf("Could not create temp directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			filename, err := downloadPartialResults(context.Background(), tc.get, dir, tc.plugin)
+			if tc.expectNotServed {
+				if errors.Cause(err) != ErrResultsNotServed {
+					t.Fatalf("Expected ErrResultsNotServed, got %v", err)
+				}
+				return
+			}
+			if tc.expectErr != "" {
+				if err == nil || err.Error() != tc.expectErr {
+					t.Fatalf("Expected error %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if filename != filepath.Join(dir, tc.expectFile) {
+				t.Errorf("Expected file %v, got %v", filepath.Join(dir, tc.expectFile), filename)
+			}
+			b, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Could not read %v: %v", filename, err)
+			}
+			if string(b) != tc.expectContents {
+				t.Errorf("Expected contents %q, got %q", tc.expectContents, string(b))
+			}
+			if _, err := os.Stat(filename + PartialDownloadSuffix); !os.IsNotExist(err) {
+				t.Errorf("Expected no partial download to be left, got stat error %v", err)
+			}
+		})
+	}
+}
@@ -30,14 +30,17 @@ import (
 	"github.com/vmware-tanzu/sonobuoy/pkg/client/results/e2e"
 )
 
-// GetTests extracts the junit results from a sonobuoy archive and returns the requested tests.
+// GetTests extracts the e2e results from a sonobuoy archive and returns the requested tests. The
+// Ginkgo JSON report is used if the plugin wrote one, otherwise the junit results are.
 func (*SonobuoyClient) GetTests(reader io.Reader, show string) ([]results.JUnitTestCase, error) {
 	read := results.NewReaderWithVersion(reader, "irrelevant")
 	junitResults := results.JUnitTestSuite{}
+	ginkgoResults := results.GinkgoReports{}
 	e2eJUnitPath := path.Join(results.PluginsDir, e2e.ResultsSubdirectory, e2e.JUnitResultsFile)
 	legacye2eJUnitPath := path.Join(results.PluginsDir, e2e.LegacyResultsSubdirectory, e2e.JUnitResultsFile)
+	e2eGinkgoPath := path.Join(results.PluginsDir, e2e.ResultsSubdirectory, e2e.GinkgoJSONResultsFile)
 
-	found := false
+	found, foundGinkgo := false, false
 	err := read.WalkFiles(
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -49,15 +52,22 @@ func (*SonobuoyClient) GetTests(reader io.Reader, show string) ([]results.JUnitT
 				found = true
 				return results.ExtractFileIntoStruct(path, path, info, &junitResults)
 			}
+			if path == e2eGinkgoPath {
+				foundGinkgo = true
+				return results.ExtractFileIntoStruct(path, path, info, &ginkgoResults)
+			}
 			return nil
 		})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to walk results archive")
 	}
 
-	if !found {
+	if !found && !foundGinkgo {
 		return nil, fmt.Errorf("failed to find results file %q in archive", e2eJUnitPath)
 	}
+	if foundGinkgo {
+		junitResults = ginkgoResults.JUnitTestSuite()
+	}
 
 	out := make([]results.JUnitTestCase, 0)
 	if show == "passed" || show == "all" {
@@ -1,9 +1,12 @@
 package client
 
 import (
+	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -88,6 +91,47 @@ func TestGetTests(t *testing.T) {
 		})
 	}
 }
+func TestGetTestsGinkgoReport(t *testing.T) {
+	report, err := ioutil.ReadFile("results/testdata/ginkgo-report.json")
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	junit := `<testsuite name="Kubernetes e2e suite"><testcase name="only in junit"><failure message="oops"/></testcase></testsuite>`
+
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for name, data := range map[string][]byte{
+		"plugins/e2e/results/global/junit_01.xml":       []byte(junit),
+		"plugins/e2e/results/global/ginkgo_report.json": report,
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+
+	sbc, err := NewSonobuoyClient(&rest.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to get Sonobuoy client")
+	}
+	failed, err := sbc.GetTests(&b, "failed")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expect := "[SynchronizedAfterSuite]\n" +
+		"[sig-network] Services should be able to change the type from ExternalName to ClusterIP [Conformance]\n" +
+		"[sig-node] Pods should support remote command execution over websockets [NodeConformance]"
+	if out := PrintableTestCases(failed).String(); out != expect {
+		t.Errorf("Expected failed tests from the ginkgo report\n%v\nbut got\n%v", expect, out)
+	}
+}
+
 func TestString(t *testing.T) {
 	testCases := []struct {
 		desc   string
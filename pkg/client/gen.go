@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/config"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
@@ -34,6 +35,7 @@ import (
 	manifesthelper "github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest/helper"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
@@ -63,10 +65,35 @@ type templateValues struct {
 	// configmap name, filename, string
 	ConfigMaps map[string]map[string]string
 
+	// RunID, if set, is the ID of the run which its resources are labelled with. The names of the
+	// resources of the run are suffixed with it so that several runs can share a namespace.
+	RunID                string
+	AggregatorName       string
+	ServiceName          string
+	ConfigMapName        string
+	PluginsConfigMapName string
+	RBACName             string
+
 	// CustomRegistries should be a multiline yaml string which represents
 	// the file contents of KUBE_TEST_REPO_LIST, the overrides for k8s e2e
 	// registries.
 	CustomRegistries string
+
+	// Resumable causes the aggregator to be created as a StatefulSet with persistent storage
+	// instead of a bare pod so that it can be restarted without losing the run.
+	Resumable bool
+
+	// StorageSize and StorageClassName describe the volume claimed by resumable aggregators.
+	StorageSize      string
+	StorageClassName string
+
+	// SigningKeySecret is the name of the secret to mount into the aggregator so that it can sign
+	// the results.
+	SigningKeySecret string
+
+	// MetricsPort, if set, is the port the aggregator serves metrics on, which is added to the
+	// aggregator service.
+	MetricsPort int
 }
 
 // GenerateManifest fills in a template with a Sonobuoy config
@@ -93,6 +120,26 @@ func (*SonobuoyClient) GenerateManifestAndPlugins(cfg *GenConfig) ([]byte, []*ma
 		conf = cfg.Config
 	}
 
+	storageSize := conf.Aggregation.StorageSize
+	if storageSize == "" {
+		storageSize = config.DefaultAggregationStorageSize
+	}
+	if _, err := resource.ParseQuantity(storageSize); conf.Aggregation.Resumable && err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid storage size %q", storageSize)
+	}
+
+	// A restarted aggregator needs to find the results (and checkpoint) of the run it is
+	// resuming, so the UUID which determines their location can't be left to the aggregator.
+	if conf.Aggregation.Resumable && conf.UUID == "" {
+		confCopy := *conf
+		runUUID, err := uuid.NewV4()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "couldn't generate run UUID")
+		}
+		confCopy.UUID = runUUID.String()
+		conf = &confCopy
+	}
+
 	marshalledConfig, err := json.Marshal(conf)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "couldn't marshall selector")
@@ -156,13 +203,14 @@ func (*SonobuoyClient) GenerateManifestAndPlugins(cfg *GenConfig) ([]byte, []*ma
 		if len(p.ConfigMap) == 0 {
 			continue
 		}
-		configs[p.SonobuoyConfig.PluginName] = p.ConfigMap
+		cmName := conf.ResourceName(fmt.Sprintf("plugin-%v-cm", p.SonobuoyConfig.PluginName))
+		configs[cmName] = p.ConfigMap
 		p.ExtraVolumes = append(p.ExtraVolumes,
 			manifest.Volume{
 				Volume: corev1.Volume{
 					Name: fmt.Sprintf("sonobuoy-%v-vol", p.SonobuoyConfig.PluginName),
 					VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{Name: fmt.Sprintf("plugin-%v-cm", p.SonobuoyConfig.PluginName)},
+						LocalObjectReference: corev1.LocalObjectReference{Name: cmName},
 					}},
 				},
 			})
@@ -179,6 +227,16 @@ func (*SonobuoyClient) GenerateManifestAndPlugins(cfg *GenConfig) ([]byte, []*ma
 		return nil, nil, errors.Wrap(err, "plugin YAML generation")
 	}
 
+	err = checkPluginDependencies(plugins)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "plugin YAML generation")
+	}
+
+	err = checkPluginRetries(plugins)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "plugin YAML generation")
+	}
+
 	cfg.PluginEnvOverrides, plugins = applyK8sVersion(cfg.KubeVersion, cfg.PluginEnvOverrides, plugins)
 
 	for pluginName, envVars := range cfg.PluginEnvOverrides {
@@ -236,6 +294,21 @@ func (*SonobuoyClient) GenerateManifestAndPlugins(cfg *GenConfig) ([]byte, []*ma
 		NodeSelectors: cfg.NodeSelectors,
 
 		ConfigMaps: configs,
+
+		RunID:                conf.UUID,
+		AggregatorName:       conf.ResourceName(config.AggregatorPodName),
+		ServiceName:          conf.ResourceName(config.AggregatorServiceName),
+		ConfigMapName:        conf.ResourceName("sonobuoy-config-cm"),
+		PluginsConfigMapName: conf.ResourceName("sonobuoy-plugins-cm"),
+		RBACName:             conf.ResourceName("sonobuoy-serviceaccount-" + conf.Namespace),
+
+		Resumable:        conf.Aggregation.Resumable,
+		StorageSize:      storageSize,
+		StorageClassName: conf.Aggregation.StorageClassName,
+
+		SigningKeySecret: conf.SigningKeySecret,
+
+		MetricsPort: conf.Aggregation.MetricsPort,
 	}
 
 	var buf bytes.Buffer
@@ -273,6 +346,29 @@ func checkPluginsUnique(plugins []*manifest.Manifest) error {
 	return nil
 }
 
+// checkPluginDependencies ensures that every plugin only depends on other plugins being
+// run and that there are no cycles between them.
+func checkPluginDependencies(plugins []*manifest.Manifest) error {
+	deps := map[string][]string{}
+	for _, v := range plugins {
+		deps[v.SonobuoyConfig.PluginName] = v.SonobuoyConfig.DependsOn
+	}
+	return plugin.ValidateDependencies(deps)
+}
+
+// checkPluginRetries ensures that no plugin has a negative number of retries or backoff.
+func checkPluginRetries(plugins []*manifest.Manifest) error {
+	for _, v := range plugins {
+		if v.SonobuoyConfig.Retries < 0 {
+			return fmt.Errorf("plugin %v has %v retries, which must not be negative", v.SonobuoyConfig.PluginName, v.SonobuoyConfig.Retries)
+		}
+		if v.SonobuoyConfig.Backoff != nil && v.SonobuoyConfig.Backoff.Duration < 0 {
+			return fmt.Errorf("plugin %v has a backoff of %v, which must not be negative", v.SonobuoyConfig.PluginName, v.SonobuoyConfig.Backoff.Duration)
+		}
+	}
+	return nil
+}
+
 // mergeEnv will combine the values from two env var sets with priority being
 // given to values in the first set in case of collision. Afterwards, any env
 // var with a name in the removal set will be removed.
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/buildinfo"
 	"github.com/vmware-tanzu/sonobuoy/pkg/client"
@@ -18,6 +19,7 @@ import (
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
@@ -259,6 +261,43 @@ func TestGenerateManifestGolden(t *testing.T) {
 				KubeVersion: "v99+static.testing",
 			},
 			expectErr: "plugin YAML generation: plugin names must be unique, got duplicated plugin name 'a'",
+		}, {
+			name: "Plugin dependency cycles fail",
+			inputcm: &client.GenConfig{
+				StaticPlugins: []*manifest.Manifest{
+					{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "a", DependsOn: []string{"b"}}},
+					{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "b", DependsOn: []string{"a"}}},
+				},
+				KubeVersion: "v99+static.testing",
+			},
+			expectErr: "plugin YAML generation: plugin dependencies form a cycle: a -> b -> a",
+		}, {
+			name: "Plugin dependencies must be run",
+			inputcm: &client.GenConfig{
+				StaticPlugins: []*manifest.Manifest{
+					{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "a", DependsOn: []string{"setup"}}},
+				},
+				KubeVersion: "v99+static.testing",
+			},
+			expectErr: "plugin YAML generation: plugin a depends on plugin setup which is not being run",
+		}, {
+			name: "Negative plugin retries fail",
+			inputcm: &client.GenConfig{
+				StaticPlugins: []*manifest.Manifest{
+					{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "a", Retries: -1}},
+				},
+				KubeVersion: "v99+static.testing",
+			},
+			expectErr: "plugin YAML generation: plugin a has -1 retries, which must not be negative",
+		}, {
+			name: "Negative plugin backoff fails",
+			inputcm: &client.GenConfig{
+				StaticPlugins: []*manifest.Manifest{
+					{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "a", Retries: 1, Backoff: &metav1.Duration{Duration: -time.Second}}},
+				},
+				KubeVersion: "v99+static.testing",
+			},
+			expectErr: "plugin YAML generation: plugin a has a backoff of -1s, which must not be negative",
 		}, {
 			// In this case the server will just load both and filter like it does currently.
 			name: "Plugin selection and custom plugins both specified allowed",
@@ -419,6 +458,86 @@ func TestGenerateManifestGolden(t *testing.T) {
 				},
 			},
 			goldenFile: filepath.Join("testdata", "imagePullPolicy-all-plugins.golden"),
+		}, {
+			name: "Resumable aggregator is a StatefulSet",
+			inputcm: &client.GenConfig{
+				Config: fromConfig(func(c *config.Config) *config.Config {
+					c.UUID = "static-uuid-for-testing"
+					c.Aggregation.Resumable = true
+					return c
+				}),
+				KubeVersion:    "v99+static.testing",
+				DynamicPlugins: []string{"e2e"},
+			},
+			goldenFile: filepath.Join("testdata", "resumable.golden"),
+		}, {
+			name: "Resumable aggregator with storage size and class",
+			inputcm: &client.GenConfig{
+				Config: fromConfig(func(c *config.Config) *config.Config {
+					c.UUID = "static-uuid-for-testing"
+					c.Aggregation.Resumable = true
+					c.Aggregation.StorageSize = "20Gi"
+					c.Aggregation.StorageClassName = "fast-ssd"
+					return c
+				}),
+				KubeVersion:    "v99+static.testing",
+				DynamicPlugins: []string{"e2e"},
+			},
+			goldenFile: filepath.Join("testdata", "resumable-storage.golden"),
+		}, {
+			name: "Invalid storage size of resumable aggregator",
+			inputcm: &client.GenConfig{
+				Config: fromConfig(func(c *config.Config) *config.Config {
+					c.UUID = "static-uuid-for-testing"
+					c.Aggregation.Resumable = true
+					c.Aggregation.StorageSize = "lots"
+					return c
+				}),
+				KubeVersion:    "v99+static.testing",
+				DynamicPlugins: []string{"e2e"},
+			},
+			expectErr: `invalid storage size "lots": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+		}, {
+			name: "Signing key secret is mounted into the aggregator",
+			inputcm: &client.GenConfig{
+				Config: fromConfig(func(c *config.Config) *config.Config {
+					c.UUID = "static-uuid-for-testing"
+					c.SigningKeySecret = "my-signing-key"
+					return c
+				}),
+				KubeVersion:    "v99+static.testing",
+				DynamicPlugins: []string{"e2e"},
+			},
+			goldenFile: filepath.Join("testdata", "signing-key-secret.golden"),
+		}, {
+			name: "Metrics port is added to the aggregator service",
+			inputcm: &client.GenConfig{
+				Config: fromConfig(func(c *config.Config) *config.Config {
+					c.UUID = "static-uuid-for-testing"
+					c.Aggregation.MetricsPort = 8081
+					return c
+				}),
+				KubeVersion:    "v99+static.testing",
+				DynamicPlugins: []string{"e2e"},
+			},
+			goldenFile: filepath.Join("testdata", "metrics-port.golden"),
+		}, {
+			name: "Resources of a run are named and labelled by its ID",
+			inputcm: &client.GenConfig{
+				Config: fromConfig(func(c *config.Config) *config.Config {
+					c.UUID = "static-uuid-for-testing"
+					return c
+				}),
+				EnableRBAC:  true,
+				KubeVersion: "v99+static.testing",
+				StaticPlugins: []*manifest.Manifest{
+					{
+						SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "myplugin"},
+						ConfigMap:      map[string]string{"file1": "contents1"},
+					},
+				},
+			},
+			goldenFile: filepath.Join("testdata", "run-id.golden"),
 		},
 	}
 
@@ -458,6 +577,26 @@ func TestGenerateManifestGolden(t *testing.T) {
 	}
 }
 
+func TestGenerateManifestResumableUUID(t *testing.T) {
+	sbc, err := client.NewSonobuoyClient(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Aggregation: plugin.AggregationConfig{Resumable: true}}
+	manifest, err := sbc.GenerateManifest(&client.GenConfig{Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.UUID != "" {
+		t.Errorf("Expected the given config not to be modified but UUID was set to %q", cfg.UUID)
+	}
+	if !bytes.Contains(manifest, []byte(`"UUID":"`)) || bytes.Contains(manifest, []byte(`"UUID":""`)) {
+		t.Errorf("Expected a UUID to be set in the config of resumable runs but got: %v", string(manifest))
+	}
+}
+
 func TestGenerateManifestInvalidConfig(t *testing.T) {
 	testcases := []struct {
 		desc             string
@@ -17,6 +17,7 @@ limitations under the License.
 package client
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -35,6 +36,8 @@ type LogConfig struct {
 	Follow bool
 	// Namespace is the namespace the sonobuoy aggregator is running in.
 	Namespace string
+	// RunID, if set, limits the logs to those of the run with that ID.
+	RunID string
 	// Plugin is the name of the plugin to show the logs of.
 	Plugin string
 	// Out is the writer to write to.
@@ -110,7 +113,10 @@ func (rc *RunConfig) Validate() error {
 
 // DeleteConfig are the input options for cleaning up a Sonobuoy run.
 type DeleteConfig struct {
-	Namespace  string
+	Namespace string
+	// RunID, if set, limits the deletion to the resources of the run with that ID. The namespace,
+	// and anything else shared with other runs in it, is left in place.
+	RunID      string
 	EnableRBAC bool
 	DeleteAll  bool
 	Wait       time.Duration
@@ -130,6 +136,9 @@ func (dc *DeleteConfig) Validate() error {
 type RetrieveConfig struct {
 	// Namespace is the namespace the sonobuoy aggregator is running in.
 	Namespace string
+	// RunID is the ID of the run to retrieve the results of. It may be left empty if only one run
+	// is in the namespace.
+	RunID string
 }
 
 // Validate checks the config to determine if it is valid.
@@ -145,6 +154,9 @@ func (rc *RetrieveConfig) Validate() error {
 type StatusConfig struct {
 	// Namespace is the namespace the sonobuoy aggregator is running in.
 	Namespace string
+	// RunID is the ID of the run to get the status of. It may be left empty if only one run is in
+	// the namespace.
+	RunID string
 }
 
 // Validate checks the config to determine if it is valid.
@@ -223,12 +235,20 @@ type Interface interface {
 	GenerateManifest(cfg *GenConfig) ([]byte, error)
 	// RetrieveResults copies results from a sonobuoy run into a Reader in tar format.
 	RetrieveResults(cfg *RetrieveConfig) (io.Reader, <-chan error, error)
+	// DownloadResults downloads the results of a sonobuoy run into a directory over HTTPS.
+	DownloadResults(ctx context.Context, cfg *RetrieveConfig, dir string) ([]string, error)
+	// DownloadPartialResults downloads the results received so far by a sonobuoy run in progress.
+	DownloadPartialResults(ctx context.Context, cfg *RetrieveConfig, dir, pluginName string) (string, error)
 	// GetStatus determines the status of the sonobuoy run in order to assist the user.
 	GetStatus(cfg *StatusConfig) (*aggregation.Status, error)
+	// WatchStatus streams the events of the sonobuoy run until its results are ready.
+	WatchStatus(ctx context.Context, cfg *StatusConfig) (<-chan aggregation.Event, error)
 	// LogReader returns a reader that contains a merged stream of sonobuoy logs.
 	LogReader(cfg *LogConfig) (*Reader, error)
 	// Delete removes a sonobuoy run, namespace, and all associated resources.
 	Delete(cfg *DeleteConfig) error
 	// PreflightChecks runs a number of preflight checks to confirm the environment is good for Sonobuoy
 	PreflightChecks(cfg *PreflightConfig) []error
+	// ListRuns returns the sonobuoy runs in all namespaces.
+	ListRuns() ([]RunSummary, error)
 }
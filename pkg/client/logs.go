@@ -29,6 +29,8 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 )
 
 const (
@@ -142,17 +144,29 @@ func (r *Reader) Read(p []byte) (int, error) {
 	return len(data), nil
 }
 
+// logsSelector returns the label selector of the pods to show the logs of, limited to those of
+// the plugin and run of the config if they are set.
+func logsSelector(cfg *LogConfig) string {
+	selector := &metav1.LabelSelector{}
+	if cfg.Plugin != "" {
+		selector = metav1.AddLabelToSelector(selector, "sonobuoy-plugin", cfg.Plugin)
+	}
+	if cfg.RunID != "" {
+		selector = metav1.AddLabelToSelector(selector, plugin.RunIDLabel, cfg.RunID)
+	}
+	if len(selector.MatchLabels) == 0 {
+		return ""
+	}
+	return metav1.FormatLabelSelector(selector)
+}
+
 // getPodsToStreamLogs retrieves the pods to stream logs from. If a plugin name has been provided, retrieve the pods with
 // only the plugin label matching that plugin name. If no pods are found, or no plugin has been specified, retrieve
 // all pods within the namespace. It will immediately return an error if unabel to list pods, but will otherwise
 // add pods onto the channel in a separate go routine so that this method does not block. It closes the pod channel once
 // all the pods have been reported.
 func getPodsToStreamLogs(client kubernetes.Interface, cfg *LogConfig, podCh chan *v1.Pod) error {
-	listOptions := metav1.ListOptions{}
-	if cfg.Plugin != "" {
-		selector := metav1.AddLabelToSelector(&metav1.LabelSelector{}, "sonobuoy-plugin", cfg.Plugin)
-		listOptions = metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(selector)}
-	}
+	listOptions := metav1.ListOptions{LabelSelector: logsSelector(cfg)}
 
 	podList, err := client.CoreV1().Pods(cfg.Namespace).List(context.TODO(), listOptions)
 	if err != nil {
@@ -176,9 +190,8 @@ func getPodsToStreamLogs(client kubernetes.Interface, cfg *LogConfig, podCh chan
 func watchPodsToStreamLogs(client kubernetes.Interface, cfg *LogConfig, podCh chan *v1.Pod) error {
 	var timeoutSeconds int64 = 5
 	listOptions := metav1.ListOptions{TimeoutSeconds: &timeoutSeconds}
-	if cfg.Plugin != "" {
-		selector := metav1.AddLabelToSelector(&metav1.LabelSelector{}, "sonobuoy-plugin", cfg.Plugin)
-		listOptions = metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(selector)}
+	if selector := logsSelector(cfg); selector != "" {
+		listOptions = metav1.ListOptions{LabelSelector: selector}
 	}
 
 	lw := &cache.ListWatch{
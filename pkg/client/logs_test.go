@@ -259,6 +259,7 @@ func TestPodsForLogs(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: map[string]string{
 				"sonobuoy-plugin": pluginName,
+				"sonobuoy-run-id": "abc",
 			},
 		},
 	}
@@ -266,6 +267,7 @@ func TestPodsForLogs(t *testing.T) {
 	testCases := []struct {
 		desc                  string
 		pluginName            string
+		runID                 string
 		expectedCallCount     int
 		expectedLabelSelector string
 		podListError          error
@@ -288,6 +290,19 @@ func TestPodsForLogs(t *testing.T) {
 			expectedPodCount:      1,
 			expectedError:         nil,
 		},
+		{
+			desc:                  "Run specified results in the pods of the run being fetched once",
+			runID:                 "abc",
+			expectedLabelSelector: "sonobuoy-run-id=abc",
+			expectedPodCount:      1,
+		},
+		{
+			desc:                  "Plugin and run specified results in the plugin pods of the run being fetched once",
+			pluginName:            pluginName,
+			runID:                 "abc",
+			expectedLabelSelector: "sonobuoy-plugin=my-plugin,sonobuoy-run-id=abc",
+			expectedPodCount:      1,
+		},
 		{
 			desc:                  "Error when fetching plugin pods results in error being returned",
 			pluginName:            pluginName,
@@ -300,7 +315,7 @@ func TestPodsForLogs(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			cfg := &LogConfig{Plugin: tc.pluginName}
+			cfg := &LogConfig{Plugin: tc.pluginName, RunID: tc.runID}
 
 			fclient := fake.NewSimpleClientset()
 			fclient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
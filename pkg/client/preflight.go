@@ -142,15 +142,17 @@ func preflightExistingNamespace(client kubernetes.Interface, cfg *PreflightConfi
 	)
 }
 
+// nsCheck checks that the run can be created in the namespace. Several runs may share a
+// namespace, so it only has to exist without being deleted.
 func nsCheck(getter nsGetFunc, ns string) error {
-	_, err := getter(context.TODO(), ns, metav1.GetOptions{})
+	namespace, err := getter(context.TODO(), ns, metav1.GetOptions{})
 	switch {
 	case apierrors.IsNotFound(err):
 		return nil
 	case err != nil:
 		return errors.Wrap(err, "error checking for namespace")
-	case err == nil:
-		return errors.New("namespace already exists")
+	case namespace.Status.Phase == apicorev1.NamespaceTerminating:
+		return errors.New("namespace is being deleted")
 	}
 	return nil
 }
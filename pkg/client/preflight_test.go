@@ -202,11 +202,16 @@ func TestNamespaceCheck(t *testing.T) {
 		expectErr string
 	}{
 		{
-			desc: "Namespace and no error indicates it exists",
+			desc: "Runs can share an existing namespace",
 			getter: func(context.Context, string, metav1.GetOptions) (*apicorev1.Namespace, error) {
-				return &apicorev1.Namespace{}, nil
+				return &apicorev1.Namespace{Status: apicorev1.NamespaceStatus{Phase: apicorev1.NamespaceActive}}, nil
 			},
-			expectErr: "namespace already exists",
+		}, {
+			desc: "Namespace being deleted can't be used",
+			getter: func(context.Context, string, metav1.GetOptions) (*apicorev1.Namespace, error) {
+				return &apicorev1.Namespace{Status: apicorev1.NamespaceStatus{Phase: apicorev1.NamespaceTerminating}}, nil
+			},
+			expectErr: "namespace is being deleted",
 		}, {
 			desc: "Random error bubbled up",
 			getter: func(context.Context, string, metav1.GetOptions) (*apicorev1.Namespace, error) {
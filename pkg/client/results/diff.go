@@ -0,0 +1,330 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	"gopkg.in/yaml.v3"
+)
+
+// DiffKind describes how a test changed between two runs.
+type DiffKind string
+
+const (
+	// DiffNewlyFailed is a test which failed in the new run but not in the old one.
+	DiffNewlyFailed DiffKind = "newly-failed"
+
+	// DiffNewlyPassed is a test which passed in the new run after failing in the old one.
+	DiffNewlyPassed DiffKind = "newly-passed"
+
+	// DiffAppeared is a test which is only in the new run.
+	DiffAppeared DiffKind = "appeared"
+
+	// DiffDisappeared is a test which is only in the old run.
+	DiffDisappeared DiffKind = "disappeared"
+
+	// DiffChanged is any other change of status, e.g. from passed to skipped.
+	DiffChanged DiffKind = "changed"
+)
+
+// testPathSeparator separates the names of the items leading to a test in TestDiff.Name.
+const testPathSeparator = "|"
+
+// TestDiff is a single test whose status differs between two runs.
+type TestDiff struct {
+	Plugin    string   `json:"plugin"`
+	Node      string   `json:"node"`
+	Name      string   `json:"name"`
+	Kind      DiffKind `json:"kind"`
+	OldStatus string   `json:"oldStatus,omitempty"`
+	NewStatus string   `json:"newStatus,omitempty"`
+}
+
+// Regression returns true if the test fails in the new run but didn't in the old one, including
+// failing tests which only appear in the new run.
+func (t TestDiff) Regression() bool {
+	return isFailureStatus(t.NewStatus) && !isFailureStatus(t.OldStatus)
+}
+
+// String describes the change, e.g. "newly-failed (passed -> failed)".
+func (t TestDiff) String() string {
+	switch t.Kind {
+	case DiffAppeared:
+		return fmt.Sprintf("%v (%v)", t.Kind, t.NewStatus)
+	case DiffDisappeared:
+		return fmt.Sprintf("%v (was %v)", t.Kind, t.OldStatus)
+	default:
+		return fmt.Sprintf("%v (%v -> %v)", t.Kind, t.OldStatus, t.NewStatus)
+	}
+}
+
+// Diff is the set of tests whose status differs between two runs, sorted by plugin, node and name.
+type Diff struct {
+	Tests []TestDiff `json:"tests"`
+}
+
+// Regressions returns the tests which fail in the new run but didn't in the old one.
+func (d *Diff) Regressions() []TestDiff {
+	var regressions []TestDiff
+	for _, t := range d.Tests {
+		if t.Regression() {
+			regressions = append(regressions, t)
+		}
+	}
+	return regressions
+}
+
+// PluginResultsItems reads the post-processed results of every plugin in the archive in a single
+// pass, keyed by plugin name.
+func (r *Reader) PluginResultsItems() (map[string]*Item, error) {
+	items := map[string]*Item{}
+	err := r.WalkFiles(func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		pluginName, ok := pluginResultsFilePlugin(filePath)
+		if !ok {
+			return nil
+		}
+		item, err := decodePluginResults(pluginName, info)
+		if err != nil {
+			return err
+		}
+		items[pluginName] = item
+		return nil
+	})
+	return items, err
+}
+
+// pluginResultsFilePlugin returns the name of the plugin if the path is that of the post-processed
+// results of a plugin, i.e. plugins/<plugin>/sonobuoy_results.yaml.
+func pluginResultsFilePlugin(filePath string) (string, bool) {
+	dir, file := path.Split(filePath)
+	pluginName := path.Base(dir)
+	if file != PostProcessedResultsFile || path.Clean(dir) != path.Join(PluginsDir, pluginName) {
+		return "", false
+	}
+	return pluginName, true
+}
+
+func decodePluginResults(pluginName string, info os.FileInfo) (*Item, error) {
+	reader, ok := info.Sys().(io.Reader)
+	if !ok {
+		return nil, errors.New("info.Sys() is not a reader")
+	}
+	item := &Item{}
+	if err := yaml.NewDecoder(reader).Decode(item); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode yaml results for plugin %v", pluginName)
+	}
+	return item, nil
+}
+
+// DiffResults compares the results of every plugin in the old and new runs, as returned by
+// PluginResultsItems. Plugins only run in one of them have all of their tests reported as
+// having appeared or disappeared.
+func DiffResults(old, new map[string]*Item) *Diff {
+	names := map[string]struct{}{}
+	for name := range old {
+		names[name] = struct{}{}
+	}
+	for name := range new {
+		names[name] = struct{}{}
+	}
+
+	d := &Diff{}
+	for name := range names {
+		d.Tests = append(d.Tests, DiffItems(name, old[name], new[name])...)
+	}
+	sortTestDiffs(d.Tests)
+	return d
+}
+
+// DiffItems compares the results of a single plugin between two runs. Either item may be nil if
+// the plugin didn't run. Tests are identified by their node and by the names of the items leading
+// to them, ignoring the names of result files since those can differ between runs.
+func DiffItems(pluginName string, old, new *Item) []TestDiff {
+	oldTests, newTests := testStatuses(old), testStatuses(new)
+
+	var diffs []TestDiff
+	for key, oldStatus := range oldTests {
+		newStatus, ok := newTests[key]
+		if ok && newStatus == oldStatus {
+			continue
+		}
+
+		t := TestDiff{Plugin: pluginName, Node: key.node, Name: key.name, OldStatus: oldStatus, NewStatus: newStatus}
+		switch {
+		case !ok:
+			t.Kind = DiffDisappeared
+		case t.Regression():
+			t.Kind = DiffNewlyFailed
+		case isFailureStatus(oldStatus) && newStatus == StatusPassed:
+			t.Kind = DiffNewlyPassed
+		default:
+			t.Kind = DiffChanged
+		}
+		diffs = append(diffs, t)
+	}
+	for key, newStatus := range newTests {
+		if _, ok := oldTests[key]; !ok {
+			diffs = append(diffs, TestDiff{Plugin: pluginName, Node: key.node, Name: key.name, Kind: DiffAppeared, NewStatus: newStatus})
+		}
+	}
+
+	sortTestDiffs(diffs)
+	return diffs
+}
+
+// TestResult is the status of a single test in a run. Tests are identified as they are in a Diff.
+type TestResult struct {
+	Plugin string `json:"plugin"`
+	Node   string `json:"node"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Tests returns the status of every test in the results of a plugin, sorted by node and name.
+func Tests(pluginName string, item *Item) []TestResult {
+	statuses := testStatuses(item)
+	tests := make([]TestResult, 0, len(statuses))
+	for key, status := range statuses {
+		tests = append(tests, TestResult{Plugin: pluginName, Node: key.node, Name: key.name, Status: status})
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].Node != tests[j].Node {
+			return tests[i].Node < tests[j].Node
+		}
+		return tests[i].Name < tests[j].Name
+	})
+	return tests
+}
+
+// testKey identifies a test within the results of a plugin.
+type testKey struct {
+	node string
+	name string
+}
+
+// testStatuses returns the status of every leaf of the item tree.
+func testStatuses(root *Item) map[testKey]string {
+	statuses := map[testKey]string{}
+	walkTests(root, func(node string, names []string, leaf *Item) {
+		key := testKey{node: node, name: strings.Join(append(names, leaf.Name), testPathSeparator)}
+		status := leaf.Status
+		if status == "" {
+			status = StatusUnknown
+		}
+
+		// Keep failures if the same test is reported more than once.
+		if existing, ok := statuses[key]; !ok || !isFailureStatus(existing) {
+			statuses[key] = status
+		}
+	})
+	return statuses
+}
+
+// walkTests calls fn with every leaf of the item tree, along with the node it ran on and the
+// names of the items leading to it. The root item and the names of result files aren't included.
+func walkTests(root *Item, fn func(node string, names []string, leaf *Item)) {
+	walkTestsInFiles(root, func(node, _ string, names []string, leaf *Item) {
+		fn(node, names, leaf)
+	})
+}
+
+// walkTestsInFiles is like walkTests but also passes the name of the result file each leaf was
+// read from, if any.
+func walkTestsInFiles(root *Item, fn func(node, file string, names []string, leaf *Item)) {
+	if root == nil {
+		return
+	}
+	for i := range root.Items {
+		walkTestItems(&root.Items[i], plugin.GlobalResult, "", nil, fn)
+	}
+}
+
+func walkTestItems(item *Item, node, file string, names []string, fn func(node, file string, names []string, leaf *Item)) {
+	if len(item.Items) == 0 {
+		fn(node, file, names, item)
+		return
+	}
+
+	node, file, names = testPath(item, node, file, names)
+	for i := range item.Items {
+		walkTestItems(&item.Items[i], node, file, names, fn)
+	}
+}
+
+// testPath returns the node, file and names of the items leading to the tests under the item,
+// given those leading to the item itself.
+func testPath(item *Item, node, file string, names []string) (string, string, []string) {
+	switch {
+	case item.Metadata[metadataTypeKey] == metadataTypeNode:
+		node = item.Name
+	case item.Metadata[metadataTypeKey] == metadataTypeFile, item.Metadata[metadataFileKey] != "":
+		// Results can be split across files differently between runs (e.g. junit_01.xml).
+		file = item.Name
+	default:
+		names = append(names[:len(names):len(names)], item.Name)
+	}
+	return node, file, names
+}
+
+func sortTestDiffs(diffs []TestDiff) {
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Plugin != diffs[j].Plugin {
+			return diffs[i].Plugin < diffs[j].Plugin
+		}
+		if diffs[i].Node != diffs[j].Node {
+			return diffs[i].Node < diffs[j].Node
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+}
+
+// JUnit returns the diff as JUnit test suites, one per plugin and node, so that CI systems can
+// report on it. Regressions are failures and tests which disappeared are skipped.
+func (d *Diff) JUnit() JUnitTestSuites {
+	suites := JUnitTestSuites{}
+	for _, t := range d.Tests {
+		suiteName := path.Join(t.Plugin, t.Node)
+		if len(suites.Suites) == 0 || suites.Suites[len(suites.Suites)-1].Name != suiteName {
+			suites.Suites = append(suites.Suites, JUnitTestSuite{Name: suiteName})
+		}
+		suite := &suites.Suites[len(suites.Suites)-1]
+
+		tc := JUnitTestCase{Classname: suiteName, Name: t.Name, SystemOut: t.String()}
+		switch {
+		case t.Regression():
+			suite.Failures++
+			tc.Failure = &JUnitFailureMessage{Message: t.String(), Type: string(t.Kind)}
+		case t.Kind == DiffDisappeared:
+			tc.SkipMessage = &JUnitSkipMessage{Message: t.String()}
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	return suites
+}
@@ -0,0 +1,203 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"archive/tar"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// junitPlugin returns the results of a job plugin with a single junit file holding the given tests.
+func junitPlugin(file string, tests map[string]string) *Item {
+	suite := Item{Name: "Kubernetes e2e suite"}
+	for name, status := range tests {
+		suite.Items = append(suite.Items, Item{Name: name, Status: status})
+	}
+	return &Item{
+		Name: "e2e",
+		Items: []Item{{
+			Name:     file,
+			Metadata: map[string]string{metadataFileKey: "results/global/" + file, metadataTypeKey: metadataTypeFile},
+			Items:    []Item{suite},
+		}},
+	}
+}
+
+func TestDiffItems(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		old, new *Item
+		expected []TestDiff
+	}{
+		{
+			desc:     "No changes",
+			old:      junitPlugin("junit_01.xml", map[string]string{"a": StatusPassed, "b": StatusFailed}),
+			new:      junitPlugin("junit_01.xml", map[string]string{"a": StatusPassed, "b": StatusFailed}),
+			expected: nil,
+		}, {
+			desc: "Each kind of change",
+			old: junitPlugin("junit_01.xml", map[string]string{
+				"regressed": StatusPassed,
+				"fixed":     StatusFailed,
+				"skipped":   StatusPassed,
+				"removed":   StatusPassed,
+			}),
+			new: junitPlugin("junit_01.xml", map[string]string{
+				"regressed": StatusFailed,
+				"fixed":     StatusPassed,
+				"skipped":   StatusSkipped,
+				"added":     StatusFailed,
+			}),
+			expected: []TestDiff{
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|added", Kind: DiffAppeared, NewStatus: StatusFailed},
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|fixed", Kind: DiffNewlyPassed, OldStatus: StatusFailed, NewStatus: StatusPassed},
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|regressed", Kind: DiffNewlyFailed, OldStatus: StatusPassed, NewStatus: StatusFailed},
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|removed", Kind: DiffDisappeared, OldStatus: StatusPassed},
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|skipped", Kind: DiffChanged, OldStatus: StatusPassed, NewStatus: StatusSkipped},
+			},
+		}, {
+			desc:     "Result files are ignored",
+			old:      junitPlugin("junit_01.xml", map[string]string{"a": StatusPassed}),
+			new:      junitPlugin("junit_02.xml", map[string]string{"a": StatusPassed}),
+			expected: nil,
+		}, {
+			desc: "Timeouts are failures",
+			old:  junitPlugin("junit_01.xml", map[string]string{"a": StatusFailed}),
+			new:  junitPlugin("junit_01.xml", map[string]string{"a": StatusTimeout}),
+			expected: []TestDiff{
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|a", Kind: DiffChanged, OldStatus: StatusFailed, NewStatus: StatusTimeout},
+			},
+		}, {
+			desc: "Plugin missing from old run",
+			new:  junitPlugin("junit_01.xml", map[string]string{"a": StatusPassed}),
+			expected: []TestDiff{
+				{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|a", Kind: DiffAppeared, NewStatus: StatusPassed},
+			},
+		}, {
+			desc: "Daemonset plugins are compared per node",
+			old: &Item{Name: "systemd-logs", Items: []Item{
+				{Name: "node1", Metadata: map[string]string{metadataTypeKey: metadataTypeNode}, Items: []Item{{Name: "out.json", Status: StatusPassed}}},
+				{Name: "node2", Metadata: map[string]string{metadataTypeKey: metadataTypeNode}, Items: []Item{{Name: "out.json", Status: StatusPassed}}},
+			}},
+			new: &Item{Name: "systemd-logs", Items: []Item{
+				{Name: "node1", Metadata: map[string]string{metadataTypeKey: metadataTypeNode}, Items: []Item{{Name: "out.json", Status: StatusPassed}}},
+				{Name: "node2", Metadata: map[string]string{metadataTypeKey: metadataTypeNode}, Items: []Item{{Name: "out.json", Status: StatusFailed}}},
+			}},
+			expected: []TestDiff{
+				{Plugin: "e2e", Node: "node2", Name: "out.json", Kind: DiffNewlyFailed, OldStatus: StatusPassed, NewStatus: StatusFailed},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := DiffItems("e2e", tc.old, tc.new)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %+v but got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTests(t *testing.T) {
+	got := Tests("e2e", junitPlugin("junit_01.xml", map[string]string{"b": StatusFailed, "a": StatusPassed}))
+	expected := []TestResult{
+		{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|a", Status: StatusPassed},
+		{Plugin: "e2e", Node: "global", Name: "Kubernetes e2e suite|b", Status: StatusFailed},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, got)
+	}
+}
+
+func TestDiffRegressionsAndJUnit(t *testing.T) {
+	d := DiffResults(
+		map[string]*Item{"e2e": junitPlugin("junit_01.xml", map[string]string{"a": StatusPassed, "b": StatusPassed})},
+		map[string]*Item{
+			"e2e":   junitPlugin("junit_01.xml", map[string]string{"a": StatusFailed}),
+			"other": junitPlugin("junit_01.xml", map[string]string{"c": StatusPassed}),
+		},
+	)
+
+	if len(d.Tests) != 3 {
+		t.Fatalf("Expected 3 changed tests but got %+v", d.Tests)
+	}
+	regressions := d.Regressions()
+	if len(regressions) != 1 || regressions[0].Name != "Kubernetes e2e suite|a" {
+		t.Errorf("Expected test a to be the only regression but got %+v", regressions)
+	}
+
+	suites := d.JUnit()
+	if len(suites.Suites) != 2 {
+		t.Fatalf("Expected a suite per plugin and node but got %+v", suites.Suites)
+	}
+	e2e := suites.Suites[0]
+	if e2e.Name != "e2e/global" || e2e.Tests != 2 || e2e.Failures != 1 {
+		t.Errorf("Expected e2e suite with 2 tests and 1 failure but got %+v", e2e)
+	}
+	if e2e.TestCases[0].Failure == nil || e2e.TestCases[1].SkipMessage == nil {
+		t.Errorf("Expected regression to fail and disappeared test to be skipped but got %+v", e2e.TestCases)
+	}
+	if other := suites.Suites[1]; other.Name != "other/global" || other.Failures != 0 {
+		t.Errorf("Expected other suite without failures but got %+v", other)
+	}
+}
+
+// testFile is a file to write into an archive with newTestArchive.
+type testFile struct{ name, data string }
+
+// newTestArchive returns an uncompressed tar archive of the files.
+func newTestArchive(t *testing.T, files []testFile) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	for _, f := range files {
+		if err := w.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestPluginResultsItems(t *testing.T) {
+	buf := newTestArchive(t, []testFile{
+		{"plugins/e2e/sonobuoy_results.yaml", "name: e2e\nstatus: passed\n"},
+		{"plugins/e2e/results/global/sonobuoy_results.yaml", "name: not-the-summary\n"},
+		{"plugins/systemd-logs/sonobuoy_results.yaml", "name: systemd-logs\nstatus: failed\n"},
+		{"meta/run.log", "log"},
+	})
+
+	items, err := NewReaderWithVersion(buf, VersionFifteen).PluginResultsItems()
+	if err != nil {
+		t.Fatalf("Unexpected error reading plugin results: %v", err)
+	}
+	expected := map[string]*Item{
+		"e2e":          {Name: "e2e", Status: StatusPassed},
+		"systemd-logs": {Name: "systemd-logs", Status: StatusFailed},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, items)
+	}
+}
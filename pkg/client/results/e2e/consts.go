@@ -27,4 +27,8 @@ const (
 
 	// JUnitResultsFile is the name of the file which e2e tests emit.
 	JUnitResultsFile = "junit_01.xml"
+
+	// GinkgoJSONResultsFile is the name of the Ginkgo JSON report which e2e tests emit when
+	// asked to (e.g. with --ginkgo.json-report). It has more detail than the junit results.
+	GinkgoJSONResultsFile = "ginkgo_report.json"
 )
@@ -0,0 +1,199 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+)
+
+const (
+	// StatusExpectedFailure is the status of a test which failed, as an expectation said it would.
+	// It is not considered a failure.
+	StatusExpectedFailure = "expected-failure"
+
+	// StatusUnexpectedPass is the status of a test which passed even though an expectation said
+	// it would not. It is not considered a failure but flags that the expectation may be stale.
+	StatusUnexpectedPass = "unexpected-pass"
+
+	// ExpectationKey is the key in the Items.Details map for the reason given by the expectation
+	// which matched the test.
+	ExpectationKey = "expectation"
+
+	// expectationDateFormat is the format of the expiry date of an expectation.
+	expectationDateFormat = "2006-01-02"
+)
+
+// ExpectationsFile is the format of the file listing the expected outcome of tests.
+type ExpectationsFile struct {
+	Expectations []config.Expectation `json:"expectations"`
+}
+
+// expectation is a validated config.Expectation.
+type expectation struct {
+	config.Expectation
+	test    *regexp.Regexp
+	expires time.Time
+}
+
+// LoadExpectations reads and validates the expectations in the YAML or JSON file.
+func LoadExpectations(path string) ([]config.Expectation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open expectations file")
+	}
+	defer f.Close()
+	return ReadExpectations(f)
+}
+
+// ReadExpectations reads and validates expectations in the format of ExpectationsFile.
+func ReadExpectations(r io.Reader) ([]config.Expectation, error) {
+	file := ExpectationsFile{}
+	if err := yaml.NewYAMLOrJSONDecoder(r, 4096).Decode(&file); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode expectations")
+	}
+	if _, err := compileExpectations(file.Expectations); err != nil {
+		return nil, err
+	}
+	return file.Expectations, nil
+}
+
+func compileExpectations(expectations []config.Expectation) ([]expectation, error) {
+	compiled := make([]expectation, len(expectations))
+	for i, e := range expectations {
+		if e.Test == "" {
+			return nil, fmt.Errorf("expectation %v: test is required", i+1)
+		}
+		re, err := regexp.Compile(e.Test)
+		if err != nil {
+			return nil, errors.Wrapf(err, "expectation %v: invalid test regexp", i+1)
+		}
+		compiled[i] = expectation{Expectation: e, test: re}
+		if compiled[i].Status == "" {
+			compiled[i].Status = StatusFailed
+		}
+		if e.Expires != "" {
+			expires, err := time.Parse(expectationDateFormat, e.Expires)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expectation %v: expires must be a date formatted as YYYY-MM-DD", i+1)
+			}
+			// The expectation still applies on the day it expires.
+			compiled[i].expires = expires.AddDate(0, 0, 1)
+		}
+	}
+	return compiled, nil
+}
+
+// ApplyExpectations sets the status of each test in the results of the plugin which matches an
+// expectation, the first one listed winning:
+//   - tests which fail as expected are marked as expected-failure;
+//   - tests which pass when they weren't expected to are marked as unexpected-pass;
+//   - tests matching an expired expectation, or which don't match the expected status in any
+//     other way, keep their status.
+//
+// The reason of the expectation is added to the details of each matching test and the status of
+// the items above them is recomputed, so that only unexpected outcomes fail the plugin. Applying
+// the same expectations again does not change the results.
+func ApplyExpectations(item *Item, plugin string, expectations []config.Expectation, now time.Time) error {
+	compiled, err := compileExpectations(expectations)
+	if err != nil {
+		return err
+	}
+
+	var forPlugin []expectation
+	for _, e := range compiled {
+		if e.Plugin == "" || e.Plugin == plugin {
+			forPlugin = append(forPlugin, e)
+		}
+	}
+	if len(forPlugin) == 0 {
+		return nil
+	}
+
+	if applyExpectations(item, forPlugin, now) && len(item.Items) > 0 {
+		item.Status = aggregateStatus(item.Items...)
+	}
+	return nil
+}
+
+// applyExpectations applies the expectations to the leaves of the tree and returns true if the
+// status of any of them changed.
+func applyExpectations(item *Item, expectations []expectation, now time.Time) bool {
+	if len(item.Items) > 0 {
+		changed := false
+		for i := range item.Items {
+			if applyExpectations(&item.Items[i], expectations, now) {
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	for _, e := range expectations {
+		if !e.test.MatchString(item.Name) {
+			continue
+		}
+		status := expectedStatus(e, originalStatus(item.Status), now)
+		changed := status != item.Status
+		item.Status = status
+
+		if item.Details == nil {
+			item.Details = map[string]interface{}{}
+		}
+		item.Details[ExpectationKey] = e.Reason
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			item.Details[ExpectationKey] = fmt.Sprintf("expired %v: %v", e.Expires, e.Reason)
+		}
+		return changed
+	}
+	return false
+}
+
+// expectedStatus returns the status of a test given the expectation it matches.
+func expectedStatus(e expectation, status string, now time.Time) string {
+	if !e.expires.IsZero() && !now.Before(e.expires) {
+		return status
+	}
+
+	expectFailure := isFailureStatus(e.Status)
+	switch {
+	case expectFailure && isFailureStatus(status):
+		return StatusExpectedFailure
+	case status == StatusPassed && e.Status != StatusPassed:
+		return StatusUnexpectedPass
+	}
+	return status
+}
+
+// originalStatus undoes a previous application of expectations.
+func originalStatus(status string) string {
+	switch status {
+	case StatusExpectedFailure:
+		return StatusFailed
+	case StatusUnexpectedPass:
+		return StatusPassed
+	}
+	return status
+}
@@ -0,0 +1,209 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+)
+
+func TestReadExpectations(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		input     string
+		expect    []config.Expectation
+		expectErr string
+	}{
+		{
+			desc: "YAML",
+			input: `expectations:
+- test: \[sig-network\] Services should serve endpoints on same port
+  reason: https://github.com/example/issues/1
+  expires: "2021-12-31"
+- test: Pods
+  plugin: e2e
+  status: skipped
+  reason: no windows nodes
+`,
+			expect: []config.Expectation{
+				{Test: `\[sig-network\] Services should serve endpoints on same port`, Reason: "https://github.com/example/issues/1", Expires: "2021-12-31"},
+				{Test: "Pods", Plugin: "e2e", Status: StatusSkipped, Reason: "no windows nodes"},
+			},
+		}, {
+			desc:   "JSON",
+			input:  `{"expectations":[{"test":"a","reason":"b"}]}`,
+			expect: []config.Expectation{{Test: "a", Reason: "b"}},
+		}, {
+			desc:  "Empty",
+			input: "",
+		}, {
+			desc:      "Missing test",
+			input:     "expectations:\n- reason: why\n",
+			expectErr: "expectation 1: test is required",
+		}, {
+			desc:      "Invalid regexp",
+			input:     "expectations:\n- test: a\n- test: '['\n",
+			expectErr: "expectation 2: invalid test regexp",
+		}, {
+			desc:      "Invalid expiry",
+			input:     "expectations:\n- test: a\n  expires: 12/31/2021\n",
+			expectErr: "expectation 1: expires must be a date formatted as YYYY-MM-DD",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			expectations, err := ReadExpectations(strings.NewReader(tc.input))
+			switch {
+			case err != nil && tc.expectErr == "":
+				t.Fatalf("Unexpected error: %v", err)
+			case err == nil && tc.expectErr != "":
+				t.Fatalf("Expected error %q but got nil", tc.expectErr)
+			case err != nil && !strings.HasPrefix(err.Error(), tc.expectErr):
+				t.Fatalf("Expected error %q but got %q", tc.expectErr, err)
+			}
+			if diff := pretty.Compare(expectations, tc.expect); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestApplyExpectations(t *testing.T) {
+	now := time.Date(2021, 11, 15, 12, 0, 0, 0, time.UTC)
+	newResults := func() Item {
+		return Item{
+			Name:   "e2e",
+			Status: StatusFailed,
+			Items: []Item{{
+				Name:   "junit_01.xml",
+				Status: StatusFailed,
+				Items: []Item{
+					{Name: "known broken", Status: StatusFailed},
+					{Name: "known broken but fixed", Status: StatusPassed},
+					{Name: "timed out", Status: StatusTimeout},
+					{Name: "new failure", Status: StatusFailed},
+					{Name: "skipped", Status: StatusSkipped},
+				},
+			}},
+		}
+	}
+	statuses := func(item Item) string {
+		var s []string
+		walkTests(&item, func(_ string, _ []string, leaf *Item) {
+			s = append(s, fmt.Sprintf("%v=%v", leaf.Name, leaf.Status))
+		})
+		return fmt.Sprintf("%v %v", item.Status, strings.Join(s, ","))
+	}
+
+	tcs := []struct {
+		desc         string
+		plugin       string
+		expectations []config.Expectation
+		expect       string
+	}{
+		{
+			desc:         "No expectations",
+			plugin:       "e2e",
+			expectations: nil,
+			expect:       "failed known broken=failed,known broken but fixed=passed,timed out=timeout,new failure=failed,skipped=skipped",
+		}, {
+			desc:         "Only unexpected failures fail the plugin",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: "^known broken", Reason: "bug"}, {Test: "timed out", Reason: "slow"}},
+			expect:       "failed known broken=expected-failure,known broken but fixed=unexpected-pass,timed out=expected-failure,new failure=failed,skipped=skipped",
+		}, {
+			desc:         "Plugin passes when every failure is expected",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: "broken|timed out|new failure", Reason: "bug"}},
+			expect:       "passed known broken=expected-failure,known broken but fixed=unexpected-pass,timed out=expected-failure,new failure=expected-failure,skipped=skipped",
+		}, {
+			desc:         "Expired expectations no longer apply",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: "broken|timed out|new failure", Reason: "bug", Expires: "2021-11-14"}},
+			expect:       "failed known broken=failed,known broken but fixed=passed,timed out=timeout,new failure=failed,skipped=skipped",
+		}, {
+			desc:         "Expectations apply on the day they expire",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: "broken|timed out|new failure", Reason: "bug", Expires: "2021-11-15"}},
+			expect:       "passed known broken=expected-failure,known broken but fixed=unexpected-pass,timed out=expected-failure,new failure=expected-failure,skipped=skipped",
+		}, {
+			desc:         "Expectations for other plugins are ignored",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: ".*", Plugin: "other", Reason: "bug"}},
+			expect:       "failed known broken=failed,known broken but fixed=passed,timed out=timeout,new failure=failed,skipped=skipped",
+		}, {
+			desc:         "Other expected statuses",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: "skipped|new failure|fixed", Status: StatusSkipped, Reason: "no windows nodes"}},
+			expect:       "failed known broken=failed,known broken but fixed=unexpected-pass,timed out=timeout,new failure=failed,skipped=skipped",
+		}, {
+			desc:         "First matching expectation wins",
+			plugin:       "e2e",
+			expectations: []config.Expectation{{Test: "new failure", Status: StatusPassed, Reason: "should pass"}, {Test: ".*", Reason: "bug"}},
+			expect:       "failed known broken=expected-failure,known broken but fixed=unexpected-pass,timed out=expected-failure,new failure=failed,skipped=skipped",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			item := newResults()
+			if err := ApplyExpectations(&item, tc.plugin, tc.expectations, now); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out := statuses(item); out != tc.expect {
+				t.Errorf("Expected\n%v\nbut got\n%v", tc.expect, out)
+			}
+
+			// Applying the expectations again, e.g. when reading results which already had them
+			// applied by the aggregator, shouldn't change anything.
+			if err := ApplyExpectations(&item, tc.plugin, tc.expectations, now); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out := statuses(item); out != tc.expect {
+				t.Errorf("Expected expectations to be idempotent but got\n%v", out)
+			}
+		})
+	}
+}
+
+func TestApplyExpectationsDetails(t *testing.T) {
+	now := time.Date(2021, 11, 15, 12, 0, 0, 0, time.UTC)
+	item := Item{Name: "e2e", Items: []Item{
+		{Name: "a", Status: StatusFailed},
+		{Name: "b", Status: StatusFailed, Details: map[string]interface{}{JUnitFailureKey: "oops"}},
+	}}
+	expectations := []config.Expectation{
+		{Test: "a", Reason: "issue 1"},
+		{Test: "b", Reason: "issue 2", Expires: "2021-11-01"},
+	}
+	if err := ApplyExpectations(&item, "e2e", expectations, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expect := Item{Name: "e2e", Status: StatusFailed, Items: []Item{
+		{Name: "a", Status: StatusExpectedFailure, Details: map[string]interface{}{ExpectationKey: "issue 1"}},
+		{Name: "b", Status: StatusFailed, Details: map[string]interface{}{JUnitFailureKey: "oops", ExpectationKey: "expired 2021-11-01: issue 2"}},
+	}}
+	if diff := pretty.Compare(item, expect); diff != "" {
+		t.Errorf("\n\n%s\n", diff)
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExportFormatJUnit writes the tests of every plugin as JUnit XML, with a test suite for each
+	// plugin, node and result file.
+	ExportFormatJUnit = "junit"
+
+	// ExportFormatJSONLines writes each test as a JSON object on its own line.
+	ExportFormatJSONLines = "jsonl"
+
+	// ExportFormatMarkdown writes a summary of each plugin followed by its failed tests, suitable
+	// for e.g. GitHub job summaries.
+	ExportFormatMarkdown = "markdown"
+
+	// ExportFormatCSV writes each test as a row of comma-separated values, with a header row.
+	ExportFormatCSV = "csv"
+)
+
+// ExportFormats are the formats supported by Export.
+var ExportFormats = []string{ExportFormatJUnit, ExportFormatJSONLines, ExportFormatMarkdown, ExportFormatCSV}
+
+// exportCSVHeader are the columns written in the CSV format.
+var exportCSVHeader = []string{"plugin", "node", "file", "name", "status", "duration", "failure"}
+
+// ExportedTest is a single test along with the result file it was read from and everything it
+// reported.
+type ExportedTest struct {
+	TestResult
+	File     string                 `json:"file,omitempty"`
+	Metadata map[string]string      `json:"meta,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// Suite is the name of the JUnit test suite of the test, e.g. e2e/global/junit_01.xml.
+func (t ExportedTest) Suite() string {
+	return path.Join(t.Plugin, t.Node, t.File)
+}
+
+// Duration is how long the test took, if its results say.
+func (t ExportedTest) Duration() (time.Duration, bool) {
+	d, err := time.ParseDuration(t.Metadata[metadataDurationKey])
+	return d, err == nil
+}
+
+// ExportTests returns every test in the results of a plugin in the order they appear. Unlike
+// Tests, tests reported more than once are all included.
+func ExportTests(pluginName string, item *Item) []ExportedTest {
+	var tests []ExportedTest
+	walkTestsInFiles(item, func(node, file string, names []string, leaf *Item) {
+		status := leaf.Status
+		if status == "" {
+			status = StatusUnknown
+		}
+		tests = append(tests, ExportedTest{
+			TestResult: TestResult{
+				Plugin: pluginName,
+				Node:   node,
+				Name:   strings.Join(append(names, leaf.Name), testPathSeparator),
+				Status: status,
+			},
+			File:     file,
+			Metadata: leaf.Metadata,
+			Details:  leaf.Details,
+		})
+	})
+	return tests
+}
+
+// Export writes the results of the plugins, keyed by plugin name, in one of the ExportFormats.
+// Plugins are written in alphabetical order.
+func Export(w io.Writer, format string, plugins map[string]*Item) error {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tests []ExportedTest
+	for _, name := range names {
+		tests = append(tests, ExportTests(name, plugins[name])...)
+	}
+
+	switch format {
+	case ExportFormatJUnit:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(ExportJUnit(tests)); err != nil {
+			return errors.Wrap(err, "could not encode results as junit")
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	case ExportFormatJSONLines:
+		enc := json.NewEncoder(w)
+		for _, t := range tests {
+			if err := enc.Encode(t); err != nil {
+				return errors.Wrapf(err, "could not encode test %q as json", t.Name)
+			}
+		}
+		return nil
+	case ExportFormatMarkdown:
+		return exportMarkdown(w, names, plugins, tests)
+	case ExportFormatCSV:
+		return exportCSV(w, tests)
+	default:
+		return fmt.Errorf("unknown export format %q, valid options are %v", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+// ExportJUnit returns the tests as JUnit test suites named after their plugin, node and result
+// file so that the results of any plugin can be read by tools which understand JUnit. Failed and
+// timed out tests are failures, passed (and unexpectedly passed) tests pass and any other status,
+// such as skipped or expected-failure, is reported as skipped.
+func ExportJUnit(tests []ExportedTest) JUnitTestSuites {
+	suites := JUnitTestSuites{}
+	for _, t := range tests {
+		suiteName := t.Suite()
+		if len(suites.Suites) == 0 || suites.Suites[len(suites.Suites)-1].Name != suiteName {
+			suites.Suites = append(suites.Suites, JUnitTestSuite{Name: suiteName})
+		}
+		suite := &suites.Suites[len(suites.Suites)-1]
+
+		tc := JUnitTestCase{
+			Classname: suiteName,
+			Name:      t.Name,
+			SystemOut: detailString(t.Details, JUnitStdoutKey),
+			SystemErr: detailString(t.Details, JUnitStderrKey),
+		}
+		if d, ok := t.Duration(); ok {
+			tc.Time = fmt.Sprintf("%.3f", d.Seconds())
+			suite.Time += d.Seconds()
+		}
+		switch {
+		case isFailureStatus(t.Status):
+			suite.Failures++
+			failure := detailString(t.Details, JUnitFailureKey)
+			tc.Failure = &JUnitFailureMessage{Message: firstLine(failure), Type: t.Status, Contents: failure}
+			if failure == "" {
+				tc.Failure.Message = t.Status
+			}
+		case t.Status == StatusPassed, t.Status == StatusUnexpectedPass:
+		default:
+			tc.SkipMessage = &JUnitSkipMessage{Message: t.Status}
+			if reason := detailString(t.Details, ExpectationKey); reason != "" {
+				tc.SkipMessage.Message = fmt.Sprintf("%v: %v", t.Status, reason)
+			}
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	return suites
+}
+
+func exportCSV(w io.Writer, tests []ExportedTest) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return errors.Wrap(err, "could not write csv header")
+	}
+	for _, t := range tests {
+		duration := ""
+		if d, ok := t.Duration(); ok {
+			duration = fmt.Sprintf("%.3f", d.Seconds())
+		}
+		row := []string{t.Plugin, t.Node, t.File, t.Name, t.Status, duration, detailString(t.Details, JUnitFailureKey)}
+		if err := cw.Write(row); err != nil {
+			return errors.Wrapf(err, "could not write test %q as csv", t.Name)
+		}
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "could not write csv")
+}
+
+// exportMarkdown writes a table summarizing each plugin followed by the failures of each one.
+// Failures are written as HTML details elements, which GitHub and GitLab render collapsed, since
+// test names and failure messages often contain characters which would break a table.
+func exportMarkdown(w io.Writer, names []string, plugins map[string]*Item, tests []ExportedTest) error {
+	type summary struct {
+		passed, failed, skipped, other int
+		failures                       []ExportedTest
+	}
+	summaries := map[string]*summary{}
+	for _, name := range names {
+		summaries[name] = &summary{}
+	}
+	for _, t := range tests {
+		s := summaries[t.Plugin]
+		switch {
+		case t.Status == StatusPassed:
+			s.passed++
+		case isFailureStatus(t.Status):
+			s.failed++
+			s.failures = append(s.failures, t)
+		case t.Status == StatusSkipped:
+			s.skipped++
+		default:
+			s.other++
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("| Plugin | Status | Passed | Failed | Skipped | Other |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, name := range names {
+		s := summaries[name]
+		fmt.Fprintf(&b, "| %v | %v | %v | %v | %v | %v |\n",
+			markdownCell(name), markdownCell(plugins[name].Status), s.passed, s.failed, s.skipped, s.other)
+	}
+
+	for _, name := range names {
+		s := summaries[name]
+		if len(s.failures) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### Failed tests: %v\n", markdownCell(name))
+		for _, t := range s.failures {
+			fmt.Fprintf(&b, "\n<details>\n<summary>%v</summary>\n\n", html.EscapeString(fmt.Sprintf("%v: %v", t.Node, t.Name)))
+			failure := detailString(t.Details, JUnitFailureKey)
+			if failure == "" {
+				failure = t.Status
+			}
+			fmt.Fprintf(&b, "<pre>%v</pre>\n\n</details>\n", html.EscapeString(failure))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes the characters which would break a markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
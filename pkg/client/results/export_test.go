@@ -0,0 +1,179 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// exportTestPlugins are the results of a Job plugin with junit results, a DaemonSet plugin with
+// manual results and a DaemonSet plugin with raw results.
+func exportTestPlugins() map[string]*Item {
+	fileMeta := func(file string) map[string]string {
+		return map[string]string{metadataFileKey: file, metadataTypeKey: metadataTypeFile}
+	}
+	nodeMeta := map[string]string{metadataTypeKey: metadataTypeNode}
+	return map[string]*Item{
+		"e2e": {Name: "e2e", Status: StatusFailed, Items: []Item{{
+			Name: "junit_01.xml", Status: StatusFailed, Metadata: fileMeta("results/global/junit_01.xml"),
+			Items: []Item{{
+				Name: "Kubernetes e2e suite", Status: StatusFailed,
+				Items: []Item{
+					{Name: "a", Status: StatusPassed, Metadata: map[string]string{metadataDurationKey: "1.5s"}},
+					{Name: "b", Status: StatusFailed, Details: map[string]interface{}{JUnitFailureKey: "oops\nat b.go:12", JUnitStdoutKey: "out"}},
+					{Name: "c", Status: StatusSkipped},
+					{Name: "d", Status: StatusExpectedFailure, Details: map[string]interface{}{ExpectationKey: "bug 1"}},
+				},
+			}},
+		}}},
+		"manual": {Name: "manual", Status: StatusPassed, Items: []Item{{
+			Name: "node1", Status: StatusPassed, Metadata: nodeMeta,
+			Items: []Item{{
+				Name: "results.yaml", Status: StatusPassed, Metadata: fileMeta("results/node1/results.yaml"),
+				Items: []Item{{Name: "check, with comma", Status: "custom"}},
+			}},
+		}}},
+		"systemd-logs": {Name: "systemd-logs", Status: StatusPassed, Items: []Item{{
+			Name: "node1", Status: StatusPassed, Metadata: nodeMeta,
+			Items: []Item{{Name: "node1.json", Status: StatusPassed, Metadata: fileMeta("results/node1/node1.json")}},
+		}}},
+	}
+}
+
+func TestExportTests(t *testing.T) {
+	plugins := exportTestPlugins()
+	var out []string
+	for _, name := range []string{"e2e", "manual", "systemd-logs"} {
+		for _, test := range ExportTests(name, plugins[name]) {
+			out = append(out, test.Suite()+" "+test.Name+"="+test.Status)
+		}
+	}
+
+	expected := []string{
+		"e2e/global/junit_01.xml Kubernetes e2e suite|a=passed",
+		"e2e/global/junit_01.xml Kubernetes e2e suite|b=failed",
+		"e2e/global/junit_01.xml Kubernetes e2e suite|c=skipped",
+		"e2e/global/junit_01.xml Kubernetes e2e suite|d=expected-failure",
+		"manual/node1/results.yaml check, with comma=custom",
+		"systemd-logs/node1 node1.json=passed",
+	}
+	if diff := pretty.Compare(out, expected); diff != "" {
+		t.Errorf("\n\n%s\n", diff)
+	}
+}
+
+func TestExport(t *testing.T) {
+	tcs := []struct {
+		format    string
+		expected  string
+		expectErr string
+	}{
+		{
+			format: ExportFormatJUnit,
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite tests="4" failures="1" time="1.5" name="e2e/global/junit_01.xml">
+    <properties></properties>
+    <testcase classname="e2e/global/junit_01.xml" name="Kubernetes e2e suite|a" time="1.500"></testcase>
+    <testcase classname="e2e/global/junit_01.xml" name="Kubernetes e2e suite|b" time="">
+      <failure message="oops" type="failed">oops&#xA;at b.go:12</failure>
+      <system-out>out</system-out>
+    </testcase>
+    <testcase classname="e2e/global/junit_01.xml" name="Kubernetes e2e suite|c" time="">
+      <skipped message="skipped"></skipped>
+    </testcase>
+    <testcase classname="e2e/global/junit_01.xml" name="Kubernetes e2e suite|d" time="">
+      <skipped message="expected-failure: bug 1"></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite tests="1" failures="0" time="0" name="manual/node1/results.yaml">
+    <properties></properties>
+    <testcase classname="manual/node1/results.yaml" name="check, with comma" time="">
+      <skipped message="custom"></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite tests="1" failures="0" time="0" name="systemd-logs/node1">
+    <properties></properties>
+    <testcase classname="systemd-logs/node1" name="node1.json" time=""></testcase>
+  </testsuite>
+</testsuites>
+`,
+		}, {
+			format: ExportFormatJSONLines,
+			expected: `{"plugin":"e2e","node":"global","name":"Kubernetes e2e suite|a","status":"passed","file":"junit_01.xml","meta":{"duration":"1.5s"}}
+{"plugin":"e2e","node":"global","name":"Kubernetes e2e suite|b","status":"failed","file":"junit_01.xml","details":{"failure":"oops\nat b.go:12","system-out":"out"}}
+{"plugin":"e2e","node":"global","name":"Kubernetes e2e suite|c","status":"skipped","file":"junit_01.xml"}
+{"plugin":"e2e","node":"global","name":"Kubernetes e2e suite|d","status":"expected-failure","file":"junit_01.xml","details":{"expectation":"bug 1"}}
+{"plugin":"manual","node":"node1","name":"check, with comma","status":"custom","file":"results.yaml"}
+{"plugin":"systemd-logs","node":"node1","name":"node1.json","status":"passed","meta":{"file":"results/node1/node1.json","type":"file"}}
+`,
+		}, {
+			format: ExportFormatCSV,
+			expected: `plugin,node,file,name,status,duration,failure
+e2e,global,junit_01.xml,Kubernetes e2e suite|a,passed,1.500,
+e2e,global,junit_01.xml,Kubernetes e2e suite|b,failed,,"oops
+at b.go:12"
+e2e,global,junit_01.xml,Kubernetes e2e suite|c,skipped,,
+e2e,global,junit_01.xml,Kubernetes e2e suite|d,expected-failure,,
+manual,node1,results.yaml,"check, with comma",custom,,
+systemd-logs,node1,,node1.json,passed,,
+`,
+		}, {
+			format: ExportFormatMarkdown,
+			expected: `| Plugin | Status | Passed | Failed | Skipped | Other |
+| --- | --- | --- | --- | --- | --- |
+| e2e | failed | 1 | 1 | 1 | 1 |
+| manual | passed | 0 | 0 | 0 | 1 |
+| systemd-logs | passed | 1 | 0 | 0 | 0 |
+
+### Failed tests: e2e
+
+<details>
+<summary>global: Kubernetes e2e suite|b</summary>
+
+<pre>oops
+at b.go:12</pre>
+
+</details>
+`,
+		}, {
+			format:    "xml",
+			expectErr: `unknown export format "xml", valid options are junit, jsonl, markdown, csv`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.format, func(t *testing.T) {
+			var out strings.Builder
+			err := Export(&out, tc.format, exportTestPlugins())
+			switch {
+			case err != nil && tc.expectErr == "":
+				t.Fatalf("Unexpected error: %v", err)
+			case err == nil && tc.expectErr != "":
+				t.Fatalf("Expected error %q but got nil", tc.expectErr)
+			case err != nil && err.Error() != tc.expectErr:
+				t.Fatalf("Expected error %q but got %q", tc.expectErr, err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Expected output:\n%v\nbut got:\n%v", tc.expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+)
+
+// defaultResultProcessorTimeout is how long a result processor may run if its manifest doesn't say.
+const defaultResultProcessorTimeout = 5 * time.Minute
+
+// resultProcessorGetter is implemented by plugins which may name a command to process their results.
+type resultProcessorGetter interface {
+	GetResultProcessor() *manifest.ResultProcessor
+}
+
+// externalSelector selects the directories holding the raw results of each node (or the global
+// results of a Job plugin) since external processors are given a whole directory rather than a file.
+func externalSelector(resultsDir string) fileSelector {
+	return func(fPath string, info os.FileInfo) bool {
+		if info == nil || !info.IsDir() {
+			return false
+		}
+		return filepath.Clean(filepath.Dir(fPath)) == filepath.Clean(resultsDir) && !isAttemptDir(info.Name())
+	}
+}
+
+// externalProcessor returns a postProcessor which runs the command of the result processor on a
+// results directory. If there is no command, the plugin is expected to have processed its own
+// results (e.g. in a sidecar container) and written them to PostProcessedResultsFile in that
+// directory. Either way the results are validated and, if anything goes wrong, replaced by a
+// failed item which explains why.
+func externalProcessor(rp *manifest.ResultProcessor) postProcessor {
+	return func(pluginDir, currentDir string) (Item, error) {
+		relPath, err := filepath.Rel(pluginDir, currentDir)
+		if err != nil {
+			logrus.Errorf("Error making path %q relative to %q: %v", pluginDir, currentDir, err)
+			relPath = currentDir
+		}
+
+		var output []byte
+		var stderr string
+		name := PostProcessedResultsFile
+		if rp == nil || len(rp.Command) == 0 {
+			output, err = os.ReadFile(filepath.Join(currentDir, PostProcessedResultsFile))
+			err = errors.Wrap(err, "no result processor command is set and the plugin did not write its results")
+		} else {
+			name = filepath.Base(rp.Command[0])
+			output, stderr, err = runResultProcessor(rp, currentDir)
+		}
+
+		resultObj := Item{
+			Name:   name,
+			Status: StatusFailed,
+			Metadata: map[string]string{
+				metadataFileKey: relPath,
+				metadataTypeKey: metadataTypeFile,
+			},
+		}
+		if stderr != "" {
+			resultObj.Details = map[string]interface{}{JUnitStderrKey: stderr}
+		}
+		if err == nil {
+			var processed Item
+			processed, err = externalProcessReader(bytes.NewReader(output))
+			if err == nil {
+				resultObj.Status = processed.Status
+				resultObj.Items = processed.Items
+				resultObj.Details = processed.Details
+				return resultObj, nil
+			}
+		}
+
+		resultObj.Metadata["error"] = err.Error()
+		return resultObj, errors.Wrapf(err, "processing results in %v", currentDir)
+	}
+}
+
+// runResultProcessor runs the command with the results directory as its last argument and
+// returns what it wrote to stdout and stderr.
+func runResultProcessor(rp *manifest.ResultProcessor, dir string) ([]byte, string, error) {
+	timeout := defaultResultProcessorTimeout
+	if rp.Timeout != nil && rp.Timeout.Duration > 0 {
+		timeout = rp.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, rp.Command[0], append(append([]string{}, rp.Command[1:]...), dir)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("result processor %q timed out after %v", strings.Join(rp.Command, " "), timeout)
+	case err != nil:
+		err = errors.Wrapf(err, "running result processor %q", strings.Join(rp.Command, " "))
+	}
+	return stdout.Bytes(), stderr.String(), err
+}
+
+// externalProcessReader decodes the results written by an external processor. Unlike manual
+// results, unknown fields are an error since they are most likely a mistake in the processor.
+func externalProcessReader(r io.Reader) (Item, error) {
+	item := Item{}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&item); err != nil {
+		if err == io.EOF {
+			return item, errors.New("result processor wrote no results")
+		}
+		return item, errors.Wrap(err, "failed to parse results written by result processor")
+	}
+	if err := validateItem(item, "results"); err != nil {
+		return item, errors.Wrap(err, "invalid results written by result processor")
+	}
+	return item, nil
+}
+
+// validateItem checks that every item in the tree has a name and status. The path is used to
+// say which item is invalid, e.g. results.items[2].
+func validateItem(item Item, path string) error {
+	if item.Name == "" {
+		return fmt.Errorf("%v: name is required", path)
+	}
+	if item.Status == "" {
+		return fmt.Errorf("%v (%v): status is required", path, item.Name)
+	}
+	for i, child := range item.Items {
+		if err := validateItem(child, fmt.Sprintf("%v.items[%v]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+)
+
+func TestExternalProcessor(t *testing.T) {
+	const validResults = `name: suite
+status: failed
+items:
+- name: a
+  status: passed
+- name: b
+  status: failed
+  details:
+    failure: oops
+`
+
+	// The results directory is appended to the command so it is $0 of the script.
+	shell := func(script string) *manifest.ResultProcessor {
+		return &manifest.ResultProcessor{Command: []string{"/bin/sh", "-c", script}}
+	}
+
+	tcs := []struct {
+		desc      string
+		processor *manifest.ResultProcessor
+		files     map[string]string
+		expect    Item
+		expectErr string
+	}{
+		{
+			desc:      "Command output is used as the results",
+			processor: shell(`cat "$0/raw.txt"`),
+			files:     map[string]string{"raw.txt": validResults},
+			expect: Item{
+				Name:     "sh",
+				Status:   StatusFailed,
+				Metadata: map[string]string{metadataFileKey: "results/global", metadataTypeKey: metadataTypeFile},
+				Items: []Item{
+					{Name: "a", Status: StatusPassed},
+					{Name: "b", Status: StatusFailed, Details: map[string]interface{}{"failure": "oops"}},
+				},
+			},
+		}, {
+			desc:      "Command which fails",
+			processor: shell(`echo "unexpected input" >&2; exit 3`),
+			expect: Item{
+				Name:   "sh",
+				Status: StatusFailed,
+				Metadata: map[string]string{
+					metadataFileKey: "results/global",
+					metadataTypeKey: metadataTypeFile,
+					"error":         `running result processor "/bin/sh -c echo \"unexpected input\" >&2; exit 3": exit status 3`,
+				},
+				Details: map[string]interface{}{JUnitStderrKey: "unexpected input\n"},
+			},
+			expectErr: "exit status 3",
+		}, {
+			desc:      "Command which times out",
+			processor: &manifest.ResultProcessor{Command: []string{"/bin/sh", "-c", "exec sleep 10"}, Timeout: &metav1.Duration{Duration: 100 * time.Millisecond}},
+			expectErr: "timed out after 100ms",
+		}, {
+			desc:      "Command which writes nothing",
+			processor: shell("true"),
+			expectErr: "result processor wrote no results",
+		}, {
+			desc:      "Results missing a status",
+			processor: shell(`printf 'name: suite\nstatus: passed\nitems:\n- name: a\n  items:\n  - name: b\n'`),
+			expectErr: "invalid results written by result processor: results.items[0] (a): status is required",
+		}, {
+			desc:      "Results with unknown fields",
+			processor: shell(`printf 'name: suite\nstate: passed\n'`),
+			expectErr: "field state not found in type results.Item",
+		}, {
+			desc:  "Results written by the plugin when there is no command",
+			files: map[string]string{PostProcessedResultsFile: validResults},
+			expect: Item{
+				Name:     PostProcessedResultsFile,
+				Status:   StatusFailed,
+				Metadata: map[string]string{metadataFileKey: "results/global", metadataTypeKey: metadataTypeFile},
+				Items: []Item{
+					{Name: "a", Status: StatusPassed},
+					{Name: "b", Status: StatusFailed, Details: map[string]interface{}{"failure": "oops"}},
+				},
+			},
+		}, {
+			desc:      "No results written by the plugin when there is no command",
+			expectErr: "no result processor command is set and the plugin did not write its results",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			pluginDir := t.TempDir()
+			dir := filepath.Join(pluginDir, "results", "global")
+			writeTestFiles(t, dir, tc.files)
+
+			item, err := externalProcessor(tc.processor)(pluginDir, dir)
+			switch {
+			case err == nil && tc.expectErr != "":
+				t.Fatalf("Expected error containing %q but got nil", tc.expectErr)
+			case err != nil && tc.expectErr == "":
+				t.Fatalf("Unexpected error: %v", err)
+			case err != nil && !strings.Contains(err.Error(), tc.expectErr):
+				t.Fatalf("Expected error containing %q but got %q", tc.expectErr, err)
+			}
+
+			if err != nil {
+				if item.Status != StatusFailed || item.Metadata["error"] == "" {
+					t.Errorf("Expected a failed item explaining the error but got %+v", item)
+				}
+				if tc.expect.Name == "" {
+					return
+				}
+			}
+			if diff := pretty.Compare(item, tc.expect); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to make dir: %v", err)
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
+
+func TestExternalSelector(t *testing.T) {
+	resultsDir := t.TempDir()
+	for _, dir := range []string{"global/nested", "node1", "attempt-1"} {
+		writeTestFiles(t, filepath.Join(resultsDir, dir), map[string]string{"out.txt": "x"})
+	}
+
+	var selected []string
+	selector := externalSelector(resultsDir)
+	err := filepath.Walk(resultsDir, func(p string, info os.FileInfo, err error) error {
+		if selector(p, info) {
+			rel, _ := filepath.Rel(resultsDir, p)
+			selected = append(selected, rel)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fmt.Sprint(selected) != "[global node1]" {
+		t.Errorf("Expected only the node directories to be selected but got %v", selected)
+	}
+}
@@ -0,0 +1,422 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+const (
+	filterMetaPrefix    = "meta."
+	filterDetailsPrefix = "details."
+)
+
+// Filter selects tests from the results of a plugin. It is parsed from an expression such as
+//
+//	status=failed and name~"\[sig-network\]" and meta.node=worker-*
+//
+// made of comparisons joined by and, or and not, with parentheses for grouping. Each comparison
+// is a field, an operator and a value. The fields are:
+//   - plugin, node and file: the plugin, the node the test ran on and the result file it was
+//     read from;
+//   - name: the name of the test;
+//   - path: the names of the items leading to the test and its own, joined by "|";
+//   - status: the status of the test;
+//   - meta.<key> and details.<key>: a value in the metadata or details of the test. The plugin,
+//     node and file are also available as meta.plugin, meta.node and meta.file unless the metadata
+//     of the test says otherwise.
+//
+// The operators are = and != which compare against a glob where * matches any characters and ?
+// matches a single one, and ~ and !~ which search with a regular expression. Values which contain
+// spaces, parentheses, operators or quotes must be quoted with ". Within quotes, \" is a quote
+// and \\ a backslash; other backslashes are kept so regular expressions don't need escaping twice.
+// Missing fields are empty.
+type Filter struct {
+	expr filterExpr
+	src  string
+}
+
+// filterTest is a test along with where it ran, which is what a filter is evaluated against.
+type filterTest struct {
+	plugin, node, file string
+	names              []string
+	leaf               *Item
+}
+
+func (t filterTest) field(name string) string {
+	switch {
+	case name == "plugin":
+		return t.plugin
+	case name == "node":
+		return t.node
+	case name == "file":
+		return t.file
+	case name == "name":
+		return t.leaf.Name
+	case name == "path":
+		return strings.Join(append(t.names, t.leaf.Name), testPathSeparator)
+	case name == "status":
+		if t.leaf.Status == "" {
+			return StatusUnknown
+		}
+		return t.leaf.Status
+	case strings.HasPrefix(name, filterDetailsPrefix):
+		return detailString(t.leaf.Details, strings.TrimPrefix(name, filterDetailsPrefix))
+	case strings.HasPrefix(name, filterMetaPrefix):
+		key := strings.TrimPrefix(name, filterMetaPrefix)
+		if v, ok := t.leaf.Metadata[key]; ok {
+			return v
+		}
+		switch key {
+		case "plugin", "node", "file":
+			return t.field(key)
+		}
+	}
+	return ""
+}
+
+// validFilterField returns true if the name is one of the fields a filter can compare.
+func validFilterField(name string) bool {
+	switch name {
+	case "plugin", "node", "file", "name", "path", "status":
+		return true
+	}
+	for _, prefix := range []string{filterMetaPrefix, filterDetailsPrefix} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFilter parses a filter expression.
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid filter %q", expr)
+	}
+	p := &filterParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err == nil && !p.done() {
+		err = fmt.Errorf("unexpected %v", p.peek())
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid filter %q", expr)
+	}
+	return &Filter{expr: e, src: expr}, nil
+}
+
+// String returns the expression the filter was parsed from.
+func (f *Filter) String() string {
+	return f.src
+}
+
+// Apply returns a copy of the results of the plugin with only the tests which match the filter,
+// dropping any items left without tests. The statuses of the remaining items are recomputed from
+// the tests they still contain. If no tests match, nil is returned.
+func (f *Filter) Apply(pluginName string, item *Item) *Item {
+	if item == nil {
+		return nil
+	}
+	root := *item
+	root.Items = nil
+	for i := range item.Items {
+		if child, ok := f.filterItem(&item.Items[i], filterTest{plugin: pluginName, node: plugin.GlobalResult}); ok {
+			root.Items = append(root.Items, child)
+		}
+	}
+	if len(root.Items) == 0 {
+		return nil
+	}
+	root.Status = aggregateStatus(root.Items...)
+	return &root
+}
+
+// filterItem returns a copy of the item with only the tests which match the filter and false if
+// there are none.
+func (f *Filter) filterItem(item *Item, t filterTest) (Item, bool) {
+	if len(item.Items) == 0 {
+		t.leaf = item
+		return *item, f.expr.eval(t)
+	}
+
+	t.node, t.file, t.names = testPath(item, t.node, t.file, t.names)
+	filtered := *item
+	filtered.Items = nil
+	for i := range item.Items {
+		if child, ok := f.filterItem(&item.Items[i], t); ok {
+			filtered.Items = append(filtered.Items, child)
+		}
+	}
+	return filtered, len(filtered.Items) > 0
+}
+
+// filterExpr is a node of a parsed filter expression.
+type filterExpr interface {
+	eval(t filterTest) bool
+}
+
+type filterAnd struct{ left, right filterExpr }
+
+func (e filterAnd) eval(t filterTest) bool { return e.left.eval(t) && e.right.eval(t) }
+
+type filterOr struct{ left, right filterExpr }
+
+func (e filterOr) eval(t filterTest) bool { return e.left.eval(t) || e.right.eval(t) }
+
+type filterNot struct{ expr filterExpr }
+
+func (e filterNot) eval(t filterTest) bool { return !e.expr.eval(t) }
+
+// filterComparison matches a field against a glob or regular expression, compiled into re.
+type filterComparison struct {
+	field  string
+	negate bool
+	re     *regexp.Regexp
+}
+
+func (e filterComparison) eval(t filterTest) bool {
+	return e.re.MatchString(t.field(e.field)) != e.negate
+}
+
+// globToRegexp converts a glob, where * matches any characters and ? a single one, into an
+// anchored regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenWord filterTokenKind = iota
+	filterTokenString
+	filterTokenOp
+	filterTokenLParen
+	filterTokenRParen
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	value string
+	pos   int
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case filterTokenString:
+		return fmt.Sprintf("string %q at position %v", t.value, t.pos+1)
+	default:
+		return fmt.Sprintf("%q at position %v", t.value, t.pos+1)
+	}
+}
+
+// isKeyword returns true if the token is the given (case-insensitive) keyword.
+func (t filterToken) isKeyword(keyword string) bool {
+	return t.kind == filterTokenWord && strings.EqualFold(t.value, keyword)
+}
+
+// filterOperators are the comparison operators, longest first so that they are lexed greedily.
+var filterOperators = []string{"!=", "!~", "=", "~"}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenLParen, value: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenRParen, value: ")", pos: i})
+			i++
+		case c == '"':
+			var b strings.Builder
+			start := i
+			for i++; ; i++ {
+				if i >= len(s) {
+					return nil, fmt.Errorf("unterminated string at position %v", start+1)
+				}
+				if s[i] == '"' {
+					i++
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, value: b.String(), pos: start})
+		case c == '=' || c == '!' || c == '~':
+			op := ""
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at position %v", c, i+1)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenOp, value: op, pos: i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n()\"=!~", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenWord, value: s[start:i], pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser of the grammar
+//
+//	or         = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | "(" or ")" | comparison
+//	comparison = field operator value
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *filterParser) peek() filterToken { return p.tokens[p.pos] }
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.done() {
+		return filterToken{}, errors.New("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && p.peek().isKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && p.peek().isKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t.isKeyword("not"):
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{expr: e}, nil
+	case t.kind == filterTokenLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.next()
+		if err != nil {
+			return nil, errors.New("missing )")
+		}
+		if closing.kind != filterTokenRParen {
+			return nil, fmt.Errorf("expected ) but got %v", closing)
+		}
+		return e, nil
+	case t.kind == filterTokenWord:
+		return p.parseComparison(t)
+	default:
+		return nil, fmt.Errorf("unexpected %v", t)
+	}
+}
+
+func (p *filterParser) parseComparison(field filterToken) (filterExpr, error) {
+	if !validFilterField(field.value) {
+		return nil, fmt.Errorf("unknown field %v", field)
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if op.kind != filterTokenOp {
+		return nil, fmt.Errorf("expected an operator after %q but got %v", field.value, op)
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if value.kind != filterTokenWord && value.kind != filterTokenString {
+		return nil, fmt.Errorf("expected a value after %q but got %v", field.value+op.value, value)
+	}
+
+	pattern := value.value
+	if op.value == "=" || op.value == "!=" {
+		pattern = globToRegexp(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid regular expression %v", value)
+	}
+	return filterComparison{field: field.value, negate: strings.HasPrefix(op.value, "!"), re: re}, nil
+}
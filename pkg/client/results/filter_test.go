@@ -0,0 +1,161 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestFilterApply(t *testing.T) {
+	nodeMeta := func(name string) Item {
+		return Item{Name: name, Status: StatusFailed, Metadata: map[string]string{metadataTypeKey: metadataTypeNode}, Items: []Item{{
+			Name: "junit_01.xml", Status: StatusFailed, Metadata: map[string]string{metadataFileKey: "results/" + name + "/junit_01.xml", metadataTypeKey: metadataTypeFile},
+			Items: []Item{{
+				Name: "Kubernetes e2e suite", Status: StatusFailed,
+				Items: []Item{
+					{Name: "[sig-network] DNS should resolve", Status: StatusFailed, Details: map[string]interface{}{JUnitFailureKey: "timed out waiting for dns"}},
+					{Name: "[sig-network] Services should serve", Status: StatusPassed, Metadata: map[string]string{"owner": "net"}},
+					{Name: "[sig-storage] Volumes should mount", Status: StatusFailed},
+					{Name: "[sig-storage] Volumes should resize", Status: ""},
+				},
+			}},
+		}}}
+	}
+	item := &Item{Name: "e2e", Status: StatusFailed, Items: []Item{nodeMeta("worker-1"), nodeMeta("control-plane")}}
+
+	tests := func(item *Item) []string {
+		var out []string
+		walkTests(item, func(node string, _ []string, leaf *Item) {
+			out = append(out, node+"/"+leaf.Name)
+		})
+		return out
+	}
+
+	tcs := []struct {
+		desc         string
+		expr         string
+		expect       []string
+		expectStatus string
+	}{
+		{
+			desc:         "Example from the docs",
+			expr:         `status=failed and name~"\[sig-network\]" and meta.node=worker-*`,
+			expect:       []string{"worker-1/[sig-network] DNS should resolve"},
+			expectStatus: StatusFailed,
+		}, {
+			desc:         "Or, not and parentheses",
+			expr:         `node=control-plane and not (status=failed or status=unknown)`,
+			expect:       []string{"control-plane/[sig-network] Services should serve"},
+			expectStatus: StatusPassed,
+		}, {
+			desc:   "And binds tighter than or",
+			expr:   `node=worker-1 and status=passed or node=control-plane and status=unknown`,
+			expect: []string{"worker-1/[sig-network] Services should serve", "control-plane/[sig-storage] Volumes should resize"},
+		}, {
+			desc:   "Keywords are case insensitive",
+			expr:   `node=worker-1 AND NOT name~sig-network`,
+			expect: []string{"worker-1/[sig-storage] Volumes should mount", "worker-1/[sig-storage] Volumes should resize"},
+		}, {
+			desc:   "Negated operators",
+			expr:   `node!=worker-? and name!~"should (mount|resize|serve)"`,
+			expect: []string{"control-plane/[sig-network] DNS should resolve"},
+		}, {
+			desc:   "Path, file and plugin",
+			expr:   `path~"^Kubernetes e2e suite\|\[sig-storage\]" and file=junit_01.xml and plugin=e2e and node=worker-1`,
+			expect: []string{"worker-1/[sig-storage] Volumes should mount", "worker-1/[sig-storage] Volumes should resize"},
+		}, {
+			desc:   "Metadata and details",
+			expr:   `meta.owner=net or details.failure~dns`,
+			expect: []string{"worker-1/[sig-network] DNS should resolve", "worker-1/[sig-network] Services should serve", "control-plane/[sig-network] DNS should resolve", "control-plane/[sig-network] Services should serve"},
+		}, {
+			desc:   "Globs match the whole value",
+			expr:   `name=sig-network`,
+			expect: nil,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := ParseFilter(tc.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			filtered := f.Apply("e2e", item)
+			if diff := pretty.Compare(tests(filtered), tc.expect); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+			if tc.expect == nil && filtered != nil {
+				t.Errorf("Expected nil when no tests match but got %+v", filtered)
+			}
+			if tc.expectStatus != "" && filtered.Status != tc.expectStatus {
+				t.Errorf("Expected status %v but got %v", tc.expectStatus, filtered.Status)
+			}
+		})
+	}
+
+	if len(tests(item)) != 8 || item.Items[0].Items[0].Items[0].Items[3].Status != "" {
+		t.Errorf("Expected the original results to be unchanged but got %v", tests(item))
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tcs := []struct {
+		expr      string
+		expectErr string
+	}{
+		{expr: `status=failed`},
+		{expr: `(status = "failed" or status="time out") and name~"a \"quoted\" \\ value"`},
+		{expr: ``, expectErr: "unexpected end of filter"},
+		{expr: `status`, expectErr: "unexpected end of filter"},
+		{expr: `status failed`, expectErr: `expected an operator after "status" but got "failed" at position 8`},
+		{expr: `status=`, expectErr: "unexpected end of filter"},
+		{expr: `status=(`, expectErr: `expected a value after "status=" but got "(" at position 8`},
+		{expr: `state=failed`, expectErr: `unknown field "state" at position 1`},
+		{expr: `meta.=x`, expectErr: `unknown field "meta." at position 1`},
+		{expr: `status=failed and`, expectErr: "unexpected end of filter"},
+		{expr: `status=failed status=passed`, expectErr: `unexpected "status" at position 15`},
+		{expr: `(status=failed`, expectErr: "missing )"},
+		{expr: `status=failed)`, expectErr: `unexpected ")" at position 14`},
+		{expr: `name~"unterminated`, expectErr: "unterminated string at position 6"},
+		{expr: `name~"["`, expectErr: "invalid regular expression"},
+		{expr: `name!x`, expectErr: `unexpected '!' at position 5`},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.expr, func(t *testing.T) {
+			f, err := ParseFilter(tc.expr)
+			switch {
+			case err != nil && tc.expectErr == "":
+				t.Fatalf("Unexpected error: %v", err)
+			case err == nil && tc.expectErr != "":
+				t.Fatalf("Expected error %q but got nil", tc.expectErr)
+			case err != nil:
+				expected := fmt.Sprintf("invalid filter %q: %v", tc.expr, tc.expectErr)
+				if !strings.HasPrefix(err.Error(), expected) {
+					t.Fatalf("Expected error %q but got %q", expected, err)
+				}
+			default:
+				if f.String() != tc.expr {
+					t.Errorf("Expected the filter to print as %q but got %q", tc.expr, f)
+				}
+			}
+		})
+	}
+}
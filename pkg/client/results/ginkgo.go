@@ -0,0 +1,319 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// GinkgoLabelsKey is the key in the Items.Metadata map for the comma separated labels of a spec,
+	// including those inherited from its containers.
+	GinkgoLabelsKey = "labels"
+
+	// GinkgoLocationKey is the key in the Items.Metadata map for the file:line where a spec is defined.
+	GinkgoLocationKey = "location"
+
+	// GinkgoFailureLocationKey is the key in the Items.Metadata map for the file:line where a spec failed.
+	GinkgoFailureLocationKey = "failure-location"
+
+	// ginkgoLeafNodeIt is the type of the leaf node of a spec. Other leaf node types, like
+	// BeforeSuite, are run once for the whole suite.
+	ginkgoLeafNodeIt = "It"
+)
+
+// GinkgoReports are the suites in a report written by Ginkgo (v2) with `--json-report`.
+type GinkgoReports []GinkgoReport
+
+// GinkgoReport is the report of a single suite. Only the fields used by Sonobuoy are decoded.
+type GinkgoReport struct {
+	SuitePath        string
+	SuiteDescription string
+	SuiteSucceeded   bool
+	RunTime          time.Duration
+	SpecReports      []GinkgoSpecReport
+}
+
+// GinkgoSpecReport is the report of a single spec or suite-level node (e.g. BeforeSuite).
+type GinkgoSpecReport struct {
+	ContainerHierarchyTexts    []string
+	ContainerHierarchyLabels   [][]string
+	LeafNodeType               string
+	LeafNodeLocation           GinkgoCodeLocation
+	LeafNodeText               string
+	LeafNodeLabels             []string
+	State                      string
+	RunTime                    time.Duration
+	Failure                    GinkgoFailure
+	CapturedGinkgoWriterOutput string
+	CapturedStdOutErr          string
+}
+
+// GinkgoFailure describes why a spec failed.
+type GinkgoFailure struct {
+	Message             string
+	Location            GinkgoCodeLocation
+	FailureNodeLocation GinkgoCodeLocation
+}
+
+// GinkgoCodeLocation is a position in the source of the suite.
+type GinkgoCodeLocation struct {
+	FileName   string
+	LineNumber int
+}
+
+// String returns the location as file:line or the empty string if it isn't set.
+func (l GinkgoCodeLocation) String() string {
+	if l.FileName == "" {
+		return ""
+	}
+	return fmt.Sprintf("%v:%v", l.FileName, l.LineNumber)
+}
+
+// UnmarshalJSON accepts either the list of suites written by Ginkgo or a single suite.
+func (r *GinkgoReports) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '{' {
+		report := GinkgoReport{}
+		if err := json.Unmarshal(b, &report); err != nil {
+			return err
+		}
+		*r = GinkgoReports{report}
+		return nil
+	}
+
+	var reports []GinkgoReport
+	if err := json.Unmarshal(b, &reports); err != nil {
+		return err
+	}
+	*r = reports
+	return nil
+}
+
+// FullText is the name of the spec as used by Ginkgo to focus or skip it: the text of each of
+// its containers followed by its own. Suite-level nodes are named by their type, e.g. [BeforeSuite].
+func (s GinkgoSpecReport) FullText() string {
+	if !s.IsSpec() {
+		return strings.TrimSpace(fmt.Sprintf("[%v] %v", s.LeafNodeType, s.LeafNodeText))
+	}
+	texts := append([]string{}, s.ContainerHierarchyTexts...)
+	if s.LeafNodeText != "" {
+		texts = append(texts, s.LeafNodeText)
+	}
+	return strings.Join(texts, " ")
+}
+
+// IsSpec returns true if the report is of a spec rather than a suite-level node.
+func (s GinkgoSpecReport) IsSpec() bool {
+	return s.LeafNodeType == ginkgoLeafNodeIt || s.LeafNodeType == ""
+}
+
+// Labels returns the labels of the spec along with those of its containers, without duplicates.
+func (s GinkgoSpecReport) Labels() []string {
+	var labels []string
+	seen := map[string]bool{}
+	for _, l := range append(append([][]string{}, s.ContainerHierarchyLabels...), s.LeafNodeLabels) {
+		for _, label := range l {
+			if !seen[label] {
+				seen[label] = true
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
+
+// Status maps the state of the spec onto the statuses Sonobuoy uses. Pending specs are never
+// run so are considered skipped; panics, timeouts and interruptions are failures.
+func (s GinkgoSpecReport) Status() string {
+	switch s.State {
+	case "passed":
+		return StatusPassed
+	case "skipped", "pending":
+		return StatusSkipped
+	case "failed", "panicked", "timedout", "interrupted", "aborted":
+		return StatusFailed
+	}
+	return StatusUnknown
+}
+
+// reported returns true if the spec should be included in the results. Suite-level nodes are
+// only interesting when they fail since they then explain why specs didn't run.
+func (s GinkgoSpecReport) reported() bool {
+	return s.IsSpec() || isFailureStatus(s.Status())
+}
+
+// JUnitTestSuite converts the reports into a junit suite, with a test case for each spec, so they
+// can be used wherever the junit results of the e2e plugin are.
+func (r GinkgoReports) JUnitTestSuite() JUnitTestSuite {
+	suite := JUnitTestSuite{}
+	for _, report := range r {
+		if suite.Name == "" {
+			suite.Name = report.SuiteDescription
+		}
+		suite.Time += report.RunTime.Seconds()
+		for _, s := range report.SpecReports {
+			if !s.reported() {
+				continue
+			}
+			tc := JUnitTestCase{
+				Classname: report.SuiteDescription,
+				Name:      s.FullText(),
+				Time:      fmt.Sprint(s.RunTime.Seconds()),
+				SystemOut: s.output(),
+			}
+			switch s.Status() {
+			case StatusSkipped:
+				tc.SkipMessage = &JUnitSkipMessage{Message: s.Failure.Message}
+			case StatusFailed:
+				tc.Failure = &JUnitFailureMessage{Message: s.Failure.Message, Type: s.State, Contents: s.Failure.Location.String()}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+			suite.Tests++
+		}
+	}
+	return suite
+}
+
+// output is everything the spec wrote, to the GinkgoWriter or otherwise.
+func (s GinkgoSpecReport) output() string {
+	return s.CapturedGinkgoWriterOutput + s.CapturedStdOutErr
+}
+
+func ginkgoJSONProcessFile(pluginDir, currentFile string) (Item, error) {
+	relPath, err := filepath.Rel(pluginDir, currentFile)
+	if err != nil {
+		logrus.Errorf("Error making path %q relative to %q: %v", pluginDir, currentFile, err)
+		relPath = currentFile
+	}
+
+	resultObj := Item{
+		Name:   filepath.Base(currentFile),
+		Status: StatusUnknown,
+		Metadata: map[string]string{
+			metadataFileKey: relPath,
+			metadataTypeKey: metadataTypeFile,
+		},
+	}
+
+	infile, err := os.Open(currentFile)
+	if err != nil {
+		resultObj.Metadata["error"] = err.Error()
+		return resultObj, errors.Wrapf(err, "opening file %v", currentFile)
+	}
+	defer infile.Close()
+
+	resultObj, err = ginkgoJSONProcessReader(infile, resultObj.Name, resultObj.Metadata)
+	if err != nil {
+		return resultObj, errors.Wrap(err, "error processing ginkgo json report")
+	}
+	return resultObj, nil
+}
+
+// ginkgoJSONProcessReader builds an Item from a Ginkgo JSON report with an item for each suite
+// and, under it, each spec. Labels and where the spec is defined and failed are kept in the
+// metadata of the spec.
+func ginkgoJSONProcessReader(r io.Reader, name string, metadata map[string]string) (Item, error) {
+	rootItem := Item{
+		Name:     name,
+		Status:   StatusPassed,
+		Metadata: metadata,
+	}
+	if rootItem.Metadata == nil {
+		rootItem.Metadata = map[string]string{}
+	}
+
+	reports := GinkgoReports{}
+	if err := json.NewDecoder(r).Decode(&reports); err != nil {
+		rootItem.Status = StatusUnknown
+		rootItem.Metadata["error"] = err.Error()
+		return rootItem, errors.Wrap(err, "decoding ginkgo json report")
+	}
+	if len(reports) == 0 {
+		rootItem.Status = StatusUnknown
+		rootItem.Metadata["error"] = "no ginkgo suites found"
+		return rootItem, errors.New("no ginkgo suites found")
+	}
+
+	for _, report := range reports {
+		suiteItem := Item{
+			Name:     report.SuiteDescription,
+			Status:   StatusPassed,
+			Metadata: map[string]string{metadataDurationKey: report.RunTime.String()},
+		}
+		if !report.SuiteSucceeded {
+			suiteItem.Status = StatusFailed
+		}
+		for _, s := range report.SpecReports {
+			if !s.reported() {
+				continue
+			}
+			specItem := s.item()
+			if isFailureStatus(specItem.Status) {
+				suiteItem.Status = StatusFailed
+			}
+			suiteItem.Items = append(suiteItem.Items, specItem)
+		}
+		if isFailureStatus(suiteItem.Status) {
+			rootItem.Status = StatusFailed
+		}
+		rootItem.Items = append(rootItem.Items, suiteItem)
+	}
+	return rootItem, nil
+}
+
+func (s GinkgoSpecReport) item() Item {
+	item := Item{
+		Name:     s.FullText(),
+		Status:   s.Status(),
+		Metadata: map[string]string{metadataDurationKey: s.RunTime.String()},
+	}
+	if labels := s.Labels(); len(labels) > 0 {
+		item.Metadata[GinkgoLabelsKey] = strings.Join(labels, ",")
+	}
+	if loc := s.LeafNodeLocation.String(); loc != "" {
+		item.Metadata[GinkgoLocationKey] = loc
+	}
+
+	details := map[string]interface{}{}
+	if isFailureStatus(item.Status) {
+		if loc := s.Failure.Location.String(); loc != "" {
+			item.Metadata[GinkgoFailureLocationKey] = loc
+		}
+		if s.Failure.Message != "" {
+			details[JUnitFailureKey] = s.Failure.Message
+		}
+	}
+	if out := s.output(); out != "" {
+		details[JUnitStdoutKey] = out
+	}
+	if len(details) > 0 {
+		item.Details = details
+	}
+	return item
+}
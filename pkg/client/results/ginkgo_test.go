@@ -0,0 +1,150 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestGinkgoJSONProcessReader(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		input     io.Reader
+		expect    Item
+		expectErr string
+
+		// Allows updating the expected item via the update flag. Will load/set `expect` field in test.
+		expectItemFromFile string
+	}{
+		{
+			desc:  "Not json",
+			input: bytes.NewBufferString("<testsuite/>"),
+			expect: Item{
+				Name:     "report.json",
+				Status:   StatusUnknown,
+				Metadata: map[string]string{"error": "invalid character '<' looking for beginning of value"},
+			},
+			expectErr: "decoding ginkgo json report: invalid character '<' looking for beginning of value",
+		}, {
+			desc:  "No suites",
+			input: bytes.NewBufferString("[]"),
+			expect: Item{
+				Name:     "report.json",
+				Status:   StatusUnknown,
+				Metadata: map[string]string{"error": "no ginkgo suites found"},
+			},
+			expectErr: "no ginkgo suites found",
+		}, {
+			desc:  "Single suite rather than a list",
+			input: bytes.NewBufferString(`{"SuiteDescription":"s","SuiteSucceeded":true,"RunTime":1000000,"SpecReports":[{"LeafNodeType":"It","LeafNodeText":"a","State":"pending"}]}`),
+			expect: Item{
+				Name:     "report.json",
+				Status:   StatusPassed,
+				Metadata: map[string]string{},
+				Items: []Item{
+					{
+						Name:     "s",
+						Status:   StatusPassed,
+						Metadata: map[string]string{metadataDurationKey: "1ms"},
+						Items: []Item{
+							{Name: "a", Status: StatusSkipped, Metadata: map[string]string{metadataDurationKey: "0s"}},
+						},
+					},
+				},
+			},
+		}, {
+			desc:               "Kubernetes e2e report",
+			input:              readerFromFile(t, filepath.Join("testdata", "ginkgo-report.json")),
+			expectItemFromFile: filepath.Join("testdata", "item_ginkgo.json"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			item, err := ginkgoJSONProcessReader(tc.input, "report.json", nil)
+
+			if *update && len(tc.expectItemFromFile) > 0 {
+				b, err := json.MarshalIndent(item, "", "")
+				if err != nil {
+					t.Fatalf("Failed to marshal expected Item for debug: %v", err)
+				}
+				t.Logf("Updating goldenfile %v", tc.expectItemFromFile)
+				ioutil.WriteFile(tc.expectItemFromFile, b, 0666)
+				return
+			}
+
+			if len(tc.expectItemFromFile) > 0 {
+				b, err := ioutil.ReadFile(tc.expectItemFromFile)
+				if err != nil {
+					t.Fatalf("Failed to read test file %v: %v", tc.expectItemFromFile, err)
+				}
+				if err := json.Unmarshal(b, &tc.expect); err != nil {
+					t.Fatalf("Failed to unmarshal test data: %v", err)
+				}
+			}
+			if diff := pretty.Compare(item, tc.expect); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+			if err != nil && fmt.Sprint(err) != tc.expectErr {
+				t.Errorf("Expected error to be %q but got %q", tc.expectErr, err)
+			}
+			if err == nil && len(tc.expectErr) > 0 {
+				t.Errorf("Expected error %q but got nil", tc.expectErr)
+			}
+		})
+	}
+}
+
+func TestGinkgoReportsJUnitTestSuite(t *testing.T) {
+	reports := GinkgoReports{}
+	if err := json.NewDecoder(readerFromFile(t, filepath.Join("testdata", "ginkgo-report.json"))).Decode(&reports); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	suite := reports.JUnitTestSuite()
+
+	if suite.Name != "Kubernetes e2e suite" || suite.Tests != 5 || suite.Failures != 3 {
+		t.Errorf("Expected suite with 5 tests and 3 failures but got %q with %v tests and %v failures", suite.Name, suite.Tests, suite.Failures)
+	}
+
+	expect := map[string][]string{
+		"passed": {"[sig-apps] Deployment should run the lifecycle of a Deployment [Conformance]"},
+		"failed": {
+			"[sig-network] Services should be able to change the type from ExternalName to ClusterIP [Conformance]",
+			"[sig-node] Pods should support remote command execution over websockets [NodeConformance]",
+			"[SynchronizedAfterSuite]",
+		},
+		"skipped": {"[sig-storage] CSI mock volume CSI attach test using mock driver should not require VolumeAttach for drivers without attachment [Serial]"},
+	}
+	predicates := map[string]func(JUnitTestCase) bool{"passed": JUnitPassed, "failed": JUnitFailed, "skipped": JUnitSkipped}
+	for status, predicate := range predicates {
+		var names []string
+		for _, tc := range JUnitFilter(predicate, suite) {
+			names = append(names, tc.Name)
+		}
+		if diff := pretty.Compare(names, expect[status]); diff != "" {
+			t.Errorf("Unexpected %v tests:\n%s", status, diff)
+		}
+	}
+}
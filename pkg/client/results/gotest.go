@@ -0,0 +1,231 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Actions reported by test2json which end a test or package.
+const (
+	goTestActionPass  = "pass"
+	goTestActionFail  = "fail"
+	goTestActionSkip  = "skip"
+	goTestActionBench = "bench"
+)
+
+// goTestEvent is a single event in the stream written by `go test -json` or `go tool test2json`.
+// See `go doc test2json` for details.
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// goTestNode is a package or test seen in the stream. Tests are nested under their package and
+// subtests under their parent test.
+type goTestNode struct {
+	name     string
+	action   string
+	elapsed  float64
+	output   strings.Builder
+	children []*goTestNode
+}
+
+func goTestProcessFile(pluginDir, currentFile string) (Item, error) {
+	relPath, err := filepath.Rel(pluginDir, currentFile)
+	if err != nil {
+		logrus.Errorf("Error making path %q relative to %q: %v", pluginDir, currentFile, err)
+		relPath = currentFile
+	}
+
+	resultObj := Item{
+		Name:   filepath.Base(currentFile),
+		Status: StatusUnknown,
+		Metadata: map[string]string{
+			metadataFileKey: relPath,
+			metadataTypeKey: metadataTypeFile,
+		},
+	}
+
+	infile, err := os.Open(currentFile)
+	if err != nil {
+		resultObj.Metadata["error"] = err.Error()
+		return resultObj, errors.Wrapf(err, "opening file %v", currentFile)
+	}
+	defer infile.Close()
+
+	resultObj, err = goTestProcessReader(infile, resultObj.Name, resultObj.Metadata)
+	if err != nil {
+		return resultObj, errors.Wrap(err, "error processing go test output")
+	}
+	return resultObj, nil
+}
+
+// goTestProcessReader builds an Item from the test2json stream, with an item for each package,
+// test and subtest. Lines which aren't events (e.g. from a build failure printed to stderr) are
+// ignored.
+func goTestProcessReader(r io.Reader, name string, metadata map[string]string) (Item, error) {
+	rootItem := Item{
+		Name:     name,
+		Status:   StatusPassed,
+		Metadata: metadata,
+	}
+	if rootItem.Metadata == nil {
+		rootItem.Metadata = map[string]string{}
+	}
+
+	var packages []*goTestNode
+	packagesByName := map[string]*goTestNode{}
+	testsByName := map[string]*goTestNode{}
+	events := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxResultLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		e := goTestEvent{}
+		if err := json.Unmarshal(line, &e); err != nil {
+			logrus.Debugf("Skipping line which isn't a go test event: %v", err)
+			continue
+		}
+		events++
+
+		pkg, ok := packagesByName[e.Package]
+		if !ok {
+			pkg = &goTestNode{name: e.Package}
+			packagesByName[e.Package] = pkg
+			packages = append(packages, pkg)
+		}
+
+		node := pkg
+		if e.Test != "" {
+			key := e.Package + "\x00" + e.Test
+			node, ok = testsByName[key]
+			if !ok {
+				node = newGoTestNode(pkg, e.Package, e.Test, testsByName)
+				testsByName[key] = node
+			}
+		}
+
+		switch e.Action {
+		case "output":
+			node.output.WriteString(e.Output)
+		case goTestActionPass, goTestActionFail, goTestActionSkip, goTestActionBench:
+			node.action = e.Action
+			node.elapsed = e.Elapsed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		rootItem.Status = StatusUnknown
+		rootItem.Metadata["error"] = err.Error()
+		return rootItem, errors.Wrap(err, "reading go test events")
+	}
+	if events == 0 {
+		rootItem.Status = StatusUnknown
+		rootItem.Metadata["error"] = "no go test events found"
+		return rootItem, errors.New("no go test events found")
+	}
+
+	for _, pkg := range packages {
+		pkgItem := pkg.packageItem()
+		if isFailureStatus(pkgItem.Status) {
+			rootItem.Status = StatusFailed
+		}
+		rootItem.Items = append(rootItem.Items, pkgItem)
+	}
+	return rootItem, nil
+}
+
+// newGoTestNode adds a node for the test to the tree. Subtests are named <parent>/<subtest> and
+// their parent always starts first, but since subtest names may themselves contain slashes we
+// look for the longest known parent rather than splitting on every slash.
+func newGoTestNode(pkg *goTestNode, pkgName, test string, testsByName map[string]*goTestNode) *goTestNode {
+	parent, name := pkg, test
+	for i := strings.LastIndex(test, "/"); i > 0; i = strings.LastIndex(test[:i], "/") {
+		if p, ok := testsByName[pkgName+"\x00"+test[:i]]; ok {
+			parent, name = p, test[i+1:]
+			break
+		}
+	}
+
+	node := &goTestNode{name: name}
+	parent.children = append(parent.children, node)
+	return node
+}
+
+// packageItem returns the item for a package. The output of the package itself, which is mostly
+// the summary line, is only kept if it failed since it then explains why (e.g. a panic or timeout).
+func (n *goTestNode) packageItem() Item {
+	status := goTestStatus(n.action, "")
+	item := Item{Name: n.name, Status: status}
+	if n.action != "" {
+		item.Metadata = map[string]string{metadataDurationKey: secondsDuration(n.elapsed)}
+	}
+	if isFailureStatus(status) && n.output.Len() > 0 {
+		item.Details = map[string]interface{}{JUnitStdoutKey: n.output.String()}
+	}
+	for _, c := range n.children {
+		item.Items = append(item.Items, c.testItem(status))
+	}
+	return item
+}
+
+// testItem returns the item for a test and its subtests. Tests which never finished, e.g.
+// because the test binary panicked or timed out, take the status of their package.
+func (n *goTestNode) testItem(pkgStatus string) Item {
+	item := Item{Name: n.name, Status: goTestStatus(n.action, pkgStatus)}
+	if n.action != "" {
+		item.Metadata = map[string]string{metadataDurationKey: secondsDuration(n.elapsed)}
+	}
+	if n.output.Len() > 0 {
+		item.Details = map[string]interface{}{JUnitStdoutKey: n.output.String()}
+	}
+	for _, c := range n.children {
+		item.Items = append(item.Items, c.testItem(pkgStatus))
+	}
+	return item
+}
+
+func goTestStatus(action, unfinishedStatus string) string {
+	switch action {
+	case goTestActionPass, goTestActionBench:
+		return StatusPassed
+	case goTestActionFail:
+		return StatusFailed
+	case goTestActionSkip:
+		return StatusSkipped
+	}
+	if isFailureStatus(unfinishedStatus) {
+		return StatusFailed
+	}
+	return StatusUnknown
+}
@@ -0,0 +1,127 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestGoTestProcessReader(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		input     io.Reader
+		expect    Item
+		expectErr string
+
+		// Allows updating the expected item via the update flag. Will load/set `expect` field in test.
+		expectItemFromFile string
+	}{
+		{
+			desc:  "No events",
+			input: bytes.NewBufferString("# example.com/broken\nbuild failed\n"),
+			expect: Item{
+				Name:     "out.json",
+				Status:   StatusUnknown,
+				Metadata: map[string]string{"error": "no go test events found"},
+			},
+			expectErr: "no go test events found",
+		}, {
+			desc: "Subtests are nested and unfinished tests take the status of their package",
+			input: bytes.NewBufferString(`{"Action":"run","Package":"p","Test":"TestA"}
+{"Action":"run","Package":"p","Test":"TestA/x/y"}
+{"Action":"output","Package":"p","Test":"TestA/x/y","Output":"ok\n"}
+{"Action":"pass","Package":"p","Test":"TestA/x/y","Elapsed":0.5}
+{"Action":"fail","Package":"p","Elapsed":1.25}
+`),
+			expect: Item{
+				Name:     "out.json",
+				Status:   StatusFailed,
+				Metadata: map[string]string{},
+				Items: []Item{{
+					Name:     "p",
+					Status:   StatusFailed,
+					Metadata: map[string]string{metadataDurationKey: "1.25s"},
+					Items: []Item{{
+						Name:   "TestA",
+						Status: StatusFailed,
+						Items: []Item{{
+							Name:     "x/y",
+							Status:   StatusPassed,
+							Metadata: map[string]string{metadataDurationKey: "500ms"},
+							Details:  map[string]interface{}{JUnitStdoutKey: "ok\n"},
+						}},
+					}},
+				}},
+			},
+		}, {
+			desc:               "Output of go test -json",
+			input:              readerFromFile(t, filepath.Join("testdata", "gotest.json")),
+			expectItemFromFile: filepath.Join("testdata", "item_gotest.json"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			item, err := goTestProcessReader(tc.input, "out.json", nil)
+
+			if *update && len(tc.expectItemFromFile) > 0 {
+				b, err := json.MarshalIndent(item, "", "")
+				if err != nil {
+					t.Fatalf("Failed to marshal expected Item for debug: %v", err)
+				}
+				t.Logf("Updating goldenfile %v", tc.expectItemFromFile)
+				ioutil.WriteFile(tc.expectItemFromFile, b, 0666)
+				return
+			}
+
+			if len(tc.expectItemFromFile) > 0 {
+				b, err := ioutil.ReadFile(tc.expectItemFromFile)
+				if err != nil {
+					t.Fatalf("Failed to read test file %v: %v", tc.expectItemFromFile, err)
+				}
+				if err := json.Unmarshal(b, &tc.expect); err != nil {
+					t.Fatalf("Failed to unmarshal test data: %v", err)
+				}
+			}
+			if diff := pretty.Compare(item, tc.expect); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+			if err != nil && fmt.Sprint(err) != tc.expectErr {
+				t.Errorf("Expected error to be %q but got %q", tc.expectErr, err)
+			}
+			if err == nil && len(tc.expectErr) > 0 {
+				t.Errorf("Expected error %q but got nil", tc.expectErr)
+			}
+		})
+	}
+}
+
+func readerFromFile(t *testing.T, path string) io.Reader {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read test file %v: %v", path, err)
+	}
+	return bytes.NewBuffer(b)
+}
@@ -0,0 +1,256 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"gopkg.in/yaml.v3"
+)
+
+// writeArchive writes an archive for the run with the given status for each e2e test.
+func writeArchive(t *testing.T, dir, name, uuid, clusterVersion string, tests map[string]string) string {
+	t.Helper()
+	root := results.Item{Name: "e2e", Status: results.StatusPassed}
+	for name, status := range tests {
+		root.Items = append(root.Items, results.Item{Name: name, Status: status})
+	}
+	resultsYAML, err := yaml.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"meta/config.json":   fmt.Sprintf(`{"UUID":%q}`, uuid),
+		"serverversion.json": fmt.Sprintf(`{"gitVersion":%q}`, clusterVersion),
+		"plugins/e2e/" + results.PostProcessedResultsFile: string(resultsYAML),
+	}
+	return writeTarball(t, filepath.Join(dir, name), files)
+}
+
+// writePartialArchive writes an archive of the partial results of the run.
+func writePartialArchive(t *testing.T, dir, name, uuid string) string {
+	t.Helper()
+	return writeTarball(t, filepath.Join(dir, name), map[string]string{
+		"meta/config.json":                fmt.Sprintf(`{"UUID":%q}`, uuid),
+		"meta/" + results.PartialInfoFile: `{"plugins":[{"name":"e2e","complete":false}]}`,
+	})
+}
+
+func writeTarball(t *testing.T, archive string, files map[string]string) string {
+	t.Helper()
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	for name, contents := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, dir
+}
+
+func TestIngest(t *testing.T) {
+	s, dir := openTestStore(t)
+	archive := writeArchive(t, dir, "202103041530_sonobuoy_uuid-1.tar.gz", "uuid-1", "v1.20.2", map[string]string{"a": results.StatusPassed})
+
+	run, added, err := s.Ingest(archive)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !added {
+		t.Error("expected run to be added")
+	}
+	expected := Run{
+		UUID:           "uuid-1",
+		Time:           time.Date(2021, 3, 4, 15, 30, 0, 0, time.UTC),
+		ClusterVersion: "v1.20.2",
+		Archive:        "202103041530_sonobuoy_uuid-1.tar.gz",
+	}
+	if *run != expected {
+		t.Errorf("expected run %+v but got %+v", expected, *run)
+	}
+
+	// A copy of the same run under another name is not added again.
+	copied := writeArchive(t, dir, "copy.tar.gz", "uuid-1", "v1.20.2", map[string]string{"a": results.StatusFailed})
+	if _, added, err := s.Ingest(copied); err != nil || added {
+		t.Errorf("expected run not to be added again, got added=%v err=%v", added, err)
+	}
+
+	runs, err := s.Runs(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runs) != 1 || runs[0] != expected {
+		t.Errorf("expected only run %+v but got %+v", expected, runs)
+	}
+}
+
+func TestIngestPartial(t *testing.T) {
+	s, dir := openTestStore(t)
+	partial := writePartialArchive(t, dir, "202103041500_sonobuoy_uuid-1_partial.tar.gz", "uuid-1")
+	if _, added, err := s.Ingest(partial); errors.Cause(err) != ErrPartialResults || added {
+		t.Errorf("expected partial results to be rejected, got added=%v err=%v", added, err)
+	}
+
+	// The complete results of the same run are still added afterwards.
+	archive := writeArchive(t, dir, "202103041530_sonobuoy_uuid-1.tar.gz", "uuid-1", "v1.20.2", map[string]string{"a": results.StatusPassed})
+	if _, added, err := s.Ingest(archive); err != nil || !added {
+		t.Fatalf("expected complete run to be added, got added=%v err=%v", added, err)
+	}
+	runs, err := s.Runs(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Archive != filepath.Base(archive) {
+		t.Errorf("expected only the complete run to be stored, got %+v", runs)
+	}
+}
+
+func TestRunsFilter(t *testing.T) {
+	s, dir := openTestStore(t)
+	for _, a := range []struct{ name, uuid, version string }{
+		{"202103030000_sonobuoy_c.tar.gz", "c", "v1.21.0"},
+		{"202103010000_sonobuoy_a.tar.gz", "a", "v1.20.2"},
+		{"202103020000_sonobuoy_b.tar.gz", "b", "v1.20.2"},
+	} {
+		if _, _, err := s.Ingest(writeArchive(t, dir, a.name, a.uuid, a.version, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		desc     string
+		filter   Filter
+		expected []string
+	}{
+		{desc: "All runs oldest first", expected: []string{"a", "b", "c"}},
+		{
+			desc:     "Since",
+			filter:   Filter{Since: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+			expected: []string{"b", "c"},
+		}, {
+			desc:     "Until",
+			filter:   Filter{Until: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)},
+			expected: []string{"a"},
+		}, {
+			desc:     "Cluster version",
+			filter:   Filter{ClusterVersion: "v1.20.2"},
+			expected: []string{"a", "b"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			runs, err := s.Runs(tc.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var uuids []string
+			for _, r := range runs {
+				uuids = append(uuids, r.UUID)
+			}
+			if fmt.Sprint(uuids) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected runs %v but got %v", tc.expected, uuids)
+			}
+		})
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	s, dir := openTestStore(t)
+	const (
+		pass = results.StatusPassed
+		fail = results.StatusFailed
+		skip = results.StatusSkipped
+	)
+	runs := []map[string]string{
+		{"flaky": pass, "broken": pass, "fixed": fail, "passing": pass, "failing": fail, "skipped": skip},
+		{"flaky": fail, "broken": pass, "fixed": fail, "passing": pass, "failing": fail, "skipped": skip},
+		{"flaky": pass, "broken": fail, "fixed": pass, "passing": pass, "failing": results.StatusTimeout},
+	}
+	for i, tests := range runs {
+		uuid := fmt.Sprintf("run-%v", i)
+		name := fmt.Sprintf("20210301000%v_sonobuoy_%v.tar.gz", i, uuid)
+		if _, _, err := s.Ingest(writeArchive(t, dir, name, uuid, "v1.20.2", tests)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := s.Stats(Filter{}, DefaultFlipThreshold)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		name           string
+		classification Classification
+		runs, flips    int
+		passRate       float64
+	}{
+		{"flaky", ClassFlaky, 3, 2, 2.0 / 3},
+		{"fixed", ClassFixed, 3, 1, 1.0 / 3},
+		{"broken", ClassBroken, 3, 1, 2.0 / 3},
+		{"failing", ClassFailing, 3, 0, 0},
+		{"skipped", ClassSkipped, 2, 0, 0},
+		{"passing", ClassPassing, 3, 0, 1},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("expected %v tests but got %v: %+v", len(expected), len(stats), stats)
+	}
+	for i, e := range expected {
+		got := stats[i]
+		if got.Name != e.name || got.Classification != e.classification || got.Runs != e.runs || got.Flips != e.flips || got.PassRate() != e.passRate {
+			t.Errorf("expected stats %v to be %+v but got %+v (pass rate %v)", i, e, got, got.PassRate())
+		}
+	}
+
+	if _, err := s.Stats(Filter{}, 0); err == nil {
+		t.Error("expected error for flip threshold of 0")
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+)
+
+// Classification summarizes how a test behaved over a series of runs.
+type Classification string
+
+const (
+	// ClassPassing tests passed every time they ran.
+	ClassPassing Classification = "passing"
+
+	// ClassFailing tests failed every time they ran.
+	ClassFailing Classification = "failing"
+
+	// ClassFlaky tests flipped between passing and failing at least as often as the flip threshold.
+	ClassFlaky Classification = "flaky"
+
+	// ClassBroken tests passed before but fail now, without having flipped often enough to be flaky.
+	ClassBroken Classification = "broken"
+
+	// ClassFixed tests failed before but pass now, without having flipped often enough to be flaky.
+	ClassFixed Classification = "fixed"
+
+	// ClassSkipped tests never passed or failed, e.g. because they were always skipped.
+	ClassSkipped Classification = "skipped"
+)
+
+// DefaultFlipThreshold is the number of flips between passing and failing after which a test
+// is considered flaky.
+const DefaultFlipThreshold = 2
+
+// TestStats are the statistics for a single test over a series of runs.
+type TestStats struct {
+	Plugin string `json:"plugin"`
+	Node   string `json:"node"`
+	Name   string `json:"name"`
+
+	// Runs is the number of runs which included the test. Skipped counts those in which it
+	// neither passed nor failed.
+	Runs    int `json:"runs"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+
+	// Flips is the number of times the test passed after having failed, or vice versa, in the
+	// previous run in which it did either.
+	Flips int `json:"flips"`
+
+	LastStatus     string         `json:"lastStatus"`
+	Classification Classification `json:"classification"`
+
+	// lastOutcome is the status of the last run in which the test passed or failed.
+	lastOutcome string
+}
+
+// PassRate returns the fraction of the runs in which the test passed or failed that it passed.
+// Tests which never passed or failed have a pass rate of 0.
+func (t TestStats) PassRate() float64 {
+	if t.Passed+t.Failed == 0 {
+		return 0
+	}
+	return float64(t.Passed) / float64(t.Passed+t.Failed)
+}
+
+// add records the status of the test in the next run.
+func (t *TestStats) add(status string) {
+	t.Runs++
+	t.LastStatus = status
+
+	var outcome string
+	switch status {
+	case results.StatusPassed:
+		t.Passed++
+		outcome = results.StatusPassed
+	case results.StatusFailed, results.StatusTimeout:
+		t.Failed++
+		outcome = results.StatusFailed
+	default:
+		t.Skipped++
+		return
+	}
+
+	if t.lastOutcome != "" && t.lastOutcome != outcome {
+		t.Flips++
+	}
+	t.lastOutcome = outcome
+}
+
+func (t *TestStats) classify(flipThreshold int) {
+	switch {
+	case t.Passed+t.Failed == 0:
+		t.Classification = ClassSkipped
+	case t.Failed == 0:
+		t.Classification = ClassPassing
+	case t.Passed == 0:
+		t.Classification = ClassFailing
+	case t.Flips >= flipThreshold:
+		t.Classification = ClassFlaky
+	case t.lastOutcome == results.StatusFailed:
+		t.Classification = ClassBroken
+	default:
+		t.Classification = ClassFixed
+	}
+}
+
+// Stats returns the statistics of every test in the runs matching the filter. Tests are sorted
+// with the most flips first, then by pass rate, plugin, node and name. A test flipping between
+// passing and failing at least flipThreshold times is classified as flaky.
+func (s *Store) Stats(f Filter, flipThreshold int) ([]TestStats, error) {
+	if flipThreshold < 1 {
+		return nil, errors.New("flip threshold must be at least 1")
+	}
+
+	runs, err := s.Runs(f)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ plugin, node, name string }
+	stats := map[key]*TestStats{}
+	for _, run := range runs {
+		err := s.forEachResult(run, f, func(t results.TestResult) {
+			k := key{t.Plugin, t.Node, t.Name}
+			ts, ok := stats[k]
+			if !ok {
+				ts = &TestStats{Plugin: t.Plugin, Node: t.Node, Name: t.Name}
+				stats[k] = ts
+			}
+			ts.add(t.Status)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]TestStats, 0, len(stats))
+	for _, ts := range stats {
+		ts.classify(flipThreshold)
+		list = append(list, *ts)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case a.Flips != b.Flips:
+			return a.Flips > b.Flips
+		case a.PassRate() != b.PassRate():
+			return a.PassRate() < b.PassRate()
+		case a.Plugin != b.Plugin:
+			return a.Plugin < b.Plugin
+		case a.Node != b.Node:
+			return a.Node < b.Node
+		}
+		return a.Name < b.Name
+	})
+	return list, nil
+}
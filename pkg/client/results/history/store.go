@@ -0,0 +1,289 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package history indexes the results of many Sonobuoy runs into a local store so that the
+// results of each test can be tracked over time, e.g. to find flaky tests.
+package history
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	bolt "go.etcd.io/bbolt"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+var (
+	// runsBucket holds the Run of each run in the store, keyed by UUID.
+	runsBucket = []byte("runs")
+
+	// resultsBucket holds a nested bucket per run, keyed by UUID, holding the status of
+	// each test in that run.
+	resultsBucket = []byte("results")
+
+	// ErrPartialResults is returned by Ingest for archives of partial results. They share the UUID
+	// of the complete run, so storing them would keep the complete results out of the store.
+	ErrPartialResults = errors.New("archive holds partial results of a run in progress")
+
+	// archiveNameRegexp matches the names of the tarballs created by the aggregator.
+	archiveNameRegexp = regexp.MustCompile(`^(\d{12})_sonobuoy_(.+)\.tar\.gz$`)
+)
+
+// archiveTimeFormat is the format of the time at the start of the tarball name.
+const archiveTimeFormat = "200601021504"
+
+// testKeySeparator separates the plugin, node and name of a test in the keys of the store.
+const testKeySeparator = "\x00"
+
+// Run is a run whose results are in the store.
+type Run struct {
+	UUID           string    `json:"uuid"`
+	Time           time.Time `json:"time"`
+	ClusterVersion string    `json:"clusterVersion,omitempty"`
+	Archive        string    `json:"archive"`
+}
+
+// Store is an index of the results of many runs, kept in a local database file.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at the given path, creating it if it doesn't exist.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't open history store %q", path)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{runsBucket, resultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "couldn't initialize history store %q", path)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Ingest adds the results in the archive at the given path to the store. Runs which are
+// already in the store are not added again; the returned bool reports whether it was added.
+// Archives of partial results aren't added; ErrPartialResults is returned for them.
+func (s *Store) Ingest(archive string) (*Run, bool, error) {
+	run, err := readRun(archive)
+	if err != nil {
+		return nil, false, err
+	}
+
+	exists := false
+	err = s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(runsBucket).Get([]byte(run.UUID)) != nil
+		return nil
+	})
+	if err != nil || exists {
+		return run, false, err
+	}
+
+	items, err := readPluginResults(archive)
+	if err != nil {
+		return nil, false, err
+	}
+
+	runJSON, err := json.Marshal(run)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "couldn't encode run")
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		runResults, err := tx.Bucket(resultsBucket).CreateBucketIfNotExists([]byte(run.UUID))
+		if err != nil {
+			return err
+		}
+		for name, item := range items {
+			for _, t := range results.Tests(name, item) {
+				if err := runResults.Put(testKey(t.Plugin, t.Node, t.Name), []byte(t.Status)); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(runsBucket).Put([]byte(run.UUID), runJSON)
+	})
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "couldn't save results of %v", archive)
+	}
+	return run, true, nil
+}
+
+// Filter selects the runs and tests to report on. Zero values match everything.
+type Filter struct {
+	Since          time.Time
+	Until          time.Time
+	ClusterVersion string
+	Plugin         string
+}
+
+func (f Filter) matchesRun(r Run) bool {
+	switch {
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && r.Time.After(f.Until):
+		return false
+	case f.ClusterVersion != "" && r.ClusterVersion != f.ClusterVersion:
+		return false
+	}
+	return true
+}
+
+// Runs returns the runs in the store which match the filter, oldest first.
+func (s *Store) Runs(f Filter) ([]Run, error) {
+	var runs []Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(k, v []byte) error {
+			r := Run{}
+			if err := json.Unmarshal(v, &r); err != nil {
+				return errors.Wrapf(err, "couldn't decode run %s", k)
+			}
+			if f.matchesRun(r) {
+				runs = append(runs, r)
+			}
+			return nil
+		})
+	})
+
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, err
+}
+
+// forEachResult calls fn with the result of every test, matching the filter, in the given run.
+func (s *Store) forEachResult(run Run, f Filter, fn func(results.TestResult)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(resultsBucket).Bucket([]byte(run.UUID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			parts := strings.SplitN(string(k), testKeySeparator, 3)
+			if len(parts) != 3 {
+				return errors.Errorf("invalid test key %q in run %v", k, run.UUID)
+			}
+			if f.Plugin != "" && parts[0] != f.Plugin {
+				return nil
+			}
+			fn(results.TestResult{Plugin: parts[0], Node: parts[1], Name: parts[2], Status: string(v)})
+			return nil
+		})
+	})
+}
+
+func testKey(plugin, node, name string) []byte {
+	return []byte(strings.Join([]string{plugin, node, name}, testKeySeparator))
+}
+
+// readRun reads the metadata of the run from its archive. The UUID and cluster version come
+// from the archive itself; the time comes from the name the aggregator gave the archive, falling
+// back to the time the file was last modified. ErrPartialResults is returned if the archive is
+// marked as partial.
+func readRun(archive string) (*Run, error) {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't stat archive %v", archive)
+	}
+	run := &Run{Archive: filepath.Base(archive), Time: info.ModTime().UTC()}
+	if m := archiveNameRegexp.FindStringSubmatch(run.Archive); m != nil {
+		if t, err := time.Parse(archiveTimeFormat, m[1]); err == nil {
+			run.Time = t
+		}
+		run.UUID = m[2]
+	}
+
+	cfg := &config.Config{}
+	serverVersion := &version.Info{}
+	partial := false
+	err = withReader(archive, func(r *results.Reader) error {
+		return r.WalkFiles(func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == r.PartialInfoFile() {
+				partial = true
+			}
+			if err := results.ExtractConfig(path, info, cfg); err != nil {
+				return err
+			}
+			return results.ExtractFileIntoStruct(r.ServerVersionFile(), path, info, serverVersion)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if partial {
+		return nil, errors.Wrap(ErrPartialResults, archive)
+	}
+
+	if cfg.UUID != "" {
+		run.UUID = cfg.UUID
+	}
+	if run.UUID == "" {
+		return nil, errors.Errorf("couldn't determine the UUID of the run in %v", archive)
+	}
+	run.ClusterVersion = serverVersion.GitVersion
+	return run, nil
+}
+
+// readPluginResults reads the results of every plugin from the archive.
+func readPluginResults(archive string) (map[string]*results.Item, error) {
+	var items map[string]*results.Item
+	err := withReader(archive, func(r *results.Reader) error {
+		var err error
+		items, err = r.PluginResultsItems()
+		return err
+	})
+	return items, err
+}
+
+// withReader calls fn with a reader for the archive, which can only be read through once.
+func withReader(archive string, fn func(*results.Reader) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't open archive %v", archive)
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't read archive %v", archive)
+	}
+	defer gzr.Close()
+
+	return errors.Wrapf(fn(results.NewReaderWithVersion(gzr, results.VersionFifteen)), "couldn't read archive %v", archive)
+}
@@ -0,0 +1,279 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+var (
+	//go:embed html.tmpl
+	htmlTemplateDoc string
+
+	htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+		"statusClass": statusClass,
+		"podLogName":  podLogName,
+	}).Parse(htmlTemplateDoc))
+)
+
+// HTMLReport is the data rendered into the HTML report of a run.
+type HTMLReport struct {
+	Archive        string
+	ClusterVersion string
+	Plugins        []HTMLPluginReport
+	QueryTimes     []QueryTime
+
+	// PodLogs are the paths, within the archive, of the logs of pods which weren't run by a plugin.
+	PodLogs []string
+}
+
+// HTMLPluginReport summarizes the results of a single plugin.
+type HTMLPluginReport struct {
+	Name   string
+	Status string
+	Counts []StatusCount
+
+	// Nodes is the status of each node for plugins whose results are split by node, e.g. those
+	// run as a DaemonSet. It is empty for other plugins.
+	Nodes    []HTMLNodeReport
+	Failures []HTMLTestReport
+
+	// PodLogs are the paths, within the archive, of the logs of the pods which ran the plugin.
+	PodLogs []string
+}
+
+// StatusCount is the number of tests with the given status.
+type StatusCount struct {
+	Status string
+	Count  int
+}
+
+// HTMLNodeReport summarizes the results of a plugin on a single node.
+type HTMLNodeReport struct {
+	Name   string
+	Status string
+	Passed int
+	Failed int
+	Other  int
+}
+
+// HTMLTestReport is a single test, along with its failure message and output if there are any.
+type HTMLTestReport struct {
+	Node      string
+	Name      string
+	Status    string
+	Failure   string
+	SystemOut string
+}
+
+// QueryTime is how long it took to query the cluster for a resource, as recorded in QueryTimeFile.
+type QueryTime struct {
+	Object    string `json:"queryobj"`
+	Namespace string `json:"namespace,omitempty"`
+	Time      string `json:"time"`
+}
+
+// HTMLReport reads everything needed for the HTML report of the run from the archive in a single
+// pass. If plugin is not empty, only the results of that plugin are included.
+func (r *Reader) HTMLReport(plugin string) (*HTMLReport, error) {
+	report := &HTMLReport{}
+	items := map[string]*Item{}
+	serverVersion := version.Info{}
+	var podLogs []string
+
+	err := r.WalkFiles(func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if pluginName, ok := pluginResultsFilePlugin(filePath); ok {
+			if plugin != "" && pluginName != plugin {
+				return nil
+			}
+			item, err := decodePluginResults(pluginName, info)
+			if err != nil {
+				return err
+			}
+			items[pluginName] = item
+			return nil
+		}
+
+		if strings.HasPrefix(filePath, r.PodLogs()) && !info.IsDir() {
+			podLogs = append(podLogs, filePath)
+			return nil
+		}
+
+		if err := ExtractFileIntoStruct(r.ServerVersionFile(), filePath, info, &serverVersion); err != nil {
+			return err
+		}
+		return ExtractFileIntoStruct(r.QueryTimeFile(), filePath, info, &report.QueryTimes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if plugin != "" && items[plugin] == nil {
+		return nil, fmt.Errorf("no results found for plugin %q", plugin)
+	}
+
+	report.ClusterVersion = serverVersion.GitVersion
+	sort.Strings(podLogs)
+
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pluginLogs := map[string]bool{}
+	for _, name := range names {
+		p := newHTMLPluginReport(name, items[name])
+		for _, logFile := range podLogs {
+			if isPluginPodLog(name, logFile) {
+				p.PodLogs = append(p.PodLogs, logFile)
+				pluginLogs[logFile] = true
+			}
+		}
+		report.Plugins = append(report.Plugins, p)
+	}
+
+	// Logs of other pods, like the aggregator, are only of interest when reporting on every plugin.
+	if plugin == "" {
+		for _, logFile := range podLogs {
+			if !pluginLogs[logFile] {
+				report.PodLogs = append(report.PodLogs, logFile)
+			}
+		}
+	}
+	return report, nil
+}
+
+// isPluginPodLog returns true if the log file, podlogs/<namespace>/<pod>/logs/<container>.txt,
+// is from a pod of the plugin. Plugin pods are named by the Job and DaemonSet drivers.
+func isPluginPodLog(pluginName, logFile string) bool {
+	parts := strings.Split(strings.TrimPrefix(logFile, podLogsDir), "/")
+	if len(parts) < 2 {
+		return false
+	}
+	pod := parts[1]
+	return strings.HasPrefix(pod, fmt.Sprintf("sonobuoy-%v-job-", pluginName)) ||
+		strings.HasPrefix(pod, fmt.Sprintf("sonobuoy-%v-daemon-set-", pluginName))
+}
+
+func newHTMLPluginReport(name string, item *Item) HTMLPluginReport {
+	p := HTMLPluginReport{Name: name, Status: item.Status}
+
+	counts := map[string]int{}
+	walkTests(item, func(node string, names []string, leaf *Item) {
+		counts[leaf.Status]++
+		if isFailureStatus(leaf.Status) {
+			p.Failures = append(p.Failures, HTMLTestReport{
+				Node:      node,
+				Name:      strings.Join(append(names, leaf.Name), testPathSeparator),
+				Status:    leaf.Status,
+				Failure:   detailString(leaf.Details, JUnitFailureKey),
+				SystemOut: detailString(leaf.Details, JUnitStdoutKey),
+			})
+		}
+	})
+	p.Counts = statusCounts(counts)
+
+	for i := range item.Items {
+		nodeItem := &item.Items[i]
+		if nodeItem.Metadata[metadataTypeKey] != metadataTypeNode {
+			continue
+		}
+		n := HTMLNodeReport{Name: nodeItem.Name, Status: nodeItem.Status}
+		walkTests(&Item{Items: []Item{*nodeItem}}, func(_ string, _ []string, leaf *Item) {
+			switch {
+			case leaf.Status == StatusPassed:
+				n.Passed++
+			case isFailureStatus(leaf.Status):
+				n.Failed++
+			default:
+				n.Other++
+			}
+		})
+		p.Nodes = append(p.Nodes, n)
+	}
+	sort.Slice(p.Nodes, func(i, j int) bool { return p.Nodes[i].Name < p.Nodes[j].Name })
+	return p
+}
+
+// statusCounts orders the counts with the built-in statuses first, followed by any custom ones
+// in alphabetical order.
+func statusCounts(counts map[string]int) []StatusCount {
+	var list []StatusCount
+	for _, status := range []string{StatusPassed, StatusFailed, StatusTimeout, StatusSkipped} {
+		if counts[status] > 0 {
+			list = append(list, StatusCount{Status: status, Count: counts[status]})
+		}
+		delete(counts, status)
+	}
+
+	custom := make([]string, 0, len(counts))
+	for status := range counts {
+		custom = append(custom, status)
+	}
+	sort.Strings(custom)
+	for _, status := range custom {
+		list = append(list, StatusCount{Status: status, Count: counts[status]})
+	}
+	return list
+}
+
+func detailString(details map[string]interface{}, key string) string {
+	v, ok := details[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// statusClass is the CSS class used to color a status.
+func statusClass(status string) string {
+	switch {
+	case status == StatusPassed:
+		return "passed"
+	case isFailureStatus(status):
+		return "failed"
+	default:
+		return "other"
+	}
+}
+
+// WriteHTML renders the report as a single, self-contained HTML page. Links to pod logs are
+// relative to the root of the archive, so they work when the page is saved alongside the
+// extracted archive.
+func WriteHTML(w io.Writer, report *HTMLReport) error {
+	return errors.Wrap(htmlTemplate.Execute(w, report), "failed to render HTML report")
+}
+
+// podLogName is the name shown for a pod log, e.g. <pod>/<container>.txt.
+func podLogName(logFile string) string {
+	dir, file := path.Split(logFile)
+	return path.Join(path.Base(path.Dir(path.Clean(dir))), file)
+}
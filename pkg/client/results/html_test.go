@@ -0,0 +1,163 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	htmlTestE2EResults = `name: e2e
+status: failed
+items:
+- name: junit_01.xml
+  status: failed
+  meta:
+    type: file
+    file: results/global/junit_01.xml
+  items:
+  - name: Kubernetes e2e suite
+    status: failed
+    items:
+    - name: a <b>
+      status: failed
+      details:
+        failure: expected <nil>
+        system-out: some output
+    - name: b
+      status: passed
+`
+
+	htmlTestDaemonSetResults = `name: systemd-logs
+status: failed
+items:
+- name: node2
+  status: failed
+  meta:
+    type: node
+  items:
+  - name: out.json
+    status: failed
+- name: node1
+  status: passed
+  meta:
+    type: node
+  items:
+  - name: out.json
+    status: passed
+  - name: other.json
+    status: unknown
+`
+)
+
+func htmlTestArchive() []testFile {
+	return []testFile{
+		{"plugins/e2e/sonobuoy_results.yaml", htmlTestE2EResults},
+		{"plugins/systemd-logs/sonobuoy_results.yaml", htmlTestDaemonSetResults},
+		{"serverversion.json", `{"gitVersion":"v1.21.1"}`},
+		{"meta/query-time.json", `[{"queryobj":"Nodes","time":"1.5ms"},{"queryobj":"pods","namespace":"default","time":"2ms"}]`},
+		{"podlogs/sonobuoy/sonobuoy/logs/kube-sonobuoy.txt", "aggregator"},
+		{"podlogs/sonobuoy/sonobuoy-e2e-job-1234/logs/e2e.txt", "e2e"},
+		{"podlogs/sonobuoy/sonobuoy-systemd-logs-daemon-set-1234-abcde/logs/plugin.txt", "systemd"},
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	report, err := NewReaderWithVersion(newTestArchive(t, htmlTestArchive()), VersionFifteen).HTMLReport("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := &HTMLReport{
+		ClusterVersion: "v1.21.1",
+		Plugins: []HTMLPluginReport{
+			{
+				Name:   "e2e",
+				Status: StatusFailed,
+				Counts: []StatusCount{{StatusPassed, 1}, {StatusFailed, 1}},
+				Failures: []HTMLTestReport{
+					{Node: "global", Name: "Kubernetes e2e suite|a <b>", Status: StatusFailed, Failure: "expected <nil>", SystemOut: "some output"},
+				},
+				PodLogs: []string{"podlogs/sonobuoy/sonobuoy-e2e-job-1234/logs/e2e.txt"},
+			}, {
+				Name:   "systemd-logs",
+				Status: StatusFailed,
+				Counts: []StatusCount{{StatusPassed, 1}, {StatusFailed, 1}, {StatusUnknown, 1}},
+				Nodes: []HTMLNodeReport{
+					{Name: "node1", Status: StatusPassed, Passed: 1, Other: 1},
+					{Name: "node2", Status: StatusFailed, Failed: 1},
+				},
+				Failures: []HTMLTestReport{{Node: "node2", Name: "out.json", Status: StatusFailed}},
+				PodLogs:  []string{"podlogs/sonobuoy/sonobuoy-systemd-logs-daemon-set-1234-abcde/logs/plugin.txt"},
+			},
+		},
+		QueryTimes: []QueryTime{{Object: "Nodes", Time: "1.5ms"}, {Object: "pods", Namespace: "default", Time: "2ms"}},
+		PodLogs:    []string{"podlogs/sonobuoy/sonobuoy/logs/kube-sonobuoy.txt"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected report\n%+v\nbut got\n%+v", expected, report)
+	}
+}
+
+func TestHTMLReportSinglePlugin(t *testing.T) {
+	report, err := NewReaderWithVersion(newTestArchive(t, htmlTestArchive()), VersionFifteen).HTMLReport("e2e")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(report.Plugins) != 1 || report.Plugins[0].Name != "e2e" {
+		t.Errorf("Expected only the e2e plugin but got %+v", report.Plugins)
+	}
+	if len(report.PodLogs) != 0 {
+		t.Errorf("Expected no other pod logs but got %v", report.PodLogs)
+	}
+
+	_, err = NewReaderWithVersion(newTestArchive(t, htmlTestArchive()), VersionFifteen).HTMLReport("missing")
+	if err == nil {
+		t.Error("Expected error for missing plugin")
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	report, err := NewReaderWithVersion(newTestArchive(t, htmlTestArchive()), VersionFifteen).HTMLReport("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	report.Archive = "results.tar.gz"
+
+	var out strings.Builder
+	if err := WriteHTML(&out, report); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{
+		"<td>results.tar.gz</td>",
+		"<td>v1.21.1</td>",
+		`<a href="#plugin-systemd-logs">systemd-logs</a>`,
+		`<div class="node passed"><div class="name">node1</div>`,
+		"Kubernetes e2e suite|a &lt;b&gt;</summary>",
+		"<pre>expected &lt;nil&gt;</pre>",
+		"<pre>some output</pre>",
+		`<a href="podlogs/sonobuoy/sonobuoy-e2e-job-1234/logs/e2e.txt">sonobuoy-e2e-job-1234/e2e.txt</a>`,
+		"<td>pods</td><td>default</td><td>2ms</td>",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q", expected)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -235,6 +236,9 @@ func junitProcessReader(r io.Reader, name string, metadata map[string]string) (I
 			Name:   ts.Name,
 			Status: StatusPassed,
 		}
+		if ts.Time > 0 {
+			suiteItem.Metadata = map[string]string{metadataDurationKey: secondsDuration(ts.Time)}
+		}
 		for _, t := range ts.TestCases {
 			status := StatusUnknown
 			switch {
@@ -252,6 +256,9 @@ func junitProcessReader(r io.Reader, name string, metadata map[string]string) (I
 				status = StatusSkipped
 			}
 			testItem := Item{Name: t.Name, Status: status, Details: map[string]interface{}{}, Metadata: map[string]string{}}
+			if seconds, err := strconv.ParseFloat(strings.TrimSpace(t.Time), 64); err == nil && seconds >= 0 {
+				testItem.Metadata[metadataDurationKey] = secondsDuration(seconds)
+			}
 
 			// Different JUnit implementations build the objects in slightly different ways.
 			// Some will only use contents, some only the message attribute. Here we just concat
@@ -0,0 +1,702 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	yamlv2 "gopkg.in/yaml.v2"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results/e2e"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+const (
+	// MergeInfoFile is the name of the file, in the meta directory of a merged archive, which
+	// lists the archives it was merged from.
+	MergeInfoFile = "merge.json"
+
+	// mergeSourcesDir is the directory, within the meta directory of a merged archive, holding the
+	// meta directory of each source archive, e.g. meta/sources/2/config.json.
+	mergeSourcesDir = "sources"
+)
+
+// MergeSource is an archive to merge.
+type MergeSource struct {
+	// Name identifies the archive, e.g. its file name.
+	Name string
+
+	// Open returns the uncompressed tar stream of the archive. It is called twice since the
+	// archive is read once to plan the merge and once to copy its files.
+	Open func() (io.ReadCloser, error)
+}
+
+// MergeOptions changes how archives are merged.
+type MergeOptions struct {
+	// RenamePlugins keeps plugins which ran in more than one archive apart by renaming them after
+	// the first, e.g. e2e and e2e-2, rather than combining their results.
+	RenamePlugins bool
+}
+
+// MergeInfo records the archives a merged archive was made from.
+type MergeInfo struct {
+	Sources []MergeSourceInfo `json:"sources"`
+}
+
+// MergeSourceInfo describes one of the archives a merged archive was made from.
+type MergeSourceInfo struct {
+	Archive string `json:"archive"`
+
+	// Meta is the directory holding the meta directory of the archive, including its config.
+	Meta string `json:"meta"`
+
+	// Plugins maps the name of each plugin in the archive to its name in the merged archive.
+	Plugins map[string]string `json:"plugins"`
+}
+
+// mergeRunInfo is the subset of discovery.RunInfo read and written when merging.
+type mergeRunInfo struct {
+	LoadedPlugins []string `json:"plugins,omitempty"`
+}
+
+// mergeSource is a source archive along with everything learnt about it while planning.
+type mergeSource struct {
+	MergeSource
+	index int
+
+	plugins     []string
+	items       map[string]*Item
+	files       []string
+	hasManifest bool
+
+	// pluginNames maps the name of each plugin to its name in the merged archive.
+	pluginNames map[string]string
+
+	// targets maps the path of each file to the paths it is copied to.
+	targets map[string][]string
+
+	// renamed maps the plugin and the path of each of its renamed files, relative to the plugin
+	// directory, to the new path.
+	renamed map[string]map[string]string
+}
+
+// mergePlan is what the merged archive will contain.
+type mergePlan struct {
+	sources []*mergeSource
+
+	// plugins are the names of the plugins in the merged archive, in order, along with the
+	// sources each one came from.
+	plugins       []string
+	pluginSources map[string][]*mergeSource
+
+	// combined are the files which are in more than one archive and whose contents are combined.
+	combined map[string][][]byte
+
+	// uuid is the UUID of the merged archive. It is written into its config in place of the UUID
+	// of the first run so that the merged archive isn't mistaken for that run.
+	uuid string
+}
+
+// Merge writes a single tar stream holding the results of every source archive, as if they
+// were from a single run, so they can be read as usual by a Reader. Plugins which only ran in one
+// archive are copied as they are. Results of plugins which ran in more than one are combined
+// (unless MergeOptions.RenamePlugins is set): results from different nodes are kept side by side,
+// junit results and Ginkgo reports written to the same file are combined into one and any other
+// file written by more than one run is renamed after the index of its archive, e.g. e2e-2.log.
+// The post-processed results of such plugins are combined in the same way, as are their statuses.
+//
+// The meta directory of each archive is kept under meta/sources/<index>/ with the first archive's
+// also left in place, so that the merged archive has the config of the first run, but with a new
+// UUID since the merged archive is a run of its own as far as its readers are concerned. The list of
+// plugins (meta/info.json) is recomputed and MergeInfoFile records where everything came from.
+// A new digest manifest is written, if every archive had one, but any signatures are dropped
+// since they no longer apply. Other files are taken from the first archive which has them.
+func Merge(w io.Writer, sources []MergeSource, opts MergeOptions) error {
+	if len(sources) == 0 {
+		return errors.New("no archives to merge")
+	}
+
+	mergedUUID, err := uuid.NewV4()
+	if err != nil {
+		return errors.Wrap(err, "failed to generate UUID of merged archive")
+	}
+
+	plan := &mergePlan{pluginSources: map[string][]*mergeSource{}, combined: map[string][][]byte{}, uuid: mergedUUID.String()}
+	for i, s := range sources {
+		src := &mergeSource{MergeSource: s, index: i + 1}
+		if err := src.read(); err != nil {
+			return errors.Wrapf(err, "failed to read archive %v", s.Name)
+		}
+		plan.sources = append(plan.sources, src)
+	}
+	plan.namePlugins(opts)
+	plan.planFiles()
+
+	mw := &mergeWriter{tw: tar.NewWriter(w), written: map[string]bool{}, digests: map[string]string{}, now: time.Now()}
+	for _, src := range plan.sources {
+		if err := plan.copyFiles(src, mw); err != nil {
+			return errors.Wrapf(err, "failed to copy archive %v", src.Name)
+		}
+	}
+	if err := plan.writeGenerated(mw); err != nil {
+		return err
+	}
+	return errors.Wrap(mw.tw.Close(), "failed to write merged archive")
+}
+
+// read lists the files of the archive and reads the post-processed results of its plugins.
+func (s *mergeSource) read() error {
+	rc, err := s.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	s.items = map[string]*Item{}
+	runInfo := mergeRunInfo{}
+	dirs := map[string]bool{}
+	err = (&Reader{Reader: rc}).WalkFiles(func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		s.files = append(s.files, filePath)
+		if p, ok := pluginFilePlugin(filePath); ok {
+			dirs[p] = true
+		}
+
+		if pluginName, ok := pluginResultsFilePlugin(filePath); ok {
+			item, err := decodePluginResults(pluginName, info)
+			if err != nil {
+				return err
+			}
+			s.items[pluginName] = item
+		}
+		switch filePath {
+		case path.Join(metadataDir, InfoFile):
+			return ExtractFileIntoStruct(filePath, filePath, info, &runInfo)
+		case path.Join(metadataDir, aggregation.ManifestFile):
+			s.hasManifest = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Plugins are listed in the order they were loaded, followed by any others with results.
+	seen := map[string]bool{}
+	for _, p := range runInfo.LoadedPlugins {
+		if !seen[p] {
+			seen[p] = true
+			s.plugins = append(s.plugins, p)
+		}
+	}
+	var others []string
+	for p := range dirs {
+		if !seen[p] {
+			others = append(others, p)
+		}
+	}
+	sort.Strings(others)
+	s.plugins = append(s.plugins, others...)
+	return nil
+}
+
+// pluginFilePlugin returns the name of the plugin if the path is within the directory of a
+// plugin, i.e. plugins/<plugin>/...
+func pluginFilePlugin(filePath string) (string, bool) {
+	parts := strings.SplitN(filePath, "/", 3)
+	if len(parts) < 3 || parts[0]+"/" != PluginsDir {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// namePlugins decides the name of each plugin in the merged archive.
+func (p *mergePlan) namePlugins(opts MergeOptions) {
+	for _, src := range p.sources {
+		src.pluginNames = map[string]string{}
+		for _, name := range src.plugins {
+			target := name
+			if opts.RenamePlugins {
+				for n := src.index; len(p.pluginSources[target]) > 0; n++ {
+					target = fmt.Sprintf("%v-%v", name, n)
+				}
+			}
+			if len(p.pluginSources[target]) == 0 {
+				p.plugins = append(p.plugins, target)
+			}
+			src.pluginNames[name] = target
+			p.pluginSources[target] = append(p.pluginSources[target], src)
+		}
+	}
+}
+
+// planFiles decides where each file of each archive is copied to.
+func (p *mergePlan) planFiles() {
+	// owners are the sources of each path of the merged archive, in order.
+	owners := map[string][]*mergeSource{}
+	for _, src := range p.sources {
+		src.targets = map[string][]string{}
+		src.renamed = map[string]map[string]string{}
+		for _, f := range src.files {
+			for _, target := range p.fileTargets(src, f) {
+				owners[target] = append(owners[target], src)
+				src.targets[f] = append(src.targets[f], target)
+			}
+		}
+	}
+
+	for target, srcs := range owners {
+		if len(srcs) < 2 {
+			continue
+		}
+		pluginName, ok := pluginFilePlugin(target)
+		switch {
+		case !ok || path.Base(target) == PostProcessedResultsFile || !isPluginResultPath(target):
+			// Other files are taken from the first archive which has them and the results of
+			// the plugin are regenerated.
+			for _, src := range srcs[1:] {
+				src.dropTarget(target)
+			}
+		case isCombinableResult(target):
+			p.combined[target] = nil
+		default:
+			for _, src := range srcs[1:] {
+				renamed := renameForSource(target, src.index)
+				src.replaceTarget(target, renamed)
+
+				rel := strings.TrimPrefix(target, path.Join(PluginsDir, pluginName)+"/")
+				if src.renamed[pluginName] == nil {
+					src.renamed[pluginName] = map[string]string{}
+				}
+				src.renamed[pluginName][rel] = strings.TrimPrefix(renamed, path.Join(PluginsDir, pluginName)+"/")
+			}
+		}
+	}
+}
+
+// fileTargets returns the paths, in the merged archive, of a file of the source.
+func (p *mergePlan) fileTargets(src *mergeSource, f string) []string {
+	if pluginName, ok := pluginFilePlugin(f); ok {
+		target := src.pluginNames[pluginName]
+		rel := strings.TrimPrefix(f, path.Join(PluginsDir, pluginName)+"/")
+		if rel == PostProcessedResultsFile && p.regenerated(target) {
+			return nil
+		}
+		return []string{path.Join(PluginsDir, target, rel)}
+	}
+
+	rel := strings.TrimPrefix(f, metadataDir)
+	if rel == f {
+		return []string{f}
+	}
+	targets := []string{path.Join(metadataDir, mergeSourcesDir, fmt.Sprint(src.index), rel)}
+	switch {
+	case rel == InfoFile, rel == MergeInfoFile, strings.HasPrefix(rel, mergeSourcesDir+"/"):
+	case rel == aggregation.ManifestFile, strings.HasSuffix(rel, signature.FileSuffix):
+	default:
+		targets = append(targets, f)
+	}
+	return targets
+}
+
+// regenerated returns true if the post-processed results of the plugin have to be written anew
+// rather than copied, i.e. if it was combined from many archives or renamed.
+func (p *mergePlan) regenerated(target string) bool {
+	srcs := p.pluginSources[target]
+	if len(srcs) != 1 {
+		return true
+	}
+	for name, t := range srcs[0].pluginNames {
+		if t == target {
+			return name != target
+		}
+	}
+	return false
+}
+
+func (s *mergeSource) dropTarget(target string) {
+	for f, targets := range s.targets {
+		for i := range targets {
+			if targets[i] == target {
+				s.targets[f] = append(targets[:i:i], targets[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (s *mergeSource) replaceTarget(target, replacement string) {
+	for _, targets := range s.targets {
+		for i := range targets {
+			if targets[i] == target {
+				targets[i] = replacement
+			}
+		}
+	}
+}
+
+// isCombinableResult returns true for the results whose contents can be combined: junit results
+// and the Ginkgo report of the e2e tests.
+func isCombinableResult(target string) bool {
+	return strings.HasSuffix(target, ".xml") || path.Base(target) == e2e.GinkgoJSONResultsFile
+}
+
+// renameForSource adds the index of the source archive to the name of the file, before its
+// extension, e.g. results/global/e2e.log becomes results/global/e2e-2.log.
+func renameForSource(target string, index int) string {
+	ext := path.Ext(target)
+	return fmt.Sprintf("%v-%v%v", strings.TrimSuffix(target, ext), index, ext)
+}
+
+// mergeWriter writes the merged archive, recording the digests of plugin results for the manifest.
+type mergeWriter struct {
+	tw      *tar.Writer
+	written map[string]bool
+	digests map[string]string
+	now     time.Time
+}
+
+func (mw *mergeWriter) write(hdr tar.Header, r io.Reader) error {
+	if mw.written[hdr.Name] {
+		return nil
+	}
+	mw.written[hdr.Name] = true
+	if err := mw.tw.WriteHeader(&hdr); err != nil {
+		return errors.Wrapf(err, "failed to write header of %v", hdr.Name)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(mw.tw, io.TeeReader(r, h)); err != nil {
+		return errors.Wrapf(err, "failed to write %v", hdr.Name)
+	}
+	if isPluginResultPath(hdr.Name) {
+		mw.digests[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return nil
+}
+
+func (mw *mergeWriter) writeBytes(name string, b []byte) error {
+	hdr := tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: mw.now, Typeflag: tar.TypeReg}
+	return mw.write(hdr, bytes.NewReader(b))
+}
+
+// copyFiles copies the files of the source into the merged archive, keeping those which are
+// combined aside.
+func (p *mergePlan) copyFiles(src *mergeSource, mw *mergeWriter) error {
+	rc, err := src.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error getting next file in archive")
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		targets := src.targets[path.Clean(hdr.Name)]
+		if len(targets) == 0 {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %v", hdr.Name)
+		}
+		for _, target := range targets {
+			if versions, ok := p.combined[target]; ok {
+				p.combined[target] = append(versions, b)
+				continue
+			}
+			copied := *hdr
+			copied.Name = target
+			content := b
+			if target == ConfigFile(VersionTen) {
+				if content, err = replaceConfigUUID(b, p.uuid); err != nil {
+					return errors.Wrapf(err, "failed to update %v", hdr.Name)
+				}
+				copied.Size = int64(len(content))
+			}
+			if err := mw.write(copied, bytes.NewReader(content)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replaceConfigUUID returns the config with its UUID replaced, leaving the rest of it as it was.
+func replaceConfigUUID(config []byte, id string) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return nil, errors.Wrap(err, "decoding config")
+	}
+	encoded, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	fields["UUID"] = encoded
+	return json.Marshal(fields)
+}
+
+// writeGenerated writes the files which are combined or recomputed from those of the sources.
+func (p *mergePlan) writeGenerated(mw *mergeWriter) error {
+	combined := make([]string, 0, len(p.combined))
+	for target := range p.combined {
+		combined = append(combined, target)
+	}
+	sort.Strings(combined)
+	for _, target := range combined {
+		b, err := combineResults(target, p.combined[target])
+		if err != nil {
+			return errors.Wrapf(err, "failed to combine %v", target)
+		}
+		if err := mw.writeBytes(target, b); err != nil {
+			return err
+		}
+	}
+
+	for _, target := range p.plugins {
+		if !p.regenerated(target) {
+			continue
+		}
+		item := p.mergedItem(target)
+		if item == nil {
+			continue
+		}
+		b, err := yamlv2.Marshal(item)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode results of plugin %v", target)
+		}
+		if err := mw.writeBytes(path.Join(PluginsDir, target, PostProcessedResultsFile), b); err != nil {
+			return err
+		}
+	}
+
+	info := MergeInfo{}
+	manifest := true
+	for _, src := range p.sources {
+		info.Sources = append(info.Sources, MergeSourceInfo{
+			Archive: src.Name,
+			Meta:    path.Join(metadataDir, mergeSourcesDir, fmt.Sprint(src.index)),
+			Plugins: src.pluginNames,
+		})
+		manifest = manifest && src.hasManifest
+	}
+	files := map[string]interface{}{
+		path.Join(metadataDir, InfoFile):      mergeRunInfo{LoadedPlugins: p.plugins},
+		path.Join(metadataDir, MergeInfoFile): info,
+	}
+	if manifest {
+		files[path.Join(metadataDir, aggregation.ManifestFile)] = aggregation.ResultsManifest{
+			Algorithm: aggregation.DigestAlgorithm,
+			Files:     mw.digests,
+		}
+	}
+	for _, name := range []string{InfoFile, MergeInfoFile, aggregation.ManifestFile} {
+		v, ok := files[path.Join(metadataDir, name)]
+		if !ok {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode %v", name)
+		}
+		if err := mw.writeBytes(path.Join(metadataDir, name), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// combineResults combines the versions of a junit file or Ginkgo report from each archive.
+func combineResults(target string, versions [][]byte) ([]byte, error) {
+	if path.Base(target) == e2e.GinkgoJSONResultsFile {
+		// The suites are kept as they are since GinkgoReport only has the fields Sonobuoy uses.
+		var combined []json.RawMessage
+		for _, b := range versions {
+			b = bytes.TrimSpace(b)
+			if len(b) > 0 && b[0] == '{' {
+				combined = append(combined, b)
+				continue
+			}
+			var reports []json.RawMessage
+			if err := json.Unmarshal(b, &reports); err != nil {
+				return nil, errors.Wrap(err, "decoding Ginkgo report")
+			}
+			combined = append(combined, reports...)
+		}
+		return json.Marshal(combined)
+	}
+
+	// Suites with the same name are combined. A single suite is written as such, rather than
+	// wrapped in testsuites, if that is how every archive had it since that is what readers of
+	// the e2e results expect.
+	var suites JUnitTestSuites
+	bare := true
+	for _, b := range versions {
+		var result junitResult
+		if err := xml.Unmarshal(b, &result); err != nil {
+			return nil, errors.Wrap(err, "decoding junit")
+		}
+		bare = bare && !bytes.Contains(b, []byte("<testsuites"))
+		for _, s := range result.suites.Suites {
+			i := 0
+			for i < len(suites.Suites) && suites.Suites[i].Name != s.Name {
+				i++
+			}
+			if i == len(suites.Suites) {
+				suites.Suites = append(suites.Suites, s)
+				continue
+			}
+			existing := &suites.Suites[i]
+			existing.Tests += s.Tests
+			existing.Failures += s.Failures
+			existing.Time += s.Time
+			existing.Properties = append(existing.Properties, s.Properties...)
+			existing.TestCases = append(existing.TestCases, s.TestCases...)
+		}
+	}
+
+	var v interface{} = suites
+	if bare && len(suites.Suites) == 1 {
+		v = suites.Suites[0]
+	}
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding junit")
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// mergedItem combines the post-processed results of the plugin from each archive it ran in.
+func (p *mergePlan) mergedItem(target string) *Item {
+	var merged *Item
+	for _, src := range p.pluginSources[target] {
+		for name, t := range src.pluginNames {
+			if t != target || src.items[name] == nil {
+				continue
+			}
+			item := *src.items[name]
+			renameResultFiles(&item, src.renamed[target])
+			if merged == nil {
+				merged = &item
+				merged.Name = target
+				continue
+			}
+			merged.Items = mergeItems(merged.Items, item.Items)
+			merged.Status = mergeStatus(merged.Status, item.Status)
+		}
+	}
+	return merged
+}
+
+// renameResultFiles updates the items which refer to files that were renamed.
+func renameResultFiles(item *Item, renamed map[string]string) {
+	if len(renamed) == 0 {
+		return
+	}
+	if file := toSlash(item.Metadata[metadataFileKey]); file != "" {
+		if newFile, ok := renamed[file]; ok {
+			item.Metadata[metadataFileKey] = newFile
+			if item.Name == path.Base(file) {
+				item.Name = path.Base(newFile)
+			}
+		}
+	}
+	for i := range item.Items {
+		renameResultFiles(&item.Items[i], renamed)
+	}
+}
+
+// mergeItems adds the items to the existing ones, combining the children of items for the same
+// node, file or suite.
+func mergeItems(existing, items []Item) []Item {
+	for _, item := range items {
+		i := 0
+		for i < len(existing) && !sameBranch(existing[i], item) {
+			i++
+		}
+		if i == len(existing) {
+			existing = append(existing, item)
+			continue
+		}
+		existing[i].Items = mergeItems(existing[i].Items, item.Items)
+		existing[i].Status = mergeStatus(existing[i].Status, item.Status)
+	}
+	return existing
+}
+
+// sameBranch returns true if both items have children and are for the same node, file or suite.
+func sameBranch(a, b Item) bool {
+	return len(a.Items) > 0 && len(b.Items) > 0 && a.Name == b.Name &&
+		a.Metadata[metadataTypeKey] == b.Metadata[metadataTypeKey] &&
+		toSlash(a.Metadata[metadataFileKey]) == toSlash(b.Metadata[metadataFileKey])
+}
+
+// mergeStatus is the status of an item combining two items with the given statuses. As with
+// aggregateStatus, failures bubble up, followed by unknown statuses. Statuses set by manual
+// results are kept if they are the same and otherwise counted, as manualResultsAggregation does.
+func mergeStatus(a, b string) string {
+	if a == b {
+		return a
+	}
+	for _, s := range []string{a, b} {
+		switch s {
+		case StatusPassed, StatusFailed, StatusTimeout, StatusSkipped, StatusUnknown, "", StatusExpectedFailure, StatusUnexpectedPass:
+		default:
+			return manualResultsAggregation(Item{Status: a}, Item{Status: b})
+		}
+	}
+	switch {
+	case isFailureStatus(a), isFailureStatus(b):
+		return StatusFailed
+	case a == StatusUnknown, a == "", b == StatusUnknown, b == "":
+		return StatusUnknown
+	}
+	return StatusPassed
+}
+
+// toSlash converts Windows separators, which may be used in the metadata written by nodes, to
+// the slashes used within archives.
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
@@ -0,0 +1,281 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+)
+
+// mergeSource returns a source for Merge reading an archive of the given files.
+func mergeSource(t *testing.T, name string, files map[string]string) results.MergeSource {
+	b := makeArchive(t, files).Bytes()
+	return results.MergeSource{Name: name, Open: func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}}
+}
+
+// readArchive returns the contents of every file in the tar archive.
+func readArchive(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("Failed to read merged archive: %v", err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read %v: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = string(data)
+	}
+}
+
+func e2eRun(config, test, status string) map[string]string {
+	failure := ""
+	if status == results.StatusFailed {
+		failure = `<failure type="Failure">oops</failure>`
+	}
+	return map[string]string{
+		"meta/config.json":        config,
+		"meta/info.json":          `{"plugins":["e2e"]}`,
+		"meta/manifest.json":      `{"algorithm":"sha256","files":{}}`,
+		"meta/manifest.json.sig":  "signature",
+		"plugins/e2e/definition":  config,
+		"resources/cluster/nodes": config,
+		"plugins/e2e/results/global/junit_01.xml": `<testsuite tests="1" failures="0" time="1"><testcase name="` + test + `" classname="" time="1">` +
+			failure + `</testcase></testsuite>`,
+		"plugins/e2e/results/global/e2e.log": "log of " + test,
+		"plugins/e2e/sonobuoy_results.yaml": `name: e2e
+status: ` + status + `
+meta:
+  type: summary
+items:
+- name: global
+  status: ` + status + `
+  meta:
+    type: node
+  items:
+  - name: junit_01.xml
+    status: ` + status + `
+    meta:
+      file: results/global/junit_01.xml
+      type: file
+    items:
+    - name: ` + test + `
+      status: ` + status + `
+  - name: e2e.log
+    status: unknown
+    meta:
+      file: results/global/e2e.log
+      type: file
+`,
+	}
+}
+
+const (
+	config1 = `{"Description":"first","UUID":"uuid-1"}`
+	config2 = `{"Description":"second","UUID":"uuid-2"}`
+)
+
+func TestMerge(t *testing.T) {
+	first := e2eRun(config1, "a", results.StatusPassed)
+	second := e2eRun(config2, "b", results.StatusFailed)
+	second["meta/info.json"] = `{"plugins":["e2e","systemd-logs"]}`
+	second["plugins/systemd-logs/results/node1/out.json"] = "{}"
+	second["plugins/systemd-logs/sonobuoy_results.yaml"] = "name: systemd-logs\nstatus: passed\n"
+
+	buf := &bytes.Buffer{}
+	sources := []results.MergeSource{mergeSource(t, "a.tar.gz", first), mergeSource(t, "b.tar.gz", second)}
+	if err := results.Merge(buf, sources, results.MergeOptions{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files := readArchive(t, buf.Bytes())
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expectedNames := []string{
+		"meta/config.json",
+		"meta/info.json",
+		"meta/manifest.json",
+		"meta/merge.json",
+		"meta/sources/1/config.json",
+		"meta/sources/1/info.json",
+		"meta/sources/1/manifest.json",
+		"meta/sources/1/manifest.json.sig",
+		"meta/sources/2/config.json",
+		"meta/sources/2/info.json",
+		"meta/sources/2/manifest.json",
+		"meta/sources/2/manifest.json.sig",
+		"plugins/e2e/definition",
+		"plugins/e2e/results/global/e2e-2.log",
+		"plugins/e2e/results/global/e2e.log",
+		"plugins/e2e/results/global/junit_01.xml",
+		"plugins/e2e/sonobuoy_results.yaml",
+		"plugins/systemd-logs/results/node1/out.json",
+		"plugins/systemd-logs/sonobuoy_results.yaml",
+		"resources/cluster/nodes",
+	}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("Expected files\n%v\nbut got\n%v", strings.Join(expectedNames, "\n"), strings.Join(names, "\n"))
+	}
+
+	for name, expected := range map[string]string{
+		"meta/sources/1/config.json":                 config1,
+		"meta/sources/2/config.json":                 config2,
+		"meta/info.json":                             `{"plugins":["e2e","systemd-logs"]}`,
+		"plugins/e2e/definition":                     config1,
+		"plugins/e2e/results/global/e2e.log":         "log of a",
+		"plugins/e2e/results/global/e2e-2.log":       "log of b",
+		"plugins/systemd-logs/sonobuoy_results.yaml": second["plugins/systemd-logs/sonobuoy_results.yaml"],
+	} {
+		if files[name] != expected {
+			t.Errorf("Expected %v to be %q but got %q", name, expected, files[name])
+		}
+	}
+
+	// The config of the first run is kept but the merged archive gets a UUID of its own.
+	cfg := map[string]string{}
+	if err := json.Unmarshal([]byte(files["meta/config.json"]), &cfg); err != nil {
+		t.Fatalf("Failed to decode merged config: %v", err)
+	}
+	if cfg["Description"] != "first" {
+		t.Errorf("Expected the config of the first run but got %v", files["meta/config.json"])
+	}
+	if cfg["UUID"] == "" || cfg["UUID"] == "uuid-1" || cfg["UUID"] == "uuid-2" {
+		t.Errorf("Expected a new UUID in the merged config but got %q", cfg["UUID"])
+	}
+
+	info := results.MergeInfo{}
+	if err := json.Unmarshal([]byte(files["meta/merge.json"]), &info); err != nil {
+		t.Fatalf("Failed to decode merge info: %v", err)
+	}
+	expectedInfo := results.MergeInfo{Sources: []results.MergeSourceInfo{
+		{Archive: "a.tar.gz", Meta: "meta/sources/1", Plugins: map[string]string{"e2e": "e2e"}},
+		{Archive: "b.tar.gz", Meta: "meta/sources/2", Plugins: map[string]string{"e2e": "e2e", "systemd-logs": "systemd-logs"}},
+	}}
+	if !reflect.DeepEqual(info, expectedInfo) {
+		t.Errorf("Expected merge info %+v but got %+v", expectedInfo, info)
+	}
+
+	// The junit results are combined into the single suite the e2e results are expected to have.
+	suite := results.JUnitTestSuite{}
+	if err := xml.Unmarshal([]byte(files["plugins/e2e/results/global/junit_01.xml"]), &suite); err != nil {
+		t.Fatalf("Failed to decode combined junit results: %v", err)
+	}
+	if suite.Tests != 2 || len(suite.TestCases) != 2 || suite.TestCases[1].Failure == nil {
+		t.Errorf("Expected both tests in the combined suite but got %+v", suite)
+	}
+
+	// The merged archive is readable as usual with the results of e2e combined and its digests
+	// recomputed.
+	r := results.NewReaderWithVersion(bytes.NewReader(buf.Bytes()), results.VersionFifteen)
+	items, err := r.PluginResultsItems()
+	if err != nil {
+		t.Fatalf("Failed to read merged plugin results: %v", err)
+	}
+	e2e := items["e2e"]
+	if e2e == nil || e2e.Status != results.StatusFailed || len(e2e.Items) != 1 {
+		t.Fatalf("Expected combined e2e results but got %+v", e2e)
+	}
+	var leaves []string
+	for _, file := range e2e.Items[0].Items {
+		leaves = append(leaves, file.Name+":"+file.Metadata["file"])
+		for _, test := range file.Items {
+			leaves = append(leaves, test.Name+":"+test.Status)
+		}
+	}
+	expectedLeaves := []string{
+		"junit_01.xml:results/global/junit_01.xml", "a:passed", "b:failed",
+		"e2e.log:results/global/e2e.log",
+		"e2e-2.log:results/global/e2e-2.log",
+	}
+	if !reflect.DeepEqual(leaves, expectedLeaves) {
+		t.Errorf("Expected e2e results %v but got %v", expectedLeaves, leaves)
+	}
+
+	report, err := results.NewReaderWithVersion(bytes.NewReader(buf.Bytes()), results.VersionFifteen).VerifyDigests()
+	if err != nil {
+		t.Fatalf("Failed to verify merged digests: %v", err)
+	}
+	if !reflect.DeepEqual(report, &results.DigestReport{Verified: 4}) {
+		t.Errorf("Expected all plugin results to be verified but got %+v", report)
+	}
+}
+
+func TestMergeRenamePlugins(t *testing.T) {
+	first := e2eRun(config1, "a", results.StatusPassed)
+	second := e2eRun(config2, "b", results.StatusFailed)
+	delete(second, "meta/manifest.json")
+
+	buf := &bytes.Buffer{}
+	sources := []results.MergeSource{mergeSource(t, "a.tar.gz", first), mergeSource(t, "b.tar.gz", second)}
+	if err := results.Merge(buf, sources, results.MergeOptions{RenamePlugins: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files := readArchive(t, buf.Bytes())
+
+	for name, expected := range map[string]string{
+		"meta/info.json": `{"plugins":["e2e","e2e-2"]}`,
+		"plugins/e2e/results/global/junit_01.xml":   first["plugins/e2e/results/global/junit_01.xml"],
+		"plugins/e2e-2/results/global/junit_01.xml": second["plugins/e2e/results/global/junit_01.xml"],
+		"plugins/e2e/sonobuoy_results.yaml":         first["plugins/e2e/sonobuoy_results.yaml"],
+	} {
+		if files[name] != expected {
+			t.Errorf("Expected %v to be %q but got %q", name, expected, files[name])
+		}
+	}
+	if _, ok := files["meta/manifest.json"]; ok {
+		t.Errorf("Expected no manifest since not every archive had one")
+	}
+
+	r := results.NewReaderWithVersion(bytes.NewReader(buf.Bytes()), results.VersionFifteen)
+	items, err := r.PluginResultsItems()
+	if err != nil {
+		t.Fatalf("Failed to read merged plugin results: %v", err)
+	}
+	if items["e2e"].Status != results.StatusPassed {
+		t.Errorf("Expected e2e to have passed but got %v", items["e2e"].Status)
+	}
+	if item := items["e2e-2"]; item == nil || item.Name != "e2e-2" || item.Status != results.StatusFailed {
+		t.Errorf("Expected renamed e2e-2 results but got %+v", item)
+	}
+}
+
+func TestMergeNoSources(t *testing.T) {
+	if err := results.Merge(&bytes.Buffer{}, nil, results.MergeOptions{}); err == nil {
+		t.Error("Expected an error merging no archives")
+	}
+}
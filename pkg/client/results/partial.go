@@ -0,0 +1,79 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PartialInfoFile is the name of the file, in the meta directory of an archive of partial
+// results, which marks it as partial. Archives of complete runs don't have it.
+const PartialInfoFile = "partial.json"
+
+// PartialInfo describes an archive of the results received while the run was still in progress.
+type PartialInfo struct {
+	// CreatedAt is when the archive was made.
+	CreatedAt time.Time `json:"created"`
+
+	// Plugins are the plugins in the archive. Only complete plugins have been post-processed.
+	Plugins []PartialPluginInfo `json:"plugins"`
+}
+
+// PartialPluginInfo describes the state of a plugin when partial results were archived.
+type PartialPluginInfo struct {
+	Name string `json:"name"`
+
+	// Complete is true if all the results of the plugin had been received.
+	Complete bool `json:"complete"`
+}
+
+// PartialInfoFile returns the path to the file marking the archive as partial.
+func (r *Reader) PartialInfoFile() string {
+	return path.Join(metadataDir, PartialInfoFile)
+}
+
+// PartialInfo returns the info marking the archive as partial, or nil if it holds the results of
+// a complete run.
+func (r *Reader) PartialInfo() (*PartialInfo, error) {
+	var info *PartialInfo
+	err := r.WalkFiles(func(path string, fi os.FileInfo, err error) error {
+		if path != r.PartialInfoFile() {
+			return nil
+		}
+		info = &PartialInfo{}
+		if err := ExtractFileIntoStruct(path, path, fi, info); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	return info, errors.Wrap(err, "reading partial results info")
+}
+
+// IncompletePlugins returns the names of the plugins which were still running.
+func (p PartialInfo) IncompletePlugins() []string {
+	names := []string{}
+	for _, plugin := range p.Plugins {
+		if !plugin.Complete {
+			names = append(names, plugin.Name)
+		}
+	}
+	return names
+}
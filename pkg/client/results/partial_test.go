@@ -0,0 +1,73 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+)
+
+func TestPartialInfo(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		files            map[string]string
+		expect           *results.PartialInfo
+		expectIncomplete []string
+	}{
+		{
+			desc: "Complete run",
+			files: map[string]string{
+				"meta/config.json": "{}",
+				"meta/info.json":   `{"plugins":["e2e"]}`,
+			},
+		}, {
+			desc: "Partial results",
+			files: map[string]string{
+				"meta/config.json":  "{}",
+				"meta/info.json":    `{"plugins":["e2e"]}`,
+				"meta/partial.json": `{"created":"2021-01-01T12:00:00Z","plugins":[{"name":"e2e","complete":true},{"name":"systemd-logs","complete":false}]}`,
+			},
+			expect: &results.PartialInfo{
+				CreatedAt: time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC),
+				Plugins: []results.PartialPluginInfo{
+					{Name: "e2e", Complete: true},
+					{Name: "systemd-logs", Complete: false},
+				},
+			},
+			expectIncomplete: []string{"systemd-logs"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := results.NewReaderWithVersion(makeArchive(t, tc.files), results.VersionTen)
+			info, err := r.PartialInfo()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(info, tc.expect) {
+				t.Fatalf("Expected partial info %+v, got %+v", tc.expect, info)
+			}
+			if info != nil && !reflect.DeepEqual(info.IncompletePlugins(), tc.expectIncomplete) {
+				t.Errorf("Expected incomplete plugins %v, got %v", tc.expectIncomplete, info.IncompletePlugins())
+			}
+		})
+	}
+}
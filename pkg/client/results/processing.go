@@ -20,14 +20,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/daemonset"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -67,6 +71,14 @@ const (
 	// of entry in the tree it is. Currently we just tag summaries, files, and nodes.
 	metadataTypeKey = "type"
 
+	// metadataDurationKey is the key used in an Item's metadata field to record how long a test
+	// took to run, formatted as a time.Duration, when the result format provides it.
+	metadataDurationKey = "duration"
+
+	// maxResultLine is the longest line read from line based result formats (e.g. gotest and tap).
+	// Output lines can be long (e.g. dumps of objects) so this is well above bufio's default.
+	maxResultLine = 4 * 1024 * 1024
+
 	metadataTypeNode    = "node"
 	metadataTypeFile    = "file"
 	metadataTypeSummary = "summary"
@@ -75,10 +87,14 @@ const (
 // ResultFormat constants are the supported values for the resultFormat field
 // which enables post processing.
 const (
-	ResultFormatJUnit  = "junit"
-	ResultFormatE2E    = "e2e"
-	ResultFormatRaw    = "raw"
-	ResultFormatManual = "manual"
+	ResultFormatJUnit      = "junit"
+	ResultFormatE2E        = "e2e"
+	ResultFormatRaw        = "raw"
+	ResultFormatManual     = "manual"
+	ResultFormatGoTest     = "gotest"
+	ResultFormatTAP        = "tap"
+	ResultFormatGinkgoJSON = "ginkgo-json"
+	ResultFormatExternal   = "external"
 )
 
 // postProcessor is a function which takes two strings: the plugin directory and the
@@ -220,6 +236,12 @@ func aggregateStatus(items ...Item) string {
 	return StatusPassed
 }
 
+// secondsDuration formats a number of seconds, as recorded by most result formats, as the value
+// of metadataDurationKey.
+func secondsDuration(seconds float64) string {
+	return time.Duration(math.Round(seconds * float64(time.Second))).String()
+}
+
 // isFailureStatus returns true if the status is any one of the failure modes (e.g.
 // StatusFailed or StatusTimeout).
 func isFailureStatus(s string) bool {
@@ -229,8 +251,9 @@ func isFailureStatus(s string) bool {
 // PostProcessPlugin will inspect the files in the given directory (representing
 // the location of the results directory for a sonobuoy run, not the plugin specific
 // results directory). Based on the type of plugin results, it will record what tests
-// passed/failed (if junit) or record what files were produced (if raw) and return
-// that information in an Item object. All errors encountered are returned.
+// passed/failed (if junit, gotest, tap or ginkgo-json), record what files were produced
+// (if raw) or run the plugin's own result processor (if external) and return that
+// information in an Item object. All errors encountered are returned.
 func PostProcessPlugin(p plugin.Interface, dir string) (Item, []error) {
 	var i Item
 	var errs []error
@@ -238,11 +261,24 @@ func PostProcessPlugin(p plugin.Interface, dir string) (Item, []error) {
 	switch p.GetResultFormat() {
 	case ResultFormatE2E, ResultFormatJUnit:
 		i, errs = processPluginWithProcessor(p, dir, junitProcessFile, fileOrExtension(p.GetResultFiles(), ".xml"))
+	case ResultFormatGoTest:
+		i, errs = processPluginWithProcessor(p, dir, goTestProcessFile, fileOrExtension(p.GetResultFiles(), ".json"))
+	case ResultFormatTAP:
+		i, errs = processPluginWithProcessor(p, dir, tapProcessFile, fileOrExtension(p.GetResultFiles(), ".tap"))
+	case ResultFormatGinkgoJSON:
+		i, errs = processPluginWithProcessor(p, dir, ginkgoJSONProcessFile, fileOrExtension(p.GetResultFiles(), ".json"))
 	case ResultFormatRaw:
 		i, errs = processPluginWithProcessor(p, dir, rawProcessFile, fileOrAny(p.GetResultFiles()))
 	case ResultFormatManual:
 		// Only process the specified plugin result files or a Sonobuoy results file.
 		i, errs = processPluginWithProcessor(p, dir, manualProcessFile, fileOrDefault(p.GetResultFiles(), PostProcessedResultsFile))
+	case ResultFormatExternal:
+		var rp *manifest.ResultProcessor
+		if getter, ok := p.(resultProcessorGetter); ok {
+			rp = getter.GetResultProcessor()
+		}
+		resultsDir := path.Join(dir, PluginsDir, p.GetName(), ResultsDir)
+		i, errs = processPluginWithProcessor(p, dir, externalProcessor(rp), externalSelector(resultsDir))
 	default:
 		// Default to raw format so that consumers can still expect the aggregate file to exist and
 		// can navigate the output of the plugin more easily.
@@ -268,7 +304,7 @@ func processNodesWithProcessor(p plugin.Interface, baseDir, dir string, processo
 	results := []Item{}
 
 	for _, nodeDirInfo := range nodeDirs {
-		if !nodeDirInfo.IsDir() {
+		if !nodeDirInfo.IsDir() || isAttemptDir(nodeDirInfo.Name()) {
 			continue
 		}
 		nodeName := filepath.Base(nodeDirInfo.Name())
@@ -329,7 +365,7 @@ func processPluginWithProcessor(p plugin.Interface, baseDir string, processor po
 	results.Items = append(results.Items, items...)
 	results.Items = append(results.Items, errItems...)
 
-	if p.GetResultFormat() == ResultFormatManual {
+	if p.GetResultFormat() == ResultFormatManual || p.GetResultFormat() == ResultFormatExternal {
 		// The user provided most of the data which we don't want to interfere with; we just want to get the
 		// status value for the summary object we wrap their results with.
 
@@ -397,6 +433,11 @@ func errProcessor(pluginDir string, currentFile string) (Item, error) {
 
 	if isTimeoutErr(resultObj) {
 		resultObj.Status = StatusTimeout
+
+		// Older versions of Sonobuoy did not record the timeout used for the plugin.
+		if timeout, ok := resultObj.Details["timeout"]; ok {
+			resultObj.Name = fmt.Sprintf("%v (timed out after %v)", resultObj.Name, timeout)
+		}
 	}
 
 	return resultObj, nil
@@ -477,5 +518,23 @@ func fileOrAny(files []string) func(fPath string, info os.FileInfo) bool {
 }
 
 func errSelector() fileSelector {
-	return fileOrExtension([]string{DefaultErrFile}, "")
+	selector := fileOrExtension([]string{DefaultErrFile}, "")
+	return func(fpath string, info os.FileInfo) bool {
+		// Errors from attempts which were retried are kept for debugging but do not count
+		// against the plugin.
+		if isAttemptDir(filepath.Base(filepath.Dir(filepath.Dir(fpath)))) {
+			return false
+		}
+		return selector(fpath, info)
+	}
+}
+
+// isAttemptDir returns whether or not the directory name is one used to hold the errors from
+// a failed attempt of a plugin which was retried (e.g. attempt-1).
+func isAttemptDir(name string) bool {
+	if !strings.HasPrefix(name, plugin.AttemptDirPrefix) {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(name, plugin.AttemptDirPrefix))
+	return err == nil
 }
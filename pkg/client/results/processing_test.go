@@ -106,6 +106,26 @@ func TestPostProcessPlugin(t *testing.T) {
 			desc:   "Daemonset junit with 2 files processed, others ignored",
 			key:    "ds-junit-03",
 			plugin: getPlugin("ds-junit-03", "daemonset", "junit", []string{"output.xml", "output2.xml"}),
+		}, {
+			desc:   "Job gotest with json file processed, others ignored",
+			key:    "job-gotest-01",
+			plugin: getPlugin("job-gotest-01", "job", "gotest", []string{}),
+		}, {
+			desc:   "Job tap with tap file processed, others ignored",
+			key:    "job-tap-01",
+			plugin: getPlugin("job-tap-01", "job", "tap", []string{}),
+		}, {
+			desc:   "Job ginkgo-json with json report processed, others ignored",
+			key:    "job-ginkgo-01",
+			plugin: getPlugin("job-ginkgo-01", "job", "ginkgo-json", []string{}),
+		}, {
+			desc:   "Job external with results written by the plugin",
+			key:    "job-external-01",
+			plugin: getPlugin("job-external-01", "job", "external", []string{}),
+		}, {
+			desc:   "Daemonset external with invalid results on one node",
+			key:    "ds-external-01",
+			plugin: getPlugin("ds-external-01", "daemonset", "external", []string{}),
 		}, {
 			desc:   "Job raw with 2 files, all processed",
 			key:    "job-raw-02",
@@ -154,6 +174,18 @@ func TestPostProcessPlugin(t *testing.T) {
 			desc:   "Timeout errors cause timeout status",
 			key:    "job-timeout",
 			plugin: getPlugin("job-timeout", "job", "junit", []string{}),
+		}, {
+			desc:   "Timeout errors include the timeout in the name",
+			key:    "job-timeout-details",
+			plugin: getPlugin("job-timeout-details", "job", "junit", []string{}),
+		}, {
+			desc:   "Job errors from retried attempts are ignored",
+			key:    "job-attempts",
+			plugin: getPlugin("job-attempts", "job", "raw", []string{}),
+		}, {
+			desc:   "DS errors from retried attempts are ignored, final errors still considered",
+			key:    "ds-attempts",
+			plugin: getPlugin("ds-attempts", "daemonset", "raw", []string{}),
 		}, {
 			desc:   "Errors can contain complex structured data",
 			key:    "job-complex-err",
@@ -61,6 +61,8 @@ const (
 	defaultNodesFile          = "Nodes.json"
 	defaultServerVersionFile  = "serverversion.json"
 	defaultServerGroupsFile   = "servergroups.json"
+	defaultQueryTimeFile      = "query-time.json"
+	podLogsDir                = "podlogs/"
 
 	// InfoFile contains data not that isn't strictly in another location
 	// but still relevent to post-processing or understanding the run in some way.
@@ -324,6 +326,16 @@ func (r *Reader) ServerGroupsFile() string {
 	return defaultServerGroupsFile
 }
 
+// QueryTimeFile returns the path to the file recording how long each query of the cluster took.
+func (r *Reader) QueryTimeFile() string {
+	return path.Join(metadataDir, defaultQueryTimeFile)
+}
+
+// PodLogs returns the path to the directory that contains the logs of the pods Sonobuoy gathered.
+func (r *Reader) PodLogs() string {
+	return podLogsDir
+}
+
 // ConfigFile returns the path to the sonobuoy config file.
 // This is not a method as it is used to determine the version of the archive.
 func ConfigFile(version string) string {
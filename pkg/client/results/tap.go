@@ -0,0 +1,346 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// TAPSkipKey is the key in the Items.Details map for the reason a test was skipped.
+	TAPSkipKey = "skip"
+
+	// TAPTodoKey is the key in the Items.Details map for the reason a test is marked as todo.
+	TAPTodoKey = "todo"
+
+	// TAPDiagnosticsKey is the key in the Items.Details map for a YAML diagnostic block
+	// which couldn't be parsed as a map.
+	TAPDiagnosticsKey = "diagnostics"
+
+	// tapSubtestIndent is how far subtests are indented relative to their parent.
+	tapSubtestIndent = 4
+
+	// tapYAMLIndent is how far a YAML diagnostic block is indented relative to its test.
+	tapYAMLIndent = 2
+)
+
+var (
+	tapPlanRegexp     = regexp.MustCompile(`^1\.\.(\d+)\s*(?:#\s*(.*))?$`)
+	tapTestRegexp     = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-(?:\s|$))?\s*(.*)$`)
+	tapDirectiveRegex = regexp.MustCompile(`(?i)^(skip|todo)\S*\s*(.*)$`)
+	tapSubtestRegexp  = regexp.MustCompile(`^#\s*Subtest:?\s*(.*)$`)
+)
+
+// tapLine is a line of a TAP stream along with how far it is indented.
+type tapLine struct {
+	indent int
+	text   string
+}
+
+// tapBlock is the result of parsing the tests at one level of indentation.
+type tapBlock struct {
+	name    string
+	items   []Item
+	planned int
+	hasPlan bool
+	skipAll bool
+	bailed  bool
+}
+
+func tapProcessFile(pluginDir, currentFile string) (Item, error) {
+	relPath, err := filepath.Rel(pluginDir, currentFile)
+	if err != nil {
+		logrus.Errorf("Error making path %q relative to %q: %v", pluginDir, currentFile, err)
+		relPath = currentFile
+	}
+
+	resultObj := Item{
+		Name:   filepath.Base(currentFile),
+		Status: StatusUnknown,
+		Metadata: map[string]string{
+			metadataFileKey: relPath,
+			metadataTypeKey: metadataTypeFile,
+		},
+	}
+
+	infile, err := os.Open(currentFile)
+	if err != nil {
+		resultObj.Metadata["error"] = err.Error()
+		return resultObj, errors.Wrapf(err, "opening file %v", currentFile)
+	}
+	defer infile.Close()
+
+	resultObj, err = tapProcessReader(infile, resultObj.Name, resultObj.Metadata)
+	if err != nil {
+		return resultObj, errors.Wrap(err, "error processing tap")
+	}
+	return resultObj, nil
+}
+
+// tapProcessReader builds an Item from a TAP (version 13 or 14) stream with an item for each
+// test point. Subtests, indented by four spaces and followed by the test point that summarizes
+// them, are nested under it. YAML diagnostic blocks are added to the details of their test.
+func tapProcessReader(r io.Reader, name string, metadata map[string]string) (Item, error) {
+	rootItem := Item{
+		Name:     name,
+		Status:   StatusPassed,
+		Metadata: metadata,
+	}
+	if rootItem.Metadata == nil {
+		rootItem.Metadata = map[string]string{}
+	}
+
+	var lines []tapLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxResultLine)
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		lines = append(lines, tapLine{indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if err := scanner.Err(); err != nil {
+		rootItem.Status = StatusUnknown
+		rootItem.Metadata["error"] = err.Error()
+		return rootItem, errors.Wrap(err, "reading tap")
+	}
+
+	block, _ := parseTAPBlock(lines, 0)
+	rootItem.Items = block.items
+	if len(block.items) == 0 && !block.hasPlan {
+		rootItem.Status = StatusUnknown
+		rootItem.Metadata["error"] = "no tap test points or plan found"
+		return rootItem, errors.New("no tap test points or plan found")
+	}
+
+	switch {
+	case block.skipAll && len(block.items) == 0:
+		rootItem.Status = StatusSkipped
+	case aggregateTAPStatus(block.items) == StatusFailed:
+		rootItem.Status = StatusFailed
+	}
+	return rootItem, nil
+}
+
+// parseTAPBlock parses the lines indented by exactly indent, handing more deeply indented
+// subtests to a recursive call. It returns the block and the number of lines consumed, which
+// stops at the first line indented less than the block.
+func parseTAPBlock(lines []tapLine, indent int) (tapBlock, int) {
+	block := tapBlock{}
+	var pendingSubtest *tapBlock
+	var subtestName string
+	tests := 0
+
+	i := 0
+	for i < len(lines) && !block.bailed {
+		line := lines[i]
+		if line.text == "" {
+			i++
+			continue
+		}
+		if line.indent < indent {
+			break
+		}
+
+		// Subtests are indented further than their parent test point, which follows them.
+		if line.indent >= indent+tapSubtestIndent {
+			sub, n := parseTAPBlock(lines[i:], indent+tapSubtestIndent)
+			i += n
+			if sub.bailed {
+				block.items = append(block.items, sub.items...)
+				block.bailed = true
+				break
+			}
+			if sub.name == "" {
+				sub.name = subtestName
+			}
+			subtestName = ""
+			pendingSubtest = &sub
+			continue
+		}
+
+		// YAML diagnostics are only indented a little and belong to the previous test point.
+		if line.indent == indent+tapYAMLIndent && line.text == "---" && len(block.items) > 0 {
+			n := addTAPDiagnostics(&block.items[len(block.items)-1], lines[i+1:], line.indent)
+			i += n + 1
+			continue
+		}
+		i++
+		if line.indent != indent {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line.text, "Bail out!"):
+			block.items = append(block.items, Item{Name: line.text, Status: StatusFailed})
+			block.bailed = true
+		case tapPlanRegexp.MatchString(line.text):
+			m := tapPlanRegexp.FindStringSubmatch(line.text)
+			block.planned, _ = strconv.Atoi(m[1])
+			block.hasPlan = true
+			block.skipAll = block.planned == 0 && strings.HasPrefix(strings.ToLower(m[2]), "skip")
+		case tapSubtestRegexp.MatchString(line.text):
+			// TAP 14 puts the comment naming a subtest inside it, older producers before it.
+			subtestName = tapSubtestRegexp.FindStringSubmatch(line.text)[1]
+			if block.name == "" && len(block.items) == 0 {
+				block.name = subtestName
+			}
+		case tapTestRegexp.MatchString(line.text):
+			tests++
+			item := newTAPTestItem(line.text, tests)
+			if pendingSubtest != nil {
+				item.Items = pendingSubtest.items
+				pendingSubtest = nil
+			}
+			block.items = append(block.items, item)
+		}
+	}
+
+	// A subtest without a test point after it, e.g. because the stream was cut short.
+	if pendingSubtest != nil {
+		name := pendingSubtest.name
+		if name == "" {
+			name = "subtest"
+		}
+		block.items = append(block.items, Item{
+			Name:   name,
+			Status: aggregateTAPStatus(pendingSubtest.items),
+			Items:  pendingSubtest.items,
+		})
+	}
+
+	if block.hasPlan && !block.bailed && tests < block.planned {
+		block.items = append(block.items, Item{
+			Name:   fmt.Sprintf("%v of %v planned tests did not run", block.planned-tests, block.planned),
+			Status: StatusFailed,
+		})
+	}
+	return block, i
+}
+
+// newTAPTestItem parses a test point, e.g. "not ok 2 - description # TODO reason".
+func newTAPTestItem(text string, number int) Item {
+	m := tapTestRegexp.FindStringSubmatch(text)
+	ok := m[1] == "ok"
+	if m[2] != "" {
+		number, _ = strconv.Atoi(m[2])
+	}
+	description, directive := splitTAPDirective(m[3])
+	if description == "" {
+		description = fmt.Sprintf("test %v", number)
+	}
+
+	item := Item{Name: description, Status: StatusFailed}
+	if ok {
+		item.Status = StatusPassed
+	}
+
+	if d := tapDirectiveRegex.FindStringSubmatch(directive); d != nil {
+		switch strings.ToLower(d[1]) {
+		case TAPSkipKey:
+			item.Status = StatusSkipped
+			item.Details = map[string]interface{}{TAPSkipKey: d[2]}
+		case TAPTodoKey:
+			// Failing todo tests are expected to fail so don't count against the run.
+			if !ok {
+				item.Status = StatusSkipped
+			}
+			item.Details = map[string]interface{}{TAPTodoKey: d[2]}
+		}
+	}
+	return item
+}
+
+// splitTAPDirective splits the description of a test point from its directive, which follows
+// the first unescaped #. Escaped # and \ in the description are unescaped.
+func splitTAPDirective(s string) (string, string) {
+	var description strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '#' || s[i+1] == '\\'):
+			description.WriteByte(s[i+1])
+			i++
+		case s[i] == '#':
+			return strings.TrimSpace(description.String()), strings.TrimSpace(s[i+1:])
+		default:
+			description.WriteByte(s[i])
+		}
+	}
+	return strings.TrimSpace(description.String()), ""
+}
+
+// addTAPDiagnostics adds the YAML block, which ends with "..." at the given indent, to the
+// details of the item. It returns the number of lines consumed, including the end marker.
+func addTAPDiagnostics(item *Item, lines []tapLine, indent int) int {
+	var doc strings.Builder
+	n := 0
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		if line.indent == indent && line.text == "..." {
+			n++
+			break
+		}
+		if line.text != "" && line.indent < indent {
+			break
+		}
+		if line.text != "" {
+			doc.WriteString(strings.Repeat(" ", line.indent-indent))
+		}
+		doc.WriteString(line.text)
+		doc.WriteString("\n")
+	}
+
+	if item.Details == nil {
+		item.Details = map[string]interface{}{}
+	}
+	diagnostics := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(doc.String()), &diagnostics); err != nil {
+		item.Details[TAPDiagnosticsKey] = doc.String()
+		return n
+	}
+	for k, v := range diagnostics {
+		item.Details[k] = v
+	}
+
+	// Some producers, such as node-tap, record how long the test took.
+	if ms, ok := diagnostics["duration_ms"].(float64); ok {
+		item.Metadata = map[string]string{metadataDurationKey: time.Duration(ms * float64(time.Millisecond)).String()}
+	} else if ms, ok := diagnostics["duration_ms"].(int); ok {
+		item.Metadata = map[string]string{metadataDurationKey: (time.Duration(ms) * time.Millisecond).String()}
+	}
+	return n
+}
+
+func aggregateTAPStatus(items []Item) string {
+	for _, item := range items {
+		if isFailureStatus(item.Status) {
+			return StatusFailed
+		}
+	}
+	return StatusPassed
+}
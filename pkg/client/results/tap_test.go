@@ -0,0 +1,147 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestTAPProcessReader(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		input     io.Reader
+		expect    Item
+		expectErr string
+
+		// Allows updating the expected item via the update flag. Will load/set `expect` field in test.
+		expectItemFromFile string
+	}{
+		{
+			desc:  "No test points or plan",
+			input: bytes.NewBufferString("just some output\n"),
+			expect: Item{
+				Name:     "out.tap",
+				Status:   StatusUnknown,
+				Metadata: map[string]string{"error": "no tap test points or plan found"},
+			},
+			expectErr: "no tap test points or plan found",
+		}, {
+			desc:  "Skip all plan",
+			input: bytes.NewBufferString("1..0 # SKIP no GPUs\n"),
+			expect: Item{
+				Name:     "out.tap",
+				Status:   StatusSkipped,
+				Metadata: map[string]string{},
+			},
+		}, {
+			desc:  "Fewer tests than planned",
+			input: bytes.NewBufferString("1..3\nok 1 - a\nok - b\n"),
+			expect: Item{
+				Name:     "out.tap",
+				Status:   StatusFailed,
+				Metadata: map[string]string{},
+				Items: []Item{
+					{Name: "a", Status: StatusPassed},
+					{Name: "b", Status: StatusPassed},
+					{Name: "1 of 3 planned tests did not run", Status: StatusFailed},
+				},
+			},
+		}, {
+			desc:  "Subtest cut short is named by its comment",
+			input: bytes.NewBufferString("ok 1 - a\n# Subtest: b\n    ok 1 - c\n"),
+			expect: Item{
+				Name:     "out.tap",
+				Status:   StatusPassed,
+				Metadata: map[string]string{},
+				Items: []Item{
+					{Name: "a", Status: StatusPassed},
+					{Name: "b", Status: StatusPassed, Items: []Item{{Name: "c", Status: StatusPassed}}},
+				},
+			},
+		}, {
+			desc:               "TAP 14 with directives, diagnostics and subtests",
+			input:              readerFromFile(t, filepath.Join("testdata", "tap14.tap")),
+			expectItemFromFile: filepath.Join("testdata", "item_tap14.json"),
+		}, {
+			desc:               "TAP 13 which bails out",
+			input:              readerFromFile(t, filepath.Join("testdata", "tap13-bailout.tap")),
+			expectItemFromFile: filepath.Join("testdata", "item_tap13_bailout.json"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			item, err := tapProcessReader(tc.input, "out.tap", nil)
+
+			if *update && len(tc.expectItemFromFile) > 0 {
+				b, err := json.MarshalIndent(item, "", "")
+				if err != nil {
+					t.Fatalf("Failed to marshal expected Item for debug: %v", err)
+				}
+				t.Logf("Updating goldenfile %v", tc.expectItemFromFile)
+				ioutil.WriteFile(tc.expectItemFromFile, b, 0666)
+				return
+			}
+
+			if len(tc.expectItemFromFile) > 0 {
+				b, err := ioutil.ReadFile(tc.expectItemFromFile)
+				if err != nil {
+					t.Fatalf("Failed to read test file %v: %v", tc.expectItemFromFile, err)
+				}
+				if err := json.Unmarshal(b, &tc.expect); err != nil {
+					t.Fatalf("Failed to unmarshal test data: %v", err)
+				}
+			}
+			if diff := pretty.Compare(item, tc.expect); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+			if err != nil && fmt.Sprint(err) != tc.expectErr {
+				t.Errorf("Expected error to be %q but got %q", tc.expectErr, err)
+			}
+			if err == nil && len(tc.expectErr) > 0 {
+				t.Errorf("Expected error %q but got nil", tc.expectErr)
+			}
+		})
+	}
+}
+
+func TestSplitTAPDirective(t *testing.T) {
+	tcs := []struct {
+		input, description, directive string
+	}{
+		{input: "plain", description: "plain"},
+		{input: "name # SKIP reason", description: "name", directive: "SKIP reason"},
+		{input: `issue \#12 # todo`, description: "issue #12", directive: "todo"},
+		{input: `back\\slash`, description: `back\slash`},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.input, func(t *testing.T) {
+			description, directive := splitTAPDirective(tc.input)
+			if description != tc.description || directive != tc.directive {
+				t.Errorf("Expected (%q, %q) but got (%q, %q)", tc.description, tc.directive, description, directive)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+const (
+	// DurationRegressionRatio is how many times longer than in the baseline a test must take for
+	// it to be considered a duration regression.
+	DurationRegressionRatio = 1.2
+
+	// DurationRegressionMin is how much longer than in the baseline a test must take for it to be
+	// considered a duration regression, so that tiny tests don't dominate.
+	DurationRegressionMin = time.Second
+)
+
+// TestDuration is how long a single test took.
+type TestDuration struct {
+	TestResult
+	Duration time.Duration `json:"duration"`
+}
+
+// DurationTotal is the total time taken by a group of tests, such as a suite or a node.
+type DurationTotal struct {
+	Name     string        `json:"name"`
+	Tests    int           `json:"tests"`
+	Duration time.Duration `json:"duration"`
+}
+
+// TimingReport summarizes how long the tests of a plugin took. Only tests whose results record
+// their duration are included.
+type TimingReport struct {
+	Plugin   string        `json:"plugin"`
+	Tests    int           `json:"tests"`
+	Duration time.Duration `json:"duration"`
+
+	// Slowest are the slowest tests, slowest first.
+	Slowest []TestDuration `json:"slowest"`
+
+	// Suites are the totals of the tests grouped by the items leading to them (e.g. the junit test
+	// suite or the go package), slowest first.
+	Suites []DurationTotal `json:"suites"`
+
+	// Nodes are the totals of the tests run on each node, slowest first. It is empty for plugins
+	// whose results aren't split by node.
+	Nodes []DurationTotal `json:"nodes,omitempty"`
+}
+
+// DurationChange is a test which took longer than in a baseline run.
+type DurationChange struct {
+	TestResult
+	Old time.Duration `json:"old"`
+	New time.Duration `json:"new"`
+}
+
+// Increase is how much longer the test took than in the baseline.
+func (c DurationChange) Increase() time.Duration {
+	return c.New - c.Old
+}
+
+// Timing returns how long the tests of the plugin took, with up to the given number of the
+// slowest tests.
+func Timing(pluginName string, item *Item, slowest int) TimingReport {
+	report := TimingReport{Plugin: pluginName}
+	suites, nodes := map[string]*DurationTotal{}, map[string]*DurationTotal{}
+	add := func(totals map[string]*DurationTotal, name string, d time.Duration) {
+		if totals[name] == nil {
+			totals[name] = &DurationTotal{Name: name}
+		}
+		totals[name].Tests++
+		totals[name].Duration += d
+	}
+
+	var tests []TestDuration
+	walkTestsInFiles(item, func(node, file string, names []string, leaf *Item) {
+		d, err := time.ParseDuration(leaf.Metadata[metadataDurationKey])
+		if err != nil {
+			return
+		}
+		tests = append(tests, TestDuration{
+			TestResult: TestResult{Plugin: pluginName, Node: node, Name: strings.Join(append(names, leaf.Name), testPathSeparator), Status: leaf.Status},
+			Duration:   d,
+		})
+		report.Tests++
+		report.Duration += d
+
+		suite := strings.Join(names, testPathSeparator)
+		if suite == "" {
+			suite = file
+		}
+		if suite == "" {
+			suite = pluginName
+		}
+		add(suites, suite, d)
+		add(nodes, node, d)
+	})
+
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Duration > tests[j].Duration })
+	if len(tests) > slowest {
+		tests = tests[:slowest]
+	}
+	report.Slowest = tests
+	report.Suites = sortedTotals(suites)
+	if _, ok := nodes[plugin.GlobalResult]; !ok || len(nodes) > 1 {
+		report.Nodes = sortedTotals(nodes)
+	}
+	return report
+}
+
+func sortedTotals(totals map[string]*DurationTotal) []DurationTotal {
+	list := make([]DurationTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Duration != list[j].Duration {
+			return list[i].Duration > list[j].Duration
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// DurationRegressions compares how long the tests of the plugin took with a baseline run and
+// returns those which took at least DurationRegressionRatio times and DurationRegressionMin
+// longer, largest increase first. Tests are identified as they are in a Diff; if a test is
+// reported more than once, its longest duration is used.
+func DurationRegressions(pluginName string, baseline, item *Item) []DurationChange {
+	oldDurations, newDurations := testDurations(baseline), testDurations(item)
+
+	var changes []DurationChange
+	for key, d := range newDurations {
+		old, ok := oldDurations[key]
+		if !ok || d-old < DurationRegressionMin || float64(d) < float64(old)*DurationRegressionRatio {
+			continue
+		}
+		changes = append(changes, DurationChange{
+			TestResult: TestResult{Plugin: pluginName, Node: key.node, Name: key.name},
+			Old:        old,
+			New:        d,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Increase() != changes[j].Increase() {
+			return changes[i].Increase() > changes[j].Increase()
+		}
+		if changes[i].Node != changes[j].Node {
+			return changes[i].Node < changes[j].Node
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// testDurations returns the duration of every leaf of the item tree which records one, keyed
+// the same way as testStatuses.
+func testDurations(root *Item) map[testKey]time.Duration {
+	durations := map[testKey]time.Duration{}
+	walkTests(root, func(node string, names []string, leaf *Item) {
+		d, err := time.ParseDuration(leaf.Metadata[metadataDurationKey])
+		if err != nil {
+			return
+		}
+		key := testKey{node: node, name: strings.Join(append(names, leaf.Name), testPathSeparator)}
+		if existing, ok := durations[key]; !ok || d > existing {
+			durations[key] = d
+		}
+	})
+	return durations
+}
@@ -0,0 +1,108 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// timedTest is a test item which took the given duration, or has no duration if it is empty.
+func timedTest(name, duration string) Item {
+	item := Item{Name: name, Status: StatusPassed}
+	if duration != "" {
+		item.Metadata = map[string]string{metadataDurationKey: duration}
+	}
+	return item
+}
+
+func timedNode(name string, tests ...Item) Item {
+	return Item{Name: name, Status: StatusPassed, Metadata: map[string]string{metadataTypeKey: metadataTypeNode}, Items: []Item{{
+		Name: "junit_01.xml", Status: StatusPassed, Metadata: map[string]string{metadataTypeKey: metadataTypeFile, metadataFileKey: "results/" + name + "/junit_01.xml"},
+		Items: []Item{{Name: "suite", Status: StatusPassed, Items: tests}},
+	}}}
+}
+
+func TestTiming(t *testing.T) {
+	item := &Item{Name: "e2e", Items: []Item{
+		timedNode("node1", timedTest("a", "3s"), timedTest("b", "1s"), timedTest("untimed", "")),
+		timedNode("node2", timedTest("a", "5s"), timedTest("c", "500ms")),
+	}}
+
+	expected := TimingReport{
+		Plugin:   "e2e",
+		Tests:    4,
+		Duration: 9500 * time.Millisecond,
+		Slowest: []TestDuration{
+			{TestResult: TestResult{Plugin: "e2e", Node: "node2", Name: "suite|a", Status: StatusPassed}, Duration: 5 * time.Second},
+			{TestResult: TestResult{Plugin: "e2e", Node: "node1", Name: "suite|a", Status: StatusPassed}, Duration: 3 * time.Second},
+			{TestResult: TestResult{Plugin: "e2e", Node: "node1", Name: "suite|b", Status: StatusPassed}, Duration: time.Second},
+		},
+		Suites: []DurationTotal{{Name: "suite", Tests: 4, Duration: 9500 * time.Millisecond}},
+		Nodes: []DurationTotal{
+			{Name: "node2", Tests: 2, Duration: 5500 * time.Millisecond},
+			{Name: "node1", Tests: 2, Duration: 4 * time.Second},
+		},
+	}
+	if diff := pretty.Compare(Timing("e2e", item, 3), expected); diff != "" {
+		t.Errorf("\n\n%s\n", diff)
+	}
+
+	// Plugins which aren't split by node have no node totals and tests outside of any suite are
+	// grouped by their file.
+	job := &Item{Name: "job", Items: []Item{{
+		Name: "out.tap", Status: StatusPassed, Metadata: map[string]string{metadataTypeKey: metadataTypeFile, metadataFileKey: "results/global/out.tap"},
+		Items: []Item{timedTest("x", "2s")},
+	}}}
+	report := Timing("job", job, 10)
+	if diff := pretty.Compare(report.Suites, []DurationTotal{{Name: "out.tap", Tests: 1, Duration: 2 * time.Second}}); diff != "" {
+		t.Errorf("\n\n%s\n", diff)
+	}
+	if report.Nodes != nil {
+		t.Errorf("Expected no node totals but got %v", report.Nodes)
+	}
+}
+
+func TestDurationRegressions(t *testing.T) {
+	baseline := &Item{Name: "e2e", Items: []Item{timedNode("node1",
+		timedTest("slower", "10s"),
+		timedTest("slightly slower", "10s"),
+		timedTest("tiny but doubled", "100ms"),
+		timedTest("much slower", "1s"),
+		timedTest("faster", "10s"),
+		timedTest("untimed", ""),
+	)}}
+	item := &Item{Name: "e2e", Items: []Item{timedNode("node1",
+		timedTest("slower", "13s"),
+		timedTest("slightly slower", "11s"),
+		timedTest("tiny but doubled", "200ms"),
+		timedTest("much slower", "30s"),
+		timedTest("faster", "1s"),
+		timedTest("untimed", "1h"),
+		timedTest("new", "1h"),
+	)}}
+
+	expected := []DurationChange{
+		{TestResult: TestResult{Plugin: "e2e", Node: "node1", Name: "suite|much slower"}, Old: time.Second, New: 30 * time.Second},
+		{TestResult: TestResult{Plugin: "e2e", Node: "node1", Name: "suite|slower"}, Old: 10 * time.Second, New: 13 * time.Second},
+	}
+	if diff := pretty.Compare(DurationRegressions("e2e", baseline, item), expected); diff != "" {
+		t.Errorf("\n\n%s\n", diff)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+// DigestReport is the outcome of checking the plugin results in an archive against the
+// digests recorded by the aggregator when it received them.
+type DigestReport struct {
+	// Verified is the number of files whose digest matched.
+	Verified int
+
+	// Missing are the files listed in the manifest which are not in the archive.
+	Missing []string
+
+	// Modified are the files whose digest doesn't match the manifest.
+	Modified []string
+
+	// Unexpected are the plugin results or errors in the archive which aren't listed
+	// in the manifest.
+	Unexpected []string
+}
+
+// OK returns true if every file matched the manifest.
+func (d *DigestReport) OK() bool {
+	return len(d.Missing) == 0 && len(d.Modified) == 0 && len(d.Unexpected) == 0
+}
+
+// String summarizes the problems found, one per line.
+func (d *DigestReport) String() string {
+	if d.OK() {
+		return fmt.Sprintf("%v files verified", d.Verified)
+	}
+
+	var b strings.Builder
+	for _, f := range d.Modified {
+		fmt.Fprintf(&b, "modified: %v\n", f)
+	}
+	for _, f := range d.Missing {
+		fmt.Fprintf(&b, "missing: %v\n", f)
+	}
+	for _, f := range d.Unexpected {
+		fmt.Fprintf(&b, "unexpected: %v\n", f)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// ManifestFile returns the path to the manifest of the digests of the plugin results. It was
+// added after v0.52.0; the function will return the same string even for earlier versions
+// where that file does not exist.
+func (r *Reader) ManifestFile() string {
+	return path.Join(metadataDir, aggregation.ManifestFile)
+}
+
+// ManifestSignatureFile returns the path to the detached signature of the results manifest,
+// which only exists if the aggregator was given a key to sign the results with. It was added
+// after v0.52.0.
+func (r *Reader) ManifestSignatureFile() string {
+	return r.ManifestFile() + signature.FileSuffix
+}
+
+// VerifyDigests reads the whole archive and checks the plugin results in it against the
+// manifest written by the aggregator. An error is returned if the archive can't be read
+// or has no manifest.
+func (r *Reader) VerifyDigests() (*DigestReport, error) {
+	return r.verifyDigests(nil)
+}
+
+// VerifySignedDigests is like VerifyDigests but also checks the signature of the manifest
+// with the given public key. An error is returned if the manifest isn't signed by it.
+func (r *Reader) VerifySignedDigests(key crypto.PublicKey) (*DigestReport, error) {
+	if key == nil {
+		return nil, errors.New("no public key to verify the results manifest with")
+	}
+	return r.verifyDigests(key)
+}
+
+// verifyDigests checks the plugin results against the manifest, first checking the signature
+// of the manifest if a key is given.
+func (r *Reader) verifyDigests(key crypto.PublicKey) (*DigestReport, error) {
+	var manifestBytes, manifestSig []byte
+	actual := map[string]string{}
+
+	err := r.WalkFiles(func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		var dest *[]byte
+		switch filePath {
+		case r.ManifestFile():
+			dest = &manifestBytes
+		case r.ManifestSignatureFile():
+			dest = &manifestSig
+		}
+		if dest == nil && (!info.Mode().IsRegular() || !isPluginResultPath(filePath)) {
+			return nil
+		}
+
+		reader, ok := info.Sys().(io.Reader)
+		if !ok {
+			return errors.New("info.Sys() is not a reader")
+		}
+		if dest != nil {
+			*dest, err = ioutil.ReadAll(reader)
+			return errors.Wrapf(err, "couldn't read %v", filePath)
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, reader); err != nil {
+			return errors.Wrapf(err, "couldn't read %v", filePath)
+		}
+		actual[filePath] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifestBytes == nil {
+		return nil, fmt.Errorf("archive has no results manifest (%v); it may have been created by an older version of Sonobuoy", r.ManifestFile())
+	}
+	if key != nil {
+		if manifestSig == nil {
+			return nil, fmt.Errorf("archive has no results manifest signature (%v); the results were not signed", r.ManifestSignatureFile())
+		}
+		if err := signature.Verify(key, bytes.NewReader(manifestBytes), manifestSig); err != nil {
+			return nil, errors.Wrap(err, "couldn't verify results manifest signature")
+		}
+	}
+
+	manifest := &aggregation.ResultsManifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return nil, errors.Wrap(err, "couldn't decode results manifest")
+	}
+	if manifest.Algorithm != aggregation.DigestAlgorithm {
+		return nil, fmt.Errorf("unsupported digest algorithm %q in results manifest", manifest.Algorithm)
+	}
+
+	report := &DigestReport{}
+	for name, expected := range manifest.Files {
+		got, ok := actual[name]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, name)
+		case !strings.EqualFold(got, expected):
+			report.Modified = append(report.Modified, name)
+		default:
+			report.Verified++
+		}
+	}
+	for name := range actual {
+		if _, ok := manifest.Files[name]; !ok {
+			report.Unexpected = append(report.Unexpected, name)
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Modified)
+	sort.Strings(report.Unexpected)
+	return report, nil
+}
+
+// isPluginResultPath returns true for the paths of plugin results and errors, which are what
+// the aggregator records in the manifest, e.g. plugins/<plugin>/results/... and
+// plugins/<plugin>/errors/...
+func isPluginResultPath(p string) bool {
+	parts := strings.SplitN(p, "/", 4)
+	if len(parts) < 4 || parts[0]+"/" != PluginsDir {
+		return false
+	}
+	return parts[2]+"/" == ResultsDir || parts[2]+"/" == ErrorsDir
+}
@@ -0,0 +1,204 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+func digestOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// makeArchive returns an uncompressed tar archive holding the given files.
+func makeArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	for _, name := range names {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatalf("Failed to write tar file: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	return buf
+}
+
+func TestVerifyDigests(t *testing.T) {
+	manifest := func(files map[string]string) string {
+		b, err := json.Marshal(aggregation.ResultsManifest{Algorithm: aggregation.DigestAlgorithm, Files: files})
+		if err != nil {
+			t.Fatalf("Failed to encode manifest: %v", err)
+		}
+		return string(b)
+	}
+
+	testCases := []struct {
+		desc      string
+		files     map[string]string
+		expected  *results.DigestReport
+		expectErr bool
+	}{
+		{
+			desc: "Unmodified archive",
+			files: map[string]string{
+				"meta/manifest.json":                   manifest(map[string]string{"plugins/e2e/results/global/junit.xml": digestOf("junit")}),
+				"plugins/e2e/results/global/junit.xml": "junit",
+				"plugins/e2e/sonobuoy_results.yaml":    "not a result",
+			},
+			expected: &results.DigestReport{Verified: 1},
+		}, {
+			desc: "Modified, missing and unexpected files",
+			files: map[string]string{
+				"meta/manifest.json": manifest(map[string]string{
+					"plugins/e2e/results/global/junit.xml":        digestOf("junit"),
+					"plugins/e2e/results/global/e2e.log":          digestOf("log"),
+					"plugins/systemd-logs/results/node1/out.json": digestOf("{}"),
+				}),
+				"plugins/e2e/results/global/junit.xml":        "tampered",
+				"plugins/e2e/errors/global/error.json":        "{}",
+				"plugins/systemd-logs/results/node1/out.json": "{}",
+			},
+			expected: &results.DigestReport{
+				Verified:   1,
+				Missing:    []string{"plugins/e2e/results/global/e2e.log"},
+				Modified:   []string{"plugins/e2e/results/global/junit.xml"},
+				Unexpected: []string{"plugins/e2e/errors/global/error.json"},
+			},
+		}, {
+			desc: "No manifest",
+			files: map[string]string{
+				"plugins/e2e/results/global/junit.xml": "junit",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := results.NewReaderWithVersion(makeArchive(t, tc.files), results.VersionFifteen)
+			report, err := r.VerifyDigests()
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error %v but got %v", tc.expectErr, err)
+			}
+			if !reflect.DeepEqual(report, tc.expected) {
+				t.Errorf("Expected report %+v but got %+v", tc.expected, report)
+			}
+		})
+	}
+}
+
+func TestVerifySignedDigests(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(aggregation.ResultsManifest{
+		Algorithm: aggregation.DigestAlgorithm,
+		Files:     map[string]string{"plugins/e2e/results/global/junit.xml": digestOf("junit")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode manifest: %v", err)
+	}
+	manifest := string(b)
+	sig, err := signature.Sign(key, strings.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("Failed to sign manifest: %v", err)
+	}
+
+	testCases := []struct {
+		desc      string
+		files     map[string]string
+		expectErr bool
+	}{
+		{
+			desc: "Signed manifest",
+			files: map[string]string{
+				"meta/manifest.json":                   manifest,
+				"meta/manifest.json.sig":               string(sig),
+				"plugins/e2e/results/global/junit.xml": "junit",
+			},
+		}, {
+			desc: "Unsigned manifest",
+			files: map[string]string{
+				"meta/manifest.json":                   manifest,
+				"plugins/e2e/results/global/junit.xml": "junit",
+			},
+			expectErr: true,
+		}, {
+			desc: "Modified manifest",
+			files: map[string]string{
+				"meta/manifest.json":                   strings.Replace(manifest, digestOf("junit"), digestOf("tampered"), 1),
+				"meta/manifest.json.sig":               string(sig),
+				"plugins/e2e/results/global/junit.xml": "tampered",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := results.NewReaderWithVersion(makeArchive(t, tc.files), results.VersionFifteen)
+			report, err := r.VerifySignedDigests(key.Public())
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error %v but got %v", tc.expectErr, err)
+			}
+			if err == nil && (!report.OK() || report.Verified != 1) {
+				t.Errorf("Expected 1 file to be verified but got %+v", report)
+			}
+		})
+	}
+
+	t.Run("Wrong key", func(t *testing.T) {
+		r := results.NewReaderWithVersion(makeArchive(t, testCases[0].files), results.VersionFifteen)
+		if _, err := r.VerifySignedDigests(other.Public()); err == nil {
+			t.Error("Expected manifest signed with a different key not to verify")
+		}
+	})
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/briandowns/spinner"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
 	"golang.org/x/crypto/ssh/terminal"
 	kubeerror "k8s.io/apimachinery/pkg/api/errors"
@@ -49,6 +50,13 @@ const (
 	stdinFile = "-"
 )
 
+// sharedObjects are the namespaced objects, by resource, which all the runs in a namespace use.
+// They are left as they are if a previous run already created them.
+var sharedObjects = map[string]string{
+	"serviceaccounts": "sonobuoy-serviceaccount",
+	"secrets":         "ssh-key",
+}
+
 // RunManifest is the same as Run(*RunConfig) execpt that the []byte given
 // should represent the output from `sonobuoy gen`, a series of YAML resources
 // separated by `---`. This method will disregard the RunConfig.GenConfig
@@ -57,7 +65,7 @@ func (c *SonobuoyClient) RunManifest(cfg *RunConfig, manifest []byte) error {
 	buf := bytes.NewBuffer(manifest)
 	d := yaml.NewYAMLOrJSONDecoder(buf, bufferSize)
 
-	var createdNamespace string
+	var createdNamespace, runID string
 	for {
 		ext := runtime.RawExtension{}
 		if err := d.Decode(&ext); err != nil {
@@ -92,6 +100,9 @@ func (c *SonobuoyClient) RunManifest(cfg *RunConfig, manifest []byte) error {
 		if createdNamespace == "" && namespace != "" {
 			createdNamespace = namespace
 		}
+		if id, ok := obj.GetLabels()[plugin.RunIDLabel]; ok && runID == "" {
+			runID = id
+		}
 
 		// err is used to determine output for user; but first extract resource
 		_, err = c.dynamicClient.CreateObject(obj)
@@ -110,7 +121,7 @@ func (c *SonobuoyClient) RunManifest(cfg *RunConfig, manifest []byte) error {
 		seenStatus := false
 		runCondition := func() (bool, error) {
 			// Get the Aggregator pod and check if its status is completed or terminated.
-			status, err := c.GetStatus(&StatusConfig{Namespace: createdNamespace})
+			status, err := c.GetStatus(&StatusConfig{Namespace: createdNamespace, RunID: runID})
 			switch {
 			case err != nil && seenStatus:
 				return false, errors.Wrap(err, "failed to get status")
@@ -201,6 +212,9 @@ func handleCreateError(name, namespace, resource string, err error) error {
 	// in this case.
 	case namespace == "" && kubeerror.IsAlreadyExists(err):
 		log.Info("object already exists")
+	// Likewise for the objects shared by the runs in a namespace.
+	case sharedObjects[resource] == name && kubeerror.IsAlreadyExists(err):
+		log.Info("object already exists")
 	case err != nil:
 		return errors.Wrapf(err, "failed to create API resource %s", name)
 	}
@@ -19,6 +19,9 @@ package client
 import (
 	"strings"
 	"testing"
+
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestRunInvalidConfig(t *testing.T) {
@@ -68,3 +71,47 @@ func TestRunInvalidConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleCreateError(t *testing.T) {
+	exists := func(resource, name string) error {
+		return kubeerror.NewAlreadyExists(schema.GroupResource{Resource: resource}, name)
+	}
+
+	testcases := []struct {
+		desc          string
+		name          string
+		namespace     string
+		resource      string
+		err           error
+		expectedError bool
+	}{
+		{
+			desc:     "Cluster-wide objects may already exist",
+			name:     "sonobuoy-serviceaccount-sonobuoy",
+			resource: "clusterroles",
+			err:      exists("clusterroles", "sonobuoy-serviceaccount-sonobuoy"),
+		}, {
+			desc:      "The service account is shared by the runs in a namespace",
+			name:      "sonobuoy-serviceaccount",
+			namespace: "sonobuoy",
+			resource:  "serviceaccounts",
+			err:       exists("serviceaccounts", "sonobuoy-serviceaccount"),
+		}, {
+			desc:          "Other namespaced objects belong to a single run",
+			name:          "sonobuoy-config-cm",
+			namespace:     "sonobuoy",
+			resource:      "configmaps",
+			err:           exists("configmaps", "sonobuoy-config-cm"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := handleCreateError(tc.name, tc.namespace, tc.resource, tc.err)
+			if tc.expectedError != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+const aggregatorComponentLabel = "sonobuoy-component=aggregator"
+
+// RunSummary describes a run of Sonobuoy found in the cluster.
+type RunSummary struct {
+	// ID is the ID of the run. It is empty for runs started without one.
+	ID string
+
+	// Namespace is the namespace of the aggregator of the run.
+	Namespace string
+
+	// Phase is the status reported by the aggregator, e.g. running or complete, or the phase of
+	// its pod if it hasn't reported one yet.
+	Phase string
+
+	// Created is when the aggregator pod was created.
+	Created time.Time
+}
+
+// ListRuns returns the runs of Sonobuoy in all namespaces, oldest first.
+func (c *SonobuoyClient) ListRuns() ([]RunSummary, error) {
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+	return listRuns(client)
+}
+
+// FindRun returns the run with the given ID, which may be shortened to any prefix which only
+// matches one of the runs in the cluster.
+func (c *SonobuoyClient) FindRun(id string) (*RunSummary, error) {
+	runs, err := c.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+	return findRun(runs, id)
+}
+
+func listRuns(client kubernetes.Interface) ([]RunSummary, error) {
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{LabelSelector: aggregatorComponentLabel})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list aggregator pods")
+	}
+
+	runs := make([]RunSummary, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		runs = append(runs, RunSummary{
+			ID:        pod.Labels[plugin.RunIDLabel],
+			Namespace: pod.Namespace,
+			Phase:     runPhase(pod),
+			Created:   pod.CreationTimestamp.Time,
+		})
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Created.Before(runs[j].Created)
+	})
+	return runs, nil
+}
+
+// runPhase returns the status the aggregator reported on its pod, or the phase of the pod
+// if there isn't one.
+func runPhase(pod corev1.Pod) string {
+	var status aggregation.Status
+	if pod.Status.Phase == corev1.PodRunning {
+		if s, ok := pod.Annotations[aggregation.StatusAnnotationName]; ok && json.Unmarshal([]byte(s), &status) == nil {
+			return status.Status
+		}
+	}
+	return strings.ToLower(string(pod.Status.Phase))
+}
+
+func findRun(runs []RunSummary, id string) (*RunSummary, error) {
+	if id == "" {
+		return nil, errors.New("run ID must not be empty")
+	}
+
+	for i := range runs {
+		if runs[i].ID == id {
+			return &runs[i], nil
+		}
+	}
+
+	var found *RunSummary
+	for i := range runs {
+		if !strings.HasPrefix(runs[i].ID, id) {
+			continue
+		}
+		if found != nil {
+			return nil, errors.Errorf("run ID %q matches more than one run, e.g. %v and %v", id, found.ID, runs[i].ID)
+		}
+		found = &runs[i]
+	}
+	if found == nil {
+		return nil, errors.Errorf("no run found with ID %q", id)
+	}
+	return found, nil
+}
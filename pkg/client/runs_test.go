@@ -0,0 +1,133 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+func TestListRuns(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregatorPod := func(name, namespace, runID string, age int, phase corev1.PodPhase, status string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				Labels:            map[string]string{"sonobuoy-component": "aggregator"},
+				Annotations:       map[string]string{},
+				CreationTimestamp: metav1.NewTime(start.Add(-time.Duration(age) * time.Hour)),
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+		if runID != "" {
+			pod.Labels["sonobuoy-run-id"] = runID
+		}
+		if status != "" {
+			pod.Annotations[aggregation.StatusAnnotationName] = status
+		}
+		return pod
+	}
+
+	client := fake.NewSimpleClientset(
+		aggregatorPod("sonobuoy-aggregator-abc", "sonobuoy", "abc", 1, corev1.PodRunning, `{"status":"running"}`),
+		aggregatorPod("sonobuoy-aggregator-def", "other", "def", 3, corev1.PodRunning, `{"status":"complete"}`),
+		aggregatorPod("sonobuoy-aggregator-ghi", "sonobuoy", "ghi", 0, corev1.PodPending, ""),
+		aggregatorPod("sonobuoy-aggregator", "legacy", "", 2, corev1.PodRunning, "not json"),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "sonobuoy-e2e-job", Namespace: "sonobuoy"}},
+	)
+
+	runs, err := listRuns(client)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []RunSummary{
+		{ID: "def", Namespace: "other", Phase: "complete", Created: start.Add(-3 * time.Hour)},
+		{ID: "", Namespace: "legacy", Phase: "running", Created: start.Add(-2 * time.Hour)},
+		{ID: "abc", Namespace: "sonobuoy", Phase: "running", Created: start.Add(-1 * time.Hour)},
+		{ID: "ghi", Namespace: "sonobuoy", Phase: "pending", Created: start},
+	}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Errorf("Expected runs %+v, got %+v", expected, runs)
+	}
+}
+
+func TestFindRun(t *testing.T) {
+	runs := []RunSummary{
+		{ID: "abc", Namespace: "a"},
+		{ID: "abcdef", Namespace: "b"},
+		{ID: "abcxyz", Namespace: "c"},
+		{ID: "", Namespace: "legacy"},
+	}
+
+	testCases := []struct {
+		desc          string
+		id            string
+		expectNS      string
+		expectErrText string
+	}{
+		{
+			desc:     "Full ID",
+			id:       "abcdef",
+			expectNS: "b",
+		}, {
+			desc:     "Full ID which is also a prefix of other IDs",
+			id:       "abc",
+			expectNS: "a",
+		}, {
+			desc:     "Unique prefix",
+			id:       "abcx",
+			expectNS: "c",
+		}, {
+			desc:          "Ambiguous prefix",
+			id:            "ab",
+			expectErrText: `run ID "ab" matches more than one run, e.g. abc and abcdef`,
+		}, {
+			desc:          "No match",
+			id:            "xyz",
+			expectErrText: `no run found with ID "xyz"`,
+		}, {
+			desc:          "Empty ID",
+			id:            "",
+			expectErrText: "run ID must not be empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			run, err := findRun(runs, tc.id)
+			switch {
+			case err != nil && tc.expectErrText == "":
+				t.Fatalf("Expected no error, got %v", err)
+			case err == nil && tc.expectErrText != "":
+				t.Fatalf("Expected error %q, got nil", tc.expectErrText)
+			case err != nil && err.Error() != tc.expectErrText:
+				t.Fatalf("Expected error %q, got %q", tc.expectErrText, err.Error())
+			case err == nil && run.Namespace != tc.expectNS:
+				t.Errorf("Expected run in namespace %v, got %v", tc.expectNS, run.Namespace)
+			}
+		})
+	}
+}
@@ -48,5 +48,5 @@ func (c *SonobuoyClient) GetStatusPod(cfg *StatusConfig) (*aggregation.Status, *
 		return nil, nil, err
 	}
 
-	return aggregation.GetStatus(client, cfg.Namespace)
+	return aggregation.GetStatus(client, cfg.Namespace, cfg.RunID)
 }
@@ -0,0 +1,290 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+const (
+	// configMapName is the name of the config map holding the config of the aggregator of runs
+	// without an ID. Runs with one suffix it with their ID.
+	configMapName = "sonobuoy-config-cm"
+	// configVolumeName is the name of the volume of the aggregator pod the config map is mounted as.
+	configVolumeName = "sonobuoy-config-volume"
+	// configMapKey is the key of the config in the config map.
+	configMapKey = "config.json"
+)
+
+var (
+	// watchAttempts is how many times in a row the stream of events may end without any new
+	// events before watching is abandoned.
+	watchAttempts = 5
+
+	// watchBackoff is how long to wait before reopening the stream of events the first time it
+	// ends early. It is doubled after each further attempt which gets no events.
+	watchBackoff = time.Second
+)
+
+// eventsOpener opens the stream of events, starting after the event with the given ID.
+type eventsOpener func(ctx context.Context, after int64) (io.ReadCloser, error)
+
+// WatchStatus streams the events of the run from the aggregator, through the API server's pod
+// proxy, until the results are ready or the context is done. The stream starts with the recent
+// events of the run so the current state can be rebuilt from it. The events are served along with
+// the results, which they are by default, or on the metrics port of runs which don't serve them.
+// If the stream ends before the results are ready, e.g. because the connection dropped, it is
+// reopened after the last event received.
+func (c *SonobuoyClient) WatchStatus(ctx context.Context, cfg *StatusConfig) (<-chan aggregation.Event, error) {
+	if cfg == nil {
+		return nil, errors.New("nil StatusConfig provided")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "config validation failed")
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := aggregation.GetAggregatorPod(client, cfg.Namespace, cfg.RunID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get aggregator pod")
+	}
+
+	open := func(ctx context.Context, after int64) (io.ReadCloser, error) {
+		return openEvents(ctx, client, pod, after)
+	}
+	body, err := open(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan aggregation.Event)
+	go func() {
+		defer close(events)
+		if err := watchEvents(ctx, body, open, events); err != nil && ctx.Err() == nil {
+			logrus.Errorf("Error reading events from the aggregator: %v", err)
+		}
+	}()
+	return events, nil
+}
+
+// watchEvents sends the events from body, and from the streams opened after it ends, on events
+// until the results are ready or the context is done. Each stream is opened after the last event
+// received so that no events are repeated, waiting longer each time a stream ends without new
+// events.
+func watchEvents(ctx context.Context, body io.ReadCloser, open eventsOpener, events chan<- aggregation.Event) error {
+	var lastID int64
+	var err error
+	backoff := watchBackoff
+	for attempt := 1; ; attempt++ {
+		if body != nil {
+			var last aggregation.Event
+			last, err = decodeEvents(ctx, body, events)
+			body.Close()
+			if last.Type == aggregation.EventTarballReady {
+				return nil
+			}
+			if last.ID > lastID {
+				lastID = last.ID
+				attempt, backoff = 1, watchBackoff
+			}
+			if err == nil {
+				err = io.EOF
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if attempt >= watchAttempts {
+			return errors.Wrapf(err, "the stream of events ended %v times in a row before the results were ready", attempt)
+		}
+
+		logrus.Debugf("Stream of events ended after event %v, reopening it in %v: %v", lastID, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		if body, err = open(ctx, lastID); err != nil {
+			body = nil
+		}
+	}
+}
+
+// openEvents opens the stream of events of the aggregator pod, after the event with the given ID,
+// from its results server, falling back to its metrics port if it doesn't serve the results or
+// the results token can't be read.
+func openEvents(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, after int64) (io.ReadCloser, error) {
+	get, err := aggregatorResultsGetter(ctx, client, pod)
+	if err == nil {
+		var body io.ReadCloser
+		body, err = streamEvents(ctx, get, after)
+		if err == nil {
+			return body, nil
+		}
+	}
+	if errors.Cause(err) != ErrResultsNotServed {
+		return nil, err
+	}
+	logrus.Debugf("Falling back to streaming events from the metrics port: %v", err)
+
+	port, err := monitoringPort(ctx, client, pod)
+	if err != nil {
+		return nil, err
+	}
+	req := client.CoreV1().RESTClient().Get().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		SubResource("proxy").
+		Name(net.JoinSchemeNamePort("http", pod.Name, strconv.Itoa(port))).
+		Suffix(aggregation.EventsPath).
+		SetHeader("Accept", "text/event-stream")
+	if after > 0 {
+		req = req.SetHeader("Last-Event-ID", strconv.FormatInt(after, 10))
+	}
+	body, err := req.Stream(ctx)
+	return body, errors.Wrap(err, "couldn't stream events from the aggregator")
+}
+
+// streamEvents opens the stream of events from the results server, after the event with the
+// given ID. Aggregators which don't serve events along with the results are reported with
+// ErrResultsNotServed.
+func streamEvents(ctx context.Context, get resultsGetter, after int64) (io.ReadCloser, error) {
+	header := http.Header{"Accept": []string{"text/event-stream"}}
+	if after > 0 {
+		header.Set("Last-Event-ID", strconv.FormatInt(after, 10))
+	}
+	resp, err := get(ctx, aggregation.EventsPath, header)
+	if err != nil {
+		return nil, errors.Wrap(ErrResultsNotServed, err.Error())
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusUnauthorized:
+		resp.Body.Close()
+		return nil, errors.New("the aggregator rejected the results token")
+	default:
+		resp.Body.Close()
+		return nil, errors.Wrapf(ErrResultsNotServed, "streaming events returned %v", resp.Status)
+	}
+}
+
+// monitoringPort returns the port the aggregator serves its metrics and events on, as set in the
+// config mounted into its pod.
+func monitoringPort(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) (int, error) {
+	cfg, err := aggregatorConfig(ctx, client, pod)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Aggregation.MetricsPort == 0 {
+		return 0, errors.New("the run doesn't serve events; to watch it, start it with Aggregation.ResultsPort set in the config or pass --metrics-port")
+	}
+	return cfg.Aggregation.MetricsPort, nil
+}
+
+// aggregatorConfig returns the config mounted into the aggregator pod. Fields which aren't set in
+// it are left empty rather than given their defaults.
+func aggregatorConfig(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) (*config.Config, error) {
+	name := configMapName
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == configVolumeName && v.ConfigMap != nil {
+			name = v.ConfigMap.Name
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(pod.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get the sonobuoy config")
+	}
+
+	cfg := &config.Config{}
+	if err := json.Unmarshal([]byte(cm.Data[configMapKey]), cfg); err != nil {
+		return nil, errors.Wrap(err, "couldn't decode the sonobuoy config")
+	}
+	return cfg, nil
+}
+
+// decodeEvents reads the server-sent events from r and sends them on events until r ends or the
+// context is done, returning the last event sent. Only the data of each event is used since it
+// holds the whole event.
+func decodeEvents(ctx context.Context, r io.Reader, events chan<- aggregation.Event) (aggregation.Event, error) {
+	br := bufio.NewReader(r)
+	var data bytes.Buffer
+	last := aggregation.Event{}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return last, nil
+			}
+			return last, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			// A blank line ends an event.
+			if data.Len() == 0 {
+				continue
+			}
+			ev := aggregation.Event{}
+			if err := json.Unmarshal(data.Bytes(), &ev); err != nil {
+				return last, errors.Wrap(err, "couldn't decode event")
+			}
+			data.Reset()
+			select {
+			case events <- ev:
+				last = ev
+			case <-ctx.Done():
+				return last, ctx.Err()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// Other fields, like the id and event type, are repeated in the data and comments are
+		// only there to keep the connection alive.
+	}
+}
@@ -0,0 +1,292 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+func TestDecodeEvents(t *testing.T) {
+	testcases := []struct {
+		desc          string
+		stream        string
+		expected      []string
+		expectedError string
+	}{
+		{
+			desc: "Events are decoded from their data",
+			stream: "id: 1\nevent: plugin-started\ndata: {\"id\":1,\"type\":\"plugin-started\",\"plugin\":\"e2e\"}\n\n" +
+				": keep-alive\n\n" +
+				"id: 2\nevent: result-received\ndata: {\"id\":2,\"type\":\"result-received\",\"plugin\":\"e2e\",\"node\":\"global\"}\n\n",
+			expected: []string{"1 plugin-started e2e ", "2 result-received e2e global"},
+		}, {
+			desc:     "Data split over several lines and CRLF line endings are supported",
+			stream:   "data: {\"id\":1,\r\ndata:\"type\":\"progress\"}\r\n\r\n",
+			expected: []string{"1 progress  "},
+		}, {
+			desc:     "An event cut off by the end of the stream is dropped",
+			stream:   "data: {\"id\":1,\"type\":\"progress\"}\n\ndata: {\"id\":2,",
+			expected: []string{"1 progress  "},
+		}, {
+			desc:          "Invalid data is an error",
+			stream:        "data: not json\n\n",
+			expectedError: "couldn't decode event",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			events := make(chan aggregation.Event, 10)
+			_, err := decodeEvents(context.Background(), strings.NewReader(tc.stream), events)
+			close(events)
+
+			switch {
+			case tc.expectedError == "" && err != nil:
+				t.Fatalf("Expected no error, got: %v", err)
+			case tc.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedError)):
+				t.Fatalf("Expected error to contain %q, got %v", tc.expectedError, err)
+			}
+
+			var got []string
+			for ev := range events {
+				got = append(got, fmt.Sprintf("%v %v %v %v", ev.ID, ev.Type, ev.Plugin, ev.Node))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected events %q but got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	defer func(backoff time.Duration) { watchBackoff = backoff }(watchBackoff)
+	watchBackoff = 0
+
+	event := func(id int64, typ string) string {
+		return fmt.Sprintf("id: %d\nevent: %s\ndata: {\"id\":%d,\"type\":%q}\n\n", id, typ, id, typ)
+	}
+
+	testcases := []struct {
+		desc  string
+		first string
+		// streams are the streams opened after the first one, by the ID of the last event received.
+		streams       map[int64]string
+		expected      []string
+		expectedAfter []int64
+		expectedError string
+	}{
+		{
+			desc:     "The stream isn't reopened once the results are ready",
+			first:    event(1, "plugin-started") + event(2, "tarball-ready"),
+			expected: []string{"1 plugin-started", "2 tarball-ready"},
+		}, {
+			desc:          "A stream which ends early is reopened after the last event",
+			first:         event(1, "plugin-started"),
+			streams:       map[int64]string{1: event(2, "progress") + event(3, "tarball-ready")},
+			expected:      []string{"1 plugin-started", "2 progress", "3 tarball-ready"},
+			expectedAfter: []int64{1},
+		}, {
+			desc:          "A stream cut off in the middle of an event is reopened",
+			first:         event(1, "plugin-started") + "data: {\"id\":2,",
+			streams:       map[int64]string{1: event(2, "tarball-ready")},
+			expected:      []string{"1 plugin-started", "2 tarball-ready"},
+			expectedAfter: []int64{1},
+		}, {
+			desc:          "Watching is abandoned once the stream keeps ending without events",
+			first:         event(1, "plugin-started"),
+			expected:      []string{"1 plugin-started"},
+			expectedAfter: []int64{1, 1, 1, 1},
+			expectedError: "the stream of events ended 5 times in a row",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var after []int64
+			open := func(ctx context.Context, a int64) (io.ReadCloser, error) {
+				after = append(after, a)
+				stream, ok := tc.streams[a]
+				if !ok {
+					return nil, errors.New("connection refused")
+				}
+				return ioutil.NopCloser(strings.NewReader(stream)), nil
+			}
+
+			events := make(chan aggregation.Event, 10)
+			err := watchEvents(context.Background(), ioutil.NopCloser(strings.NewReader(tc.first)), open, events)
+			close(events)
+
+			switch {
+			case tc.expectedError == "" && err != nil:
+				t.Fatalf("Expected no error, got: %v", err)
+			case tc.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedError)):
+				t.Fatalf("Expected error to contain %q, got %v", tc.expectedError, err)
+			}
+
+			var got []string
+			for ev := range events {
+				got = append(got, fmt.Sprintf("%v %v", ev.ID, ev.Type))
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected events %q but got %q", tc.expected, got)
+			}
+			if !reflect.DeepEqual(after, tc.expectedAfter) {
+				t.Errorf("Expected the stream to be reopened after events %v but got %v", tc.expectedAfter, after)
+			}
+		})
+	}
+}
+
+func TestMonitoringPort(t *testing.T) {
+	testcases := []struct {
+		desc          string
+		configMap     string
+		config        string
+		expected      int
+		expectedError string
+	}{
+		{
+			desc:     "The metrics port is read from the config",
+			config:   `{"Server":{"bindport":8080,"metricsport":9090}}`,
+			expected: 9090,
+		}, {
+			desc:      "The config map is the one mounted into the aggregator pod",
+			configMap: "sonobuoy-config-cm-abc",
+			config:    `{"Server":{"bindport":8080,"metricsport":9091}}`,
+			expected:  9091,
+		}, {
+			desc:          "Runs without a metrics port can't be watched",
+			config:        `{"Server":{"bindport":8080}}`,
+			expectedError: "start it with Aggregation.ResultsPort set in the config or pass --metrics-port",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "sonobuoy", Namespace: "sonobuoy"}}
+			cmName := configMapName
+			if tc.configMap != "" {
+				cmName = tc.configMap
+				pod.Spec.Volumes = []corev1.Volume{{
+					Name: configVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: cmName}},
+					},
+				}}
+			}
+			client := fake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: "sonobuoy"},
+				Data:       map[string]string{configMapKey: tc.config},
+			})
+			port, err := monitoringPort(context.Background(), client, pod)
+			switch {
+			case tc.expectedError == "" && err != nil:
+				t.Fatalf("Expected no error, got: %v", err)
+			case tc.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedError)):
+				t.Fatalf("Expected error to contain %q, got %v", tc.expectedError, err)
+			}
+			if port != tc.expected {
+				t.Errorf("Expected port %v but got %v", tc.expected, port)
+			}
+		})
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	events := aggregation.NewEvents()
+	events.Publish(aggregation.Event{Type: aggregation.EventPluginStarted})
+	events.Publish(aggregation.Event{Type: aggregation.EventTarballReady})
+	withEvents := &testResultsServer{Server: httptest.NewTLSServer(aggregation.NewResultsHandler("", "", "token", nil, events))}
+	defer withEvents.Close()
+	withoutEvents := &testResultsServer{Server: httptest.NewTLSServer(aggregation.NewResultsHandler("", "", "token", nil, nil))}
+	defer withoutEvents.Close()
+
+	testcases := []struct {
+		desc            string
+		get             resultsGetter
+		after           int64
+		expectEvents    []string
+		expectNotServed bool
+		expectedError   string
+	}{
+		{
+			desc:         "Events are streamed from the results server",
+			get:          withEvents.getter("token"),
+			expectEvents: []string{"event: plugin-started", "event: tarball-ready"},
+		}, {
+			desc:         "Only events after the given one are streamed",
+			get:          withEvents.getter("token"),
+			after:        1,
+			expectEvents: []string{"event: tarball-ready"},
+		}, {
+			desc:          "Wrong token",
+			get:           withEvents.getter("wrong"),
+			expectedError: "the aggregator rejected the results token",
+		}, {
+			desc:            "Results server without events",
+			get:             withoutEvents.getter("token"),
+			expectNotServed: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			body, err := streamEvents(context.Background(), tc.get, tc.after)
+			if notServed := errors.Cause(err) == ErrResultsNotServed; notServed != tc.expectNotServed {
+				t.Fatalf("Expected error to be ErrResultsNotServed: %v, got %v", tc.expectNotServed, err)
+			}
+			if tc.expectedError != "" && (err == nil || err.Error() != tc.expectedError) {
+				t.Fatalf("Expected error %q, got %v", tc.expectedError, err)
+			}
+			if tc.expectEvents == nil {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			defer body.Close()
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Could not read events: %v", err)
+			}
+			var got []string
+			for _, line := range strings.Split(string(b), "\n") {
+				if strings.HasPrefix(line, "event: ") {
+					got = append(got, line)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.expectEvents) {
+				t.Errorf("Expected events %q, got %q", tc.expectEvents, got)
+			}
+		})
+	}
+}
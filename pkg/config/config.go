@@ -41,10 +41,16 @@ const (
 	DefaultAggregationServerBindPort = 8080
 	// DefaultAggregationServerBindAddress is the default address for the aggregation server to bind to.
 	DefaultAggregationServerBindAddress = "0.0.0.0"
+	// DefaultAggregationResultsPort is the default port the aggregator serves the results on.
+	DefaultAggregationResultsPort = 8443
+	// DefaultAggregationStorageSize is the default size of the volume of resumable runs.
+	DefaultAggregationStorageSize = "1Gi"
 	// DefaultAggregationServerTimeoutSeconds is the default amount of time the aggregation server will wait for all plugins to complete.
 	DefaultAggregationServerTimeoutSeconds = 21600 // 360 min
 	// AggregatorPodName is the name of the main pod that runs plugins and collects results.
 	AggregatorPodName = "sonobuoy"
+	// AggregatorServiceName is the name of the service in front of the aggregator pod.
+	AggregatorServiceName = "sonobuoy-aggregator"
 	// AggregatorContainerName is the name of the main container in the aggregator pod.
 	AggregatorContainerName = "kube-sonobuoy"
 	// AggregatorResultsPath is the location in the main container of the aggregator pod where results will be archived.
@@ -57,6 +63,11 @@ const (
 	DefaultQueryBurst = 50
 	// DefaultProgressUpdatesPort is the port on which the Sonobuoy worker will listen for status updates from its plugin.
 	DefaultProgressUpdatesPort = "8099"
+	// SigningKeyMountPath is the location in the main container of the aggregator pod where the secret
+	// holding the key used to sign results is mounted.
+	SigningKeyMountPath = "/etc/sonobuoy-signing"
+	// SigningKeySecretKey is the key in the signing key secret which holds the PEM encoded private key.
+	SigningKeySecretKey = "signing.key"
 
 	// DefaultDNSNamespace is the namespace where the DNS pods for the cluster are found.
 	DefaultDNSNamespace = "kube-system"
@@ -177,6 +188,34 @@ type Config struct {
 
 	// ProgressUpdatesPort is the port on which the Sonobuoy worker will listen for status updates from its plugin.
 	ProgressUpdatesPort string `json:"ProgressUpdatesPort,omitempty" mapstructure:"ProgressUpdatesPort"`
+
+	// SigningKeySecret is the name of a secret holding a private key, under SigningKeySecretKey, with
+	// which the aggregator signs the results archive and its digest manifest. Results are not signed
+	// if it is empty.
+	SigningKeySecret string `json:"SigningKeySecret,omitempty" mapstructure:"SigningKeySecret"`
+
+	// Expectations are the tests known to fail (or otherwise not pass). They are applied to the
+	// results of each plugin after post-processing so that only unexpected outcomes affect its status.
+	Expectations []Expectation `json:"Expectations,omitempty" mapstructure:"Expectations"`
+}
+
+// Expectation is the known outcome of the tests whose names match a regular expression.
+type Expectation struct {
+	// Test is a regular expression matched against the name of each test.
+	Test string `json:"test" mapstructure:"test"`
+
+	// Plugin, if set, limits the expectation to the results of the named plugin.
+	Plugin string `json:"plugin,omitempty" mapstructure:"plugin"`
+
+	// Status is the expected status of the tests. Defaults to failed.
+	Status string `json:"status,omitempty" mapstructure:"status"`
+
+	// Reason explains why the tests are expected to have the status, e.g. a link to an issue.
+	Reason string `json:"reason" mapstructure:"reason"`
+
+	// Expires is an optional date, formatted as YYYY-MM-DD, after which the expectation no
+	// longer applies and the tests are reported as they are.
+	Expires string `json:"expires,omitempty" mapstructure:"expires"`
 }
 
 // LimitConfig is a configuration on the limits of various responses, such as limits of sizes
@@ -292,6 +331,16 @@ func (cfg *Config) OutputDir() string {
 	return path.Join(cfg.ResultsDir, cfg.UUID)
 }
 
+// ResourceName returns the name of the resource of the run with the given base name, e.g. the
+// aggregator pod. Runs with a UUID suffix the name with it so that several runs can share a
+// namespace; runs without one keep the fixed names.
+func (cfg *Config) ResourceName(name string) string {
+	if cfg.UUID == "" {
+		return name
+	}
+	return name + "-" + cfg.UUID
+}
+
 // Deprecated: use PodLogLimits.LimitBytes instead
 // SizeLimitBytes returns how many bytes the configuration is set to limit,
 // returning defaultVal if not set.
@@ -363,6 +412,7 @@ func New() *Config {
 	cfg.Aggregation.BindAddress = DefaultAggregationServerBindAddress
 	cfg.Aggregation.BindPort = DefaultAggregationServerBindPort
 	cfg.Aggregation.TimeoutSeconds = DefaultAggregationServerTimeoutSeconds
+	cfg.Aggregation.ResultsPort = DefaultAggregationResultsPort
 
 	cfg.PluginSearchPath = []string{
 		"./plugins.d",
@@ -64,9 +64,12 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.Wrapf(err, "unmarshal config file %q", fpath)
 	}
 
-	// 3 - figure out what address we will tell pods to dial for aggregation
+	// 3 - figure out what address we will tell pods to dial for aggregation. A resumable
+	// aggregator may be replaced by a pod with a new IP so plugins must dial its service instead.
 	if cfg.Aggregation.AdvertiseAddress == "" {
-		if ip := os.Getenv("SONOBUOY_ADVERTISE_IP"); ip != "" {
+		if cfg.Aggregation.Resumable {
+			cfg.Aggregation.AdvertiseAddress = fmt.Sprintf("%v.%v.svc:%d", cfg.ResourceName(AggregatorServiceName), cfg.Namespace, cfg.Aggregation.BindPort)
+		} else if ip := os.Getenv("SONOBUOY_ADVERTISE_IP"); ip != "" {
 			cfg.Aggregation.AdvertiseAddress = fmt.Sprintf("[%v]:%d", ip, cfg.Aggregation.BindPort)
 		} else {
 			hostname, _ := os.Hostname()
@@ -162,6 +165,10 @@ func loadAllPlugins(cfg *Config) error {
 		}
 	}
 
+	if err := plugin.ValidateDependencies(plugin.PluginDependencies(plugins)); err != nil {
+		return errors.Wrap(err, "invalid plugin dependencies")
+	}
+
 	for _, p := range plugins {
 		cfg.addPlugin(p)
 	}
@@ -157,6 +157,50 @@ func TestLoadConfigSetsUUID(t *testing.T) {
 	}
 }
 
+func TestLoadConfigResumableAdvertiseAddress(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		uuid     string
+		expected string
+	}{
+		{
+			desc:     "Runs without an ID use the fixed service name",
+			expected: "sonobuoy-aggregator.custom-ns.svc:8080",
+		}, {
+			desc:     "Runs with an ID use the service named by it",
+			uuid:     "abc",
+			expected: "sonobuoy-aggregator-abc.custom-ns.svc:8080",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := New()
+			cfg.UUID = tc.uuid
+			cfg.Namespace = "custom-ns"
+			cfg.Aggregation.Resumable = true
+
+			if blob, err := json.Marshal(&cfg); err == nil {
+				if err = ioutil.WriteFile("./config.json", blob, 0644); err != nil {
+					t.Fatalf("Failed to write default config.json: %v", err)
+				}
+				defer os.Remove("./config.json")
+			} else {
+				t.Fatalf("Failed to serialize %v", err)
+			}
+
+			loadedCfg, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if loadedCfg.Aggregation.AdvertiseAddress != tc.expected {
+				t.Errorf("Expected advertise address %q but got %q", tc.expected, loadedCfg.Aggregation.AdvertiseAddress)
+			}
+		})
+	}
+}
+
 func TestDefaultResources(t *testing.T) {
 	// Check that giving empty resources results in empty resources
 	blob := `{"Resources":[]}`
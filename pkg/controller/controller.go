@@ -0,0 +1,398 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller reconciles SonobuoyRun custom resources: it creates the resources of each
+// run, as `sonobuoy run` does, reflects the status of the aggregator into the status of the run
+// and deletes finished runs once they have been kept for long enough.
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client"
+	"github.com/vmware-tanzu/sonobuoy/pkg/image"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+)
+
+const (
+	// DefaultTTL is how long finished runs are kept by default.
+	DefaultTTL = 24 * time.Hour
+
+	// DefaultPollInterval is how often the status of unfinished runs is checked by default.
+	DefaultPollInterval = 20 * time.Second
+
+	// resyncPeriod is how often every run is reconciled even if it didn't change.
+	resyncPeriod = 10 * time.Minute
+
+	// manifestBufferSize is the buffer size used to decode the generated manifest.
+	manifestBufferSize = 4096
+)
+
+// builtinPlugins are the plugins which can be given by name in the spec of a run.
+var builtinPlugins = map[string]bool{"e2e": true, "systemd-logs": true}
+
+// Controller starts the SonobuoyRuns created in the cluster and keeps their status up to date.
+type Controller struct {
+	kube    kubernetes.Interface
+	dynamic dynamic.Interface
+	objects client.SonobuoyKubeAPIClient
+
+	// TTL is how long finished runs are kept before they are deleted along with all of their
+	// resources, unless the run sets its own. Finished runs are kept forever if it is 0.
+	TTL time.Duration
+
+	// PollInterval is how often the status of unfinished runs is checked.
+	PollInterval time.Duration
+
+	queue workqueue.RateLimitingInterface
+	now   func() time.Time
+}
+
+// NewController returns a controller which reads the runs with the dynamic client and creates the
+// resources of each run with objects.
+func NewController(kube kubernetes.Interface, dyn dynamic.Interface, objects client.SonobuoyKubeAPIClient) *Controller {
+	return &Controller{
+		kube:         kube,
+		dynamic:      dyn,
+		objects:      objects,
+		TTL:          DefaultTTL,
+		PollInterval: DefaultPollInterval,
+		queue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "sonobuoyruns"),
+		now:          time.Now,
+	}
+}
+
+// Run reconciles the runs with the given number of workers until the context is done.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	defer c.queue.ShutDown()
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamic, resyncPeriod)
+	informer := factory.ForResource(SonobuoyRunResource).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
+	})
+	factory.Start(ctx.Done())
+
+	logrus.Info("Waiting for the SonobuoyRun cache to sync")
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("couldn't sync the SonobuoyRun cache; is the CRD installed?")
+	}
+
+	logrus.WithField("workers", workers).Info("Starting SonobuoyRun controller")
+	for i := 0; i < workers; i++ {
+		go wait.Until(func() {
+			for c.processNextItem(ctx) {
+			}
+		}, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		logrus.Errorf("Couldn't get the key of SonobuoyRun: %v", err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// processNextItem reconciles the next run in the queue, returning false once the queue is shut down.
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	requeueAfter, err := c.reconcile(ctx, key.(string))
+	switch {
+	case err != nil:
+		logrus.WithField("run", key).Errorf("Error reconciling SonobuoyRun: %v", err)
+		c.queue.AddRateLimited(key)
+	case requeueAfter > 0:
+		c.queue.Forget(key)
+		c.queue.AddAfter(key, requeueAfter)
+	default:
+		c.queue.Forget(key)
+	}
+	return true
+}
+
+// reconcile brings the run with the given name up to date, returning how long to wait before
+// checking on it again or 0 if it doesn't need to be.
+func (c *Controller) reconcile(ctx context.Context, name string) (time.Duration, error) {
+	runs := c.dynamic.Resource(SonobuoyRunResource)
+	obj, err := runs.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case kubeerror.IsNotFound(err):
+		return 0, nil
+	case err != nil:
+		return 0, errors.Wrap(err, "couldn't get SonobuoyRun")
+	case obj.GetDeletionTimestamp() != nil:
+		return 0, nil
+	}
+
+	run, err := decodeRun(obj)
+	if err != nil {
+		return 0, err
+	}
+	oldStatus, err := json.Marshal(run.Status)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't encode status")
+	}
+
+	now := metav1.NewTime(c.now())
+	var reconcileErr error
+	switch {
+	case run.Status.finished():
+	case !run.Status.isTrue(ConditionCreated):
+		reconcileErr = c.start(ctx, run, now)
+	default:
+		c.refresh(ctx, run, now)
+	}
+
+	if newStatus, err := json.Marshal(run.Status); err != nil {
+		return 0, errors.Wrap(err, "couldn't encode status")
+	} else if !bytes.Equal(oldStatus, newStatus) {
+		updated, err := encodeRun(run)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := runs.UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return 0, errors.Wrap(err, "couldn't update status of SonobuoyRun")
+		}
+	}
+	if reconcileErr != nil {
+		return 0, reconcileErr
+	}
+
+	if !run.Status.finished() {
+		return c.PollInterval, nil
+	}
+	ttl, ok := c.ttl(run)
+	if !ok {
+		return 0, nil
+	}
+	if remaining := run.Status.CompletionTime.Add(ttl).Sub(now.Time); remaining > 0 {
+		return remaining, nil
+	}
+
+	logrus.WithField("run", name).Info("Deleting finished SonobuoyRun")
+	propagation := metav1.DeletePropagationBackground
+	err = runs.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !kubeerror.IsNotFound(err) {
+		return 0, errors.Wrap(err, "couldn't delete finished SonobuoyRun")
+	}
+	return 0, nil
+}
+
+// ttl returns how long the run is kept once finished and whether it is deleted at all.
+func (c *Controller) ttl(run *SonobuoyRun) (time.Duration, bool) {
+	if run.Spec.TTLSecondsAfterFinished != nil {
+		return time.Duration(*run.Spec.TTLSecondsAfterFinished) * time.Second, true
+	}
+	return c.TTL, c.TTL > 0
+}
+
+// fail marks the run as failed for good.
+func fail(run *SonobuoyRun, now metav1.Time, reason string, err error) {
+	run.Status.setCondition(Condition{Type: ConditionFailed, Status: corev1.ConditionTrue, Reason: reason, Message: err.Error()}, now)
+	run.Status.CompletionTime = &now
+}
+
+// start creates the resources of the run. Errors which won't go away by trying again are
+// recorded in the status of the run rather than returned.
+func (c *Controller) start(ctx context.Context, run *SonobuoyRun, now metav1.Time) error {
+	namespace := run.Spec.Config.Namespace
+	run.Status.Namespace = namespace
+	run.Status.RunID = run.Spec.Config.UUID
+
+	// Runs share nothing but cluster-wide resources, so the namespace must be the run's own.
+	ns, err := c.kube.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case kubeerror.IsNotFound(err):
+	case err != nil:
+		return errors.Wrapf(err, "couldn't get namespace %v", namespace)
+	case !ownedBy(ns.OwnerReferences, run):
+		fail(run, now, "NamespaceInUse", fmt.Errorf("namespace %v already exists and doesn't belong to this run", namespace))
+		return nil
+	}
+
+	m, err := c.generateManifest(run)
+	if err != nil {
+		fail(run, now, "InvalidSpec", err)
+		return nil
+	}
+
+	if err := c.createObjects(m, run); err != nil {
+		run.Status.setCondition(Condition{Type: ConditionCreated, Status: corev1.ConditionFalse, Reason: "CreateFailed", Message: err.Error()}, now)
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"run": run.Name, "namespace": namespace}).Info("Started SonobuoyRun")
+	run.Status.setCondition(Condition{Type: ConditionCreated, Status: corev1.ConditionTrue, Reason: "ResourcesCreated"}, now)
+	return nil
+}
+
+// generateManifest returns the manifest `sonobuoy gen` would for the spec of the run.
+func (c *Controller) generateManifest(run *SonobuoyRun) ([]byte, error) {
+	for _, p := range run.Spec.Plugins {
+		if !builtinPlugins[p] {
+			return nil, fmt.Errorf("unknown plugin %q; other plugins are given in pluginDefinitions", p)
+		}
+	}
+	plugins := make([]*manifest.Manifest, len(run.Spec.PluginDefinitions))
+	for i := range run.Spec.PluginDefinitions {
+		plugins[i] = &run.Spec.PluginDefinitions[i]
+	}
+
+	version, err := c.kubernetesVersion(run.Spec.KubernetesVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't determine the Kubernetes version")
+	}
+
+	cfg := run.Spec.Config
+	return (&client.SonobuoyClient{}).GenerateManifest(&client.GenConfig{
+		Config:             &cfg,
+		EnableRBAC:         true,
+		ImagePullPolicy:    cfg.ImagePullPolicy,
+		DynamicPlugins:     run.Spec.Plugins,
+		StaticPlugins:      plugins,
+		PluginEnvOverrides: run.Spec.PluginEnv,
+		KubeVersion:        version,
+	})
+}
+
+// kubernetesVersion resolves the version the same way as the --kubernetes-version flag.
+func (c *Controller) kubernetesVersion(v string) (string, error) {
+	version := image.ConformanceImageVersion(v)
+	switch version {
+	case "", image.ConformanceImageVersionAuto, image.ConformanceImageVersionLatest, image.ConformanceImageVersionIgnore:
+		_, resolved, err := version.Get(c.kube.Discovery(), image.DevVersionURL)
+		return resolved, err
+	default:
+		return version.String(), nil
+	}
+}
+
+// createObjects creates the objects of the manifest. Cluster-wide objects, including the namespace
+// of the run, are owned by the run so that they are deleted along with it. Objects which already
+// exist are left as they are so that creating the run can be retried.
+func (c *Controller) createObjects(m []byte, run *SonobuoyRun) error {
+	owner := metav1.OwnerReference{
+		APIVersion: GroupName + "/" + Version,
+		Kind:       Kind,
+		Name:       run.Name,
+		UID:        run.UID,
+		Controller: new(bool),
+	}
+	*owner.Controller = true
+
+	d := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(m), manifestBufferSize)
+	for {
+		ext := runtime.RawExtension{}
+		if err := d.Decode(&ext); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrap(err, "couldn't decode manifest")
+		}
+		ext.Raw = bytes.TrimSpace(ext.Raw)
+		if len(ext.Raw) == 0 || bytes.Equal(ext.Raw, []byte("null")) {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := runtime.DecodeInto(scheme.Codecs.UniversalDecoder(), ext.Raw, obj); err != nil {
+			return errors.Wrap(err, "couldn't decode manifest")
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetOwnerReferences([]metav1.OwnerReference{owner})
+		}
+
+		_, err := c.objects.CreateObject(obj)
+		switch {
+		case kubeerror.IsAlreadyExists(errors.Cause(err)):
+		case err != nil:
+			return errors.Wrapf(err, "couldn't create %v %v", obj.GetKind(), obj.GetName())
+		}
+	}
+}
+
+// ownedBy returns whether the owner references include the run.
+func ownedBy(refs []metav1.OwnerReference, run *SonobuoyRun) bool {
+	for _, ref := range refs {
+		if ref.UID == run.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh updates the status of the run from the status of its aggregator.
+func (c *Controller) refresh(ctx context.Context, run *SonobuoyRun, now metav1.Time) {
+	status, pod, err := aggregation.GetStatus(c.kube, run.Status.Namespace, run.Status.RunID)
+	switch {
+	case pod != nil && pod.Status.Phase == corev1.PodFailed:
+		fail(run, now, "AggregatorFailed", fmt.Errorf("aggregator pod %v failed", pod.Name))
+		return
+	case err != nil:
+		// The aggregator may not have started yet.
+		logrus.WithField("run", run.Name).Debugf("Status of SonobuoyRun not available: %v", err)
+		return
+	}
+
+	run.Status.Phase = status.Status
+	run.Status.Plugins = status.Plugins
+	if status.Tarball.Name != "" {
+		tarball := status.Tarball
+		run.Status.Tarball = &tarball
+	}
+
+	switch status.Status {
+	case aggregation.CompleteStatus:
+		run.Status.setCondition(Condition{Type: ConditionComplete, Status: corev1.ConditionTrue, Reason: "ResultsReady"}, now)
+		run.Status.CompletionTime = &now
+	case aggregation.FailedStatus:
+		// The aggregator keeps going after a plugin fails, so the run isn't finished yet.
+		run.Status.setCondition(Condition{Type: ConditionFailed, Status: corev1.ConditionTrue, Reason: "PluginFailed", Message: "one or more plugins failed"}, now)
+	}
+}
@@ -0,0 +1,423 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+var now = time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+
+// fakeObjects records the objects created from the manifest of a run.
+type fakeObjects struct {
+	created []*unstructured.Unstructured
+}
+
+func (f *fakeObjects) CreateObject(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	for _, o := range f.created {
+		if o.GetKind() == obj.GetKind() && o.GetNamespace() == obj.GetNamespace() && o.GetName() == obj.GetName() {
+			return nil, kubeerror.NewAlreadyExists(schema.GroupResource{Resource: obj.GetKind()}, obj.GetName())
+		}
+	}
+	f.created = append(f.created, obj)
+	return obj, nil
+}
+
+func (f *fakeObjects) Name(obj *unstructured.Unstructured) (string, error) { return obj.GetName(), nil }
+
+func (f *fakeObjects) Namespace(obj *unstructured.Unstructured) (string, error) {
+	return obj.GetNamespace(), nil
+}
+
+func (f *fakeObjects) ResourceVersion(obj *unstructured.Unstructured) (string, error) {
+	return obj.GetResourceVersion(), nil
+}
+
+func (f *fakeObjects) find(kind, name string) *unstructured.Unstructured {
+	for _, o := range f.created {
+		if o.GetKind() == kind && o.GetName() == name {
+			return o
+		}
+	}
+	return nil
+}
+
+func newRun(t *testing.T, spec SonobuoyRunSpec, status SonobuoyRunStatus) *unstructured.Unstructured {
+	t.Helper()
+	if spec.KubernetesVersion == "" {
+		spec.KubernetesVersion = "v1.20.0"
+	}
+	run := &SonobuoyRun{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupName + "/" + Version, Kind: Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "conformance", UID: types.UID("run-uid")},
+		Spec:       spec,
+		Status:     status,
+	}
+	obj, err := encodeRun(run)
+	if err != nil {
+		t.Fatalf("Failed to encode run: %v", err)
+	}
+	// Like the runs users write, only the fields of the config which are set are given.
+	unstructured.RemoveNestedField(obj.Object, "spec", "config")
+	return obj
+}
+
+func newTestController(run *unstructured.Unstructured, kubeObjects ...runtime.Object) (*Controller, *fakeObjects) {
+	objects := &fakeObjects{}
+	c := NewController(fake.NewSimpleClientset(kubeObjects...), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), run), objects)
+	c.now = func() time.Time { return now }
+	return c, objects
+}
+
+// getRun returns the run as stored, or nil if it was deleted.
+func getRun(t *testing.T, c *Controller) *SonobuoyRun {
+	t.Helper()
+	obj, err := c.dynamic.Resource(SonobuoyRunResource).Get(context.Background(), "conformance", metav1.GetOptions{})
+	if kubeerror.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("Failed to get run: %v", err)
+	}
+	run, err := decodeRun(obj)
+	if err != nil {
+		t.Fatalf("Failed to decode run: %v", err)
+	}
+	return run
+}
+
+func aggregatorPod(namespace string, phase corev1.PodPhase, status *aggregation.Status) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sonobuoy",
+			Namespace: namespace,
+			Labels:    map[string]string{"sonobuoy-component": "aggregator"},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if status != nil {
+		b, _ := json.Marshal(status)
+		pod.Annotations = map[string]string{aggregation.StatusAnnotationName: string(b)}
+	}
+	return pod
+}
+
+func conditionSummary(run *SonobuoyRun) map[string]string {
+	summary := map[string]string{}
+	for _, c := range run.Status.Conditions {
+		summary[c.Type] = string(c.Status) + "/" + c.Reason
+	}
+	return summary
+}
+
+func TestReconcileStartsRun(t *testing.T) {
+	c, objects := newTestController(newRun(t, SonobuoyRunSpec{Plugins: []string{"systemd-logs"}}, SonobuoyRunStatus{}))
+
+	requeue, err := c.reconcile(context.Background(), "conformance")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if requeue != DefaultPollInterval {
+		t.Errorf("Expected to check on the run again after %v but got %v", DefaultPollInterval, requeue)
+	}
+
+	// The namespace defaults to the name of the run and is deleted along with it.
+	ns := objects.find("Namespace", "conformance")
+	if ns == nil {
+		t.Fatalf("Expected the namespace of the run to be created")
+	}
+	refs := ns.GetOwnerReferences()
+	if len(refs) != 1 || refs[0].UID != "run-uid" || refs[0].Kind != Kind || refs[0].Controller == nil || !*refs[0].Controller {
+		t.Errorf("Expected the namespace to be owned by the run but got %+v", refs)
+	}
+	if objects.find("ClusterRoleBinding", "sonobuoy-serviceaccount-conformance-run-uid").GetOwnerReferences() == nil {
+		t.Errorf("Expected the cluster role binding to be owned by the run")
+	}
+	// The resources of the run are named by its ID, which defaults to the UID of the run.
+	pod := objects.find("Pod", "sonobuoy-run-uid")
+	if pod == nil || pod.GetNamespace() != "conformance" || pod.GetOwnerReferences() != nil {
+		t.Errorf("Expected the aggregator pod to be created in the namespace of the run but got %+v", pod)
+	}
+
+	cm := objects.find("ConfigMap", "sonobuoy-config-cm-run-uid")
+	if cm == nil {
+		t.Fatalf("Expected the sonobuoy config to be created")
+	}
+	data, _, _ := unstructured.NestedString(cm.Object, "data", "config.json")
+	cfg := config.Config{}
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Failed to decode the sonobuoy config: %v", err)
+	}
+	if cfg.Namespace != "conformance" || cfg.UUID != "run-uid" || cfg.Aggregation.TimeoutSeconds != config.DefaultAggregationServerTimeoutSeconds {
+		t.Errorf("Expected the default config in the namespace of the run but got %+v", cfg)
+	}
+	if objects.find("ConfigMap", "sonobuoy-plugins-cm-run-uid") == nil {
+		t.Errorf("Expected the plugins to be created")
+	}
+
+	run := getRun(t, c)
+	if run.Status.Namespace != "conformance" || run.Status.RunID != "run-uid" || run.Status.finished() {
+		t.Errorf("Expected the run to be in progress in its namespace but got %+v", run.Status)
+	}
+	if expected := map[string]string{ConditionCreated: "True/ResourcesCreated"}; !reflect.DeepEqual(conditionSummary(run), expected) {
+		t.Errorf("Expected conditions %v but got %v", expected, conditionSummary(run))
+	}
+
+	// Reconciling again only checks on the status of the run.
+	created := len(objects.created)
+	if _, err := c.reconcile(context.Background(), "conformance"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(objects.created) != created {
+		t.Errorf("Expected no more objects to be created but got %v", len(objects.created)-created)
+	}
+}
+
+func TestReconcileInvalidRuns(t *testing.T) {
+	testcases := []struct {
+		desc       string
+		spec       SonobuoyRunSpec
+		namespace  string
+		namespaces []runtime.Object
+		expected   string
+	}{
+		{
+			desc:     "Unknown plugins",
+			spec:     SonobuoyRunSpec{Plugins: []string{"e2e", "unknown"}},
+			expected: "True/InvalidSpec",
+		}, {
+			desc:      "Namespace of another run",
+			namespace: "sonobuoy",
+			namespaces: []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:            "sonobuoy",
+				OwnerReferences: []metav1.OwnerReference{{UID: "other-run"}},
+			}}},
+			expected: "True/NamespaceInUse",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			obj := newRun(t, tc.spec, SonobuoyRunStatus{})
+			if tc.namespace != "" {
+				unstructured.SetNestedField(obj.Object, tc.namespace, "spec", "config", "Namespace")
+			}
+			c, objects := newTestController(obj, tc.namespaces...)
+			if _, err := c.reconcile(context.Background(), "conformance"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(objects.created) != 0 {
+				t.Errorf("Expected no objects to be created but got %v", len(objects.created))
+			}
+			run := getRun(t, c)
+			if got := conditionSummary(run)[ConditionFailed]; got != tc.expected {
+				t.Errorf("Expected failed condition %v but got %v", tc.expected, got)
+			}
+			if !run.Status.finished() {
+				t.Errorf("Expected the run to be finished")
+			}
+		})
+	}
+}
+
+func TestReconcileReflectsStatus(t *testing.T) {
+	created := SonobuoyRunStatus{
+		Namespace: "conformance",
+		Conditions: []Condition{
+			{Type: ConditionCreated, Status: corev1.ConditionTrue, Reason: "ResourcesCreated", LastTransitionTime: metav1.NewTime(now.Add(-time.Hour))},
+		},
+	}
+	plugins := []aggregation.PluginStatus{{Plugin: "e2e", Node: "global", Status: aggregation.CompleteStatus, ResultStatus: "passed"}}
+
+	testcases := []struct {
+		desc               string
+		pod                *corev1.Pod
+		expectedPhase      string
+		expectedConditions map[string]string
+		expectedFinished   bool
+		expectedRequeue    time.Duration
+	}{
+		{
+			desc:               "Aggregator not started yet",
+			expectedConditions: map[string]string{ConditionCreated: "True/ResourcesCreated"},
+			expectedRequeue:    DefaultPollInterval,
+		}, {
+			desc:               "Running",
+			pod:                aggregatorPod("conformance", corev1.PodRunning, &aggregation.Status{Status: aggregation.RunningStatus, Plugins: plugins}),
+			expectedPhase:      aggregation.RunningStatus,
+			expectedConditions: map[string]string{ConditionCreated: "True/ResourcesCreated"},
+			expectedRequeue:    DefaultPollInterval,
+		}, {
+			desc:          "Plugin failed",
+			pod:           aggregatorPod("conformance", corev1.PodRunning, &aggregation.Status{Status: aggregation.FailedStatus, Plugins: plugins}),
+			expectedPhase: aggregation.FailedStatus,
+			expectedConditions: map[string]string{
+				ConditionCreated: "True/ResourcesCreated",
+				ConditionFailed:  "True/PluginFailed",
+			},
+			expectedRequeue: DefaultPollInterval,
+		}, {
+			desc: "Complete",
+			pod: aggregatorPod("conformance", corev1.PodRunning, &aggregation.Status{
+				Status: aggregation.CompleteStatus, Plugins: plugins, Tarball: aggregation.TarInfo{Name: "results.tar.gz"},
+			}),
+			expectedPhase: aggregation.CompleteStatus,
+			expectedConditions: map[string]string{
+				ConditionCreated:  "True/ResourcesCreated",
+				ConditionComplete: "True/ResultsReady",
+			},
+			expectedFinished: true,
+			expectedRequeue:  DefaultTTL,
+		}, {
+			desc: "Aggregator failed",
+			pod:  aggregatorPod("conformance", corev1.PodFailed, nil),
+			expectedConditions: map[string]string{
+				ConditionCreated: "True/ResourcesCreated",
+				ConditionFailed:  "True/AggregatorFailed",
+			},
+			expectedFinished: true,
+			expectedRequeue:  DefaultTTL,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			kubeObjects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "conformance"}}}
+			if tc.pod != nil {
+				kubeObjects = append(kubeObjects, tc.pod)
+			}
+			c, _ := newTestController(newRun(t, SonobuoyRunSpec{}, created), kubeObjects...)
+
+			requeue, err := c.reconcile(context.Background(), "conformance")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if requeue != tc.expectedRequeue {
+				t.Errorf("Expected to requeue after %v but got %v", tc.expectedRequeue, requeue)
+			}
+
+			run := getRun(t, c)
+			if run.Status.Phase != tc.expectedPhase {
+				t.Errorf("Expected phase %q but got %q", tc.expectedPhase, run.Status.Phase)
+			}
+			if !reflect.DeepEqual(conditionSummary(run), tc.expectedConditions) {
+				t.Errorf("Expected conditions %v but got %v", tc.expectedConditions, conditionSummary(run))
+			}
+			if run.Status.finished() != tc.expectedFinished {
+				t.Errorf("Expected finished to be %v but got %v", tc.expectedFinished, run.Status.finished())
+			}
+			if created := run.Status.condition(ConditionCreated); !created.LastTransitionTime.Time.Equal(now.Add(-time.Hour)) {
+				t.Errorf("Expected the transition time of unchanged conditions to be kept but got %v", created.LastTransitionTime)
+			}
+			if tc.expectedPhase == aggregation.CompleteStatus {
+				if run.Status.Tarball == nil || run.Status.Tarball.Name != "results.tar.gz" || len(run.Status.Plugins) != 1 {
+					t.Errorf("Expected the tarball and plugins in the status but got %+v", run.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileDeletesFinishedRuns(t *testing.T) {
+	hour := int64(3600)
+	testcases := []struct {
+		desc            string
+		ttl             time.Duration
+		runTTL          *int64
+		finished        time.Duration
+		expectedDeleted bool
+		expectedRequeue time.Duration
+	}{
+		{
+			desc:            "Kept until the TTL has passed",
+			ttl:             DefaultTTL,
+			finished:        time.Hour,
+			expectedRequeue: DefaultTTL - time.Hour,
+		}, {
+			desc:            "Deleted once the TTL has passed",
+			ttl:             DefaultTTL,
+			finished:        DefaultTTL,
+			expectedDeleted: true,
+		}, {
+			desc:            "The TTL of the run overrides that of the controller",
+			ttl:             DefaultTTL,
+			runTTL:          &hour,
+			finished:        time.Hour,
+			expectedDeleted: true,
+		}, {
+			desc:     "Kept forever without a TTL",
+			finished: 365 * DefaultTTL,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.desc, func(t *testing.T) {
+			completed := metav1.NewTime(now.Add(-tc.finished))
+			status := SonobuoyRunStatus{
+				Namespace:      "conformance",
+				Phase:          aggregation.CompleteStatus,
+				CompletionTime: &completed,
+				Conditions:     []Condition{{Type: ConditionComplete, Status: corev1.ConditionTrue, LastTransitionTime: completed}},
+			}
+			c, _ := newTestController(newRun(t, SonobuoyRunSpec{TTLSecondsAfterFinished: tc.runTTL}, status))
+			c.TTL = tc.ttl
+
+			requeue, err := c.reconcile(context.Background(), "conformance")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if requeue != tc.expectedRequeue {
+				t.Errorf("Expected to requeue after %v but got %v", tc.expectedRequeue, requeue)
+			}
+			if deleted := getRun(t, c) == nil; deleted != tc.expectedDeleted {
+				t.Errorf("Expected deleted to be %v but got %v", tc.expectedDeleted, deleted)
+			}
+		})
+	}
+}
+
+func TestDecodeRunKeepsDefaults(t *testing.T) {
+	obj := newRun(t, SonobuoyRunSpec{}, SonobuoyRunStatus{})
+	unstructured.SetNestedField(obj.Object, []interface{}{"pods"}, "spec", "config", "Resources")
+	run, err := decodeRun(obj)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(run.Spec.Config.Resources, []string{"pods"}) {
+		t.Errorf("Expected the resources of the run but got %v", run.Spec.Config.Resources)
+	}
+	if config.DefaultResources[0] == "pods" {
+		t.Errorf("Expected the default resources to be left as they are")
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	_ "embed"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+)
+
+const (
+	// GroupName is the API group of the SonobuoyRun resource.
+	GroupName = "sonobuoy.hept.io"
+	// Version is the API version of the SonobuoyRun resource.
+	Version = "v1alpha1"
+	// Kind is the kind of the SonobuoyRun resource.
+	Kind = "SonobuoyRun"
+)
+
+// SonobuoyRunResource is the resource of SonobuoyRuns, for use with the dynamic client.
+var SonobuoyRunResource = schema.GroupVersionResource{Group: GroupName, Version: Version, Resource: "sonobuoyruns"}
+
+//go:embed crd.yaml
+var crd []byte
+
+// CRD returns the manifest of the SonobuoyRun custom resource definition.
+func CRD() []byte {
+	return crd
+}
+
+// The types of the conditions of a SonobuoyRun.
+const (
+	// ConditionCreated is true once the resources of the run have been created.
+	ConditionCreated = "Created"
+	// ConditionComplete is true once the results of the run are ready.
+	ConditionComplete = "Complete"
+	// ConditionFailed is true if a plugin failed, the aggregator failed or the resources of the run
+	// couldn't be created.
+	ConditionFailed = "Failed"
+)
+
+// SonobuoyRun is a run of Sonobuoy which is started and tracked by the controller.
+type SonobuoyRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SonobuoyRunSpec   `json:"spec,omitempty"`
+	Status SonobuoyRunStatus `json:"status,omitempty"`
+}
+
+// SonobuoyRunSpec describes a run the same way as the flags of `sonobuoy run`. It is only read
+// when the run is started; changing it afterwards has no effect.
+type SonobuoyRunSpec struct {
+	// Config is the Sonobuoy config, as given to `sonobuoy run --config`. Fields which aren't set
+	// have their usual defaults, except for the namespace which defaults to the name of the run and
+	// the UUID, which is the ID of the run, which defaults to the UID of the SonobuoyRun.
+	Config config.Config `json:"config,omitempty"`
+
+	// Plugins are the names of the built-in plugins (e2e and systemd-logs) to run. Both are run
+	// if neither these nor PluginDefinitions are set.
+	Plugins []string `json:"plugins,omitempty"`
+
+	// PluginDefinitions are other plugins to run, as given to `sonobuoy run --plugin`.
+	PluginDefinitions []manifest.Manifest `json:"pluginDefinitions,omitempty"`
+
+	// PluginEnv sets env vars on plugins, by plugin name, as with `sonobuoy run --plugin-env`.
+	PluginEnv map[string]map[string]string `json:"pluginEnv,omitempty"`
+
+	// KubernetesVersion is the version of Kubernetes the plugins are given, as with
+	// `sonobuoy run --kubernetes-version`. Defaults to the version of the cluster.
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// TTLSecondsAfterFinished, if set, overrides how long the run is kept once it is finished
+	// before it and all of its resources are deleted. 0 deletes it as soon as it is finished.
+	TTLSecondsAfterFinished *int64 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// SonobuoyRunStatus reflects the status the aggregator reports on its pod.
+type SonobuoyRunStatus struct {
+	// Namespace is the namespace the resources of the run are in.
+	Namespace string `json:"namespace,omitempty"`
+
+	// RunID is the ID of the run, as shown by `sonobuoy list` and given to `sonobuoy status --run`.
+	RunID string `json:"runID,omitempty"`
+
+	// Phase is the status of the run as reported by `sonobuoy status`, e.g. running or complete.
+	Phase string `json:"phase,omitempty"`
+
+	// Plugins is the status of each plugin on each node.
+	Plugins []aggregation.PluginStatus `json:"plugins,omitempty"`
+
+	// Tarball describes the results once they are ready.
+	Tarball *aggregation.TarInfo `json:"tarball,omitempty"`
+
+	// CompletionTime is when the run was seen to be finished, either complete or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// Condition is an aspect of the state of a run.
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+}
+
+// condition returns the condition of the given type, or nil if it isn't set.
+func (s *SonobuoyRunStatus) condition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// isTrue returns whether the condition of the given type is set and true.
+func (s *SonobuoyRunStatus) isTrue(conditionType string) bool {
+	c := s.condition(conditionType)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
+// setCondition sets the condition, only changing its transition time if its status changed.
+func (s *SonobuoyRunStatus) setCondition(c Condition, now metav1.Time) {
+	existing := s.condition(c.Type)
+	if existing == nil {
+		c.LastTransitionTime = now
+		s.Conditions = append(s.Conditions, c)
+		return
+	}
+	c.LastTransitionTime = existing.LastTransitionTime
+	if existing.Status != c.Status {
+		c.LastTransitionTime = now
+	}
+	*existing = c
+}
+
+// finished returns whether the run is complete or failed for good. Runs in which a plugin failed
+// are only finished once the aggregator has gathered the rest of the results.
+func (s *SonobuoyRunStatus) finished() bool {
+	return s.CompletionTime != nil
+}
+
+// decodeRun converts the object into a SonobuoyRun. Fields of the config which aren't set are
+// given their defaults.
+func decodeRun(obj *unstructured.Unstructured) (*SonobuoyRun, error) {
+	b, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't encode SonobuoyRun")
+	}
+
+	run := &SonobuoyRun{Spec: SonobuoyRunSpec{Config: *config.New()}}
+	run.Spec.Config.Namespace = ""
+	// Decoding reuses the slices of the defaults, which must not change the package defaults.
+	run.Spec.Config.Resources = append([]string(nil), run.Spec.Config.Resources...)
+	if err := json.Unmarshal(b, run); err != nil {
+		return nil, errors.Wrap(err, "couldn't decode SonobuoyRun")
+	}
+	if run.Spec.Config.Namespace == "" {
+		run.Spec.Config.Namespace = run.Name
+	}
+	if run.Spec.Config.UUID == "" {
+		run.Spec.Config.UUID = string(run.UID)
+	}
+	return run, nil
+}
+
+// encodeRun converts the SonobuoyRun into an object for the dynamic client.
+func encodeRun(run *SonobuoyRun) (*unstructured.Unstructured, error) {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't encode SonobuoyRun")
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(b); err != nil {
+		return nil, errors.Wrap(err, "couldn't decode SonobuoyRun")
+	}
+	return obj, nil
+}
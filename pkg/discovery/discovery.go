@@ -18,11 +18,13 @@ package discovery
 
 import (
 	"context"
+	"crypto"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"time"
@@ -81,6 +83,24 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 	// `meta` directory inside it (which we always need regardless of
 	// config)
 	outpath := filepath.Join(cfg.ResultsDir, cfg.UUID)
+
+	// A resumable run which a previous aggregator already completed only needs to report its
+	// tarball again; running it again would replace the results with an empty tarball.
+	if cfg.Aggregation.Resumable {
+		tarInfo, err := pluginaggregation.CompletedRun(outpath)
+		if err != nil {
+			errlog.LogError(errors.Wrap(err, "could not load aggregator checkpoint"))
+			return errCount + 1
+		}
+		if tarInfo != nil {
+			logrus.Infof("Run was already completed, results available at %v", filepath.Join(cfg.ResultsDir, tarInfo.Name))
+			if err := publishCompletedRun(kubeClient, cfg, tarInfo); err != nil {
+				errlog.LogError(err)
+				return errCount + 1
+			}
+			return errCount
+		}
+	}
 	metapath := filepath.Join(outpath, MetaLocation)
 	err = os.MkdirAll(metapath, 0755)
 	if err != nil {
@@ -114,16 +134,40 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 		}
 	}
 
+	// Load the signing key up front so that a misconfigured secret is reported at the start of the run.
+	var signingKey crypto.Signer
+	if cfg.SigningKeySecret != "" {
+		signingKey, err = loadSigningKey(config.SigningKeyMountPath)
+		trackErrorsFor("loading signing key")(err)
+	}
+
 	// Set initial annotation stating the pod is running. Ensures the annotation
 	// exists sooner for user/polling consumption and prevents issues were we try
 	// to patch a non-existant status later.
 	trackErrorsFor("setting initial pod status")(
-		setPodStatusAnnotation(kubeClient, cfg.Namespace,
+		setPodStatusAnnotation(kubeClient, cfg.Namespace, cfg.UUID,
 			&pluginaggregation.Status{
 				Status: pluginaggregation.RunningStatus,
 			}),
 	)
 
+	// Serve metrics and events about the run, if configured. The server is left running once the
+	// run is complete so that the final values can still be scraped (e.g. with --no-exit).
+	metrics := pluginaggregation.NewMetrics()
+	metrics.SetPhase(pluginaggregation.PhasePlugins)
+	events := pluginaggregation.NewEvents()
+	if cfg.Aggregation.MetricsPort != 0 {
+		serveMonitoring(cfg, metrics, events)
+	}
+
+	// Serve the results and events, if configured. Until the plugins are done this only serves
+	// partial results; the tarball is listed once it is written, before the run is reported as
+	// complete.
+	partial := newPartialResults(kubeClient, cfg, outpath)
+	if cfg.Aggregation.ResultsPort != 0 {
+		trackErrorsFor("serving results")(serveResults(kubeClient, cfg, partial.build, events))
+	}
+
 	// 2. Get the list of namespaces and apply the regex filter on the namespace
 	logrus.Infof("Filtering namespaces based on the following regex:%s", cfg.Filters.Namespaces)
 	nslist, err := FilterNamespaces(kubeClient, cfg.Filters.Namespaces)
@@ -146,11 +190,13 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 	}
 
 	// 4. Run the plugin aggregator. Save this error for clear logging later.
-	runErr := pluginaggregation.Run(kubeClient, cfg.LoadedPlugins, cfg.Aggregation, cfg.ProgressUpdatesPort, cfg.Namespace, outpath)
+	runErr := pluginaggregation.Run(kubeClient, cfg.LoadedPlugins, cfg.Aggregation, cfg.ProgressUpdatesPort, cfg.Namespace, cfg.UUID, outpath, metrics, events)
 	trackErrorsFor("running plugins")(runErr)
 
 	// 5. Run the queries
+	metrics.SetPhase(pluginaggregation.PhaseQueries)
 	recorder := NewQueryRecorder()
+	recorder.metrics = metrics
 	clusterResources, nsResources, err := getAllFilteredResources(apiHelper, cfg.Resources)
 	if err != nil {
 		errlog.LogError(errors.Wrap(err, "unable to filter resources"))
@@ -212,7 +258,10 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 	)
 
 	// 7. Clean up after the plugins
+	metrics.SetPhase(pluginaggregation.PhasePostProcessing)
+	events.Publish(pluginaggregation.Event{Type: pluginaggregation.EventPostProcessing})
 	pluginaggregation.Cleanup(kubeClient, cfg.LoadedPlugins)
+	partial.finalize()
 
 	// Postprocessing before we create the tarball.
 	for _, p := range cfg.LoadedPlugins {
@@ -220,6 +269,9 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 		for _, e := range errs {
 			logrus.Errorf("Error processing plugin %v: %v", p.GetName(), e)
 		}
+		if err := results.ApplyExpectations(&item, p.GetName(), cfg.Expectations, time.Now()); err != nil {
+			logrus.Errorf("Unable to apply expectations to the results of plugin %v: %v", p.GetName(), err)
+		}
 
 		// Save results object regardless of errors; it is our best effort to understand the results.
 		if err := results.SaveProcessedResults(p.GetName(), outpath, item); err != nil {
@@ -227,7 +279,7 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 		}
 
 		// Update the plugin status with this post-processed information.
-		if err := updatePluginStatus(kubeClient, cfg.Namespace, p.GetName(), item); err != nil {
+		if err := updatePluginStatus(kubeClient, cfg.Namespace, cfg.UUID, p.GetName(), item); err != nil {
 			logrus.Errorf("Failed to update status for plugin %v: %v", p.GetName(), err)
 		}
 	}
@@ -248,6 +300,12 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 		trackErrorsFor("saving" + results.InfoFile)(err)
 	}
 
+	// Sign the digest manifest so that the results can be verified once extracted from the tarball.
+	manifestPath := filepath.Join(metapath, pluginaggregation.ManifestFile)
+	if _, err := os.Stat(manifestPath); signingKey != nil && err == nil {
+		trackErrorsFor("signing results manifest")(signFile(signingKey, manifestPath))
+	}
+
 	// 8. tarball up results YYYYMMDDHHMM_sonobuoy_UID.tar.gz
 	filename := fmt.Sprintf("%v_sonobuoy_%v.tar.gz", t.Format("200601021504"), cfg.UUID)
 	tb := filepath.Join(cfg.ResultsDir, filename)
@@ -257,24 +315,102 @@ func Run(restConf *rest.Config, cfg *config.Config) (errCount int) {
 	}
 	trackErrorsFor("assembling results tarball")(err)
 
+	// The detached signature of the tarball is retrieved alongside it.
+	if signingKey != nil && err == nil {
+		trackErrorsFor("signing results tarball")(signFile(signingKey, tb))
+	}
+
 	tarInfo, err := getFileInfo(tb)
 	trackErrorsFor("recording tarball info")(err)
 
+	// Record the tarball in the checkpoint before the results directory is removed so that a
+	// restarted aggregator reports it rather than running the plugins again.
+	if cfg.Aggregation.Resumable && err == nil {
+		trackErrorsFor("checkpointing completed run")(pluginaggregation.CompleteCheckpoint(outpath, tarInfo))
+	}
+
 	// 9. Mark final annotation stating the results are available and status is completed.
 	trackErrorsFor("updating pod status")(
 		updateStatus(
 			kubeClient,
 			cfg.Namespace,
+			cfg.UUID,
 			pluginaggregation.CompleteStatus,
 			&tarInfo,
 		),
 	)
 
+	metrics.SetPhase(pluginaggregation.PhaseComplete)
+	events.Publish(pluginaggregation.Event{Type: pluginaggregation.EventTarballReady, Tarball: &tarInfo})
 	logrus.Infof("Results available at %v", tb)
 
 	return errCount
 }
 
+// publishCompletedRun reports a run which was already completed by a previous aggregator as
+// complete with the given tarball, serving its final metrics, events and results if configured.
+func publishCompletedRun(client kubernetes.Interface, cfg *config.Config, tarInfo *pluginaggregation.TarInfo) error {
+	metrics := pluginaggregation.NewMetrics()
+	metrics.SetPhase(pluginaggregation.PhaseComplete)
+	events := pluginaggregation.NewEvents()
+	events.Publish(pluginaggregation.Event{Type: pluginaggregation.EventTarballReady, Tarball: tarInfo})
+	if cfg.Aggregation.MetricsPort != 0 {
+		serveMonitoring(cfg, metrics, events)
+	}
+	if cfg.Aggregation.ResultsPort != 0 {
+		if err := serveResults(client, cfg, nil, events); err != nil {
+			return errors.Wrap(err, "error serving results")
+		}
+	}
+
+	return errors.Wrap(
+		updateStatus(client, cfg.Namespace, cfg.UUID, pluginaggregation.CompleteStatus, tarInfo),
+		"error updating pod status",
+	)
+}
+
+// serveMonitoring starts serving the metrics and events on the configured port in the background.
+func serveMonitoring(cfg *config.Config, metrics *pluginaggregation.Metrics, events *pluginaggregation.Events) {
+	srv := pluginaggregation.NewMonitoringServer(cfg.Aggregation.BindAddress, cfg.Aggregation.MetricsPort, metrics, events)
+	go func() {
+		logrus.WithFields(logrus.Fields{
+			"address": cfg.Aggregation.BindAddress,
+			"port":    cfg.Aggregation.MetricsPort,
+		}).Info("Starting monitoring server")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errlog.LogError(errors.Wrap(err, "monitoring server failed"))
+		}
+	}()
+}
+
+// serveResults saves a new results token for the run and starts serving the results directory,
+// the result files of the plugins of the run, partial results built by the given func and the events of the run over HTTPS in the background
+// to clients which give it.
+func serveResults(client kubernetes.Interface, cfg *config.Config, partial pluginaggregation.PartialResultsFunc, events *pluginaggregation.Events) error {
+	token, err := pluginaggregation.NewResultsToken()
+	if err != nil {
+		return err
+	}
+	srv, err := pluginaggregation.NewResultsServer(cfg.Aggregation.BindAddress, cfg.Aggregation.ResultsPort, cfg.ResultsDir, filepath.Join(cfg.ResultsDir, cfg.UUID), token, partial, events)
+	if err != nil {
+		return err
+	}
+	if err := pluginaggregation.SetResultsToken(client, cfg.Namespace, cfg.UUID, token); err != nil {
+		return err
+	}
+
+	go func() {
+		logrus.WithFields(logrus.Fields{
+			"address": cfg.Aggregation.BindAddress,
+			"port":    cfg.Aggregation.ResultsPort,
+		}).Info("Starting results server")
+		if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			errlog.LogError(errors.Wrap(err, "results server failed"))
+		}
+	}()
+	return nil
+}
+
 func statusCounts(item *results.Item, startingCounts map[string]int) {
 	if item == nil {
 		return
@@ -348,8 +484,8 @@ func getPodLogNamespaceFilter(cfg *config.Config) string {
 // updateStatus changes the summary status of the sonobuoy pod in order to
 // effect the finalized status the user sees. This does not change the
 // status of individual plugins.
-func updateStatus(client kubernetes.Interface, namespace string, status string, tarInfo *pluginaggregation.TarInfo) error {
-	podStatus, _, err := pluginaggregation.GetStatus(client, namespace)
+func updateStatus(client kubernetes.Interface, namespace, runID string, status string, tarInfo *pluginaggregation.TarInfo) error {
+	podStatus, _, err := pluginaggregation.GetStatus(client, namespace, runID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get the existing status")
 	}
@@ -359,18 +495,18 @@ func updateStatus(client kubernetes.Interface, namespace string, status string,
 	if tarInfo != nil {
 		podStatus.Tarball = *tarInfo
 	}
-	return setPodStatusAnnotation(client, namespace, podStatus)
+	return setPodStatusAnnotation(client, namespace, runID, podStatus)
 }
 
-func updatePluginStatus(client kubernetes.Interface, namespace string, pluginName string, item results.Item) error {
-	podStatus, _, err := pluginaggregation.GetStatus(client, namespace)
+func updatePluginStatus(client kubernetes.Interface, namespace, runID string, pluginName string, item results.Item) error {
+	podStatus, _, err := pluginaggregation.GetStatus(client, namespace, runID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get the existing status")
 	}
 
 	integrateResultsIntoStatus(podStatus, pluginName, &item)
 
-	return setPodStatusAnnotation(client, namespace, podStatus)
+	return setPodStatusAnnotation(client, namespace, runID, podStatus)
 }
 
 func integrateResultsIntoStatus(podStatus *pluginaggregation.Status, pluginName string, item *results.Item) {
@@ -398,7 +534,7 @@ func integrateResultsIntoStatus(podStatus *pluginaggregation.Status, pluginName
 
 // setPodStatusAnnotation sets the status on the pod via an annotation. It will overwrite the
 // existing status.
-func setPodStatusAnnotation(client kubernetes.Interface, namespace string, status *pluginaggregation.Status) error {
+func setPodStatusAnnotation(client kubernetes.Interface, namespace, runID string, status *pluginaggregation.Status) error {
 	// Marshal back into json, inject into the patch, then serialize again.
 	statusBytes, err := json.Marshal(status)
 	if err != nil {
@@ -412,7 +548,7 @@ func setPodStatusAnnotation(client kubernetes.Interface, namespace string, statu
 	}
 
 	// Determine sonobuoy pod name
-	podName, err := pluginaggregation.GetAggregatorPodName(client, namespace)
+	podName, err := pluginaggregation.GetAggregatorPodName(client, namespace, runID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get the name of the aggregator pod to set the status on")
 	}
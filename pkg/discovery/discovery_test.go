@@ -1,13 +1,21 @@
 package discovery
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
 	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 	pluginaggregation "github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
 
 	"github.com/kylelemons/godebug/pretty"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestGetPodLogNamespaceFilter(t *testing.T) {
@@ -237,3 +245,65 @@ func TestIntegrateResultsIntoStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestPublishCompletedRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_discovery_test")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{
+		UUID:       "abc",
+		Namespace:  "sonobuoy",
+		ResultsDir: dir,
+	}
+	cfg.Aggregation.Resumable = true
+	outpath := filepath.Join(dir, cfg.UUID)
+
+	// The first aggregator completed the run and removed its results directory.
+	tarInfo := pluginaggregation.TarInfo{Name: "202101020304_sonobuoy_abc.tar.gz", SHA256: "abc", Size: 42}
+	if err := pluginaggregation.CompleteCheckpoint(outpath, tarInfo); err != nil {
+		t.Fatalf("Could not complete checkpoint: %v", err)
+	}
+
+	// The restarted aggregator starts from a running status again.
+	statusJSON, err := json.Marshal(pluginaggregation.Status{Status: pluginaggregation.RunningStatus})
+	if err != nil {
+		t.Fatalf("Could not encode status: %v", err)
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sonobuoy"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "sonobuoy-abc",
+				Namespace:   "sonobuoy",
+				Labels:      map[string]string{"sonobuoy-component": "aggregator", plugin.RunIDLabel: "abc"},
+				Annotations: map[string]string{pluginaggregation.StatusAnnotationName: string(statusJSON)},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	)
+
+	completed, err := pluginaggregation.CompletedRun(outpath)
+	if err != nil || completed == nil {
+		t.Fatalf("Expected the run to be complete but got %+v, %v", completed, err)
+	}
+	if err := publishCompletedRun(client, cfg, completed); err != nil {
+		t.Fatalf("Unexpected error publishing completed run: %v", err)
+	}
+
+	status, _, err := pluginaggregation.GetStatus(client, cfg.Namespace, cfg.UUID)
+	if err != nil {
+		t.Fatalf("Could not get status: %v", err)
+	}
+	if status.Status != pluginaggregation.CompleteStatus {
+		t.Errorf("Expected status %q but got %q", pluginaggregation.CompleteStatus, status.Status)
+	}
+	if status.Tarball != tarInfo {
+		t.Errorf("Expected status to point at the original tarball %+v but got %+v", tarInfo, status.Tarball)
+	}
+	if _, err := os.Stat(outpath); !os.IsNotExist(err) {
+		t.Errorf("Expected no results directory to be recreated but got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	pluginaggregation "github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/tarball"
+)
+
+// partialResults archives the results received so far while the plugins are running, so that
+// the results of finished plugins can be looked at before the whole run is done.
+type partialResults struct {
+	// mu is held while an archive is built so that finalize waits for it rather than the
+	// plugins being post-processed in place while they are copied.
+	mu         sync.Mutex
+	finalizing bool
+
+	client  kubernetes.Interface
+	cfg     *config.Config
+	outpath string
+}
+
+func newPartialResults(client kubernetes.Interface, cfg *config.Config, outpath string) *partialResults {
+	return &partialResults{client: client, cfg: cfg, outpath: outpath}
+}
+
+// finalize stops archiving partial results, waiting for an archive in progress to be done. The
+// full results should be retrieved from then on.
+func (p *partialResults) finalize() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finalizing = true
+}
+
+// build is a pluginaggregation.PartialResultsFunc. It copies the meta directory and the results
+// of the plugins into dir, post-processes the copies of the plugins which are complete according
+// to the status of the run and archives them along with meta/partial.json. Only complete plugins
+// are listed in meta/info.json since the others have no post-processed results yet.
+func (p *partialResults) build(dir, pluginName string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finalizing {
+		return "", pluginaggregation.ErrPartialResultsUnavailable
+	}
+
+	plugins := p.cfg.LoadedPlugins
+	if pluginName != "" {
+		plugins = nil
+		for _, plugin := range p.cfg.LoadedPlugins {
+			if plugin.GetName() == pluginName {
+				plugins = append(plugins, plugin)
+			}
+		}
+		if len(plugins) == 0 {
+			return "", errors.Wrapf(pluginaggregation.ErrUnknownPlugin, "plugin %q isn't part of the run", pluginName)
+		}
+	}
+
+	status, _, err := pluginaggregation.GetStatus(p.client, p.cfg.Namespace, p.cfg.UUID)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't get the status of the plugins")
+	}
+	complete := completePlugins(status)
+
+	created := time.Now()
+	snapshot := filepath.Join(dir, "results")
+	if err := copyDir(filepath.Join(p.outpath, MetaLocation), filepath.Join(snapshot, MetaLocation)); err != nil {
+		return "", errors.Wrap(err, "couldn't copy run metadata")
+	}
+
+	info := results.PartialInfo{CreatedAt: created, Plugins: []results.PartialPluginInfo{}}
+	runInfo := RunInfo{LoadedPlugins: []string{}}
+	for _, plugin := range plugins {
+		name := plugin.GetName()
+		if err := copyDir(filepath.Join(p.outpath, results.PluginsDir, name), filepath.Join(snapshot, results.PluginsDir, name)); err != nil {
+			return "", errors.Wrapf(err, "couldn't copy results of plugin %v", name)
+		}
+		if err := dumpPlugin(plugin, snapshot); err != nil {
+			return "", err
+		}
+
+		info.Plugins = append(info.Plugins, results.PartialPluginInfo{Name: name, Complete: complete[name]})
+		if !complete[name] {
+			continue
+		}
+
+		item, errs := results.PostProcessPlugin(plugin, snapshot)
+		for _, e := range errs {
+			logrus.Errorf("Error processing partial results of plugin %v: %v", name, e)
+		}
+		if err := results.ApplyExpectations(&item, name, p.cfg.Expectations, created); err != nil {
+			logrus.Errorf("Unable to apply expectations to the partial results of plugin %v: %v", name, err)
+		}
+		if err := results.SaveProcessedResults(name, snapshot, item); err != nil {
+			return "", errors.Wrapf(err, "couldn't save partial results of plugin %v", name)
+		}
+		runInfo.LoadedPlugins = append(runInfo.LoadedPlugins, name)
+	}
+
+	for file, v := range map[string]interface{}{results.InfoFile: runInfo, results.PartialInfoFile: info} {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			return "", errors.Wrapf(err, "couldn't encode %v", file)
+		}
+		if err := ioutil.WriteFile(filepath.Join(snapshot, MetaLocation, file), blob, 0644); err != nil {
+			return "", errors.Wrapf(err, "couldn't write %v", file)
+		}
+	}
+
+	tb := filepath.Join(dir, fmt.Sprintf("%v_sonobuoy_%v_partial.tar.gz", created.Format("200601021504"), p.cfg.UUID))
+	if err := tarball.DirToTarball(snapshot, tb, true); err != nil {
+		return "", errors.Wrap(err, "couldn't archive partial results")
+	}
+	return tb, nil
+}
+
+// completePlugins returns, by name, whether each plugin in the status has reported all its
+// results, whether or not they failed.
+func completePlugins(status *pluginaggregation.Status) map[string]bool {
+	complete := map[string]bool{}
+	for _, s := range status.Plugins {
+		if _, ok := complete[s.Plugin]; !ok {
+			complete[s.Plugin] = true
+		}
+		if s.Status == pluginaggregation.RunningStatus {
+			complete[s.Plugin] = false
+		}
+	}
+	return complete
+}
+
+// copyDir copies the directories and regular files in src to dst. A missing src is copied as an
+// empty directory since plugins may not have sent any results yet.
+func copyDir(src, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(src, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, 0755)
+		case fi.Mode().IsRegular():
+			return copyFile(file, target, fi.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
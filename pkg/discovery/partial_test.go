@@ -0,0 +1,220 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	pluginaggregation "github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/daemonset"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/job"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+	"github.com/vmware-tanzu/sonobuoy/pkg/tarball"
+)
+
+func TestPartialResults(t *testing.T) {
+	status := pluginaggregation.Status{
+		Status: pluginaggregation.RunningStatus,
+		Plugins: []pluginaggregation.PluginStatus{
+			{Plugin: "done", Node: plugin.GlobalResult, Status: pluginaggregation.CompleteStatus},
+			{Plugin: "running", Node: "node1", Status: pluginaggregation.CompleteStatus},
+			{Plugin: "running", Node: "node2", Status: pluginaggregation.RunningStatus},
+		},
+	}
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		t.Fatalf("Could not encode status: %v", err)
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sonobuoy"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "sonobuoy-abc",
+				Namespace:   "sonobuoy",
+				Labels:      map[string]string{"sonobuoy-component": "aggregator", plugin.RunIDLabel: "abc"},
+				Annotations: map[string]string{pluginaggregation.StatusAnnotationName: string(statusJSON)},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	)
+
+	definition := func(name string) driver.Base {
+		return driver.Base{Definition: manifest.Manifest{SonobuoyConfig: manifest.SonobuoyConfig{
+			PluginName:   name,
+			ResultFormat: results.ResultFormatRaw,
+		}}}
+	}
+	cfg := &config.Config{
+		UUID:      "abc",
+		Namespace: "sonobuoy",
+		LoadedPlugins: []plugin.Interface{
+			&job.Plugin{Base: definition("done")},
+			&daemonset.Plugin{Base: definition("running")},
+		},
+	}
+
+	outpath, err := ioutil.TempDir("", "sonobuoy_partial_test")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	defer os.RemoveAll(outpath)
+	for name, content := range map[string]string{
+		"meta/config.json":                         "{}",
+		"plugins/done/results/global/out.txt":      "done",
+		"plugins/running/results/node1/out.txt":    "node1",
+		"plugins/running/results/node1/extra.json": "{}",
+	} {
+		file := filepath.Join(outpath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Could not create directory for %v: %v", name, err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %v: %v", name, err)
+		}
+	}
+
+	testCases := []struct {
+		desc          string
+		plugin        string
+		expectFiles   []string
+		expectPlugins []string
+		expectPartial []results.PartialPluginInfo
+	}{
+		{
+			desc: "All plugins",
+			expectFiles: []string{
+				"meta/config.json",
+				"meta/info.json",
+				"meta/partial.json",
+				"plugins/done/defintion.json",
+				"plugins/done/results/global/out.txt",
+				"plugins/done/sonobuoy_results.yaml",
+				"plugins/running/defintion.json",
+				"plugins/running/results/node1/extra.json",
+				"plugins/running/results/node1/out.txt",
+			},
+			expectPlugins: []string{"done"},
+			expectPartial: []results.PartialPluginInfo{{Name: "done", Complete: true}, {Name: "running", Complete: false}},
+		}, {
+			desc:   "Single plugin",
+			plugin: "running",
+			expectFiles: []string{
+				"meta/config.json",
+				"meta/info.json",
+				"meta/partial.json",
+				"plugins/running/defintion.json",
+				"plugins/running/results/node1/extra.json",
+				"plugins/running/results/node1/out.txt",
+			},
+			expectPlugins: []string{},
+			expectPartial: []results.PartialPluginInfo{{Name: "running", Complete: false}},
+		},
+	}
+
+	p := newPartialResults(client, cfg, outpath)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sonobuoy_partial_build")
+			if err != nil {
+				t.Fatalf("Could not create temp directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			tb, err := p.build(dir, tc.plugin)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(tb, "_sonobuoy_abc_partial.tar.gz") {
+				t.Errorf("Expected tarball to be named as partial results of the run, got %v", tb)
+			}
+
+			extracted := filepath.Join(dir, "extracted")
+			f, err := os.Open(tb)
+			if err != nil {
+				t.Fatalf("Could not open tarball: %v", err)
+			}
+			defer f.Close()
+			if err := tarball.DecodeTarball(f, extracted); err != nil {
+				t.Fatalf("Could not extract tarball: %v", err)
+			}
+
+			files := []string{}
+			filepath.Walk(extracted, func(file string, fi os.FileInfo, err error) error {
+				if err == nil && fi.Mode().IsRegular() {
+					rel, _ := filepath.Rel(extracted, file)
+					files = append(files, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			sort.Strings(files)
+			if strings.Join(files, ",") != strings.Join(tc.expectFiles, ",") {
+				t.Errorf("Expected files %v, got %v", tc.expectFiles, files)
+			}
+
+			runInfo := RunInfo{}
+			readJSON(t, filepath.Join(extracted, "meta", results.InfoFile), &runInfo)
+			if strings.Join(runInfo.LoadedPlugins, ",") != strings.Join(tc.expectPlugins, ",") {
+				t.Errorf("Expected run info to list plugins %v, got %v", tc.expectPlugins, runInfo.LoadedPlugins)
+			}
+
+			info := results.PartialInfo{}
+			readJSON(t, filepath.Join(extracted, "meta", results.PartialInfoFile), &info)
+			if len(info.Plugins) != len(tc.expectPartial) {
+				t.Fatalf("Expected partial info for plugins %v, got %v", tc.expectPartial, info.Plugins)
+			}
+			for i := range info.Plugins {
+				if info.Plugins[i] != tc.expectPartial[i] {
+					t.Errorf("Expected partial info for plugins %v, got %v", tc.expectPartial, info.Plugins)
+				}
+			}
+		})
+	}
+
+	if _, err := p.build(outpath, "missing"); errors.Cause(err) != pluginaggregation.ErrUnknownPlugin {
+		t.Errorf("Expected ErrUnknownPlugin for a plugin which isn't part of the run, got %v", err)
+	}
+
+	p.finalize()
+	if _, err := p.build(outpath, ""); errors.Cause(err) != pluginaggregation.ErrPartialResultsUnavailable {
+		t.Errorf("Expected ErrPartialResultsUnavailable once finalizing, got %v", err)
+	}
+}
+
+func readJSON(t *testing.T, file string, v interface{}) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Could not read %v: %v", file, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("Could not decode %v: %v", file, err)
+	}
+}
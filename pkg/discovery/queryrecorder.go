@@ -25,11 +25,15 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	pluginaggregation "github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
 )
 
 // QueryRecorder records a sequence of queries
 type QueryRecorder struct {
 	queries []*QueryData
+
+	// metrics, if set, also record the queries.
+	metrics *pluginaggregation.Metrics
 }
 
 // NewQueryRecorder returns a new empty QueryRecorder
@@ -60,6 +64,7 @@ func (q *QueryRecorder) RecordQuery(name string, namespace string, duration time
 	}
 
 	q.queries = append(q.queries, summary)
+	q.metrics.ObserveQuery(name, duration, recerr)
 }
 
 // DumpQueryData writes query information out to a file at the give filepath
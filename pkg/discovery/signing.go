@@ -0,0 +1,53 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"crypto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+// loadSigningKey reads the private key mounted into the aggregator from the signing key secret.
+func loadSigningKey(dir string) (crypto.Signer, error) {
+	path := filepath.Join(dir, config.SigningKeySecretKey)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read signing key %q", path)
+	}
+	return signature.ParsePrivateKey(b)
+}
+
+// signFile writes a detached signature of the file at the given path next to it.
+func signFile(key crypto.Signer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't open %q to sign it", path)
+	}
+	defer f.Close()
+
+	sig, err := signature.Sign(key, f)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't sign %q", path)
+	}
+	return errors.Wrap(ioutil.WriteFile(path+signature.FileSuffix, sig, 0644), "couldn't write signature")
+}
@@ -0,0 +1,79 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/config"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+func TestSignFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := loadSigningKey(dir); err == nil {
+		t.Error("Expected an error loading a missing signing key")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(dir, config.SigningKeySecretKey), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := loadSigningKey(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error loading signing key: %v", err)
+	}
+
+	path := filepath.Join(dir, "results.tar.gz")
+	if err := ioutil.WriteFile(path, []byte("results"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := signFile(signer, path); err != nil {
+		t.Fatalf("Unexpected error signing file: %v", err)
+	}
+
+	sig, err := ioutil.ReadFile(path + signature.FileSuffix)
+	if err != nil {
+		t.Fatalf("Expected detached signature to be written: %v", err)
+	}
+	if err := signature.Verify(key.Public(), strings.NewReader("results"), sig); err != nil {
+		t.Errorf("Expected detached signature to verify but got %v", err)
+	}
+}
@@ -22,8 +22,11 @@ package aggregation
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -49,6 +52,10 @@ type Aggregator struct {
 	// OutputDir is the directory to write the node results
 	OutputDir string
 
+	// UploadsDir is the directory where results being uploaded in chunks are kept
+	// until they have been fully received.
+	UploadsDir string
+
 	// Results stores a map of check-in results the server has seen
 	Results map[string]*plugin.Result
 
@@ -66,6 +73,10 @@ type Aggregator struct {
 	// are common.
 	FailedResults map[string]time.Time
 
+	// Digests are the hex encoded SHA-256 digests of the files written to OutputDir, keyed
+	// by their slash separated path relative to it.
+	Digests map[string]string
+
 	// resultEvents is a channel that is written to when results are seen
 	// by the server, so we can block until we're done.
 	resultEvents chan *plugin.Result
@@ -81,6 +92,20 @@ type Aggregator struct {
 	// Wait() after a FailedResult has been reported, even if all expected results
 	// are accounted for. This prevents racing the client retries that may occur.
 	retryWindow time.Duration
+
+	// checkpoint, if set, is the state saved to checkpointPath in resumable mode so that
+	// a restarted aggregator can resume the run. Both are guarded by resultsMutex.
+	checkpoint     *checkpoint
+	checkpointPath string
+
+	// uploadLocks serializes the requests for each chunked upload.
+	uploadLocks uploadLocks
+
+	// metrics, if set, records the size of the results received.
+	metrics *Metrics
+
+	// events, if set, is sent the results and progress updates received.
+	events *Events
 }
 
 // httpError is an internal error type which allows us to unify result processing
@@ -115,9 +140,11 @@ type keyer interface {
 func NewAggregator(outputDir string, expected []plugin.ExpectedResult) *Aggregator {
 	aggr := &Aggregator{
 		OutputDir:             outputDir,
+		UploadsDir:            filepath.Clean(outputDir) + ".uploads",
 		Results:               make(map[string]*plugin.Result, len(expected)),
 		ExpectedResults:       make(map[string]*plugin.ExpectedResult, len(expected)),
 		FailedResults:         make(map[string]time.Time, len(expected)),
+		Digests:               map[string]string{},
 		LatestProgressUpdates: make(map[string]*plugin.ProgressUpdate, len(expected)),
 		resultEvents:          make(chan *plugin.Result, len(expected)),
 		retryWindow:           defaultRetryWindow,
@@ -199,6 +226,12 @@ func (a *Aggregator) processResult(result *plugin.Result) error {
 		}
 	}
 
+	// Errors from failed attempts which are being retried are saved but are not the plugin's
+	// result, so they are only accepted until the plugin reports its final result.
+	if result.Attempt > 0 {
+		return a.handleAttemptResult(result)
+	}
+
 	// Don't allow duplicates unless it failed to process fully.
 	isDup := a.isResultDuplicate(result)
 	_, hadErrs := a.FailedResults[resultID]
@@ -213,12 +246,14 @@ func (a *Aggregator) processResult(result *plugin.Result) error {
 	// that Wait() doesn't hang forever on problems.
 	defer func() {
 		a.Results[result.Key()] = result
+		a.writeCheckpoint()
 		a.resultEvents <- result
 	}()
 
 	if err := a.handleResult(result); err != nil {
 		// Drop a breadcrumb so that we reconsider new results from this result.
 		a.FailedResults[result.Key()] = time.Now()
+		a.publishResult(result, err)
 		return &httpError{
 			err:  fmt.Errorf("error handling result %v: %v", resultID, err),
 			code: http.StatusInternalServerError,
@@ -227,10 +262,24 @@ func (a *Aggregator) processResult(result *plugin.Result) error {
 
 	// Upon success, we no longer want to keep processing duplicate results.
 	delete(a.FailedResults, result.Key())
+	a.publishResult(result, nil)
 
 	return nil
 }
 
+// publishResult sends an event for the result, which failed if the plugin reported an error or
+// the result couldn't be saved.
+func (a *Aggregator) publishResult(result *plugin.Result, err error) {
+	ev := Event{Type: EventResultReceived, Plugin: result.ResultType, Node: result.NodeName}
+	switch {
+	case err != nil:
+		ev.Type, ev.Error = EventResultFailed, err.Error()
+	case result.Error != "":
+		ev.Type, ev.Error = EventResultFailed, result.Error
+	}
+	a.events.Publish(ev)
+}
+
 // processProgressUpdate is the main aggregator logic for handling the progress updates from plugins.
 // We first
 func (a *Aggregator) processProgressUpdate(progress plugin.ProgressUpdate) error {
@@ -252,6 +301,8 @@ func (a *Aggregator) processProgressUpdate(progress plugin.ProgressUpdate) error
 	a.LatestProgressUpdates[progress.Key()] = &progress
 	a.progressMutex.Unlock()
 
+	a.events.Publish(Event{Type: EventProgress, Plugin: progress.PluginName, Node: progress.Node, Progress: &progress})
+
 	return nil
 }
 
@@ -369,20 +420,78 @@ func (a *Aggregator) handleResult(result *plugin.Result) error {
 	if err != nil {
 		return errors.Wrapf(err, "couldn't create results file %q", resultFile)
 	}
-	defer outFile.Close()
 
-	if _, err = io.Copy(outFile, result.Body); err != nil {
+	h := sha256.New()
+	n, err := io.Copy(outFile, io.TeeReader(result.Body, h))
+	outFile.Close()
+	a.metrics.addResultBytes(result.ResultType, n)
+	if err != nil {
 		return errors.Wrapf(err, "could not write body to file %q", resultFile)
 	}
 
+	digest := hex.EncodeToString(h.Sum(nil))
+	if err := checkDigest(result.Digest, digest); err != nil {
+		os.Remove(resultFile)
+		return errors.Wrapf(err, "result %v is corrupt", result.Key())
+	}
+	a.recordDigest(resultFile, digest)
+
+	return nil
+}
+
+// handleAttemptResult saves the error from a failed attempt of a plugin which is being retried.
+// The caller is expected to hold the resultsMutex.
+func (a *Aggregator) handleAttemptResult(result *plugin.Result) error {
+	if a.isResultDuplicate(result) {
+		return &httpError{
+			err:  fmt.Errorf("result %v already received, ignoring error from attempt %v", result.Key(), result.Attempt),
+			code: http.StatusConflict,
+		}
+	}
+
+	if err := a.handleResult(result); err != nil {
+		return &httpError{
+			err:  fmt.Errorf("error handling attempt %v of result %v: %v", result.Attempt, result.Key(), err),
+			code: http.StatusInternalServerError,
+		}
+	}
 	return nil
 }
 
 func (a *Aggregator) handleArchiveResult(result *plugin.Result) error {
 	resultsDir := filepath.Join(a.OutputDir, result.Path())
 
-	return errors.Wrapf(
-		tarball.DecodeTarball(result.Body, resultsDir),
-		"couldn't decode result %v", result.Path(),
-	)
+	h := sha256.New()
+	size := new(byteCounter)
+	body := io.TeeReader(result.Body, io.MultiWriter(h, size))
+	defer func() { a.metrics.addResultBytes(result.ResultType, int64(*size)) }()
+	if err := tarball.DecodeTarball(body, resultsDir); err != nil {
+		return errors.Wrapf(err, "couldn't decode result %v", result.Path())
+	}
+	// The decoder may stop before the end of the stream (e.g. at padding), but the digest
+	// has to cover all of it.
+	if _, err := io.Copy(ioutil.Discard, body); err != nil {
+		return errors.Wrapf(err, "couldn't read result %v", result.Path())
+	}
+
+	if err := checkDigest(result.Digest, hex.EncodeToString(h.Sum(nil))); err != nil {
+		os.RemoveAll(resultsDir)
+		return errors.Wrapf(err, "result %v is corrupt", result.Key())
+	}
+
+	digests, err := dirDigests(resultsDir)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't compute digests of result %v", result.Key())
+	}
+	for name, expected := range result.FileDigests {
+		if err := checkDigest(expected, digests[name]); err != nil {
+			os.RemoveAll(resultsDir)
+			return errors.Wrapf(err, "file %v of result %v is corrupt", name, result.Key())
+		}
+	}
+	for name, digest := range digests {
+		a.recordDigest(filepath.Join(resultsDir, filepath.FromSlash(name)), digest)
+	}
+
+	return nil
 }
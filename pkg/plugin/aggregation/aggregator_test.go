@@ -352,6 +352,40 @@ func TestAggregation_errors(t *testing.T) {
 	})
 }
 
+func TestAggregation_attemptErrors(t *testing.T) {
+	expected := []plugin.ExpectedResult{
+		{ResultType: "e2e", NodeName: "global"},
+	}
+
+	withAggregator(t, expected, func(agg *Aggregator, srv *authtest.Server) {
+		// Errors from retried attempts are saved but don't complete the plugin.
+		attempt := pluginutils.MakeAttemptErrorResult("e2e", 1, map[string]interface{}{"error": "attempt"}, "global")
+		if err := agg.processResult(attempt); err != nil {
+			t.Fatalf("Unexpected error processing attempt result: %v", err)
+		}
+		if agg.isComplete() {
+			t.Fatalf("Expected aggregator to still be waiting for results after attempt error")
+		}
+		bytes, err := ioutil.ReadFile(path.Join(agg.OutputDir, "e2e", "errors", "attempt-1", "global", "error.json"))
+		if err != nil || string(bytes) != `{"error":"attempt"}` {
+			t.Errorf("attempt error for e2e plugin incorrect (got %v): %v", string(bytes), err)
+		}
+
+		if err := agg.processResult(pluginutils.MakeErrorResult("e2e", map[string]interface{}{"error": "final"}, "global")); err != nil {
+			t.Fatalf("Unexpected error processing final result: %v", err)
+		}
+		if !agg.isComplete() {
+			t.Errorf("Expected aggregator to be complete after final result")
+		}
+
+		// Once the plugin has a result, later attempt errors are rejected.
+		late := pluginutils.MakeAttemptErrorResult("e2e", 2, map[string]interface{}{"error": "late"}, "global")
+		if err := agg.processResult(late); err == nil {
+			t.Errorf("Expected error processing attempt result after final result")
+		}
+	})
+}
+
 func TestProcessProgressUpdates(t *testing.T) {
 	defaultExpectedResults := []plugin.ExpectedResult{
 		{ResultType: "type1", NodeName: "global"},
@@ -456,7 +490,8 @@ func withAggregator(t *testing.T, expected []plugin.ExpectedResult, callback fun
 	defer os.RemoveAll(dir)
 
 	agg := NewAggregator(dir, expected)
-	handler := NewHandler(agg.HandleHTTPResult, agg.HandleHTTPProgressUpdate)
+	agg.UploadsDir = filepath.Join(dir, "uploads")
+	handler := NewHandler(agg.HandleHTTPResult, agg.HandleHTTPProgressUpdate, agg.HandleHTTPUpload)
 	srv := authtest.NewTLSServer(handler, t)
 	defer srv.Close()
 
@@ -0,0 +1,267 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+// checkpointFile is the name of the file, next to the results directory of a run, which
+// holds the aggregator checkpoint. It is kept outside of the results directory so that the
+// CA key it contains never ends up in the results tarball.
+const checkpointFile = ".checkpoint.json"
+
+// checkpoint is the state of the aggregator which is saved in resumable mode so that a
+// restarted aggregator can resume the run instead of starting it over.
+type checkpoint struct {
+	// Plugins records when each plugin was started and the session it was started with so
+	// that a restarted aggregator can find its pods again.
+	Plugins map[string]pluginCheckpoint `json:"plugins,omitempty"`
+
+	// Results are the results already received, without their bodies which are on disk.
+	Results []checkpointResult `json:"results,omitempty"`
+
+	// Digests are the digests of the result files already received, for the results manifest.
+	Digests map[string]string `json:"digests,omitempty"`
+
+	FailedResults         map[string]time.Time              `json:"failedResults,omitempty"`
+	LatestProgressUpdates map[string]*plugin.ProgressUpdate `json:"progressUpdates,omitempty"`
+
+	// CACert and CAKey are the PEM encoded certificate authority which issued the client
+	// certificates of running plugins so that they can still talk to a restarted aggregator.
+	CACert []byte `json:"caCert"`
+	CAKey  []byte `json:"caKey"`
+
+	// Complete is the tarball of the run once it has been assembled. A restarted aggregator which
+	// finds it only has to report the run as complete again since the results directory is gone.
+	Complete *TarInfo `json:"complete,omitempty"`
+}
+
+// pluginCheckpoint records a plugin which was started by the aggregator.
+type pluginCheckpoint struct {
+	SessionID string    `json:"sessionID"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// checkpointResult is the serializable form of a plugin.Result which has already been
+// written to disk.
+type checkpointResult struct {
+	NodeName   string `json:"node"`
+	ResultType string `json:"type"`
+	MimeType   string `json:"mimeType,omitempty"`
+	Filename   string `json:"filename,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// checkpointPath returns the location of the checkpoint for a run with the given output directory.
+func checkpointPath(outdir string) string {
+	return filepath.Clean(outdir) + checkpointFile
+}
+
+// loadCheckpoint reads the checkpoint at the given path. If there is no checkpoint yet, nil
+// is returned without an error.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read checkpoint %q", path)
+	}
+
+	cp := &checkpoint{}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, errors.Wrapf(err, "couldn't decode checkpoint %q", path)
+	}
+	return cp, nil
+}
+
+// CompleteCheckpoint records in the checkpoint of the run with the given output directory that
+// the run is complete and its results are in the given tarball. It must be called before the
+// output directory is removed so that a restarted aggregator doesn't post-process the run again.
+func CompleteCheckpoint(outdir string, tarInfo TarInfo) error {
+	path := checkpointPath(outdir)
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	if cp == nil {
+		cp = &checkpoint{}
+	}
+	cp.Complete = &tarInfo
+
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode checkpoint")
+	}
+	return writeCheckpointFile(path, b)
+}
+
+// CompletedRun returns the tarball recorded in the checkpoint of the run with the given output
+// directory if the run was already completed, or nil if it wasn't.
+func CompletedRun(outdir string) (*TarInfo, error) {
+	cp, err := loadCheckpoint(checkpointPath(outdir))
+	if err != nil || cp == nil {
+		return nil, err
+	}
+	return cp.Complete, nil
+}
+
+// enableCheckpoints causes the aggregator to save its state to the given path whenever
+// it changes. The certificate authority is saved with it.
+func (a *Aggregator) enableCheckpoints(path string, caCert, caKey []byte) {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	a.checkpointPath = path
+	a.checkpoint = &checkpoint{
+		Plugins: map[string]pluginCheckpoint{},
+		CACert:  caCert,
+		CAKey:   caKey,
+	}
+}
+
+// restoreCheckpoint loads the results and progress saved by a previous aggregator for this run.
+// Results which are no longer expected are ignored.
+func (a *Aggregator) restoreCheckpoint(cp *checkpoint) {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	for _, r := range cp.Results {
+		result := &plugin.Result{
+			NodeName:   r.NodeName,
+			ResultType: r.ResultType,
+			MimeType:   r.MimeType,
+			Filename:   r.Filename,
+			Error:      r.Error,
+		}
+		if !a.isExpected(result) {
+			logrus.Warningf("Ignoring checkpointed result %v which is not expected", result.Key())
+			continue
+		}
+		a.Results[result.Key()] = result
+	}
+
+	for k, v := range cp.FailedResults {
+		a.FailedResults[k] = v
+	}
+
+	for k, v := range cp.Digests {
+		a.Digests[k] = v
+	}
+
+	a.progressMutex.Lock()
+	for k, v := range cp.LatestProgressUpdates {
+		a.LatestProgressUpdates[k] = v
+	}
+	a.progressMutex.Unlock()
+
+	if a.checkpoint != nil {
+		for name, p := range cp.Plugins {
+			a.checkpoint.Plugins[name] = p
+		}
+	}
+}
+
+// startedPlugin returns the checkpointed record of the given plugin having been started, if any.
+func (a *Aggregator) startedPlugin(name string) (pluginCheckpoint, bool) {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	if a.checkpoint == nil {
+		return pluginCheckpoint{}, false
+	}
+	p, ok := a.checkpoint.Plugins[name]
+	return p, ok
+}
+
+// pluginStarted records that the given plugin was started and saves the checkpoint.
+func (a *Aggregator) pluginStarted(p plugin.Interface, startedAt time.Time) {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	if a.checkpoint == nil {
+		return
+	}
+	started := pluginCheckpoint{StartedAt: startedAt}
+	if s, ok := p.(sessionPlugin); ok {
+		started.SessionID = s.GetSessionID()
+	}
+	a.checkpoint.Plugins[p.GetName()] = started
+	a.writeCheckpoint()
+}
+
+// saveCheckpoint saves the current state of the aggregator if checkpoints are enabled.
+func (a *Aggregator) saveCheckpoint() {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+	a.writeCheckpoint()
+}
+
+// writeCheckpoint saves the current state of the aggregator if checkpoints are enabled.
+// The caller is expected to hold the resultsMutex. Errors are logged rather than returned
+// since failing to checkpoint only matters if the aggregator is later restarted.
+func (a *Aggregator) writeCheckpoint() {
+	if a.checkpoint == nil {
+		return
+	}
+
+	cp := *a.checkpoint
+	cp.Results = make([]checkpointResult, 0, len(a.Results))
+	for _, r := range a.Results {
+		cp.Results = append(cp.Results, checkpointResult{
+			NodeName:   r.NodeName,
+			ResultType: r.ResultType,
+			MimeType:   r.MimeType,
+			Filename:   r.Filename,
+			Error:      r.Error,
+		})
+	}
+	cp.FailedResults = a.FailedResults
+	cp.Digests = a.Digests
+
+	a.progressMutex.Lock()
+	cp.LatestProgressUpdates = a.LatestProgressUpdates
+	b, err := json.Marshal(cp)
+	a.progressMutex.Unlock()
+	if err != nil {
+		logrus.Errorf("Failed to encode aggregator checkpoint: %v", err)
+		return
+	}
+
+	if err := writeCheckpointFile(a.checkpointPath, b); err != nil {
+		logrus.Errorf("Failed to write aggregator checkpoint: %v", err)
+	}
+}
+
+// writeCheckpointFile writes the encoded checkpoint to the given path. It is written to a
+// temporary file first so that a crash never leaves a partial checkpoint behind.
+func writeCheckpointFile(path string, b []byte) error {
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0600); err != nil {
+		return errors.Wrapf(err, "couldn't write checkpoint %q", tmpPath)
+	}
+	return errors.Wrapf(os.Rename(tmpPath, path), "couldn't write checkpoint %q", path)
+}
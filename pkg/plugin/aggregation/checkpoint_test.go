@@ -0,0 +1,247 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/job"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+)
+
+func TestCheckpointPath(t *testing.T) {
+	testCases := []struct {
+		outdir   string
+		expected string
+	}{
+		{outdir: "/tmp/sonobuoy/abc", expected: "/tmp/sonobuoy/abc.checkpoint.json"},
+		{outdir: "/tmp/sonobuoy/abc/", expected: "/tmp/sonobuoy/abc.checkpoint.json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.outdir, func(t *testing.T) {
+			if got := checkpointPath(tc.outdir); got != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadCheckpoint_missing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_checkpoint")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cp, err := loadCheckpoint(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Errorf("Expected no error for a missing checkpoint but got %v", err)
+	}
+	if cp != nil {
+		t.Errorf("Expected no checkpoint but got %+v", cp)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte("not json"), 0600); err != nil {
+		t.Fatalf("Could not write checkpoint: %v", err)
+	}
+	if _, err := loadCheckpoint(invalid); err == nil {
+		t.Error("Expected an error for an invalid checkpoint but got none")
+	}
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_checkpoint")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	expected := []plugin.ExpectedResult{
+		{NodeName: "node1", ResultType: "systemd-logs"},
+		{NodeName: "node2", ResultType: "systemd-logs"},
+		{NodeName: plugin.GlobalResult, ResultType: "e2e"},
+	}
+	path := checkpointPath(filepath.Join(dir, "run"))
+
+	newPlugin := func(name, session string) *job.Plugin {
+		return &job.Plugin{
+			Base: driver.Base{
+				Definition: manifest.Manifest{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: name}},
+				SessionID:  session,
+			},
+		}
+	}
+
+	// Record some results and progress with the first aggregator.
+	first := NewAggregator(filepath.Join(dir, "run", "plugins"), expected)
+	first.enableCheckpoints(path, []byte("cert"), []byte("key"))
+	startedAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	first.pluginStarted(newPlugin("systemd-logs", "session1"), startedAt)
+
+	if err := first.processResult(&plugin.Result{
+		NodeName:   "node1",
+		ResultType: "systemd-logs",
+		Filename:   "out.json",
+		Body:       strings.NewReader("foo"),
+	}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+	if err := first.processProgressUpdate(plugin.ProgressUpdate{PluginName: "e2e", Node: plugin.GlobalResult, Message: "halfway"}); err != nil {
+		t.Fatalf("Unexpected error processing progress update: %v", err)
+	}
+	first.saveCheckpoint()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected checkpoint to only be readable by its owner but had mode %v", info.Mode())
+	}
+
+	// A second aggregator should pick up where the first left off.
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading checkpoint: %v", err)
+	}
+	if string(cp.CACert) != "cert" || string(cp.CAKey) != "key" {
+		t.Errorf("Expected CA to be saved in the checkpoint but got cert %q and key %q", cp.CACert, cp.CAKey)
+	}
+
+	second := NewAggregator(filepath.Join(dir, "run", "plugins"), expected)
+	second.enableCheckpoints(path, cp.CACert, cp.CAKey)
+	second.restoreCheckpoint(cp)
+
+	result, ok := second.Results["systemd-logs/node1"]
+	if !ok {
+		t.Fatalf("Expected result to be restored but got %v", second.Results)
+	}
+	if result.Filename != "out.json" || result.Body != nil {
+		t.Errorf("Expected result to be restored without its body but got %+v", result)
+	}
+	if len(second.Results) != 1 {
+		t.Errorf("Expected only 1 result to be restored but got %v", second.Results)
+	}
+
+	if got := second.Digests["systemd-logs/results/node1/out.json"]; got != digestOf("foo") {
+		t.Errorf("Expected digest of result to be restored but got %v", second.Digests)
+	}
+
+	if progress, ok := second.LatestProgressUpdates["e2e/global"]; !ok || progress.Message != "halfway" {
+		t.Errorf("Expected progress update to be restored but got %v", second.LatestProgressUpdates)
+	}
+
+	started, ok := second.startedPlugin("systemd-logs")
+	if !ok || started.SessionID != "session1" || !started.StartedAt.Equal(startedAt) {
+		t.Errorf("Expected plugin start to be restored but got %+v", started)
+	}
+	if _, ok := second.startedPlugin("e2e"); ok {
+		t.Error("Expected e2e plugin not to have been started")
+	}
+
+	// Plugins which were started keep the session they were started with.
+	plugins := []plugin.Interface{newPlugin("systemd-logs", "new1"), newPlugin("e2e", "new2")}
+	restoreSessions(second, plugins)
+	if got := plugins[0].(*job.Plugin).GetSessionID(); got != "session1" {
+		t.Errorf("Expected started plugin to use its original session but got %q", got)
+	}
+	if got := plugins[1].(*job.Plugin).GetSessionID(); got != "new2" {
+		t.Errorf("Expected plugin which wasn't started to keep its session but got %q", got)
+	}
+
+	// Results already received are still treated as duplicates.
+	err = second.processResult(&plugin.Result{NodeName: "node1", ResultType: "systemd-logs", Body: strings.NewReader("foo")})
+	if err == nil {
+		t.Error("Expected restored result to be treated as a duplicate")
+	}
+}
+
+func TestCheckpointDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_checkpoint")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	expected := []plugin.ExpectedResult{{NodeName: "node1", ResultType: "systemd-logs"}}
+	agg := NewAggregator(filepath.Join(dir, "plugins"), expected)
+	if err := agg.processResult(&plugin.Result{NodeName: "node1", ResultType: "systemd-logs", Body: strings.NewReader("foo")}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+	agg.saveCheckpoint()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+checkpointFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no checkpoint to be written but found %v", files)
+	}
+}
+
+func TestCompleteCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_checkpoint")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	outdir := filepath.Join(dir, "run")
+	expected := []plugin.ExpectedResult{{NodeName: "node1", ResultType: "systemd-logs"}}
+	agg := NewAggregator(filepath.Join(outdir, "plugins"), expected)
+	agg.enableCheckpoints(checkpointPath(outdir), []byte("cert"), []byte("key"))
+	agg.saveCheckpoint()
+
+	if tarInfo, err := CompletedRun(outdir); err != nil || tarInfo != nil {
+		t.Fatalf("Expected run not to be complete yet but got %+v, %v", tarInfo, err)
+	}
+
+	tarInfo := TarInfo{Name: "202101020304_sonobuoy_run.tar.gz", SHA256: "abc", Size: 42}
+	if err := CompleteCheckpoint(outdir, tarInfo); err != nil {
+		t.Fatalf("Unexpected error completing checkpoint: %v", err)
+	}
+
+	// The output directory is removed once the tarball is built; the marker must outlive it.
+	if err := os.RemoveAll(outdir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := CompletedRun(outdir)
+	if err != nil {
+		t.Fatalf("Unexpected error loading completed run: %v", err)
+	}
+	if got == nil || *got != tarInfo {
+		t.Errorf("Expected completed run with tarball %+v but got %+v", tarInfo, got)
+	}
+
+	cp, err := loadCheckpoint(checkpointPath(outdir))
+	if err != nil {
+		t.Fatalf("Unexpected error loading checkpoint: %v", err)
+	}
+	if string(cp.CACert) != "cert" || string(cp.CAKey) != "key" {
+		t.Errorf("Expected the rest of the checkpoint to be kept but got cert %q and key %q", cp.CACert, cp.CAKey)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ManifestFile is the name of the file, in the meta directory of the results, which lists
+	// the digests of the plugin results received by the aggregator.
+	ManifestFile = "manifest.json"
+
+	// DigestAlgorithm is the algorithm used for all result digests.
+	DigestAlgorithm = "sha256"
+
+	// metaDir is the directory of the results which holds metadata about the run.
+	metaDir = "meta"
+)
+
+// ResultsManifest lists the digests of the plugin results received by the aggregator so that
+// the files in a results tarball can later be checked against what was received.
+type ResultsManifest struct {
+	Algorithm string `json:"algorithm"`
+
+	// Files maps the slash separated path of each file within the results to its hex
+	// encoded digest.
+	Files map[string]string `json:"files"`
+}
+
+// checkDigest returns an error if the expected digest is set and doesn't match the actual one.
+func checkDigest(expected, actual string) error {
+	if len(expected) == 0 || strings.EqualFold(expected, actual) {
+		return nil
+	}
+	return fmt.Errorf("%v digest %q does not match the expected digest %q", DigestAlgorithm, actual, expected)
+}
+
+// recordDigest saves the digest of a file written to the OutputDir. The caller is expected to
+// hold the resultsMutex.
+func (a *Aggregator) recordDigest(file, digest string) {
+	rel, err := filepath.Rel(a.OutputDir, file)
+	if err != nil {
+		logrus.Errorf("Failed to record digest of %v: %v", file, err)
+		return
+	}
+	a.Digests[filepath.ToSlash(rel)] = digest
+}
+
+// Manifest returns the manifest of the results received so far. The paths of the files are
+// prefixed with the given (slash separated) directory, which should be the location of the
+// OutputDir within the results.
+func (a *Aggregator) Manifest(prefix string) ResultsManifest {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	m := ResultsManifest{
+		Algorithm: DigestAlgorithm,
+		Files:     make(map[string]string, len(a.Digests)),
+	}
+	for name, digest := range a.Digests {
+		m.Files[path.Join(prefix, name)] = digest
+	}
+	return m
+}
+
+// writeManifest writes the manifest of the results received so far into the meta directory
+// of the given results directory.
+func (a *Aggregator) writeManifest(outdir string) error {
+	prefix, err := filepath.Rel(outdir, a.OutputDir)
+	if err != nil {
+		return errors.Wrap(err, "couldn't determine location of plugin results")
+	}
+
+	b, err := json.Marshal(a.Manifest(filepath.ToSlash(prefix)))
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode results manifest")
+	}
+
+	dir := filepath.Join(outdir, metaDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "couldn't create directory %q", dir)
+	}
+	return errors.Wrap(
+		ioutil.WriteFile(filepath.Join(dir, ManifestFile), b, 0644),
+		"couldn't write results manifest",
+	)
+}
+
+// dirDigests returns the digest of each regular file under the given directory, keyed by its
+// slash separated path relative to the directory.
+func dirDigests(dir string) (map[string]string, error) {
+	digests := map[string]string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		digest, err := fileDigest(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		digests[filepath.ToSlash(rel)] = digest
+		return nil
+	})
+	return digests, err
+}
+
+// fileDigest returns the hex encoded digest of the file at the given path.
+func fileDigest(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "couldn't read %q", p)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
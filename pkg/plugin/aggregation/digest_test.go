@@ -0,0 +1,186 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+func TestProcessResultDigests(t *testing.T) {
+	tarBytes := makeTarWithContents(t, "inside_tar.txt", []byte("foo"))
+
+	testCases := []struct {
+		desc     string
+		result   *plugin.Result
+		expected map[string]string
+		wantErr  bool
+	}{
+		{
+			desc:     "Digest is recorded without being given",
+			result:   &plugin.Result{NodeName: "node1", ResultType: "systemd_logs", Filename: "out.txt", Body: strings.NewReader("foo")},
+			expected: map[string]string{"systemd_logs/results/node1/out.txt": digestOf("foo")},
+		}, {
+			desc:     "Matching digest is accepted",
+			result:   &plugin.Result{NodeName: "node1", ResultType: "systemd_logs", Filename: "out.txt", Body: strings.NewReader("foo"), Digest: digestOf("foo")},
+			expected: map[string]string{"systemd_logs/results/node1/out.txt": digestOf("foo")},
+		}, {
+			desc:     "Mismatched digest is rejected",
+			result:   &plugin.Result{NodeName: "node1", ResultType: "systemd_logs", Filename: "out.txt", Body: strings.NewReader("fob"), Digest: digestOf("foo")},
+			expected: map[string]string{},
+			wantErr:  true,
+		}, {
+			desc: "Files in tarballs are recorded",
+			result: &plugin.Result{
+				NodeName: "node1", ResultType: "systemd_logs", MimeType: gzipMimeType, Body: bytes.NewReader(tarBytes),
+				Digest:      digestOf(string(tarBytes)),
+				FileDigests: map[string]string{"inside_tar.txt": digestOf("foo")},
+			},
+			expected: map[string]string{"systemd_logs/results/node1/inside_tar.txt": digestOf("foo")},
+		}, {
+			desc: "Mismatched tarball digest is rejected",
+			result: &plugin.Result{
+				NodeName: "node1", ResultType: "systemd_logs", MimeType: gzipMimeType, Body: bytes.NewReader(tarBytes),
+				Digest: digestOf("foo"),
+			},
+			expected: map[string]string{},
+			wantErr:  true,
+		}, {
+			desc: "Mismatched file digest is rejected",
+			result: &plugin.Result{
+				NodeName: "node1", ResultType: "systemd_logs", MimeType: gzipMimeType, Body: bytes.NewReader(tarBytes),
+				FileDigests: map[string]string{"inside_tar.txt": digestOf("bar")},
+			},
+			expected: map[string]string{},
+			wantErr:  true,
+		}, {
+			desc: "Missing file is rejected",
+			result: &plugin.Result{
+				NodeName: "node1", ResultType: "systemd_logs", MimeType: gzipMimeType, Body: bytes.NewReader(tarBytes),
+				FileDigests: map[string]string{"other.txt": digestOf("foo")},
+			},
+			expected: map[string]string{},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sonobuoy_digest_test")
+			if err != nil {
+				t.Fatalf("Could not create temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			agg := NewAggregator(dir, []plugin.ExpectedResult{{NodeName: "node1", ResultType: "systemd_logs"}})
+			err = agg.processResult(tc.result)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v but got %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(agg.Digests, tc.expected) {
+				t.Errorf("Expected digests %v but got %v", tc.expected, agg.Digests)
+			}
+
+			if tc.wantErr {
+				if _, ok := agg.FailedResults[tc.result.Key()]; !ok {
+					t.Error("Expected corrupt result to be retriable")
+				}
+				if files, err := dirDigests(dir); err != nil || len(files) > 0 {
+					t.Errorf("Expected corrupt result to be removed but found %v (err %v)", files, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_digest_test")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	agg := NewAggregator(filepath.Join(dir, "plugins"), []plugin.ExpectedResult{{NodeName: plugin.GlobalResult, ResultType: "e2e"}})
+	if err := agg.processResult(&plugin.Result{NodeName: plugin.GlobalResult, ResultType: "e2e", Filename: "e2e.txt", Body: strings.NewReader("foo")}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+	if err := agg.writeManifest(dir); err != nil {
+		t.Fatalf("Unexpected error writing manifest: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "meta", ManifestFile))
+	if err != nil {
+		t.Fatalf("Expected manifest to be written: %v", err)
+	}
+	m := ResultsManifest{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Couldn't decode manifest: %v", err)
+	}
+
+	expected := ResultsManifest{
+		Algorithm: DigestAlgorithm,
+		Files:     map[string]string{"plugins/e2e/results/global/e2e.txt": digestOf("foo")},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected manifest %+v but got %+v", expected, m)
+	}
+}
+
+func TestFileDigestsFromHeader(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		values   []string
+		expected map[string]string
+	}{
+		{
+			desc: "No header",
+		}, {
+			desc:     "Paths are cleaned",
+			values:   []string{"abc ./results/e2e.log", "def junit.xml"},
+			expected: map[string]string{"results/e2e.log": "abc", "junit.xml": "def"},
+		}, {
+			desc:     "Paths may contain spaces",
+			values:   []string{"abc my file.txt"},
+			expected: map[string]string{"my file.txt": "abc"},
+		}, {
+			desc:     "Malformed values are ignored",
+			values:   []string{"abc", "def ok.txt"},
+			expected: map[string]string{"ok.txt": "def"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range tc.values {
+				h.Add(ResultFileDigestHeader, v)
+			}
+			if got := fileDigestsFromHeader(h); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+// The types of events in a run.
+const (
+	// EventPluginStarted is sent when the aggregator launches a plugin.
+	EventPluginStarted = "plugin-started"
+	// EventProgress is sent for every progress update a plugin sends.
+	EventProgress = "progress"
+	// EventResultReceived is sent when a plugin sends its results for a node.
+	EventResultReceived = "result-received"
+	// EventResultFailed is sent when a plugin fails, times out or its results can't be saved.
+	EventResultFailed = "result-failed"
+	// EventPostProcessing is sent once all the results are in and are being processed.
+	EventPostProcessing = "post-processing"
+	// EventTarballReady is sent once the results can be retrieved. It is the last event of a run.
+	EventTarballReady = "tarball-ready"
+)
+
+const (
+	// EventsPath is the path the stream of events is served on.
+	EventsPath = "/events"
+
+	// eventHistorySize is how many past events are sent to clients when they connect.
+	eventHistorySize = 1000
+
+	// eventBufferSize is how many events can be waiting to be sent to a client before the
+	// client is considered too slow and disconnected.
+	eventBufferSize = 100
+
+	// eventKeepAliveInterval is how often a comment is sent to clients when there are no events
+	// so that proxies don't close the connection.
+	eventKeepAliveInterval = 30 * time.Second
+)
+
+// Event is something which happened during a run.
+type Event struct {
+	// ID is the position of the event in the run, starting at 1.
+	ID   int64     `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	Plugin string `json:"plugin,omitempty"`
+	Node   string `json:"node,omitempty"`
+
+	// Progress is the progress update of EventProgress events.
+	Progress *plugin.ProgressUpdate `json:"progress,omitempty"`
+
+	// Error is why the result of EventResultFailed events failed.
+	Error string `json:"error,omitempty"`
+
+	// Tarball describes the results of EventTarballReady events.
+	Tarball *TarInfo `json:"tarball,omitempty"`
+}
+
+// Events keeps track of the events of a run and streams them to clients as server-sent events.
+// Clients first get the most recent past events so that they can tell what the run is doing.
+// A nil *Events records nothing.
+type Events struct {
+	mu          sync.Mutex
+	nextID      int64
+	history     []Event
+	subscribers map[chan Event]struct{}
+	done        bool
+}
+
+// NewEvents returns a stream of events for a run which hasn't started yet.
+func NewEvents() *Events {
+	return &Events{
+		nextID:      1,
+		subscribers: map[chan Event]struct{}{},
+	}
+}
+
+// Publish sends the event to every client. Clients which can't keep up are disconnected rather
+// than allowed to slow down the run.
+func (e *Events) Publish(ev Event) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ev.ID = e.nextID
+	e.nextID++
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	e.history = append(e.history, ev)
+	if len(e.history) > eventHistorySize {
+		e.history = e.history[len(e.history)-eventHistorySize:]
+	}
+	if ev.Type == EventTarballReady {
+		e.done = true
+	}
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			logrus.Warn("Disconnecting slow client of the event stream")
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the past events after the given ID and a channel of the events to come,
+// which is closed once the run is complete. The returned function stops the subscription.
+func (e *Events) subscribe(after int64) ([]Event, <-chan Event, func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var past []Event
+	for _, ev := range e.history {
+		if ev.ID > after {
+			past = append(past, ev)
+		}
+	}
+
+	ch := make(chan Event, eventBufferSize)
+	if e.done {
+		close(ch)
+		return past, ch, func() {}
+	}
+	e.subscribers[ch] = struct{}{}
+	return past, ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, ok := e.subscribers[ch]; ok {
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// ServeHTTP streams the events as server-sent events until the run is complete or the client
+// goes away. Clients which reconnect with the Last-Event-ID header only get the events since.
+func (e *Events) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var after int64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		var err error
+		if after, err = strconv.ParseInt(id, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid Last-Event-ID %q", id), http.StatusBadRequest)
+			return
+		}
+	}
+
+	past, ch, cancel := e.subscribe(after)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range past {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event in the server-sent events format.
+func writeEvent(w http.ResponseWriter, ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, b)
+	return err
+}
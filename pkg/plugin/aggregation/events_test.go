@@ -0,0 +1,200 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+// readEvents reads the data of each event of the stream until it ends. Failures are reported with
+// t.Errorf since it is called from other goroutines.
+func readEvents(t *testing.T, srv *httptest.Server, lastEventID string) []Event {
+	req, err := http.NewRequest("GET", srv.URL+EventsPath, nil)
+	if err != nil {
+		t.Errorf("Could not create request: %v", err)
+		return nil
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("Could not get events: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected content type text/event-stream but got %q", ct)
+	}
+
+	var events []Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if !strings.HasPrefix(scanner.Text(), "data: ") {
+			continue
+		}
+		ev := Event{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(scanner.Text(), "data: ")), &ev); err != nil {
+			t.Errorf("Could not decode event %q: %v", scanner.Text(), err)
+			return nil
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// eventSummaries returns the type, plugin and node of each event.
+func eventSummaries(events []Event) []string {
+	var summaries []string
+	for _, ev := range events {
+		summaries = append(summaries, strings.Join([]string{ev.Type, ev.Plugin, ev.Node}, "/"))
+	}
+	return summaries
+}
+
+func TestEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_events_test")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	aggr := NewAggregator(dir, []plugin.ExpectedResult{
+		{ResultType: "e2e", NodeName: "global"},
+		{ResultType: "systemd-logs", NodeName: "node1"},
+	})
+	events := NewEvents()
+	aggr.events = events
+	srv := httptest.NewServer(NewMonitoringServer("", 0, NewMetrics(), events).Handler)
+	defer srv.Close()
+
+	events.Publish(Event{Type: EventPluginStarted, Plugin: "e2e"})
+	if err := aggr.processProgressUpdate(plugin.ProgressUpdate{PluginName: "e2e", Node: "global", Total: 10, Completed: 4}); err != nil {
+		t.Fatalf("Unexpected error processing progress update: %v", err)
+	}
+	if err := aggr.processResult(&plugin.Result{ResultType: "systemd-logs", NodeName: "node1", Body: strings.NewReader("{}"), Error: "oops"}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+
+	// Clients connecting during the run get the past events and then the new ones as they happen.
+	done := make(chan []Event)
+	go func() { done <- readEvents(t, srv, "") }()
+	// The reconnecting client only gets the events it missed.
+	reconnected := make(chan []Event)
+	go func() { reconnected <- readEvents(t, srv, "2") }()
+
+	if err := aggr.processResult(&plugin.Result{ResultType: "e2e", NodeName: "global", Body: strings.NewReader("results")}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+	events.Publish(Event{Type: EventPostProcessing})
+	events.Publish(Event{Type: EventTarballReady, Tarball: &TarInfo{Name: "results.tar.gz"}})
+
+	expected := []string{
+		"plugin-started/e2e/",
+		"progress/e2e/global",
+		"result-failed/systemd-logs/node1",
+		"result-received/e2e/global",
+		"post-processing//",
+		"tarball-ready//",
+	}
+
+	// The clients may have connected at any point but must not miss or repeat events.
+	got := <-done
+	if len(got) != len(expected) {
+		t.Fatalf("Expected events %v but got %v", expected, eventSummaries(got))
+	}
+	if !reflect.DeepEqual(eventSummaries(got), expected) {
+		t.Errorf("Expected events %v but got %v", expected, eventSummaries(got))
+	}
+	for i, ev := range got {
+		if ev.ID != int64(i+1) || ev.Time.IsZero() {
+			t.Errorf("Expected event %v to have ID %v and a time but got %+v", i, i+1, ev)
+		}
+	}
+	if got[1].Progress == nil || got[1].Progress.Completed != 4 {
+		t.Errorf("Expected the progress update in the event but got %+v", got[1].Progress)
+	}
+	if got[2].Error != "oops" {
+		t.Errorf("Expected the plugin error in the event but got %q", got[2].Error)
+	}
+	if got[5].Tarball == nil || got[5].Tarball.Name != "results.tar.gz" {
+		t.Errorf("Expected the tarball in the event but got %+v", got[5].Tarball)
+	}
+
+	if got := <-reconnected; !reflect.DeepEqual(eventSummaries(got), expected[2:]) {
+		t.Errorf("Expected events %v after reconnecting but got %v", expected[2:], eventSummaries(got))
+	}
+
+	// Once the run is complete, clients get the past events and the stream ends.
+	if got := readEvents(t, srv, ""); !reflect.DeepEqual(eventSummaries(got), expected) {
+		t.Errorf("Expected events %v once complete but got %v", expected, eventSummaries(got))
+	}
+}
+
+func TestEventsInvalidLastEventID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", EventsPath, nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	NewEvents().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v but got %v", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestEventsHistoryIsBounded(t *testing.T) {
+	events := NewEvents()
+	for i := 0; i < eventHistorySize+10; i++ {
+		events.Publish(Event{Type: EventProgress})
+	}
+	past, _, cancel := events.subscribe(0)
+	defer cancel()
+	if len(past) != eventHistorySize || past[0].ID != 11 {
+		t.Errorf("Expected the last %v events but got %v starting at %v", eventHistorySize, len(past), past[0].ID)
+	}
+}
+
+func TestEventsSlowSubscriber(t *testing.T) {
+	events := NewEvents()
+	_, ch, cancel := events.subscribe(0)
+	defer cancel()
+	for i := 0; i < eventBufferSize+1; i++ {
+		events.Publish(Event{Type: EventProgress})
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != eventBufferSize {
+		t.Errorf("Expected the slow subscriber to get %v events before being disconnected but got %v", eventBufferSize, n)
+	}
+}
+
+func TestNilEvents(t *testing.T) {
+	var e *Events
+	e.Publish(Event{Type: EventTarballReady})
+}
@@ -21,6 +21,9 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -50,6 +53,36 @@ const (
 	// Callers should add one path element as a suffix to this to specify the plugin name (e.g. `<path>/plugin`)
 	PathProgressGlobal = "/api/v1/progress/global"
 
+	// PathUploadsByNode is the path for chunked uploads of node-specific results. Callers should
+	// add two path elements as a suffix to this to specify the node and plugin (e.g. `<path>/node/plugin`).
+	// An upload is started (or resumed) by POSTing to it and chunks are then sent with PATCH.
+	PathUploadsByNode = "/api/v1/uploads/by-node"
+
+	// PathUploadsGlobal is the path for chunked uploads of global (non-node-specific) results. Callers
+	// should add one path element as a suffix to this to specify the plugin name (e.g. `<path>/plugin`).
+	PathUploadsGlobal = "/api/v1/uploads/global"
+
+	// ResultDigestHeader is the header giving the hex encoded SHA-256 digest of a result. If set,
+	// the result is only accepted if its digest matches.
+	ResultDigestHeader = "Sonobuoy-Result-Digest"
+
+	// ResultFileDigestHeader is the header giving the digest of one of the files inside a tarball
+	// result. It may be repeated, once per file, and has the form `<hex encoded SHA-256> <path>`
+	// like the output of sha256sum.
+	ResultFileDigestHeader = "Sonobuoy-Result-File-Digest"
+
+	// UploadLengthHeader is the header giving the total size, in bytes, of a result when starting a
+	// chunked upload.
+	UploadLengthHeader = "Sonobuoy-Upload-Length"
+
+	// UploadDigestHeader is the header giving the hex encoded SHA-256 digest of a result when
+	// starting a chunked upload.
+	UploadDigestHeader = "Sonobuoy-Upload-Digest"
+
+	// UploadOffsetHeader is the header giving the offset of the chunk being sent in a request or,
+	// in a response, the offset the upload should continue from.
+	UploadOffsetHeader = "Sonobuoy-Upload-Offset"
+
 	// resultsGlobal is the path for node-specific results to be PUT
 	resultsByNode = PathResultsByNode + "/{node}/{plugin}"
 
@@ -62,6 +95,12 @@ const (
 	// progressGlobal is the path for progress updates to be POSTed to for global (non node-specific) plugins
 	progressGlobal = PathProgressGlobal + "/{plugin}"
 
+	// uploadsByNode is the path for chunked uploads of node-specific results
+	uploadsByNode = PathUploadsByNode + "/{node}/{plugin}"
+
+	// uploadsGlobal is the path for chunked uploads of global (non-node-specific) results
+	uploadsGlobal = PathUploadsGlobal + "/{plugin}"
+
 	// defaultFilename is the name given to the file if no filename is given in the
 	// content-disposition header
 	defaultFilename = "result"
@@ -69,9 +108,11 @@ const (
 
 var (
 	// Only used for route reversals
-	r           = mux.NewRouter()
-	nodeRoute   = r.Path(resultsByNode).BuildOnly()
-	globalRoute = r.Path(resultsGlobal).BuildOnly()
+	r                 = mux.NewRouter()
+	nodeRoute         = r.Path(resultsByNode).BuildOnly()
+	globalRoute       = r.Path(resultsGlobal).BuildOnly()
+	nodeUploadRoute   = r.Path(uploadsByNode).BuildOnly()
+	globalUploadRoute = r.Path(uploadsGlobal).BuildOnly()
 )
 
 // Handler is a net/http Handler that can handle API requests for aggregation of
@@ -84,6 +125,10 @@ type Handler struct {
 
 	// ProgressCallback is the function that is called when a progress update is checked in.
 	ProgressCallback func(plugin.ProgressUpdate, http.ResponseWriter)
+
+	// UploadCallback is the function that is called when a chunked upload is started or
+	// a chunk of it is sent.
+	UploadCallback func(*Upload, http.ResponseWriter)
 }
 
 // NewHandler constructs a new aggregation handler which will handler results
@@ -91,11 +136,13 @@ type Handler struct {
 func NewHandler(
 	resultsCallback func(*plugin.Result, http.ResponseWriter),
 	progressCallback func(plugin.ProgressUpdate, http.ResponseWriter),
+	uploadCallback func(*Upload, http.ResponseWriter),
 ) http.Handler {
 	handler := &Handler{
 		Router:           *mux.NewRouter(),
 		ResultsCallback:  resultsCallback,
 		ProgressCallback: progressCallback,
+		UploadCallback:   uploadCallback,
 	}
 	// We accept PUT because the client is specifying the resource identifier via
 	// the HTTP path. (As opposed to POST, where typically the clients would post
@@ -105,6 +152,9 @@ func NewHandler(
 
 	handler.HandleFunc(progressByNode, handler.progressHandler).Methods("POST")
 	handler.HandleFunc(progressGlobal, handler.progressHandler).Methods("POST")
+
+	handler.HandleFunc(uploadsByNode, handler.uploadHandler).Methods("POST", "PATCH")
+	handler.HandleFunc(uploadsGlobal, handler.uploadHandler).Methods("POST", "PATCH")
 	return handler
 }
 
@@ -115,6 +165,9 @@ func resultFromRequest(r *http.Request, muxVars map[string]string) *plugin.Resul
 		Body:       r.Body,
 		MimeType:   r.Header.Get("content-type"),
 		Filename:   filenameFromHeader(r.Header.Get("content-disposition")),
+
+		Digest:      r.Header.Get(ResultDigestHeader),
+		FileDigests: fileDigestsFromHeader(r.Header),
 	}
 
 	if result.NodeName == "" {
@@ -149,6 +202,47 @@ func (h *Handler) resultsHandler(w http.ResponseWriter, r *http.Request) {
 	r.Body.Close()
 }
 
+// uploadFromRequest builds the Upload described by the request. Requests to start an upload
+// are POSTed and give the length and digest of the result; chunks are sent with PATCH along
+// with their offset.
+func uploadFromRequest(r *http.Request, muxVars map[string]string) (*Upload, error) {
+	upload := &Upload{
+		ResultType: muxVars["plugin"],
+		NodeName:   muxVars["node"],
+		MimeType:   r.Header.Get("content-type"),
+		Filename:   filenameFromHeader(r.Header.Get("content-disposition")),
+	}
+	if upload.NodeName == "" {
+		upload.NodeName = plugin.GlobalResult
+	}
+
+	var err error
+	if r.Method == http.MethodPost {
+		upload.Digest = r.Header.Get(UploadDigestHeader)
+		upload.FileDigests = fileDigestsFromHeader(r.Header)
+		upload.Length, err = strconv.ParseInt(r.Header.Get(UploadLengthHeader), 10, 64)
+		return upload, errors.Wrapf(err, "invalid %v header", UploadLengthHeader)
+	}
+
+	upload.Body = r.Body
+	upload.Offset, err = strconv.ParseInt(r.Header.Get(UploadOffsetHeader), 10, 64)
+	return upload, errors.Wrapf(err, "invalid %v header", UploadOffsetHeader)
+}
+
+func (h *Handler) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	vars := mux.Vars(r)
+	upload, err := uploadFromRequest(r, vars)
+	if err != nil {
+		logrus.Errorf("Failed to get upload from request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.UploadCallback(upload, w)
+	r.Body.Close()
+}
+
 func (h *Handler) progressHandler(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
 	vars := mux.Vars(r)
@@ -201,6 +295,39 @@ func GlobalResultURL(baseURL, pluginName string) (string, error) {
 	return path.String(), nil
 }
 
+// NodeUploadURL is the URL for chunked uploads of a given node result. Takes the baseURL
+// (http[s]://hostname:port/, with trailing slash) nodeName and pluginName.
+func NodeUploadURL(baseURL, nodeName, pluginName string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't get node upload URL")
+	}
+	path, err := nodeUploadRoute.URLPath("node", nodeName, "plugin", pluginName)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't get node upload URL")
+	}
+	path.Scheme = base.Scheme
+	path.Host = base.Host
+	return path.String(), nil
+}
+
+// GlobalUploadURL is the URL for chunked uploads of results that are not node-specific. Takes
+// the baseURL (http[s]://hostname:port/, with trailing slash) and pluginName.
+func GlobalUploadURL(baseURL, pluginName string) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't get global upload URL")
+	}
+	path, err := globalUploadRoute.URLPath("plugin", pluginName)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't get global upload URL")
+	}
+	path.Scheme = base.Scheme
+	// Host includes port
+	path.Host = base.Host
+	return path.String(), nil
+}
+
 func logRequest(req *http.Request) {
 	vars := mux.Vars(req)
 	log := logrus.WithField("plugin_name", vars["plugin"])
@@ -213,6 +340,27 @@ func logRequest(req *http.Request) {
 	log.Info("received aggregator request")
 }
 
+// fileDigestsFromHeader gets the digests of the files inside a tarball result from the
+// ResultFileDigestHeader values, which are of the form `<digest> <path>`. Malformed values
+// are ignored.
+func fileDigestsFromHeader(h http.Header) map[string]string {
+	values := h.Values(ResultFileDigestHeader)
+	if len(values) == 0 {
+		return nil
+	}
+
+	digests := make(map[string]string, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(strings.TrimSpace(v), " ", 2)
+		if len(parts) != 2 || len(parts[1]) == 0 {
+			logrus.Warningf("Ignoring malformed %v header %q", ResultFileDigestHeader, v)
+			continue
+		}
+		digests[path.Clean(parts[1])] = parts[0]
+	}
+	return digests
+}
+
 // filenameFromHeader gets the filename from a content-disposition of the form:
 // Content-Disposition: attachment; filename=foo.txt
 // If there is an error parsing the string, the empty string is returned.
@@ -52,6 +52,8 @@ func TestStart(t *testing.T) {
 		checkins[checkin.Path()] = checkin
 	}, func(status plugin.ProgressUpdate, w http.ResponseWriter) {
 		return
+	}, func(upload *Upload, w http.ResponseWriter) {
+		return
 	})
 
 	srv := authtest.NewTLSServer(h, t)
@@ -0,0 +1,320 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MetricsPath is the path the metrics are served on.
+	MetricsPath = "/metrics"
+
+	// metricsContentType is the content type of the Prometheus text format.
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// The phases of a run, as reported by the sonobuoy_run_phase metric.
+const (
+	// PhasePlugins is the phase in which the plugins are run and their results gathered.
+	PhasePlugins = "plugins"
+	// PhaseQueries is the phase in which the cluster resources and pod logs are queried.
+	PhaseQueries = "queries"
+	// PhasePostProcessing is the phase in which the plugin results are processed and the
+	// tarball is created.
+	PhasePostProcessing = PostProcessingStatus
+	// PhaseComplete is the phase once the results are available.
+	PhaseComplete = CompleteStatus
+)
+
+// phases are all the phases of a run, in order.
+var phases = []string{PhasePlugins, PhaseQueries, PhasePostProcessing, PhaseComplete}
+
+// Metrics exposes the state of a run, in the Prometheus text format, so that long runs can be
+// watched from monitoring systems. The results and progress of each plugin are read from the
+// aggregator when the metrics are scraped. A nil *Metrics records nothing.
+type Metrics struct {
+	mu sync.Mutex
+
+	phase       string
+	aggr        *Aggregator
+	resultBytes map[string]int64
+	queries     map[string]*queryMetrics
+}
+
+// queryMetrics are the totals of the queries for a kind of resource, across namespaces.
+type queryMetrics struct {
+	count    int
+	errors   int
+	duration time.Duration
+}
+
+// NewMetrics returns metrics for a run which hasn't started any plugins yet.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		resultBytes: map[string]int64{},
+		queries:     map[string]*queryMetrics{},
+	}
+}
+
+// SetPhase records the phase the run is in.
+func (m *Metrics) SetPhase(phase string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.phase = phase
+}
+
+// ObserveQuery records a query of the cluster, as recorded in the query times of the results.
+func (m *Metrics) ObserveQuery(name string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.queries[name]
+	if q == nil {
+		q = &queryMetrics{}
+		m.queries[name] = q
+	}
+	q.count++
+	q.duration += duration
+	if err != nil {
+		q.errors++
+	}
+}
+
+// setAggregator makes the metrics report the results and progress updates of the aggregator.
+func (m *Metrics) setAggregator(a *Aggregator) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aggr = a
+	a.metrics = m
+}
+
+// addResultBytes records the size of a result received from a plugin.
+func (m *Metrics) addResultBytes(plugin string, n int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resultBytes[plugin] += n
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(buf.Bytes())
+}
+
+// NewMonitoringServer returns a plain HTTP server for the metrics and the stream of events. Unlike
+// the aggregation server it doesn't require client certificates so that the metrics can be scraped
+// like any others and the events can be watched through the API server's pod proxy.
+func NewMonitoringServer(address string, port int, m *Metrics, e *Events) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, m)
+	mux.Handle(EventsPath, e)
+	return &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", address, port),
+		Handler: mux,
+	}
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// metricSample is a single value of a metric.
+type metricSample struct {
+	suffix string
+	labels []string
+	value  float64
+}
+
+// metricFamily is a metric along with all of its values.
+type metricFamily struct {
+	name, typ, help string
+	samples         []metricSample
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var families []metricFamily
+	add := func(name, typ, help string, samples []metricSample) {
+		families = append(families, metricFamily{name: name, typ: typ, help: help, samples: samples})
+	}
+
+	// The state of the aggregator is read before locking the metrics since the aggregator records
+	// the size of results with its own lock held.
+	m.mu.Lock()
+	aggr := m.aggr
+	m.mu.Unlock()
+	expected, received, failed := map[string]int{}, map[string]int{}, map[string]int{}
+	var progress []*progressSnapshot
+	if aggr != nil {
+		aggr.resultsMutex.Lock()
+		// Every expected plugin is reported, even before it has sent any results.
+		for _, e := range aggr.ExpectedResults {
+			expected[e.ResultType]++
+			received[e.ResultType], failed[e.ResultType] = 0, 0
+		}
+		for _, r := range aggr.Results {
+			received[r.ResultType]++
+			if !r.IsSuccess() {
+				failed[r.ResultType]++
+			}
+		}
+		aggr.resultsMutex.Unlock()
+
+		aggr.progressMutex.Lock()
+		for _, p := range aggr.LatestProgressUpdates {
+			progress = append(progress, &progressSnapshot{
+				plugin:    p.PluginName,
+				node:      p.Node,
+				completed: p.Completed,
+				total:     p.Total,
+				failures:  len(p.Failures),
+				errors:    len(p.Errors),
+			})
+		}
+		aggr.progressMutex.Unlock()
+	}
+
+	m.mu.Lock()
+	var phaseSamples []metricSample
+	for _, p := range phases {
+		v := 0.0
+		if p == m.phase {
+			v = 1
+		}
+		phaseSamples = append(phaseSamples, metricSample{labels: []string{"phase", p}, value: v})
+	}
+	add("sonobuoy_run_phase", "gauge", "Whether the run is in the given phase.", phaseSamples)
+
+	add("sonobuoy_plugin_results_expected", "gauge", "Number of results expected from the plugin, one per node it runs on or one for the whole cluster.", pluginSamples(expected))
+	add("sonobuoy_plugin_results_received", "gauge", "Number of results received from the plugin, including failed ones.", pluginSamples(received))
+	add("sonobuoy_plugin_results_failed", "gauge", "Number of results received from the plugin which report that it failed or timed out.", pluginSamples(failed))
+	bytesReceived := map[string]int{}
+	for p, n := range m.resultBytes {
+		bytesReceived[p] = int(n)
+	}
+	add("sonobuoy_plugin_result_bytes_received_total", "counter", "Size of the results received from the plugin in bytes.", pluginSamples(bytesReceived))
+
+	sort.Slice(progress, func(i, j int) bool {
+		if progress[i].plugin != progress[j].plugin {
+			return progress[i].plugin < progress[j].plugin
+		}
+		return progress[i].node < progress[j].node
+	})
+	progressSamples := func(value func(*progressSnapshot) float64) []metricSample {
+		var samples []metricSample
+		for _, p := range progress {
+			samples = append(samples, metricSample{labels: []string{"plugin", p.plugin, "node", p.node}, value: value(p)})
+		}
+		return samples
+	}
+	add("sonobuoy_plugin_progress_completed", "gauge", "Number of tests the plugin last reported as completed.",
+		progressSamples(func(p *progressSnapshot) float64 { return float64(p.completed) }))
+	add("sonobuoy_plugin_progress_expected", "gauge", "Number of tests the plugin last reported it will run.",
+		progressSamples(func(p *progressSnapshot) float64 { return float64(p.total) }))
+	add("sonobuoy_plugin_progress_failures", "gauge", "Number of failed tests the plugin last reported.",
+		progressSamples(func(p *progressSnapshot) float64 { return float64(p.failures) }))
+	add("sonobuoy_plugin_progress_errors", "gauge", "Number of errors the plugin last reported.",
+		progressSamples(func(p *progressSnapshot) float64 { return float64(p.errors) }))
+
+	names := make([]string, 0, len(m.queries))
+	for name := range m.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var durationSamples, errorSamples []metricSample
+	for _, name := range names {
+		q := m.queries[name]
+		labels := []string{"query", name}
+		durationSamples = append(durationSamples,
+			metricSample{suffix: "_sum", labels: labels, value: q.duration.Seconds()},
+			metricSample{suffix: "_count", labels: labels, value: float64(q.count)},
+		)
+		errorSamples = append(errorSamples, metricSample{labels: labels, value: float64(q.errors)})
+	}
+	add("sonobuoy_query_duration_seconds", "summary", "Time taken querying the cluster, by the kind of resource or data queried.", durationSamples)
+	add("sonobuoy_query_errors_total", "counter", "Number of queries of the cluster which failed.", errorSamples)
+	m.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, f := range families {
+		writeMetricFamily(&buf, f)
+	}
+	return buf.WriteTo(w)
+}
+
+// progressSnapshot is the latest progress update of a plugin on a node.
+type progressSnapshot struct {
+	plugin, node     string
+	completed, total int64
+	failures, errors int
+}
+
+// pluginSamples returns a sample per plugin, sorted by plugin.
+func pluginSamples(values map[string]int) []metricSample {
+	plugins := make([]string, 0, len(values))
+	for p := range values {
+		plugins = append(plugins, p)
+	}
+	sort.Strings(plugins)
+
+	samples := make([]metricSample, 0, len(plugins))
+	for _, p := range plugins {
+		samples = append(samples, metricSample{labels: []string{"plugin", p}, value: float64(values[p])})
+	}
+	return samples
+}
+
+func writeMetricFamily(w io.Writer, f metricFamily) {
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.typ)
+	for _, s := range f.samples {
+		var labels []string
+		for i := 0; i+1 < len(s.labels); i += 2 {
+			labels = append(labels, fmt.Sprintf(`%v="%v"`, s.labels[i], labelValueEscaper.Replace(s.labels[i+1])))
+		}
+		fmt.Fprintf(w, "%v%v{%v} %v\n", f.name, s.suffix, strings.Join(labels, ","), strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+// labelValueEscaper escapes the characters which can't appear as they are in label values.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
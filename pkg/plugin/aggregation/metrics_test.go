@@ -0,0 +1,128 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+func TestMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_metrics_test")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	aggr := NewAggregator(dir, []plugin.ExpectedResult{
+		{ResultType: "e2e", NodeName: "global"},
+		{ResultType: "systemd-logs", NodeName: "node1"},
+		{ResultType: "systemd-logs", NodeName: "node2"},
+	})
+	m := NewMetrics()
+	m.setAggregator(aggr)
+	m.SetPhase(PhaseQueries)
+
+	if err := aggr.processResult(&plugin.Result{ResultType: "systemd-logs", NodeName: "node1", Body: strings.NewReader("logs")}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+	if err := aggr.processResult(&plugin.Result{ResultType: "systemd-logs", NodeName: "node2", Body: strings.NewReader("{}"), Error: "oops"}); err != nil {
+		t.Fatalf("Unexpected error processing result: %v", err)
+	}
+	if err := aggr.processProgressUpdate(plugin.ProgressUpdate{PluginName: "e2e", Node: "global", Total: 10, Completed: 4, Failures: []string{"a \"quoted\" test"}}); err != nil {
+		t.Fatalf("Unexpected error processing progress update: %v", err)
+	}
+	m.ObserveQuery("pods", 1500*time.Millisecond, nil)
+	m.ObserveQuery("pods", 500*time.Millisecond, errors.New("forbidden"))
+
+	rec := httptest.NewRecorder()
+	NewMonitoringServer("", 0, m, NewEvents()).Handler.ServeHTTP(rec, httptest.NewRequest("GET", MetricsPath, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != metricsContentType {
+		t.Errorf("Expected content type %q but got %q", metricsContentType, ct)
+	}
+
+	expected := `# HELP sonobuoy_run_phase Whether the run is in the given phase.
+# TYPE sonobuoy_run_phase gauge
+sonobuoy_run_phase{phase="plugins"} 0
+sonobuoy_run_phase{phase="queries"} 1
+sonobuoy_run_phase{phase="post-processing"} 0
+sonobuoy_run_phase{phase="complete"} 0
+# HELP sonobuoy_plugin_results_expected Number of results expected from the plugin, one per node it runs on or one for the whole cluster.
+# TYPE sonobuoy_plugin_results_expected gauge
+sonobuoy_plugin_results_expected{plugin="e2e"} 1
+sonobuoy_plugin_results_expected{plugin="systemd-logs"} 2
+# HELP sonobuoy_plugin_results_received Number of results received from the plugin, including failed ones.
+# TYPE sonobuoy_plugin_results_received gauge
+sonobuoy_plugin_results_received{plugin="e2e"} 0
+sonobuoy_plugin_results_received{plugin="systemd-logs"} 2
+# HELP sonobuoy_plugin_results_failed Number of results received from the plugin which report that it failed or timed out.
+# TYPE sonobuoy_plugin_results_failed gauge
+sonobuoy_plugin_results_failed{plugin="e2e"} 0
+sonobuoy_plugin_results_failed{plugin="systemd-logs"} 1
+# HELP sonobuoy_plugin_result_bytes_received_total Size of the results received from the plugin in bytes.
+# TYPE sonobuoy_plugin_result_bytes_received_total counter
+sonobuoy_plugin_result_bytes_received_total{plugin="systemd-logs"} 6
+# HELP sonobuoy_plugin_progress_completed Number of tests the plugin last reported as completed.
+# TYPE sonobuoy_plugin_progress_completed gauge
+sonobuoy_plugin_progress_completed{plugin="e2e",node="global"} 4
+# HELP sonobuoy_plugin_progress_expected Number of tests the plugin last reported it will run.
+# TYPE sonobuoy_plugin_progress_expected gauge
+sonobuoy_plugin_progress_expected{plugin="e2e",node="global"} 10
+# HELP sonobuoy_plugin_progress_failures Number of failed tests the plugin last reported.
+# TYPE sonobuoy_plugin_progress_failures gauge
+sonobuoy_plugin_progress_failures{plugin="e2e",node="global"} 1
+# HELP sonobuoy_plugin_progress_errors Number of errors the plugin last reported.
+# TYPE sonobuoy_plugin_progress_errors gauge
+sonobuoy_plugin_progress_errors{plugin="e2e",node="global"} 0
+# HELP sonobuoy_query_duration_seconds Time taken querying the cluster, by the kind of resource or data queried.
+# TYPE sonobuoy_query_duration_seconds summary
+sonobuoy_query_duration_seconds_sum{query="pods"} 2
+sonobuoy_query_duration_seconds_count{query="pods"} 2
+# HELP sonobuoy_query_errors_total Number of queries of the cluster which failed.
+# TYPE sonobuoy_query_errors_total counter
+sonobuoy_query_errors_total{query="pods"} 1
+`
+	if rec.Body.String() != expected {
+		t.Errorf("Expected metrics:\n%v\nbut got:\n%v", expected, rec.Body.String())
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	var b strings.Builder
+	writeMetricFamily(&b, metricFamily{name: "m", typ: "gauge", help: "h", samples: []metricSample{
+		{labels: []string{"query", "a\\b \"c\"\nd"}, value: 1.5},
+	}})
+	expected := "# HELP m h\n# TYPE m gauge\nm{query=\"a\\\\b \\\"c\\\"\\nd\"} 1.5\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, b.String())
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.SetPhase(PhaseComplete)
+	m.ObserveQuery("pods", time.Second, nil)
+	m.addResultBytes("e2e", 1)
+	m.setAggregator(NewAggregator("", nil))
+}
@@ -0,0 +1,501 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/backplane/ca"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	"github.com/vmware-tanzu/sonobuoy/pkg/signature"
+)
+
+const (
+	// ResultsPath is the path the listing of the results is served on. Each file is served
+	// beneath it, by name.
+	ResultsPath = "/results"
+
+	// ResultsFilesPath is the path the listing of the individual result files of the plugins is
+	// served on while the run is in progress. Each file is served beneath it by its path within
+	// the results, e.g. plugins/e2e/results/global/e2e.log.
+	ResultsFilesPath = ResultsPath + "/files"
+
+	// PartialResultsPath is the path a tarball of the results received so far is served on while
+	// the run is in progress. The plugin query parameter limits it to the results of one plugin.
+	PartialResultsPath = "/partial"
+
+	// PartialResultsPluginParam is the query parameter naming the plugin to get partial results of.
+	PartialResultsPluginParam = "plugin"
+
+	// ResultsTokenHeader is the header clients give the results token in. The Authorization
+	// header can't be used since the API server doesn't pass it on through its proxy.
+	ResultsTokenHeader = "X-Sonobuoy-Results-Token"
+
+	// ResultsTokenKey is the key of the token in the results token secret.
+	ResultsTokenKey = "token"
+
+	// ResultsTokenSecretAnnotationName is the annotation of the aggregator pod naming the secret
+	// which holds its results token, so that clients don't have to work out the name of the secret
+	// from the labels of the pod.
+	ResultsTokenSecretAnnotationName = "sonobuoy.hept.io/results-token-secret"
+
+	// resultsTokenSecretName is the name of the secret holding the results token of runs without
+	// an ID. Runs with one suffix it with their ID.
+	resultsTokenSecretName = "sonobuoy-results-token"
+
+	// resultsTokenBytes is the number of random bytes in a results token.
+	resultsTokenBytes = 32
+
+	// tarballSuffix is the suffix of the results tarballs in the results directory.
+	tarballSuffix = ".tar.gz"
+
+	// resultsServerName is the name in the certificate of the results server. It isn't checked
+	// by the API server's proxy.
+	resultsServerName = "sonobuoy-aggregator"
+
+	// partialResultsDirPrefix prefixes the temporary directories partial results are built in. They
+	// are within the results directory, since it has room for the results, but aren't listed.
+	partialResultsDirPrefix = ".partial-"
+
+	// resultFilesDir is the directory of the results of a run which individual files are served
+	// from. Nothing else in the results directory of a run is served on its own.
+	resultFilesDir = "plugins"
+)
+
+var (
+	// ErrPartialResultsUnavailable is returned by a PartialResultsFunc once the run is being
+	// finalized, at which point the full results should be retrieved instead.
+	ErrPartialResultsUnavailable = errors.New("partial results are only available while plugins are running")
+
+	// ErrNoResultsToken is returned by GetResultsToken if the aggregator pod doesn't name the
+	// secret holding its results token, e.g. because it doesn't serve the results.
+	ErrNoResultsToken = errors.New("the aggregator pod doesn't name a results token secret")
+
+	// ErrUnknownPlugin is returned by a PartialResultsFunc if asked for a plugin which isn't part
+	// of the run.
+	ErrUnknownPlugin = errors.New("unknown plugin")
+)
+
+// PartialResultsFunc writes a tarball of the results received so far into dir, limited to the
+// results of the named plugin unless it is empty, and returns its path.
+type PartialResultsFunc func(dir, pluginName string) (string, error)
+
+// ResultsFile describes a file of the results served by the aggregator.
+type ResultsFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ResultsHandler serves the results tarball and its detached signature from the results directory
+// of the aggregator to clients which give the results token, along with the individual result
+// files of the plugins while the run is in progress. Files are served with support for ranges so
+// that interrupted downloads can be resumed. If it has a PartialResultsFunc, it also
+// serves partial results while the run is in progress, and if it has the events of the run, it
+// streams them too so that runs can be watched without serving metrics.
+type ResultsHandler struct {
+	dir     string
+	runDir  string
+	token   string
+	partial PartialResultsFunc
+	events  *Events
+}
+
+// NewResultsHandler returns a handler for the files in dir, and the result files of the plugins in
+// the run's results directory runDir, which requires the given token. The partial func and events
+// may be nil if partial results or events aren't served.
+func NewResultsHandler(dir, runDir, token string, partial PartialResultsFunc, events *Events) *ResultsHandler {
+	return &ResultsHandler{dir: dir, runDir: runDir, token: token, partial: partial, events: events}
+}
+
+// ServeHTTP serves the listing of the results on ResultsPath, each file beneath it, the listing of
+// the result files of the plugins on ResultsFilesPath, each of them beneath it, partial results on
+// PartialResultsPath and events on EventsPath.
+func (h *ResultsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(ResultsTokenHeader)), []byte(h.token)) != 1 {
+		http.Error(w, "missing or invalid results token", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case PartialResultsPath:
+		h.servePartial(w, r)
+		return
+	case EventsPath:
+		if h.events == nil {
+			http.NotFound(w, r)
+			return
+		}
+		h.events.ServeHTTP(w, r)
+		return
+	}
+
+	if r.URL.Path == ResultsFilesPath || strings.HasPrefix(r.URL.Path, ResultsFilesPath+"/") {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, ResultsFilesPath), "/")
+		if name == "" {
+			h.serveResultFilesListing(w)
+			return
+		}
+		h.serveResultFile(w, r, name)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, ResultsPath), "/")
+	if name == "" {
+		h.serveListing(w)
+		return
+	}
+	h.serveFile(w, r, name)
+}
+
+func (h *ResultsHandler) serveListing(w http.ResponseWriter) {
+	files, err := h.list()
+	if err != nil {
+		http.Error(w, "couldn't list results", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+func (h *ResultsHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	if name != filepath.Base(name) || !isResultsFile(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(h.dir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+func (h *ResultsHandler) serveResultFilesListing(w http.ResponseWriter) {
+	files, err := h.listResultFiles()
+	if err != nil {
+		http.Error(w, "couldn't list result files", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+func (h *ResultsHandler) serveResultFile(w http.ResponseWriter, r *http.Request, name string) {
+	if h.runDir == "" || !isResultFile(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Plugins can put symlinks into their results so the file has to resolve to somewhere within
+	// the results of the plugins, not just be named like it is.
+	root, err := filepath.EvalSymlinks(filepath.Join(h.runDir, resultFilesDir))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	filename, err := filepath.EvalSymlinks(filepath.Join(h.runDir, filepath.FromSlash(name)))
+	if err != nil || !strings.HasPrefix(filename, root+string(filepath.Separator)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, path.Base(name), info.ModTime(), f)
+}
+
+// servePartial builds a tarball of the results received so far and serves it, naming it in the
+// Content-Disposition header. The tarball is removed once served since it is only a snapshot.
+func (h *ResultsHandler) servePartial(w http.ResponseWriter, r *http.Request) {
+	if h.partial == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	dir, err := ioutil.TempDir(h.dir, partialResultsDirPrefix)
+	if err != nil {
+		http.Error(w, "couldn't create directory for partial results", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	tb, err := h.partial(dir, r.URL.Query().Get(PartialResultsPluginParam))
+	switch {
+	case errors.Cause(err) == ErrPartialResultsUnavailable:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Cause(err) == ErrUnknownPlugin:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		logrus.WithError(err).Error("Couldn't build partial results")
+		http.Error(w, "couldn't build partial results", http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(tb)
+	if err != nil {
+		http.Error(w, "couldn't open partial results", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "couldn't open partial results", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(tb)}))
+	http.ServeContent(w, r, filepath.Base(tb), info.ModTime(), f)
+}
+
+// list returns the results files in the results directory, by name.
+func (h *ResultsHandler) list() ([]ResultsFile, error) {
+	infos, err := ioutil.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []ResultsFile{}
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || !isResultsFile(info.Name()) {
+			continue
+		}
+		files = append(files, ResultsFile{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// listResultFiles returns the result files of the plugins in the run's results directory, by
+// their slash separated path within it. There are none once the run is complete since the
+// directory is removed after the tarball is written.
+func (h *ResultsHandler) listResultFiles() ([]ResultsFile, error) {
+	files := []ResultsFile{}
+	if h.runDir == "" {
+		return files, nil
+	}
+
+	err := filepath.Walk(filepath.Join(h.runDir, resultFilesDir), func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(h.runDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !isResultFile(name) {
+			return nil
+		}
+		files = append(files, ResultsFile{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// isResultFile returns whether the slash separated path within the results directory of a run is
+// served on its own. Only files in the results of the plugins are; hidden files, which are
+// still being written, and paths which don't stay within the results aren't.
+func isResultFile(name string) bool {
+	if name != path.Clean(name) || !strings.HasPrefix(name, resultFilesDir+"/") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// isResultsFile returns whether the file of the results directory is served. Only tarballs and
+// their signatures are; other files there, like the checkpoint of a resumable run, hold secrets.
+func isResultsFile(name string) bool {
+	return strings.HasSuffix(name, tarballSuffix) || strings.HasSuffix(name, tarballSuffix+signature.FileSuffix)
+}
+
+// NewResultsServer returns an HTTPS server for the results in dir, the result files of the plugins
+// in the run's results directory runDir, and the events of the run. Its
+// certificate is signed by a new authority since clients reach it through the API server's pod
+// proxy, which doesn't verify the certificates of pods; clients are instead authenticated by the
+// token.
+func NewResultsServer(address string, port int, dir, runDir, token string, partial PartialResultsFunc, events *Events) (*http.Server, error) {
+	auth, err := ca.NewAuthority()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't make certificate authority for results server")
+	}
+	cert, err := auth.ServerKeyPair(resultsServerName)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	h := NewResultsHandler(dir, runDir, token, partial, events)
+	mux.Handle(ResultsPath, h)
+	mux.Handle(ResultsPath+"/", h)
+	mux.Handle(PartialResultsPath, h)
+	mux.Handle(EventsPath, h)
+	return &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", address, port),
+		Handler:   mux,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{*cert}},
+	}, nil
+}
+
+// NewResultsToken returns a new random token for the results server.
+func NewResultsToken() (string, error) {
+	b := make([]byte, resultsTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "couldn't generate results token")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ResultsTokenSecretName returns the name of the secret holding the results token of the run.
+func ResultsTokenSecretName(runID string) string {
+	if runID == "" {
+		return resultsTokenSecretName
+	}
+	return resultsTokenSecretName + "-" + runID
+}
+
+// SetResultsToken saves the results token of the run in its secret so that only those allowed to
+// read secrets in the namespace can retrieve the results. The secret is owned by the aggregator
+// pod so that it is deleted along with it, and its name is recorded in the annotations of the pod.
+func SetResultsToken(client kubernetes.Interface, namespace, runID, token string) error {
+	pod, err := GetAggregatorPod(client, namespace, runID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get aggregator pod")
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ResultsTokenSecretName(runID),
+			Namespace: namespace,
+			Labels:    map[string]string{"component": "sonobuoy"},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod.Name,
+				UID:        pod.UID,
+			}},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{ResultsTokenKey: []byte(token)},
+	}
+	if runID != "" {
+		secret.Labels[plugin.RunIDLabel] = runID
+	}
+
+	secrets := client.CoreV1().Secrets(namespace)
+	_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+	if kubeerror.IsAlreadyExists(err) {
+		// A restarted aggregator replaces the token of the previous one.
+		_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrap(err, "couldn't save results token")
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				ResultsTokenSecretAnnotationName: secret.Name,
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode patch")
+	}
+	_, err = client.CoreV1().Pods(namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return errors.Wrap(err, "couldn't annotate aggregator pod with the results token secret")
+}
+
+// GetResultsToken returns the results token from the secret named in the annotations of the
+// aggregator pod. ErrNoResultsToken is returned if the pod doesn't name one.
+func GetResultsToken(client kubernetes.Interface, pod *corev1.Pod) (string, error) {
+	name := pod.Annotations[ResultsTokenSecretAnnotationName]
+	if name == "" {
+		return "", ErrNoResultsToken
+	}
+	secret, err := client.CoreV1().Secrets(pod.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	token := string(secret.Data[ResultsTokenKey])
+	if token == "" {
+		return "", errors.Errorf("secret %v has no results token", secret.Name)
+	}
+	return token, nil
+}
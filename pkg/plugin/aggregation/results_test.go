@@ -0,0 +1,424 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResultsHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_results_test")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"202101010000_sonobuoy_abc.tar.gz":     "0123456789",
+		"202101010000_sonobuoy_abc.tar.gz.sig": "signature",
+		"abc.checkpoint.json":                  "secret",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %v: %v", name, err)
+		}
+	}
+	runDir := filepath.Join(dir, "abc")
+	for name, content := range map[string]string{
+		"meta/config.json":                       "{}",
+		"plugins/e2e/sonobuoy_results.yaml":      "name: e2e",
+		"plugins/e2e/results/global/e2e.log":     "0123456789",
+		"plugins/e2e/results/global/.upload.tmp": "partial",
+	} {
+		file := filepath.Join(runDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Could not create directory for %v: %v", name, err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %v: %v", name, err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "abc.checkpoint.json"), filepath.Join(runDir, "plugins/e2e/results/global/escape")); err != nil {
+		t.Fatalf("Could not create symlink: %v", err)
+	}
+
+	events := NewEvents()
+	events.Publish(Event{Type: EventTarballReady, Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)})
+	h := NewResultsHandler(dir, runDir, "token", nil, events)
+
+	testCases := []struct {
+		desc         string
+		method       string
+		path         string
+		token        string
+		rangeHeader  string
+		expectStatus int
+		expectBody   string
+		expectNames  []string
+	}{
+		{
+			desc:         "Listing only has tarballs and signatures",
+			path:         "/results",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectNames:  []string{"202101010000_sonobuoy_abc.tar.gz", "202101010000_sonobuoy_abc.tar.gz.sig"},
+		}, {
+			desc:         "Listing with trailing slash",
+			path:         "/results/",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectNames:  []string{"202101010000_sonobuoy_abc.tar.gz", "202101010000_sonobuoy_abc.tar.gz.sig"},
+		}, {
+			desc:         "Missing token",
+			path:         "/results",
+			expectStatus: http.StatusUnauthorized,
+		}, {
+			desc:         "Wrong token",
+			path:         "/results/202101010000_sonobuoy_abc.tar.gz",
+			token:        "wrong",
+			expectStatus: http.StatusUnauthorized,
+		}, {
+			desc:         "Whole file",
+			path:         "/results/202101010000_sonobuoy_abc.tar.gz",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectBody:   "0123456789",
+		}, {
+			desc:         "Range of file",
+			path:         "/results/202101010000_sonobuoy_abc.tar.gz",
+			token:        "token",
+			rangeHeader:  "bytes=4-",
+			expectStatus: http.StatusPartialContent,
+			expectBody:   "456789",
+		}, {
+			desc:         "Signature",
+			path:         "/results/202101010000_sonobuoy_abc.tar.gz.sig",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectBody:   "signature",
+		}, {
+			desc:         "Other files aren't served",
+			path:         "/results/abc.checkpoint.json",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Files outside the directory aren't served",
+			path:         "/results/abc/../202101010000_sonobuoy_abc.tar.gz",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Missing file",
+			path:         "/results/missing.tar.gz",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Listing of result files only has the results of plugins",
+			path:         "/results/files",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectNames:  []string{"plugins/e2e/results/global/e2e.log", "plugins/e2e/sonobuoy_results.yaml"},
+		}, {
+			desc:         "Listing of result files requires the token",
+			path:         "/results/files",
+			expectStatus: http.StatusUnauthorized,
+		}, {
+			desc:         "Result file",
+			path:         "/results/files/plugins/e2e/results/global/e2e.log",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectBody:   "0123456789",
+		}, {
+			desc:         "Range of result file",
+			path:         "/results/files/plugins/e2e/results/global/e2e.log",
+			token:        "token",
+			rangeHeader:  "bytes=4-",
+			expectStatus: http.StatusPartialContent,
+			expectBody:   "456789",
+		}, {
+			desc:         "Files outside of the results of plugins aren't served",
+			path:         "/results/files/meta/config.json",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Hidden result files aren't served",
+			path:         "/results/files/plugins/e2e/results/global/.upload.tmp",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Result files can't traverse out of the results",
+			path:         "/results/files/plugins/../../abc.checkpoint.json",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Result files can't link out of the results",
+			path:         "/results/files/plugins/e2e/results/global/escape",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Events of the run",
+			path:         "/events",
+			token:        "token",
+			expectStatus: http.StatusOK,
+			expectBody:   "id: 1\nevent: tarball-ready\ndata: {\"id\":1,\"type\":\"tarball-ready\",\"time\":\"2021-01-01T00:00:00Z\"}\n\n",
+		}, {
+			desc:         "Events require the token",
+			path:         "/events",
+			expectStatus: http.StatusUnauthorized,
+		}, {
+			desc:         "Only reads are allowed",
+			method:       http.MethodDelete,
+			path:         "/results/202101010000_sonobuoy_abc.tar.gz",
+			token:        "token",
+			expectStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set(ResultsTokenHeader, tc.token)
+			}
+			if tc.rangeHeader != "" {
+				req.Header.Set("Range", tc.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.expectStatus {
+				t.Fatalf("Expected status %v, got %v: %v", tc.expectStatus, w.Code, w.Body.String())
+			}
+			if tc.expectBody != "" && w.Body.String() != tc.expectBody {
+				t.Errorf("Expected body %q, got %q", tc.expectBody, w.Body.String())
+			}
+			if tc.expectNames != nil {
+				listing := []ResultsFile{}
+				if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+					t.Fatalf("Could not decode listing: %v", err)
+				}
+				names := []string{}
+				for _, f := range listing {
+					names = append(names, f.Name)
+				}
+				if len(names) != len(tc.expectNames) {
+					t.Fatalf("Expected files %v, got %v", tc.expectNames, names)
+				}
+				for i := range names {
+					if names[i] != tc.expectNames[i] {
+						t.Errorf("Expected files %v, got %v", tc.expectNames, names)
+					}
+				}
+			}
+		})
+	}
+
+	// Once the tarball is written the results directory of the run is removed.
+	if err := os.RemoveAll(runDir); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, ResultsFilesPath, nil)
+	req.Header.Set(ResultsTokenHeader, "token")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty listing of result files once they are removed, got %v: %q", w.Code, w.Body.String())
+	}
+}
+
+func TestResultsHandlerPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy_results_test")
+	if err != nil {
+		t.Fatalf("Could not create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	built := ""
+	partial := func(tmp, pluginName string) (string, error) {
+		built = tmp
+		switch pluginName {
+		case "missing":
+			return "", errors.Wrap(ErrUnknownPlugin, "plugin \"missing\" isn't part of the run")
+		case "late":
+			return "", ErrPartialResultsUnavailable
+		case "broken":
+			return "", errors.New("couldn't copy results")
+		}
+		tb := filepath.Join(tmp, "202101010000_sonobuoy_abc_partial.tar.gz")
+		return tb, ioutil.WriteFile(tb, []byte("partial "+pluginName), 0644)
+	}
+	h := NewResultsHandler(dir, "", "token", partial, nil)
+
+	testCases := []struct {
+		desc              string
+		path              string
+		token             string
+		expectStatus      int
+		expectBody        string
+		expectDisposition string
+	}{
+		{
+			desc:         "Requires the token",
+			path:         "/partial",
+			expectStatus: http.StatusUnauthorized,
+		}, {
+			desc:              "All plugins",
+			path:              "/partial",
+			token:             "token",
+			expectStatus:      http.StatusOK,
+			expectBody:        "partial ",
+			expectDisposition: `attachment; filename=202101010000_sonobuoy_abc_partial.tar.gz`,
+		}, {
+			desc:              "Single plugin",
+			path:              "/partial?plugin=e2e",
+			token:             "token",
+			expectStatus:      http.StatusOK,
+			expectBody:        "partial e2e",
+			expectDisposition: `attachment; filename=202101010000_sonobuoy_abc_partial.tar.gz`,
+		}, {
+			desc:         "Unknown plugin",
+			path:         "/partial?plugin=missing",
+			token:        "token",
+			expectStatus: http.StatusNotFound,
+		}, {
+			desc:         "Run being finalized",
+			path:         "/partial?plugin=late",
+			token:        "token",
+			expectStatus: http.StatusConflict,
+		}, {
+			desc:         "Failure building results",
+			path:         "/partial?plugin=broken",
+			token:        "token",
+			expectStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			built = ""
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set(ResultsTokenHeader, tc.token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.expectStatus {
+				t.Fatalf("Expected status %v, got %v: %v", tc.expectStatus, w.Code, w.Body.String())
+			}
+			if tc.expectBody != "" && w.Body.String() != tc.expectBody {
+				t.Errorf("Expected body %q, got %q", tc.expectBody, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tc.expectDisposition {
+				t.Errorf("Expected Content-Disposition %q, got %q", tc.expectDisposition, got)
+			}
+			if built != "" {
+				if filepath.Dir(built) != dir {
+					t.Errorf("Expected partial results to be built in the results directory, got %v", built)
+				}
+				if _, err := os.Stat(built); !os.IsNotExist(err) {
+					t.Errorf("Expected %v to be removed once served, got stat error %v", built, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResultsToken(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		labels       map[string]string
+		runID        string
+		expectSecret string
+	}{
+		{
+			desc:         "Secret is named after the run",
+			labels:       map[string]string{"sonobuoy-component": "aggregator", "sonobuoy-run-id": "abc"},
+			runID:        "abc",
+			expectSecret: "sonobuoy-results-token-abc",
+		}, {
+			desc:         "Pod without a run ID label",
+			labels:       map[string]string{"sonobuoy-component": "aggregator"},
+			expectSecret: "sonobuoy-results-token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "sonobuoy-abc",
+					Namespace: "sonobuoy",
+					UID:       "pod-uid",
+					Labels:    tc.labels,
+				},
+			}
+			client := fake.NewSimpleClientset(pod)
+
+			if _, err := GetResultsToken(client, pod); errors.Cause(err) != ErrNoResultsToken {
+				t.Errorf("Expected ErrNoResultsToken before the token is set, got %v", err)
+			}
+
+			for _, token := range []string{"first", "second"} {
+				if err := SetResultsToken(client, "sonobuoy", tc.runID, token); err != nil {
+					t.Fatalf("Unexpected error setting token %q: %v", token, err)
+				}
+				annotated, err := client.CoreV1().Pods("sonobuoy").Get(context.TODO(), pod.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Could not get the aggregator pod: %v", err)
+				}
+				if name := annotated.Annotations[ResultsTokenSecretAnnotationName]; name != tc.expectSecret {
+					t.Errorf("Expected the pod to name secret %q, got %q", tc.expectSecret, name)
+				}
+				got, err := GetResultsToken(client, annotated)
+				if err != nil {
+					t.Fatalf("Unexpected error getting token: %v", err)
+				}
+				if got != token {
+					t.Errorf("Expected token %q, got %q", token, got)
+				}
+			}
+
+			secret, err := client.CoreV1().Secrets("sonobuoy").Get(context.TODO(), tc.expectSecret, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Expected secret %v: %v", tc.expectSecret, err)
+			}
+			if secret.Labels["sonobuoy-run-id"] != tc.runID {
+				t.Errorf("Expected the secret to be labelled with run ID %q, got labels %v", tc.runID, secret.Labels)
+			}
+			if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != pod.UID {
+				t.Errorf("Expected the secret to be owned by the aggregator pod, got %v", secret.OwnerReferences)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/pkg/errors"
@@ -63,11 +64,19 @@ func (t *timeoutErr) Error() string { return t.e.Error() }
 // 2. Launch the HTTP server with the aggr's HandleHTTPResult function as the
 //    callback
 // 3. Run all the aggregation plugins, monitoring each one in a goroutine,
-//    configuring them to send failure results through a shared channel
+//    configuring them to send failure results through a shared channel.
+//    Plugins which depend on others are held back until their dependencies
+//    have reported results and are skipped if any of those failed.
 // 4. Hook the shared monitoring channel up to aggr's IngestResults() function
 // 5. Block until aggr shows all results accounted for (results come in through
 //    the HTTP callback), stopping the HTTP server on completion
-func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.AggregationConfig, progressPort, namespace, outdir string) error {
+//
+// The runID, if set, is used to find the aggregator pod of the run and to label the resources of
+// its plugins.
+// If metrics are given, they report the results and progress updates received by the aggregator.
+// If events are given, they are sent the plugins started and the results and progress updates
+// received.
+func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.AggregationConfig, progressPort, namespace, runID, outdir string, metrics *Metrics, events *Events) error {
 	// Construct a list of things we'll need to dispatch
 	if len(plugins) == 0 {
 		logrus.Info("Skipping host data gathering: no plugins defined")
@@ -83,21 +92,73 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 		return errors.WithStack(err)
 	}
 
-	// Find out what results we should expect for each of the plugins
+	// Ensure the plugins can actually be run in some order before starting any of them.
+	if err := plugin.ValidateDependencies(plugin.PluginDependencies(plugins)); err != nil {
+		return errors.Wrap(err, "invalid plugin dependencies")
+	}
+
+	if runID != "" {
+		for _, p := range plugins {
+			if r, ok := p.(runPlugin); ok {
+				r.SetRunID(runID)
+			}
+		}
+	}
+
+	// Find out what results we should expect for each of the plugins and how long to wait for them.
 	var expectedResults []plugin.ExpectedResult
+	timeouts := map[string]time.Duration{}
 	for _, p := range plugins {
 		expectedResults = append(expectedResults, p.ExpectedResults(nodes.Items)...)
+		timeouts[p.GetName()] = pluginTimeout(p, time.Duration(cfg.TimeoutSeconds)*time.Second)
 	}
 
-	auth, err := ca.NewAuthority()
-	if err != nil {
-		return errors.Wrap(err, "couldn't make new certificate authority for plugin aggregator")
+	// Resumable runs checkpoint their state, including the certificate authority the plugins
+	// trust, so that a restarted aggregator can pick up where the previous one left off.
+	var cp *checkpoint
+	if cfg.Resumable {
+		cp, err = loadCheckpoint(checkpointPath(outdir))
+		if err != nil {
+			return errors.Wrap(err, "couldn't load aggregator checkpoint")
+		}
+	}
+
+	var auth *ca.Authority
+	if cp != nil {
+		logrus.Info("Resuming run from checkpoint")
+		auth, err = ca.LoadAuthority(cp.CACert, cp.CAKey)
+		if err != nil {
+			return errors.Wrap(err, "couldn't load certificate authority from checkpoint")
+		}
+	} else {
+		auth, err = ca.NewAuthority()
+		if err != nil {
+			return errors.Wrap(err, "couldn't make new certificate authority for plugin aggregator")
+		}
 	}
 
 	logrus.Infof("Starting server Expected Results: %v", expectedResults)
 
 	// 1. Await results from each plugin
 	aggr := NewAggregator(outdir+"/plugins", expectedResults)
+	metrics.setAggregator(aggr)
+	aggr.events = events
+
+	// Chunked uploads are kept beside the results directory so that partial uploads never end
+	// up in the tarball but, in resumable mode, are still there after a restart.
+	aggr.UploadsDir = filepath.Clean(outdir) + ".uploads"
+	if cfg.Resumable {
+		caCert, caKey, err := auth.MarshalPEM()
+		if err != nil {
+			return errors.Wrap(err, "couldn't save certificate authority for checkpoints")
+		}
+		aggr.enableCheckpoints(checkpointPath(outdir), caCert, caKey)
+		if cp != nil {
+			aggr.restoreCheckpoint(cp)
+			restoreSessions(aggr, plugins)
+		}
+	}
+
 	doneAggr := make(chan bool, 1)
 	stopWaitCh := make(chan bool, 1)
 
@@ -120,7 +181,7 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 	// 2. Launch the aggregation servers
 	srv := &http.Server{
 		Addr:      fmt.Sprintf("%s:%d", cfg.BindAddress, cfg.BindPort),
-		Handler:   NewHandler(aggr.HandleHTTPResult, aggr.HandleHTTPProgressUpdate),
+		Handler:   NewHandler(aggr.HandleHTTPResult, aggr.HandleHTTPProgressUpdate, aggr.HandleHTTPUpload),
 		TLSConfig: tlsCfg,
 	}
 
@@ -137,7 +198,7 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 		srv.Close()
 	}()
 
-	updater := newUpdater(expectedResults, namespace, client)
+	updater := newUpdater(expectedResults, timeouts, namespace, runID, client)
 	ctxAnnotation, cancelAnnotation := context.WithCancel(context.TODO())
 	pluginsdone := false
 	defer func() {
@@ -158,6 +219,8 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 	go func() {
 		wait.JitterUntil(func() {
 			pluginsdone = aggr.isComplete()
+			// Progress updates aren't checkpointed as they arrive since they are so frequent.
+			aggr.saveCheckpoint()
 			if err := updater.Annotate(aggr.Results, aggr.LatestProgressUpdates); err != nil {
 				logrus.WithError(err).Info("couldn't annotate sonobuoy pod")
 			}
@@ -178,16 +241,33 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 		certs[p.GetName()] = cert
 	}
 
-	// Get a reference to the aggregator pod to set up owner references correctly for each started plugin
-	aggregatorPod, err := GetAggregatorPod(client, namespace)
-	if err != nil {
-		return errors.Wrapf(err, "couldn't get aggregator pod")
+	// Get a reference to the aggregator pod to set up owner references correctly for each started plugin.
+	// The plugins of a resumable run have to outlive the aggregator pod so they are left without an owner.
+	var aggregatorPod *corev1.Pod
+	if !cfg.Resumable {
+		aggregatorPod, err = GetAggregatorPod(client, namespace, runID)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't get aggregator pod")
+		}
 	}
 
-	for _, p := range plugins {
+	// 5. Plugins are started as soon as all the plugins they depend on have reported results.
+	// Plugins already started by a previous aggregator are only monitored for the rest of their timeout.
+	go aggr.runPluginsInOrder(context.Background(), plugins, func(p plugin.Interface) {
+		if started, ok := aggr.startedPlugin(p.GetName()); ok {
+			if aggr.pluginHasResults(p) {
+				return
+			}
+			logrus.WithField("plugin", p.GetName()).Info("Resuming monitoring of plugin")
+			go aggr.monitorPlugin(context.Background(), timeouts[p.GetName()], started.StartedAt, p, client, nodes.Items, nil)
+			return
+		}
+
 		logrus.WithField("plugin", p.GetName()).Info("Running plugin")
-		go aggr.RunAndMonitorPlugin(context.Background(), time.Duration(cfg.TimeoutSeconds)*time.Second, p, client, nodes.Items, cfg.AdvertiseAddress, certs[p.GetName()], aggregatorPod, progressPort)
-	}
+		aggr.pluginStarted(p, time.Now())
+		events.Publish(Event{Type: EventPluginStarted, Plugin: p.GetName()})
+		go aggr.RunAndMonitorPlugin(context.Background(), timeouts[p.GetName()], p, client, nodes.Items, cfg.AdvertiseAddress, certs[p.GetName()], aggregatorPod, progressPort)
+	})
 
 	// 6. Wait for aggr to show that all results are accounted for
 	for {
@@ -196,6 +276,9 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 			stopWaitCh <- true
 			return err
 		case <-doneAggr:
+			if err := aggr.writeManifest(outdir); err != nil {
+				logrus.Errorf("Failed to write results manifest: %v", err)
+			}
 			if aggr.hadTimeout() {
 				return &timeoutErr{errors.New("timeout occurred when waiting for plugin results")}
 			}
@@ -204,6 +287,42 @@ func Run(client kubernetes.Interface, plugins []plugin.Interface, cfg plugin.Agg
 	}
 }
 
+// pluginTimeout returns the timeout configured for the plugin, falling back to the
+// given default if the plugin doesn't specify one.
+func pluginTimeout(p plugin.Interface, defaultTimeout time.Duration) time.Duration {
+	if t := p.GetTimeout(); t > 0 {
+		return t
+	}
+	return defaultTimeout
+}
+
+// sessionPlugin is implemented by plugins whose session can be saved and restored, allowing
+// a restarted aggregator to manage the pods started by the previous one.
+type sessionPlugin interface {
+	GetSessionID() string
+	SetSessionID(string)
+}
+
+// runPlugin is implemented by plugins which label their resources with the ID of the run they
+// belong to.
+type runPlugin interface {
+	SetRunID(string)
+}
+
+// restoreSessions sets the session of each plugin which was started by a previous aggregator
+// to the one it was started with.
+func restoreSessions(aggr *Aggregator, plugins []plugin.Interface) {
+	for _, p := range plugins {
+		started, ok := aggr.startedPlugin(p.GetName())
+		if !ok {
+			continue
+		}
+		if s, ok := p.(sessionPlugin); ok {
+			s.SetSessionID(started.SessionID)
+		}
+	}
+}
+
 // Cleanup calls cleanup on all plugins
 func Cleanup(client kubernetes.Interface, plugins []plugin.Interface) {
 	// Cleanup after each plugin unless cleanup is explicitly skipped
@@ -217,20 +336,32 @@ func Cleanup(client kubernetes.Interface, plugins []plugin.Interface) {
 // RunAndMonitorPlugin will start a plugin then monitor it for errors starting/running.
 // Errors detected will be handled by saving an error result in the aggregator.Results.
 func (a *Aggregator) RunAndMonitorPlugin(ctx context.Context, timeout time.Duration, p plugin.Interface, client kubernetes.Interface, nodes []corev1.Node, address string, cert *tls.Certificate, aggregatorPod *corev1.Pod, progressPort string) {
-	monitorCh := make(chan *plugin.Result, 1)
-
-	// Give the ingestion routine a tad more time to avoid races where the monitor routine, at timeout, tries
-	// to return results.
-	ctxMonitor, cancelMonitor := context.WithTimeout(ctx, timeout)
-	ctxIngest, cancelIngest := context.WithTimeout(ctx, timeout+timeoutMonitoringOffset)
-
+	var runErr *plugin.Result
 	if err := p.Run(client, address, cert, aggregatorPod, progressPort); err != nil {
 		err := errors.Wrapf(err, "error running plugin %v", p.GetName())
 		logrus.Error(err)
-		monitorCh <- utils.MakeErrorResult(p.GetName(), map[string]interface{}{"error": err.Error()}, "")
+		runErr = utils.MakeErrorResult(p.GetName(), map[string]interface{}{"error": err.Error()}, "")
+	}
+
+	a.monitorPlugin(ctx, timeout, time.Now(), p, client, nodes, runErr)
+}
+
+// monitorPlugin monitors an already running plugin for errors until it reports all of its results,
+// the timeout since it was started expires or the context is cancelled. If runErr is set, it is
+// recorded as the result of the plugin.
+func (a *Aggregator) monitorPlugin(ctx context.Context, timeout time.Duration, startedAt time.Time, p plugin.Interface, client kubernetes.Interface, nodes []corev1.Node, runErr *plugin.Result) {
+	monitorCh := make(chan *plugin.Result, 1)
+	if runErr != nil {
+		monitorCh <- runErr
 	}
 
-	go p.Monitor(ctxMonitor, client, nodes, monitorCh)
+	// Give the ingestion routine a tad more time to avoid races where the monitor routine, at timeout, tries
+	// to return results.
+	deadline := startedAt.Add(timeout)
+	ctxMonitor, cancelMonitor := context.WithDeadline(ctx, deadline)
+	ctxIngest, cancelIngest := context.WithDeadline(ctx, deadline.Add(timeoutMonitoringOffset))
+
+	go p.Monitor(ctxMonitor, client, nodes, timeout, monitorCh)
 	go a.IngestResults(ctxIngest, monitorCh)
 
 	// Control loop; check regularly if we have results or not for this plugin. If results are in,
@@ -253,6 +384,94 @@ func (a *Aggregator) RunAndMonitorPlugin(ctx context.Context, timeout time.Durat
 	}
 }
 
+// runPluginsInOrder calls start for each plugin once all of the plugins it depends on have
+// reported results. If any of those dependencies reported an error, the plugin is not started
+// and an error result is recorded for each of its expected results instead. It returns once
+// every plugin has been started or skipped, or when the context is cancelled.
+func (a *Aggregator) runPluginsInOrder(ctx context.Context, plugins []plugin.Interface, start func(plugin.Interface)) {
+	pending := plugins
+	for {
+		var waiting []plugin.Interface
+		for _, p := range pending {
+			ready, failedDep := a.dependenciesComplete(p)
+			switch {
+			case failedDep != "":
+				a.skipPlugin(p, failedDep)
+			case ready:
+				start(p)
+			default:
+				waiting = append(waiting, p)
+			}
+		}
+
+		pending = waiting
+		if len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-sonotime.After(pollingInterval):
+		}
+	}
+}
+
+// dependenciesComplete returns true if all of the plugins the given plugin depends on have
+// reported all their results successfully. If any of them have reported an error, the name
+// of that plugin is returned so the caller can avoid running the plugin.
+func (a *Aggregator) dependenciesComplete(p plugin.Interface) (bool, string) {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	complete := true
+	for _, dep := range p.GetDependencies() {
+		for expResultID, expResult := range a.ExpectedResults {
+			if expResult.ResultType != dep {
+				continue
+			}
+
+			result, ok := a.Results[expResultID]
+			switch {
+			case !ok:
+				complete = false
+			case !result.IsSuccess():
+				return false, dep
+			}
+		}
+	}
+
+	return complete, ""
+}
+
+// skipPlugin records an error result for each of the expected results of the given plugin,
+// marking that it was not run since one of its dependencies failed.
+func (a *Aggregator) skipPlugin(p plugin.Interface, failedDep string) {
+	logrus.WithFields(logrus.Fields{
+		"plugin":     p.GetName(),
+		"dependency": failedDep,
+	}).Error("Skipping plugin since one of its dependencies failed")
+
+	a.resultsMutex.Lock()
+	var nodes []string
+	for _, expResult := range a.ExpectedResults {
+		if expResult.ResultType == p.GetName() {
+			nodes = append(nodes, expResult.NodeName)
+		}
+	}
+	a.resultsMutex.Unlock()
+
+	for _, node := range nodes {
+		err := a.processResult(utils.MakeErrorResult(p.GetName(), map[string]interface{}{
+			"error":      plugin.DependencyFailedErrMsg,
+			"dependency": failedDep,
+		}, node))
+		if err != nil {
+			logrus.Errorf("Failed to record skipped result for plugin %v: %v", p.GetName(), err)
+		}
+	}
+}
+
 // pluginHasResults returns true if all the expected results for the given plugin
 // have already been reported.
 func (a *Aggregator) pluginHasResults(p plugin.Interface) bool {
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -182,6 +183,89 @@ func TestRunAndMonitorPlugin(t *testing.T) {
 	}
 }
 
+func TestRunPluginsInOrder(t *testing.T) {
+	newPlugin := func(name string, deps ...string) plugin.Interface {
+		return &job.Plugin{
+			Base: driver.Base{
+				Definition: manifest.Manifest{
+					SonobuoyConfig: manifest.SonobuoyConfig{PluginName: name, DependsOn: deps},
+				},
+			},
+		}
+	}
+	plugins := []plugin.Interface{newPlugin("a"), newPlugin("b", "a"), newPlugin("c", "b"), newPlugin("d")}
+	expectedResults := []plugin.ExpectedResult{
+		{ResultType: "a", NodeName: "global"},
+		{ResultType: "b", NodeName: "global"},
+		{ResultType: "c", NodeName: "global"},
+		{ResultType: "d", NodeName: "global"},
+	}
+	sonotime.UseShortAfter()
+	defer sonotime.ResetAfter()
+
+	testCases := []struct {
+		desc          string
+		results       map[string]*plugin.Result
+		expectStarted []string
+		expectSkipped []string
+	}{
+		{
+			desc:          "Only plugins without dependencies are started initially",
+			expectStarted: []string{"a", "d"},
+		}, {
+			desc: "Dependents are started once dependencies succeed",
+			results: map[string]*plugin.Result{
+				"a/global": {ResultType: "a", NodeName: "global"},
+			},
+			expectStarted: []string{"a", "b", "d"},
+		}, {
+			desc: "Dependents are skipped transitively when a dependency fails",
+			results: map[string]*plugin.Result{
+				"a/global": {ResultType: "a", NodeName: "global", Error: "oops"},
+			},
+			expectStarted: []string{"a", "d"},
+			expectSkipped: []string{"b", "c"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "sonobuoy-test")
+			if err != nil {
+				t.Fatalf("Failed to make temp directory: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			a := NewAggregator(tmpDir, expectedResults)
+			for k, v := range tc.results {
+				a.Results[k] = v
+			}
+
+			started := []string{}
+			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+			defer cancel()
+			a.runPluginsInOrder(ctx, plugins, func(p plugin.Interface) {
+				started = append(started, p.GetName())
+			})
+
+			if !reflect.DeepEqual(started, tc.expectStarted) {
+				t.Errorf("Expected plugins %v to be started but got %v", tc.expectStarted, started)
+			}
+
+			for _, name := range tc.expectSkipped {
+				r, ok := a.Results[name+"/global"]
+				if !ok {
+					t.Errorf("Expected skipped result for plugin %v but found none", name)
+					continue
+				}
+				if r.Error != plugin.DependencyFailedErrMsg {
+					t.Errorf("Expected plugin %v to have error %q but got %q", name, plugin.DependencyFailedErrMsg, r.Error)
+				}
+			}
+		})
+	}
+}
+
 func getTestCert() (*tls.Certificate, error) {
 	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -214,7 +298,7 @@ func (cp *MockCleanupPlugin) Cleanup(_ kubernetes.Interface) {
 	cp.cleanedUp = true
 }
 
-func (cp *MockCleanupPlugin) Monitor(_ context.Context, _ kubernetes.Interface, _ []corev1.Node, _ chan<- *plugin.Result) {
+func (cp *MockCleanupPlugin) Monitor(_ context.Context, _ kubernetes.Interface, _ []corev1.Node, _ time.Duration, _ chan<- *plugin.Result) {
 	return
 }
 
@@ -250,6 +334,54 @@ func (cp *MockCleanupPlugin) GetSourceURL() string {
 	return ""
 }
 
+func (cp *MockCleanupPlugin) GetDependencies() []string {
+	return nil
+}
+
+func (cp *MockCleanupPlugin) GetTimeout() time.Duration {
+	return 0
+}
+
+func TestPluginTimeout(t *testing.T) {
+	newPlugin := func(timeout *metav1.Duration) plugin.Interface {
+		return &job.Plugin{
+			Base: driver.Base{
+				Definition: manifest.Manifest{
+					SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "p", Timeout: timeout},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		desc     string
+		plugin   plugin.Interface
+		expected time.Duration
+	}{
+		{
+			desc:     "Default used if plugin does not specify a timeout",
+			plugin:   newPlugin(nil),
+			expected: 6 * time.Hour,
+		}, {
+			desc:     "Plugin timeout overrides the default",
+			plugin:   newPlugin(&metav1.Duration{Duration: 5 * time.Minute}),
+			expected: 5 * time.Minute,
+		}, {
+			desc:     "Default used if plugin timeout is zero",
+			plugin:   newPlugin(&metav1.Duration{}),
+			expected: 6 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := pluginTimeout(tc.plugin, 6*time.Hour); got != tc.expected {
+				t.Errorf("Expected timeout %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestCleanup(t *testing.T) {
 	createPlugin := func(skipCleanup bool) *MockCleanupPlugin {
 		return &MockCleanupPlugin{
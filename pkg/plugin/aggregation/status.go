@@ -49,6 +49,9 @@ type PluginStatus struct {
 	Node   string `json:"node"`
 	Status string `json:"status"`
 
+	// Timeout is how long the aggregator will wait for results from the plugin.
+	Timeout string `json:"timeout,omitempty"`
+
 	ResultStatus       string         `json:"result-status"`
 	ResultStatusCounts map[string]int `json:"result-counts"`
 
@@ -104,16 +107,17 @@ func (s *Status) updateStatus() error {
 	return nil
 }
 
-// GetStatus returns the current status status on the sonobuoy pod. If the pod
+// GetStatus returns the current status status on the sonobuoy pod of the run with
+// the given ID, or of the only run in the namespace if runID is empty. If the pod
 // does not exist, is not running, or is missing the status annotation, an error
 // is returned.
-func GetStatus(client kubernetes.Interface, namespace string) (*Status, *corev1.Pod, error) {
+func GetStatus(client kubernetes.Interface, namespace, runID string) (*Status, *corev1.Pod, error) {
 	if _, err := client.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{}); err != nil {
 		return nil, nil, errors.Wrapf(err, "failed to get namespace %v", namespace)
 	}
 
 	// Determine sonobuoy pod name
-	podName, err := GetAggregatorPodName(client, namespace)
+	podName, err := GetAggregatorPodName(client, namespace, runID)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to get the name of the aggregator pod to get the status from")
 	}
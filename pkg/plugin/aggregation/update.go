@@ -20,7 +20,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -50,11 +53,13 @@ type updater struct {
 	positionLookup map[string]*PluginStatus
 	status         Status
 	namespace      string
+	runID          string
 	client         kubernetes.Interface
 }
 
-// newUpdater creates an an updater that expects ExpectedResult.
-func newUpdater(expected []plugin.ExpectedResult, namespace string, client kubernetes.Interface) *updater {
+// newUpdater creates an an updater that expects ExpectedResult. The timeouts map, keyed by
+// plugin name, is recorded in the status so users can see how long each plugin has to run.
+func newUpdater(expected []plugin.ExpectedResult, timeouts map[string]time.Duration, namespace, runID string, client kubernetes.Interface) *updater {
 	u := &updater{
 		positionLookup: make(map[string]*PluginStatus),
 		status: Status{
@@ -62,6 +67,7 @@ func newUpdater(expected []plugin.ExpectedResult, namespace string, client kuber
 			Status:  RunningStatus,
 		},
 		namespace: namespace,
+		runID:     runID,
 		client:    client,
 	}
 
@@ -71,6 +77,9 @@ func newUpdater(expected []plugin.ExpectedResult, namespace string, client kuber
 			Plugin: result.ResultType,
 			Status: RunningStatus,
 		}
+		if t, ok := timeouts[result.ResultType]; ok {
+			u.status.Plugins[i].Timeout = t.String()
+		}
 
 		u.positionLookup[result.ID()] = &u.status.Plugins[i]
 	}
@@ -98,6 +107,9 @@ func deepCopyPluginStatus(dst, src *PluginStatus) {
 	dst.Node = src.Node
 	dst.Status = src.Status
 	dst.ResultStatus = src.ResultStatus
+	if src.Timeout != "" {
+		dst.Timeout = src.Timeout
+	}
 
 	if src.ResultStatusCounts != nil {
 		dst.ResultStatusCounts = map[string]int{}
@@ -151,7 +163,7 @@ func (u *updater) Annotate(results map[string]*plugin.Result, progressUpdates ma
 	}
 
 	// Determine sonobuoy pod name
-	podName, err := GetAggregatorPodName(u.client, u.namespace)
+	podName, err := GetAggregatorPodName(u.client, u.namespace, u.runID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get name of the aggregator pod to annotate")
 	}
@@ -217,10 +229,13 @@ func GetPatch(annotation string) map[string]interface{} {
 	}
 }
 
-// GetAggregatorPod gets the sonobuoy aggregator pod based on its label.
+// GetAggregatorPod gets the sonobuoy aggregator pod based on its label. If runID is set, only the
+// pod labelled with that run ID is returned since several runs may share the namespace. Otherwise
+// the only aggregator pod in the namespace is returned; if there are several, an error listing
+// their run IDs is returned rather than picking one of them.
 // It returns NoPodWithLabelError in the case where a pod with sonobuoy aggregator label could not be found.
-func GetAggregatorPod(client kubernetes.Interface, namespace string) (*v1.Pod, error) {
-	getPodWithLabel := func(label string) (*v1.Pod, error) {
+func GetAggregatorPod(client kubernetes.Interface, namespace, runID string) (*v1.Pod, error) {
+	getPodsWithLabel := func(label string) ([]v1.Pod, error) {
 		listOptions := metav1.ListOptions{
 			LabelSelector: label,
 		}
@@ -230,24 +245,27 @@ func GetAggregatorPod(client kubernetes.Interface, namespace string) (*v1.Pod, e
 			return nil, errors.Wrapf(err, "unable to list pods with label %q", label)
 		}
 
-		switch {
-		case len(podList.Items) == 0:
+		if len(podList.Items) == 0 {
 			logrus.Warningf("no pods found with label %q in namespace %s", label, namespace)
 			return nil, NoPodWithLabelError(fmt.Sprintf("no pods found with label %q in namespace %s", label, namespace))
-
-		case len(podList.Items) > 1:
-			logrus.Warningf("Found more than one pod with label %q. Using pod with name %q", label, podList.Items[0].GetName())
-			return &podList.Items[0], nil
-		default:
-			return &podList.Items[0], nil
 		}
+		return podList.Items, nil
 	}
 
 	aggregatorPodLabel := "sonobuoy-component=aggregator"
 	deprecatedAggregatorPodLabel := "run=sonobuoy-master"
 
-	pod, err := getPodWithLabel(aggregatorPodLabel)
-	if err != nil || pod == nil {
+	if runID != "" {
+		// Never fall back to other aggregators; they belong to other runs.
+		pods, err := getPodsWithLabel(fmt.Sprintf("%v,%v=%v", aggregatorPodLabel, plugin.RunIDLabel, runID))
+		if err != nil {
+			return nil, err
+		}
+		return &pods[0], nil
+	}
+
+	pods, err := getPodsWithLabel(aggregatorPodLabel)
+	if err != nil {
 		if _, ok := err.(NoPodWithLabelError); !ok {
 			// If we encountered an error which doesn't correspond to no pods existing,
 			// log the error as a warning.
@@ -256,19 +274,37 @@ func GetAggregatorPod(client kubernetes.Interface, namespace string) (*v1.Pod, e
 
 		// In either case, retry using the deprecated aggregator pod label.
 		logrus.Warningf("retrying with deprecated label %q", deprecatedAggregatorPodLabel)
-		pod, err = getPodWithLabel(deprecatedAggregatorPodLabel)
+		pods, err = getPodsWithLabel(deprecatedAggregatorPodLabel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(pods) > 1 {
+		ids := make([]string, len(pods))
+		for i, pod := range pods {
+			ids[i] = pod.Labels[plugin.RunIDLabel]
+			if ids[i] == "" {
+				ids[i] = "<none>"
+			}
+		}
+		sort.Strings(ids)
+		return nil, fmt.Errorf("multiple sonobuoy runs in namespace %v, pass --run with one of their IDs: %v", namespace, strings.Join(ids, ", "))
 	}
-	return pod, err
+	return &pods[0], nil
 }
 
 // GetAggregatorPodName gets the sonobuoy aggregator pod name. It returns the default pod name
-// if the pod cannot be found.
-func GetAggregatorPodName(client kubernetes.Interface, namespace string) (string, error) {
-	ap, err := GetAggregatorPod(client, namespace)
+// if the pod cannot be found, unless runID is set since that pod may belong to another run.
+func GetAggregatorPodName(client kubernetes.Interface, namespace, runID string) (string, error) {
+	ap, err := GetAggregatorPod(client, namespace, runID)
 
 	if err != nil {
 		switch err.(type) {
 		case NoPodWithLabelError:
+			if runID != "" {
+				return "", errors.Wrap(err, "failed to get aggregator pod")
+			}
 			logrus.Warningf("Aggregator pod not found, using default pod name %q: %v", DefaultStatusPodName, err)
 			return DefaultStatusPodName, nil
 		default:
@@ -17,7 +17,9 @@ limitations under the License.
 package aggregation
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 
@@ -39,7 +41,9 @@ func TestCreateUpdater(t *testing.T) {
 
 	updater := newUpdater(
 		expected,
+		map[string]time.Duration{"systemd": 5 * time.Minute, "e2e": 6 * time.Hour},
 		"sonobuoy-test",
+		"",
 		nil,
 	)
 
@@ -54,6 +58,13 @@ func TestCreateUpdater(t *testing.T) {
 	if updater.status.Status != FailedStatus {
 		t.Errorf("expected status to be failed, got %v", updater.status.Status)
 	}
+
+	for _, p := range updater.status.Plugins {
+		expected := map[string]string{"systemd": "5m0s", "e2e": "6h0m0s"}[p.Plugin]
+		if p.Timeout != expected {
+			t.Errorf("expected timeout for plugin %v to be %v, got %v", p.Plugin, expected, p.Timeout)
+		}
+	}
 }
 
 func TestGetAggregatorPod(t *testing.T) {
@@ -87,6 +98,13 @@ func TestGetAggregatorPod(t *testing.T) {
 		return nil
 	}
 
+	checkMultipleRunsError := func(err error) error {
+		if err == nil || !strings.Contains(err.Error(), "multiple sonobuoy runs in namespace sonobuoy") {
+			return errors.Errorf("expected error about multiple runs, got %v", err)
+		}
+		return nil
+	}
+
 	checkNoPodWithLabelError := func(err error) error {
 		if _, ok := err.(NoPodWithLabelError); !ok {
 			return errors.Wrap(err, "expected error to have type NoPodWithLabelError")
@@ -121,10 +139,10 @@ func TestGetAggregatorPod(t *testing.T) {
 			expectedPod:  &testPods[0],
 		},
 		{
-			desc:         "More that one pod results in the first pod being returned",
+			desc:         "More that one pod results in an error rather than an arbitrary run",
 			podsOnServer: corev1.PodList{Items: testPods},
-			checkError:   checkNoError,
-			expectedPod:  &testPods[0],
+			checkError:   checkMultipleRunsError,
+			expectedPod:  nil,
 		},
 		{
 			desc:         "Only one pod with deprecated label results in that pod being returned",
@@ -147,7 +165,7 @@ func TestGetAggregatorPod(t *testing.T) {
 				return true, &tc.podsOnServer, tc.errFromServer
 			})
 
-			pod, err := GetAggregatorPod(fclient, "sonobuoy")
+			pod, err := GetAggregatorPod(fclient, "sonobuoy", "")
 			if checkErr := tc.checkError(err); checkErr != nil {
 				t.Errorf("error check failed: %v", checkErr)
 			}
@@ -165,6 +183,62 @@ func TestGetAggregatorPod(t *testing.T) {
 	}
 }
 
+func TestGetAggregatorPodOfRun(t *testing.T) {
+	aggregatorPod := func(name, runID string) *corev1.Pod {
+		labels := map[string]string{"sonobuoy-component": "aggregator"}
+		if runID != "" {
+			labels[plugin.RunIDLabel] = runID
+		}
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "sonobuoy", Labels: labels}}
+	}
+
+	testCases := []struct {
+		desc        string
+		pods        []kuberuntime.Object
+		runID       string
+		expectedPod string
+		expectedErr string
+	}{
+		{
+			desc:        "The pod of the run is found among those of other runs",
+			pods:        []kuberuntime.Object{aggregatorPod("sonobuoy-a", "a"), aggregatorPod("sonobuoy-b", "b")},
+			runID:       "b",
+			expectedPod: "sonobuoy-b",
+		}, {
+			desc:        "Aggregators of other runs aren't used for a run ID",
+			pods:        []kuberuntime.Object{aggregatorPod("sonobuoy", ""), aggregatorPod("sonobuoy-a", "a")},
+			runID:       "c",
+			expectedErr: `no pods found with label "sonobuoy-component=aggregator,sonobuoy-run-id=c" in namespace sonobuoy`,
+		}, {
+			desc:        "Without a run ID the only aggregator is used",
+			pods:        []kuberuntime.Object{aggregatorPod("sonobuoy-a", "a")},
+			expectedPod: "sonobuoy-a",
+		}, {
+			desc:        "Without a run ID several runs are an error",
+			pods:        []kuberuntime.Object{aggregatorPod("sonobuoy-a", "a"), aggregatorPod("sonobuoy", "")},
+			expectedErr: "multiple sonobuoy runs in namespace sonobuoy, pass --run with one of their IDs: <none>, a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			pod, err := GetAggregatorPod(fake.NewSimpleClientset(tc.pods...), "sonobuoy", tc.runID)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("Expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if pod.Name != tc.expectedPod {
+				t.Errorf("Expected pod %q but got %q", tc.expectedPod, pod.Name)
+			}
+		})
+	}
+}
+
 func TestGetAggregatorPodName(t *testing.T) {
 	createPodWithRunLabel := func(name string) corev1.Pod {
 		return corev1.Pod{
@@ -179,6 +253,8 @@ func TestGetAggregatorPodName(t *testing.T) {
 		desc            string
 		podsOnServer    corev1.PodList
 		errFromServer   error
+		runID           string
+		expectErr       bool
 		expectedPodName string
 	}{
 		{
@@ -193,6 +269,13 @@ func TestGetAggregatorPodName(t *testing.T) {
 			errFromServer:   nil,
 			expectedPodName: "sonobuoy",
 		},
+		{
+			desc:            "No pod of the run results in an error rather than the default pod name",
+			podsOnServer:    corev1.PodList{},
+			runID:           "abc",
+			expectErr:       true,
+			expectedPodName: "",
+		},
 		{
 			desc: "A returned pod results in that pod name being used",
 			podsOnServer: corev1.PodList{
@@ -212,11 +295,12 @@ func TestGetAggregatorPodName(t *testing.T) {
 				return true, &tc.podsOnServer, tc.errFromServer
 			})
 
-			podName, err := GetAggregatorPodName(fclient, "sonobuoy")
-			if tc.errFromServer == nil && err != nil {
+			podName, err := GetAggregatorPodName(fclient, "sonobuoy", tc.runID)
+			expectErr := tc.expectErr || tc.errFromServer != nil
+			if !expectErr && err != nil {
 				t.Errorf("Unexpected error returned, expected nil but got %q", err)
 			}
-			if tc.errFromServer != nil && err == nil {
+			if expectErr && err == nil {
 				t.Errorf("Error not returned, expected %q but was nil", tc.errFromServer)
 			}
 			if podName != tc.expectedPodName {
@@ -335,7 +419,7 @@ func TestReceive(t *testing.T) {
 
 	for _, tc := range tcs {
 		t.Run(tc.desc, func(t *testing.T) {
-			u := newUpdater(expectedResults, "testns", nil)
+			u := newUpdater(expectedResults, nil, "testns", "", nil)
 			u.ReceiveAll(tc.results, tc.updates)
 			if diff := pretty.Compare(tc.expected, u.status); diff != "" {
 				t.Fatalf("\n\n%s\n", diff)
@@ -0,0 +1,319 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+const (
+	uploadDataFile = "data"
+	uploadMetaFile = "meta.json"
+)
+
+// Upload is a request to start (or resume) a chunked upload of a result or to append a chunk to it.
+// Chunked uploads let large results be sent in pieces so that a dropped connection only requires
+// the remainder of the result to be sent again.
+type Upload struct {
+	NodeName   string
+	ResultType string
+	MimeType   string
+	Filename   string
+
+	// Length and Digest describe the complete result when starting an upload. Digest
+	// is the hex encoded SHA-256 digest of the result. FileDigests are optional digests
+	// of the files inside a tarball result.
+	Length      int64
+	Digest      string
+	FileDigests map[string]string
+
+	// Offset is the position in the result at which Body starts. Body is nil when
+	// starting an upload.
+	Offset int64
+	Body   io.Reader
+}
+
+// result returns the result which is being uploaded, without its body.
+func (u *Upload) result() *plugin.Result {
+	return &plugin.Result{
+		NodeName:   u.NodeName,
+		ResultType: u.ResultType,
+		MimeType:   u.MimeType,
+		Filename:   u.Filename,
+	}
+}
+
+// uploadMeta is saved alongside the data of an upload in progress so that it can be resumed,
+// even by a restarted aggregator.
+type uploadMeta struct {
+	Length      int64             `json:"length"`
+	Digest      string            `json:"digest"`
+	FileDigests map[string]string `json:"fileDigests,omitempty"`
+	MimeType    string            `json:"mimeType,omitempty"`
+	Filename    string            `json:"filename,omitempty"`
+}
+
+// uploadLocks serializes requests for the same upload without blocking other uploads.
+type uploadLocks struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (l *uploadLocks) lock(key string) func() {
+	l.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*sync.Mutex{}
+	}
+	m, ok := l.locks[key]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[key] = m
+	}
+	l.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// HandleHTTPUpload is called every time the HTTP server gets a request to start a chunked upload or
+// to append to one. The response always includes the offset the client should continue the upload
+// from. Once all of the result has been received and its digest verified, it is processed as if it
+// had been sent in a single request.
+func (a *Aggregator) HandleHTTPUpload(u *Upload, w http.ResponseWriter) {
+	var offset int64
+	var err error
+	if u.Body == nil {
+		offset, err = a.startUpload(u)
+	} else {
+		offset, err = a.appendUpload(u)
+	}
+
+	if offset >= 0 {
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(offset, 10))
+	}
+	if err == nil {
+		return
+	}
+
+	code := http.StatusInternalServerError
+	if t, ok := err.(*httpError); ok {
+		code = t.HttpCode()
+	}
+	logrus.Errorf("Upload processing error (%v): %v", code, err.Error())
+	http.Error(w, err.Error(), code)
+}
+
+// uploadDir returns the directory where the given upload is saved while in progress.
+func (a *Aggregator) uploadDir(u *Upload) string {
+	return filepath.Join(a.UploadsDir, u.result().Key())
+}
+
+// checkUpload ensures the result being uploaded is one that is still expected.
+func (a *Aggregator) checkUpload(u *Upload) error {
+	a.resultsMutex.Lock()
+	defer a.resultsMutex.Unlock()
+
+	result := u.result()
+	if !a.isExpected(result) {
+		return &httpError{
+			err:  fmt.Errorf("result %v unexpected", result.Key()),
+			code: http.StatusForbidden,
+		}
+	}
+
+	_, hadErrs := a.FailedResults[result.Key()]
+	if a.isResultDuplicate(result) && !hadErrs {
+		return &httpError{
+			err:  fmt.Errorf("result %v already received", result.Key()),
+			code: http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+// startUpload starts a new upload or, if an upload of the same data is already in progress,
+// returns the offset to resume it from.
+func (a *Aggregator) startUpload(u *Upload) (int64, error) {
+	if err := a.checkUpload(u); err != nil {
+		return -1, err
+	}
+	if u.Length < 0 {
+		return -1, &httpError{err: errors.New("upload length must not be negative"), code: http.StatusBadRequest}
+	}
+	if b, err := hex.DecodeString(u.Digest); err != nil || len(b) != sha256.Size {
+		return -1, &httpError{err: fmt.Errorf("upload digest %q is not a hex encoded SHA-256 digest", u.Digest), code: http.StatusBadRequest}
+	}
+
+	unlock := a.uploadLocks.lock(u.result().Key())
+	defer unlock()
+
+	dir := a.uploadDir(u)
+	meta, size, err := readUpload(dir)
+	if err != nil {
+		return -1, err
+	}
+	if meta != nil && meta.Length == u.Length && meta.Digest == u.Digest && size <= u.Length {
+		logrus.WithField("offset", size).Infof("Resuming upload of result %v", u.result().Key())
+		return size, nil
+	}
+
+	// Anything else in progress is for different data so start over.
+	if err := os.RemoveAll(dir); err != nil {
+		return -1, errors.Wrapf(err, "couldn't remove previous upload %q", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return -1, errors.Wrapf(err, "couldn't create upload directory %q", dir)
+	}
+	b, err := json.Marshal(uploadMeta{Length: u.Length, Digest: u.Digest, FileDigests: u.FileDigests, MimeType: u.MimeType, Filename: u.Filename})
+	if err != nil {
+		return -1, errors.Wrap(err, "couldn't encode upload metadata")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, uploadMetaFile), b, 0644); err != nil {
+		return -1, errors.Wrap(err, "couldn't save upload metadata")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, uploadDataFile), nil, 0644); err != nil {
+		return -1, errors.Wrap(err, "couldn't create upload data file")
+	}
+	return 0, nil
+}
+
+// appendUpload writes the chunk of the upload to disk and returns the new offset. Once the
+// upload is complete its digest is checked and the result is processed.
+func (a *Aggregator) appendUpload(u *Upload) (int64, error) {
+	if err := a.checkUpload(u); err != nil {
+		return -1, err
+	}
+
+	unlock := a.uploadLocks.lock(u.result().Key())
+	defer unlock()
+
+	dir := a.uploadDir(u)
+	meta, size, err := readUpload(dir)
+	if err != nil {
+		return -1, err
+	}
+	if meta == nil {
+		return -1, &httpError{err: fmt.Errorf("no upload of result %v in progress", u.result().Key()), code: http.StatusNotFound}
+	}
+	if u.Offset != size {
+		return size, &httpError{err: fmt.Errorf("chunk for offset %v does not match upload offset %v", u.Offset, size), code: http.StatusConflict}
+	}
+
+	dataPath := filepath.Join(dir, uploadDataFile)
+	f, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return size, errors.Wrapf(err, "couldn't open upload data file %q", dataPath)
+	}
+	// Read one byte more than remains so that oversized uploads are caught. Anything written
+	// before a dropped connection is kept so that the client can resume after it.
+	n, copyErr := io.Copy(f, io.LimitReader(u.Body, meta.Length-size+1))
+	closeErr := f.Close()
+	size += n
+
+	switch {
+	case size > meta.Length:
+		os.RemoveAll(dir)
+		return -1, &httpError{err: fmt.Errorf("upload of result %v exceeds its length of %v bytes", u.result().Key(), meta.Length), code: http.StatusBadRequest}
+	case copyErr != nil:
+		return size, errors.Wrap(copyErr, "couldn't write upload data")
+	case closeErr != nil:
+		return size, errors.Wrap(closeErr, "couldn't write upload data")
+	case size < meta.Length:
+		return size, nil
+	}
+
+	// The upload is removed once complete, even if it failed, so there is no offset to resume from.
+	if err := a.completeUpload(u, dir, meta); err != nil {
+		return -1, err
+	}
+	return size, nil
+}
+
+// completeUpload verifies the digest of a fully received upload and processes it as the result.
+// The upload is removed afterwards; if it failed verification the client has to start over.
+func (a *Aggregator) completeUpload(u *Upload, dir string, meta *uploadMeta) error {
+	defer os.RemoveAll(dir)
+
+	dataPath := filepath.Join(dir, uploadDataFile)
+	f, err := os.Open(dataPath)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't open upload data file %q", dataPath)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.Wrapf(err, "couldn't read upload data file %q", dataPath)
+	}
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != meta.Digest {
+		return &httpError{
+			err:  fmt.Errorf("digest of result %v is %v, expected %v", u.result().Key(), digest, meta.Digest),
+			code: http.StatusBadRequest,
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "couldn't read upload data file %q", dataPath)
+	}
+
+	result := u.result()
+	result.MimeType = meta.MimeType
+	result.Filename = meta.Filename
+	result.Digest = meta.Digest
+	result.FileDigests = meta.FileDigests
+	result.Body = f
+	return a.processResult(result)
+}
+
+// readUpload returns the metadata and current size of the upload in the given directory. If there
+// is no upload in progress, the metadata is nil.
+func readUpload(dir string) (*uploadMeta, int64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, uploadMetaFile))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "couldn't read upload metadata")
+	}
+
+	meta := &uploadMeta{}
+	if err := json.Unmarshal(b, meta); err != nil {
+		return nil, 0, errors.Wrap(err, "couldn't decode upload metadata")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, uploadDataFile))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "couldn't read upload data")
+	}
+	return meta, info.Size(), nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/backplane/ca/authtest"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+)
+
+func digestOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHandleHTTPUpload(t *testing.T) {
+	const data = "0123456789"
+
+	start := func(length int64, digest string) *Upload {
+		return &Upload{NodeName: "node1", ResultType: "systemd_logs", Filename: "logs.txt", Length: length, Digest: digest}
+	}
+	chunk := func(offset int64, body string) *Upload {
+		return &Upload{NodeName: "node1", ResultType: "systemd_logs", Filename: "logs.txt", Offset: offset, Body: strings.NewReader(body)}
+	}
+
+	testCases := []struct {
+		desc           string
+		requests       []*Upload
+		expectedCode   int
+		expectedOffset string
+		expectResult   bool
+	}{
+		{
+			desc:           "Starting an upload returns offset 0",
+			requests:       []*Upload{start(10, digestOf(data))},
+			expectedCode:   http.StatusOK,
+			expectedOffset: "0",
+		}, {
+			desc:         "Invalid digest is rejected",
+			requests:     []*Upload{start(10, "abc")},
+			expectedCode: http.StatusBadRequest,
+		}, {
+			desc:         "Unexpected result is rejected",
+			requests:     []*Upload{{NodeName: "node2", ResultType: "systemd_logs", Length: 10, Digest: digestOf(data)}},
+			expectedCode: http.StatusForbidden,
+		}, {
+			desc:         "Chunk without an upload in progress is not found",
+			requests:     []*Upload{chunk(0, data)},
+			expectedCode: http.StatusNotFound,
+		}, {
+			desc:           "Partial chunk returns the new offset",
+			requests:       []*Upload{start(10, digestOf(data)), chunk(0, data[:4])},
+			expectedCode:   http.StatusOK,
+			expectedOffset: "4",
+		}, {
+			desc:           "Restarting the same upload resumes it",
+			requests:       []*Upload{start(10, digestOf(data)), chunk(0, data[:4]), start(10, digestOf(data))},
+			expectedCode:   http.StatusOK,
+			expectedOffset: "4",
+		}, {
+			desc:           "Starting a different upload starts over",
+			requests:       []*Upload{start(10, digestOf(data)), chunk(0, data[:4]), start(3, digestOf("abc"))},
+			expectedCode:   http.StatusOK,
+			expectedOffset: "0",
+		}, {
+			desc:           "Chunk at the wrong offset returns the current offset",
+			requests:       []*Upload{start(10, digestOf(data)), chunk(0, data[:4]), chunk(2, data[2:])},
+			expectedCode:   http.StatusConflict,
+			expectedOffset: "4",
+		}, {
+			desc:         "Oversized upload is rejected",
+			requests:     []*Upload{start(4, digestOf(data[:4])), chunk(0, data)},
+			expectedCode: http.StatusBadRequest,
+		}, {
+			desc:         "Digest mismatch is rejected",
+			requests:     []*Upload{start(10, digestOf(data)), chunk(0, "9876543210")},
+			expectedCode: http.StatusBadRequest,
+		}, {
+			desc:           "Complete upload is processed",
+			requests:       []*Upload{start(10, digestOf(data)), chunk(0, data[:4]), chunk(4, data[4:])},
+			expectedCode:   http.StatusOK,
+			expectedOffset: "10",
+			expectResult:   true,
+		}, {
+			desc:           "Empty upload is processed",
+			requests:       []*Upload{start(0, digestOf("")), chunk(0, "")},
+			expectedCode:   http.StatusOK,
+			expectedOffset: "0",
+			expectResult:   true,
+		}, {
+			desc:         "Upload of a received result is a conflict",
+			requests:     []*Upload{start(10, digestOf(data)), chunk(0, data), start(10, digestOf(data))},
+			expectedCode: http.StatusConflict,
+			expectResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sonobuoy_upload_test")
+			if err != nil {
+				t.Fatalf("Could not create temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			agg := NewAggregator(filepath.Join(dir, "plugins"), []plugin.ExpectedResult{{NodeName: "node1", ResultType: "systemd_logs"}})
+			agg.UploadsDir = filepath.Join(dir, "uploads")
+
+			var w *httptest.ResponseRecorder
+			for _, u := range tc.requests {
+				w = httptest.NewRecorder()
+				agg.HandleHTTPUpload(u, w)
+			}
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %v but got %v: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get(UploadOffsetHeader); got != tc.expectedOffset {
+				t.Errorf("Expected offset %q but got %q", tc.expectedOffset, got)
+			}
+
+			_, ok := agg.Results["systemd_logs/node1"]
+			if ok != tc.expectResult {
+				t.Errorf("Expected result received to be %v but got %v", tc.expectResult, ok)
+			}
+			if tc.expectResult {
+				b, err := ioutil.ReadFile(filepath.Join(agg.OutputDir, "systemd_logs", "results", "node1", "logs.txt"))
+				if err != nil {
+					t.Fatalf("Expected result to be saved: %v", err)
+				}
+				if len(b) > 0 && string(b) != data {
+					t.Errorf("Expected result %q but got %q", data, string(b))
+				}
+			}
+		})
+	}
+}
+
+func TestUploadHandler(t *testing.T) {
+	const data = "some results"
+	expected := []plugin.ExpectedResult{{NodeName: plugin.GlobalResult, ResultType: "e2e"}}
+
+	withAggregator(t, expected, func(agg *Aggregator, srv *authtest.Server) {
+		URL, err := GlobalUploadURL(srv.URL, "e2e")
+		if err != nil {
+			t.Fatalf("Couldn't get upload URL: %v", err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(UploadLengthHeader, strconv.Itoa(len(data)))
+		req.Header.Set(UploadDigestHeader, digestOf(data))
+		req.Header.Set("content-disposition", "attachment;filename=e2e.txt")
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("Failed to start upload: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(UploadOffsetHeader) != "0" {
+			t.Fatalf("Expected upload to start at offset 0 but got %v with offset %q", resp.StatusCode, resp.Header.Get(UploadOffsetHeader))
+		}
+
+		req, err = http.NewRequest(http.MethodPatch, URL, bytes.NewReader([]byte(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(UploadOffsetHeader, "0")
+		req.Header.Set("content-disposition", "attachment;filename=e2e.txt")
+		resp, err = srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send chunk: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			t.Fatalf("Expected chunk to be accepted but got %v: %s", resp.StatusCode, body)
+		}
+
+		result, ok := agg.Results["e2e/global"]
+		if !ok {
+			t.Fatalf("Expected result to be received")
+		}
+		if result.Filename != "e2e.txt" {
+			t.Errorf("Expected filename e2e.txt but got %q", result.Filename)
+		}
+	})
+}
@@ -25,4 +25,16 @@ const (
 
 	// TimeoutErrMsg is the message used when Sonobuoy experiences a timeout while waiting for results.
 	TimeoutErrMsg = "Plugin timeout while waiting for results so there are no results. Check pod logs or other cluster details for more information as to why this occurred."
+
+	// AttemptDirPrefix prefixes the directories, within a plugin's errors directory, which hold
+	// the errors from failed attempts which were retried (e.g. errors/attempt-1).
+	AttemptDirPrefix = "attempt-"
+
+	// DependencyFailedErrMsg is the message used when a plugin is not run because one of the plugins
+	// it depends on failed.
+	DependencyFailedErrMsg = "skipped: dependency failed"
+
+	// RunIDLabel is the label holding the ID of a run, which is the UUID of its config, on the
+	// resources created for the run.
+	RunIDLabel = "sonobuoy-run-id"
 )
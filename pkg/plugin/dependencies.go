@@ -0,0 +1,92 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateDependencies checks the dependency graph between plugins, given as a map of
+// plugin names to the names of the plugins they depend on. An error is returned if a
+// plugin depends on itself, on a plugin which is not in the map, or if the dependencies
+// form a cycle.
+func ValidateDependencies(deps map[string][]string) error {
+	// Sort names so that errors are reported consistently.
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, dep := range deps[name] {
+			if dep == name {
+				return fmt.Errorf("plugin %v cannot depend on itself", name)
+			}
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("plugin %v depends on plugin %v which is not being run", name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+
+	// visit does a depth-first walk of the graph, keeping track of the path so that
+	// the cycle can be reported to the user.
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("plugin dependencies form a cycle: %v", strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PluginDependencies returns the map of plugin names to their dependencies in the
+// format expected by ValidateDependencies.
+func PluginDependencies(plugins []Interface) map[string][]string {
+	deps := make(map[string][]string, len(plugins))
+	for _, p := range plugins {
+		deps[p.GetName()] = p.GetDependencies()
+	}
+	return deps
+}
@@ -0,0 +1,62 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		deps      map[string][]string
+		expectErr string
+	}{
+		{
+			desc: "No dependencies",
+			deps: map[string][]string{"a": nil, "b": nil},
+		}, {
+			desc: "Chain of dependencies",
+			deps: map[string][]string{"setup": nil, "e2e": {"setup"}, "teardown": {"e2e", "setup"}},
+		}, {
+			desc:      "Self dependency",
+			deps:      map[string][]string{"a": {"a"}},
+			expectErr: "plugin a cannot depend on itself",
+		}, {
+			desc:      "Missing dependency",
+			deps:      map[string][]string{"a": {"b"}},
+			expectErr: "plugin a depends on plugin b which is not being run",
+		}, {
+			desc:      "Cycle",
+			deps:      map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			expectErr: "plugin dependencies form a cycle: a -> b -> c -> a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ValidateDependencies(tc.deps)
+			switch {
+			case err == nil && len(tc.expectErr) > 0:
+				t.Fatalf("Expected error %q but got nil", tc.expectErr)
+			case err != nil && fmt.Sprint(err) != tc.expectErr:
+				t.Errorf("Expected error %q but got %q", tc.expectErr, err)
+			}
+		})
+	}
+}
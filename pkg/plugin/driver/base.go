@@ -23,6 +23,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
@@ -35,12 +36,16 @@ import (
 type Base struct {
 	Definition        manifest.Manifest
 	SessionID         string
+	RunID             string
 	Namespace         string
 	SonobuoyImage     string
 	CleanedUp         bool
 	ImagePullPolicy   string
 	ImagePullSecrets  string
 	CustomAnnotations map[string]string
+
+	// retryStates tracks the failed attempts of the plugin, keyed by node name.
+	retryStates map[string]*retryState
 }
 
 // GetSessionID returns the session id associated with the plugin.
@@ -48,6 +53,17 @@ func (b *Base) GetSessionID() string {
 	return b.SessionID
 }
 
+// SetSessionID sets the session id associated with the plugin. This allows an aggregator
+// to take over the plugin pods started by a previous one.
+func (b *Base) SetSessionID(id string) {
+	b.SessionID = id
+}
+
+// SetRunID sets the ID of the run the plugin belongs to, which its resources are labelled with.
+func (b *Base) SetRunID(id string) {
+	b.RunID = id
+}
+
 // GetName returns the name of this Job plugin.
 func (b *Base) GetName() string {
 	return b.Definition.SonobuoyConfig.PluginName
@@ -78,6 +94,11 @@ func (b *Base) GetResultFiles() []string {
 	return b.Definition.SonobuoyConfig.ResultFiles
 }
 
+// GetResultProcessor returns the command which processes the results of this plugin, if any.
+func (b *Base) GetResultProcessor() *manifest.ResultProcessor {
+	return b.Definition.SonobuoyConfig.ResultProcessor
+}
+
 // GetSourceURL returns the sourceURL of the plugin.
 func (b *Base) GetSourceURL() string {
 	return b.Definition.SonobuoyConfig.SourceURL
@@ -88,6 +109,50 @@ func (b *Base) GetDescription() string {
 	return b.Definition.SonobuoyConfig.Description
 }
 
+// GetDependencies returns the names of the plugins this plugin depends on.
+func (b *Base) GetDependencies() []string {
+	return b.Definition.SonobuoyConfig.DependsOn
+}
+
+// GetTimeout returns the timeout specific to this plugin or 0 if the aggregator's
+// default timeout should be used.
+func (b *Base) GetTimeout() time.Duration {
+	if b.Definition.SonobuoyConfig.Timeout == nil {
+		return 0
+	}
+	return b.Definition.SonobuoyConfig.Timeout.Duration
+}
+
+// GetRetries returns how many times a failed pod for this plugin should be replaced.
+func (b *Base) GetRetries() int {
+	return b.Definition.SonobuoyConfig.Retries
+}
+
+// GetBackoff returns how long to wait before the first retry of this plugin.
+func (b *Base) GetBackoff() time.Duration {
+	if b.Definition.SonobuoyConfig.Backoff == nil {
+		return 0
+	}
+	return b.Definition.SonobuoyConfig.Backoff.Duration
+}
+
+// OwnerReferences returns the owner references for the resources of a plugin started by the
+// given aggregator pod. A nil pod results in no owner references so that the resources are not
+// garbage collected along with the aggregator, as is needed for resumable runs.
+func OwnerReferences(ownerPod *v1.Pod) []metav1.OwnerReference {
+	if ownerPod == nil {
+		return nil
+	}
+	return []metav1.OwnerReference{
+		{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       ownerPod.GetName(),
+			UID:        ownerPod.GetUID(),
+		},
+	}
+}
+
 // MakeTLSSecret makes a Kubernetes secret object for the given TLS certificate.
 func (b *Base) MakeTLSSecret(cert *tls.Certificate, ownerPod *v1.Pod) (*v1.Secret, error) {
 	rsaKey, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
@@ -110,18 +175,17 @@ func (b *Base) MakeTLSSecret(cert *tls.Certificate, ownerPod *v1.Pod) (*v1.Secre
 		return nil, errors.Wrap(err, "couldn't PEM encode TLS key")
 	}
 
+	var labels map[string]string
+	if b.RunID != "" {
+		labels = map[string]string{plugin.RunIDLabel: b.RunID}
+	}
+
 	return &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      b.GetSecretName(),
-			Namespace: b.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "v1",
-					Kind:       "Pod",
-					Name:       ownerPod.GetName(),
-					UID:        ownerPod.GetUID(),
-				},
-			},
+			Name:            b.GetSecretName(),
+			Namespace:       b.Namespace,
+			Labels:          labels,
+			OwnerReferences: OwnerReferences(ownerPod),
 		},
 		Data: map[string][]byte{
 			v1.TLSPrivateKeyKey: keyPEM,
@@ -111,6 +111,31 @@ func TestMakeTLSSecret(t *testing.T) {
 	}
 }
 
+func TestMakeTLSSecret_noOwner(t *testing.T) {
+	auth, err := ca.NewAuthority()
+	if err != nil {
+		t.Fatalf("unexpected error %v making authority", err)
+	}
+	cert, err := auth.ClientKeyPair("")
+	if err != nil {
+		t.Fatalf("unexpected error %v making client pair", err)
+	}
+
+	driver := &Base{
+		Definition: manifest.Manifest{
+			SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "test-name"},
+		},
+	}
+
+	secret, err := driver.MakeTLSSecret(cert, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v making TLS Secret", err)
+	}
+	if len(secret.ObjectMeta.OwnerReferences) != 0 {
+		t.Errorf("expected secret to have no owner references, got %v", secret.ObjectMeta.OwnerReferences)
+	}
+}
+
 func TestSkipCleanup(t *testing.T) {
 	b := &Base{
 		Definition: manifest.Manifest{
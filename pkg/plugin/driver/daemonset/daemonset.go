@@ -125,20 +125,16 @@ func (p *Plugin) createDaemonSetDefinition(hostname string, cert *tls.Certificat
 		"sonobuoy-run":       p.SessionID,
 		"tier":               "analysis",
 	}
+	if p.RunID != "" {
+		labels[plugin.RunIDLabel] = p.RunID
+	}
 
 	ds.ObjectMeta = metav1.ObjectMeta{
-		Name:        fmt.Sprintf("sonobuoy-%s-daemon-set-%s", p.GetName(), p.SessionID),
-		Namespace:   p.Namespace,
-		Labels:      labels,
-		Annotations: annotations,
-		OwnerReferences: []metav1.OwnerReference{
-			{
-				APIVersion: "v1",
-				Kind:       "Pod",
-				Name:       ownerPod.GetName(),
-				UID:        ownerPod.GetUID(),
-			},
-		},
+		Name:            fmt.Sprintf("sonobuoy-%s-daemon-set-%s", p.GetName(), p.SessionID),
+		Namespace:       p.Namespace,
+		Labels:          labels,
+		Annotations:     annotations,
+		OwnerReferences: driver.OwnerReferences(ownerPod),
 	}
 
 	ds.Spec.Selector = &metav1.LabelSelector{
@@ -249,7 +245,7 @@ func (p *Plugin) findDaemonSet(kubeclient kubernetes.Interface) (*appsv1.DaemonS
 
 // Monitor adheres to plugin.Interface by ensuring the DaemonSet is correctly
 // configured and that each pod is running normally.
-func (p *Plugin) Monitor(ctx context.Context, kubeclient kubernetes.Interface, availableNodes []v1.Node, resultsCh chan<- *plugin.Result) {
+func (p *Plugin) Monitor(ctx context.Context, kubeclient kubernetes.Interface, availableNodes []v1.Node, timeout time.Duration, resultsCh chan<- *plugin.Result) {
 	availableNodes = p.filterByNodeSelector(availableNodes)
 	podsReported := make(map[string]bool)
 	podsFound := make(map[string]bool, len(availableNodes))
@@ -268,10 +264,10 @@ func (p *Plugin) Monitor(ctx context.Context, kubeclient kubernetes.Interface, a
 			// nodes have returned results to the aggregator. We can report the error for every node though
 			// since the aggregator will throw out duplicate results.
 			case ctx.Err() == context.DeadlineExceeded:
-				logrus.Errorf("Timeout waiting for plugin %v. Try checking the pod logs and other data in the results tarball for more information.", p.GetName())
+				logrus.Errorf("Timeout after %v waiting for plugin %v. Try checking the pod logs and other data in the results tarball for more information.", timeout, p.GetName())
 				errs := makeErrorResultsForNodes(
 					p.GetName(),
-					map[string]interface{}{"error": plugin.TimeoutErrMsg},
+					utils.TimeoutErrorData(p.GetName(), timeout),
 					availableNodes,
 				)
 				for _, e := range errs {
@@ -340,14 +336,39 @@ func (p *Plugin) monitorOnce(kubeclient kubernetes.Interface, availableNodes []v
 		}
 
 		podsFound[nodeName] = true
+
+		// Pods deleted in order to retry them may linger while they terminate.
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		// If the failed pod is waiting to be retried, delete it once the backoff has
+		// elapsed; the DaemonSet controller will create a new one in its place.
+		if attempt, due := p.PendingRetry(nodeName); attempt > 0 {
+			if due {
+				if err := p.deletePod(kubeclient, &pod); err != nil {
+					errlog.LogError(errors.Wrapf(err, "could not retry plugin %v on node %v, will try again", p.GetName(), nodeName))
+					continue
+				}
+				p.RetryStarted(nodeName)
+			}
+			continue
+		}
+
 		// Check if it's failing and submit the error result
 		if isFailing, reason := utils.IsPodFailing(&pod); isFailing {
-			podsReported[nodeName] = true
-
-			retErrs = append(retErrs, utils.MakeErrorResult(p.GetName(), map[string]interface{}{
+			errData := map[string]interface{}{
 				"error": reason,
 				"pod":   pod,
-			}, nodeName))
+			}
+			if attempt, retry := p.FailedAttempt(nodeName); retry {
+				logrus.Warnf("Attempt %v of plugin %v failed on node %v, it will be retried: %v", attempt, p.GetName(), nodeName, reason)
+				retErrs = append(retErrs, utils.MakeAttemptErrorResult(p.GetName(), attempt, errData, nodeName))
+				continue
+			}
+
+			podsReported[nodeName] = true
+			retErrs = append(retErrs, utils.MakeErrorResult(p.GetName(), errData, nodeName))
 		}
 	}
 
@@ -378,6 +399,13 @@ func (p *Plugin) monitorOnce(kubeclient kubernetes.Interface, availableNodes []v
 	return false, retErrs
 }
 
+// deletePod deletes one of the pods created by the DaemonSet so that it will be replaced.
+func (p *Plugin) deletePod(kubeclient kubernetes.Interface, pod *v1.Pod) error {
+	gracePeriod := int64(0)
+	err := kubeclient.CoreV1().Pods(p.Namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+	return errors.Wrapf(err, "could not delete pod %v", pod.Name)
+}
+
 func makeErrorResultsForNodes(resultType string, errdata map[string]interface{}, nodes []v1.Node) []*plugin.Result {
 	results := []*plugin.Result{}
 	for _, n := range nodes {
@@ -17,6 +17,7 @@ limitations under the License.
 package daemonset
 
 import (
+	"context"
 	"crypto/sha1"
 	"crypto/tls"
 	"encoding/pem"
@@ -541,6 +542,60 @@ func TestMonitorOnce(t *testing.T) {
 	}
 }
 
+func TestMonitorOnceRetries(t *testing.T) {
+	p := &Plugin{driver.Base{
+		Definition: manifest.Manifest{
+			SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "myPlugin", Retries: 1},
+		},
+		SessionID: "abc",
+		Namespace: expectedNamespace,
+	}}
+	labels := map[string]string{"sonobuoy-run": "abc"}
+	pod := func(name, node string, failing bool) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: expectedNamespace, Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+		if failing {
+			pod.Status.Conditions = []corev1.PodCondition{{Reason: "Unschedulable", Message: "conditionMsg"}}
+		}
+		return pod
+	}
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+	}
+	fclient := fake.NewSimpleClientset(
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "ds", Namespace: expectedNamespace, Labels: labels}},
+		pod("pod1", "node1", true),
+		pod("pod2", "node2", false),
+	)
+	foundmap, reportedmap := map[string]bool{}, map[string]bool{}
+
+	// The first failure is reported as an attempt for that node only.
+	_, errResults := p.monitorOnce(fclient, nodes, foundmap, reportedmap)
+	if len(errResults) != 1 || errResults[0].Attempt != 1 || errResults[0].NodeName != "node1" {
+		t.Fatalf("Expected a single error for attempt 1 on node1 but got %+v", errResults)
+	}
+
+	// The failed pod is then deleted so the DaemonSet will replace it.
+	if _, errResults = p.monitorOnce(fclient, nodes, foundmap, reportedmap); len(errResults) != 0 {
+		t.Fatalf("Expected no errors while retrying but got %+v", errResults)
+	}
+	if _, err := fclient.CoreV1().Pods(expectedNamespace).Get(context.TODO(), "pod1", metav1.GetOptions{}); err == nil {
+		t.Fatalf("Expected failed pod to be deleted")
+	}
+
+	// Once retries are exhausted the failure is the node's result.
+	if _, err := fclient.CoreV1().Pods(expectedNamespace).Create(context.TODO(), pod("pod1-new", "node1", true), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Unexpected error creating pod: %v", err)
+	}
+	_, errResults = p.monitorOnce(fclient, nodes, foundmap, reportedmap)
+	if len(errResults) != 1 || errResults[0].Attempt != 0 || errResults[0].NodeName != "node1" {
+		t.Fatalf("Expected a final error on node1 but got %+v", errResults)
+	}
+}
+
 func TestExpectedResults(t *testing.T) {
 	testNodes := []corev1.Node{
 		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
@@ -91,20 +91,16 @@ func (p *Plugin) createPodDefinition(hostname string, cert *tls.Certificate, own
 		"sonobuoy-run":       p.SessionID,
 		"tier":               "analysis",
 	}
+	if p.RunID != "" {
+		labels[plugin.RunIDLabel] = p.RunID
+	}
 
 	pod.ObjectMeta = metav1.ObjectMeta{
-		Name:        fmt.Sprintf("sonobuoy-%s-job-%s", p.GetName(), p.SessionID),
-		Namespace:   p.Namespace,
-		Labels:      labels,
-		Annotations: annotations,
-		OwnerReferences: []metav1.OwnerReference{
-			{
-				APIVersion: "v1",
-				Kind:       "Pod",
-				Name:       ownerPod.GetName(),
-				UID:        ownerPod.GetUID(),
-			},
-		},
+		Name:            p.podName(),
+		Namespace:       p.Namespace,
+		Labels:          labels,
+		Annotations:     annotations,
+		OwnerReferences: driver.OwnerReferences(ownerPod),
 	}
 
 	var podSpec v1.PodSpec
@@ -163,7 +159,7 @@ func (p *Plugin) Run(kubeclient kubernetes.Interface, hostname string, cert *tls
 // Monitor adheres to plugin.Interface by ensuring the pod created by the job
 // doesn't have any unrecoverable failures. It closes the results channel when
 // it is done.
-func (p *Plugin) Monitor(ctx context.Context, kubeclient kubernetes.Interface, _ []v1.Node, resultsCh chan<- *plugin.Result) {
+func (p *Plugin) Monitor(ctx context.Context, kubeclient kubernetes.Interface, _ []v1.Node, timeout time.Duration, resultsCh chan<- *plugin.Result) {
 	defer close(resultsCh)
 	for {
 		// Sleep between each poll, which should give the Job
@@ -173,12 +169,8 @@ func (p *Plugin) Monitor(ctx context.Context, kubeclient kubernetes.Interface, _
 		case <-ctx.Done():
 			switch {
 			case ctx.Err() == context.DeadlineExceeded:
-				logrus.Errorf("Timeout waiting for plugin %v. Try checking the pod logs and other data in the results tarball for more information.", p.GetName())
-				resultsCh <- utils.MakeErrorResult(
-					p.GetName(),
-					map[string]interface{}{"error": plugin.TimeoutErrMsg},
-					plugin.GlobalResult,
-				)
+				logrus.Errorf("Timeout after %v waiting for plugin %v. Try checking the pod logs and other data in the results tarball for more information.", timeout, p.GetName())
+				resultsCh <- utils.MakeTimeoutErrorResult(p.GetName(), timeout, plugin.GlobalResult)
 			case ctx.Err() == context.Canceled:
 				// Do nothing, just stop.
 			case ctx.Err() != nil:
@@ -216,17 +208,66 @@ func (p *Plugin) monitorOnce(kubeclient kubernetes.Interface, _ []v1.Node) (done
 		return false, nil
 	}
 
+	// If a failed pod is waiting to be retried, replace it once the backoff has elapsed.
+	if attempt, due := p.PendingRetry(plugin.GlobalResult); attempt > 0 {
+		if due {
+			if err := p.retryPod(kubeclient, pod, attempt); err != nil {
+				errlog.LogError(errors.Wrapf(err, "could not retry plugin %v, will try again", p.GetName()))
+				return false, nil
+			}
+			p.RetryStarted(plugin.GlobalResult)
+		}
+		return false, nil
+	}
+
 	// Make sure the pod isn't failing
 	if isFailing, reason := utils.IsPodFailing(pod); isFailing {
-		return true, utils.MakeErrorResult(p.GetName(), map[string]interface{}{
+		errData := map[string]interface{}{
 			"error": reason,
 			"pod":   pod,
-		}, plugin.GlobalResult)
+		}
+		if attempt, retry := p.FailedAttempt(plugin.GlobalResult); retry {
+			logrus.Warnf("Attempt %v of plugin %v failed, it will be retried: %v", attempt, p.GetName(), reason)
+			return false, utils.MakeAttemptErrorResult(p.GetName(), attempt, errData, plugin.GlobalResult)
+		}
+		return true, utils.MakeErrorResult(p.GetName(), errData, plugin.GlobalResult)
 	}
 
 	return false, nil
 }
 
+// retryPod replaces the failed pod from the given attempt with a new pod using the same spec.
+// The new pod is created before the failed one is deleted so that a failure part way through
+// can simply be retried.
+func (p *Plugin) retryPod(kubeclient kubernetes.Interface, failed *v1.Pod, attempt int) error {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("%s-retry-%d", p.podName(), attempt),
+			Namespace:       failed.Namespace,
+			Labels:          failed.Labels,
+			Annotations:     failed.Annotations,
+			OwnerReferences: failed.OwnerReferences,
+		},
+		Spec: *failed.Spec.DeepCopy(),
+	}
+	// Let the scheduler choose a node again in case the problem was node-specific.
+	pod.Spec.NodeName = ""
+
+	if _, err := kubeclient.CoreV1().Pods(p.Namespace).Create(context.TODO(), &pod, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "could not create pod %v", pod.Name)
+	}
+
+	gracePeriod := int64(0)
+	if err := kubeclient.CoreV1().Pods(p.Namespace).Delete(context.TODO(), failed.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
+		errlog.LogError(errors.Wrapf(err, "could not delete failed pod %v for plugin %v", failed.Name, p.GetName()))
+	}
+	return nil
+}
+
+func (p *Plugin) podName() string {
+	return fmt.Sprintf("sonobuoy-%s-job-%s", p.GetName(), p.SessionID)
+}
+
 // Cleanup cleans up the k8s Job and ConfigMap created by this plugin instance
 func (p *Plugin) Cleanup(kubeclient kubernetes.Interface) {
 	p.CleanedUp = true
@@ -264,17 +305,28 @@ func (p *Plugin) listOptions() metav1.ListOptions {
 }
 
 // findPod finds the pod created by this plugin, using a kubernetes label
-// search.  If no pod is found, or if multiple pods are found, returns an
-// error.
+// search. Pods which are being deleted (e.g. failed pods which were retried) are
+// ignored and if multiple pods remain, the newest is returned. If no pod is found,
+// returns an error.
 func (p *Plugin) findPod(kubeclient kubernetes.Interface) (*v1.Pod, error) {
 	pods, err := kubeclient.CoreV1().Pods(p.Namespace).List(context.TODO(), p.listOptions())
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	if len(pods.Items) != 1 {
+	var found *v1.Pod
+	for i, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if found == nil || found.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			found = &pods.Items[i]
+		}
+	}
+
+	if found == nil {
 		return nil, errors.Errorf("no pods were created by plugin %v", p.GetName())
 	}
 
-	return &pods.Items[0], nil
+	return found, nil
 }
@@ -20,6 +20,7 @@ import (
 	"context"
 	"crypto/sha1"
 	"crypto/tls"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -444,6 +445,62 @@ func TestMonitorOnce(t *testing.T) {
 	}
 }
 
+func TestMonitorOnceRetries(t *testing.T) {
+	p := &Plugin{driver.Base{
+		Definition: manifest.Manifest{
+			SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "myplugin", Retries: 1},
+		},
+		SessionID: "abc",
+		Namespace: expectedNamespace,
+	}}
+	failingStatus := corev1.PodStatus{
+		Conditions: []corev1.PodCondition{
+			{Reason: "Unschedulable", Message: "conditionMsg"},
+		},
+	}
+	fclient := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.podName(),
+			Namespace: expectedNamespace,
+			Labels:    map[string]string{"sonobuoy-run": "abc"},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node1"},
+		Status: failingStatus,
+	})
+
+	// The first failure is reported as an attempt and the plugin keeps being monitored.
+	done, errResult := p.monitorOnce(fclient, nil)
+	if done || errResult == nil || errResult.Attempt != 1 {
+		t.Fatalf("Expected error result for attempt 1 and monitoring to continue, got done %v, result %+v", done, errResult)
+	}
+
+	// The failed pod is then replaced.
+	if done, errResult = p.monitorOnce(fclient, nil); done || errResult != nil {
+		t.Fatalf("Expected pod to be retried without error, got done %v, result %+v", done, errResult)
+	}
+	pods, err := fclient.CoreV1().Pods(expectedNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error listing pods: %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != p.podName()+"-retry-1" {
+		t.Fatalf("Expected failed pod to be replaced by %v-retry-1 but got %+v", p.podName(), pods.Items)
+	}
+	if pods.Items[0].Spec.NodeName != "" {
+		t.Errorf("Expected retried pod to be rescheduled but it was assigned to node %q", pods.Items[0].Spec.NodeName)
+	}
+
+	// Once retries are exhausted the failure is the plugin's result.
+	retried := pods.Items[0]
+	retried.Status = failingStatus
+	if _, err := fclient.CoreV1().Pods(expectedNamespace).Update(context.TODO(), &retried, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Unexpected error updating pod: %v", err)
+	}
+	done, errResult = p.monitorOnce(fclient, nil)
+	if !done || errResult == nil || errResult.Attempt != 0 {
+		t.Fatalf("Expected final error result and monitoring to stop, got done %v, result %+v", done, errResult)
+	}
+}
+
 func TestMonitor(t *testing.T) {
 	// For these tests ensure sleeping is fast; choosing non-zero we know which
 	// branch of select may be chosen first.
@@ -522,7 +579,7 @@ func TestMonitor(t *testing.T) {
 					cancel()
 				}()
 			}
-			go p.Monitor(ctx, fclient, nil, ch)
+			go p.Monitor(ctx, fclient, nil, time.Hour, ch)
 
 			count := 0
 			for range ch {
@@ -538,3 +595,50 @@ func TestMonitor(t *testing.T) {
 		})
 	}
 }
+
+func TestMonitorReportsConfiguredTimeout(t *testing.T) {
+	// A plugin resumed by a restarted aggregator may have little or none of its timeout left but
+	// its error still reports the timeout it was configured with.
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	p := &Plugin{Base: driver.Base{Definition: manifest.Manifest{SonobuoyConfig: manifest.SonobuoyConfig{PluginName: "e2e"}}}}
+	ch := make(chan (*plugin.Result), 1)
+	go p.Monitor(ctx, fake.NewSimpleClientset(), nil, 2*time.Hour, ch)
+
+	results := []*plugin.Result{}
+	for r := range ch {
+		results = append(results, r)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected a timeout error result but got %v", results)
+	}
+	var data map[string]interface{}
+	if err := json.NewDecoder(results[0].Body).Decode(&data); err != nil {
+		t.Fatalf("Could not decode error result: %v", err)
+	}
+	if data["timeout"] != "2h0m0s" {
+		t.Errorf("Expected the configured timeout to be reported but got %v", data["timeout"])
+	}
+}
+
+func TestCreatePodDefinitionSetsRunID(t *testing.T) {
+	m := manifest.Manifest{
+		SonobuoyConfig: manifest.SonobuoyConfig{
+			PluginName: "test-job",
+		},
+		Spec: manifest.Container{Container: corev1.Container{}},
+	}
+	testPlugin := NewPlugin(m, expectedNamespace, expectedImageName, "Always", "image-pull-secret", map[string]string{})
+	testPlugin.SetRunID("abc")
+
+	clientCert, err := createClientCertificate("test-job")
+	if err != nil {
+		t.Fatalf("couldn't create client certificate: %v", err)
+	}
+
+	pod := testPlugin.createPodDefinition("", clientCert, &corev1.Pod{}, "")
+	if got := pod.ObjectMeta.Labels[plugin.RunIDLabel]; got != "abc" {
+		t.Errorf("Expected label %v to be %q, got %q", plugin.RunIDLabel, "abc", got)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"time"
+)
+
+// maxRetryBackoff is the longest the backoff between retries grows to by doubling. A plugin
+// configured with a longer backoff still waits that long.
+const maxRetryBackoff = time.Hour
+
+// retryState is the retry bookkeeping for a plugin on a single node.
+type retryState struct {
+	// attempts is the number of failed attempts which have been retried (or are about to be).
+	attempts int
+
+	// pending is set between a failure being recorded and the replacement pod being started.
+	pending bool
+
+	// retryAt is when the pending retry may be started.
+	retryAt time.Time
+}
+
+func (b *Base) retryStateFor(node string) *retryState {
+	if b.retryStates == nil {
+		b.retryStates = map[string]*retryState{}
+	}
+	if _, ok := b.retryStates[node]; !ok {
+		b.retryStates[node] = &retryState{}
+	}
+	return b.retryStates[node]
+}
+
+// FailedAttempt records that the plugin failed on the given node. If the plugin's retry
+// policy allows another attempt, it returns the number of the failed attempt and true; the
+// driver should then replace the pod once PendingRetry reports it is due. Otherwise it
+// returns false and the failure should be reported as the plugin's result.
+func (b *Base) FailedAttempt(node string) (int, bool) {
+	state := b.retryStateFor(node)
+	if state.attempts >= b.GetRetries() {
+		return state.attempts, false
+	}
+
+	state.attempts++
+	state.pending = true
+	state.retryAt = time.Now().Add(retryBackoff(b.GetBackoff(), state.attempts))
+	return state.attempts, true
+}
+
+// retryBackoff returns how long to wait before retrying the given attempt. The backoff doubles
+// with each attempt until it reaches maxRetryBackoff so that it can't overflow.
+func retryBackoff(backoff time.Duration, attempt int) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+	return backoff
+}
+
+// PendingRetry returns the number of the failed attempt which is waiting to be retried on the
+// node (0 if there is none) and whether or not its backoff has elapsed.
+func (b *Base) PendingRetry(node string) (attempt int, due bool) {
+	state := b.retryStateFor(node)
+	if !state.pending {
+		return 0, false
+	}
+	return state.attempts, !time.Now().Before(state.retryAt)
+}
+
+// RetryStarted marks the pending retry on the node as started.
+func (b *Base) RetryStarted(node string) {
+	b.retryStateFor(node).pending = false
+}
@@ -0,0 +1,113 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFailedAttempt(t *testing.T) {
+	newBase := func(retries int, backoff time.Duration) *Base {
+		return &Base{
+			Definition: manifest.Manifest{
+				SonobuoyConfig: manifest.SonobuoyConfig{
+					PluginName: "p",
+					Retries:    retries,
+					Backoff:    &metav1.Duration{Duration: backoff},
+				},
+			},
+		}
+	}
+
+	t.Run("No retries by default", func(t *testing.T) {
+		b := &Base{}
+		if _, retry := b.FailedAttempt("node"); retry {
+			t.Errorf("Expected no retry without a retry policy")
+		}
+	})
+
+	t.Run("Retries are limited and tracked per node", func(t *testing.T) {
+		b := newBase(2, 0)
+		for i := 1; i <= 2; i++ {
+			attempt, retry := b.FailedAttempt("node1")
+			if !retry || attempt != i {
+				t.Fatalf("Expected retry of attempt %v but got attempt %v, retry %v", i, attempt, retry)
+			}
+			if pending, due := b.PendingRetry("node1"); pending != i || !due {
+				t.Fatalf("Expected attempt %v to be due but got attempt %v, due %v", i, pending, due)
+			}
+			b.RetryStarted("node1")
+			if pending, _ := b.PendingRetry("node1"); pending != 0 {
+				t.Fatalf("Expected no pending retry after it started but got attempt %v", pending)
+			}
+		}
+		if _, retry := b.FailedAttempt("node1"); retry {
+			t.Errorf("Expected no more retries for node1")
+		}
+		if attempt, retry := b.FailedAttempt("node2"); !retry || attempt != 1 {
+			t.Errorf("Expected node2 to be retried independently but got attempt %v, retry %v", attempt, retry)
+		}
+	})
+
+	t.Run("Backoff of many retries doesn't overflow", func(t *testing.T) {
+		b := newBase(40, 10*time.Second)
+		for i := 1; i <= 40; i++ {
+			b.FailedAttempt("node")
+			b.RetryStarted("node")
+		}
+		state := b.retryStateFor("node")
+		if wait := time.Until(state.retryAt); wait <= 0 || wait > maxRetryBackoff {
+			t.Errorf("Expected the backoff of attempt 40 to be capped at %v but had to wait %v", maxRetryBackoff, wait)
+		}
+	})
+
+	t.Run("Retry is not due until backoff elapses", func(t *testing.T) {
+		b := newBase(1, time.Hour)
+		b.FailedAttempt("node")
+		if attempt, due := b.PendingRetry("node"); attempt != 1 || due {
+			t.Errorf("Expected attempt 1 to be pending but not due, got attempt %v, due %v", attempt, due)
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		backoff  time.Duration
+		attempt  int
+		expected time.Duration
+	}{
+		{desc: "First attempt", backoff: 10 * time.Second, attempt: 1, expected: 10 * time.Second},
+		{desc: "Doubles with each attempt", backoff: 10 * time.Second, attempt: 3, expected: 40 * time.Second},
+		{desc: "Capped once it would pass the maximum", backoff: 10 * time.Second, attempt: 10, expected: maxRetryBackoff},
+		{desc: "Large attempt counts stay capped", backoff: 10 * time.Second, attempt: 1000, expected: maxRetryBackoff},
+		{desc: "Longer configured backoff is kept", backoff: 2 * time.Hour, attempt: 5, expected: 2 * time.Hour},
+		{desc: "No backoff", attempt: 40, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := retryBackoff(tc.backoff, tc.attempt); got != tc.expected {
+				t.Errorf("Expected backoff %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
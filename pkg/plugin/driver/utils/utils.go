@@ -115,3 +115,27 @@ func MakeErrorResult(resultType string, errdata map[string]interface{}, nodeName
 		Filename:   "error.json",
 	}
 }
+
+// MakeAttemptErrorResult constructs the error result for a failed attempt to run a plugin
+// which is going to be retried. It is saved separately from the plugin's final result.
+func MakeAttemptErrorResult(resultType string, attempt int, errdata map[string]interface{}, nodeName string) *plugin.Result {
+	r := MakeErrorResult(resultType, errdata, nodeName)
+	r.Attempt = attempt
+	return r
+}
+
+// MakeTimeoutErrorResult constructs the error result used when a plugin fails to report
+// results before its timeout. The plugin name and the timeout are recorded alongside the
+// error so users can see which plugin timed out and after how long.
+func MakeTimeoutErrorResult(resultType string, timeout time.Duration, nodeName string) *plugin.Result {
+	return MakeErrorResult(resultType, TimeoutErrorData(resultType, timeout), nodeName)
+}
+
+// TimeoutErrorData returns the error data saved when the given plugin times out.
+func TimeoutErrorData(pluginName string, timeout time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"error":   plugin.TimeoutErrMsg,
+		"plugin":  pluginName,
+		"timeout": timeout.String(),
+	}
+}
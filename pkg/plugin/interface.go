@@ -19,6 +19,7 @@ package plugin
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"io"
 	"path"
 	"time"
@@ -48,8 +49,9 @@ type Interface interface {
 	// plugin (either because it won't schedule, or the image won't
 	// download, too many failed executions, etc) and sends the errors as
 	// Result objects through the provided channel. It should return once the context
-	// is cancelled.
-	Monitor(ctx context.Context, kubeClient kubernetes.Interface, availableNodes []v1.Node, resultsCh chan<- *Result)
+	// is cancelled. The timeout is the one configured for the plugin, which is reported
+	// if the context's deadline passes; a resumed plugin's deadline may be sooner.
+	Monitor(ctx context.Context, kubeClient kubernetes.Interface, availableNodes []v1.Node, timeout time.Duration, resultsCh chan<- *Result)
 
 	// ExpectedResults is an array of Result objects that a plugin should
 	// expect to submit.
@@ -74,6 +76,14 @@ type Interface interface {
 
 	// GetSourceURL returns the URL where the plugin came from and where updates to it will be located.
 	GetSourceURL() string
+
+	// GetDependencies returns the names of the plugins which must complete successfully
+	// before this plugin can be run.
+	GetDependencies() []string
+
+	// GetTimeout returns how long the aggregator should wait for results from this plugin.
+	// A value of 0 means the aggregator's default timeout is used.
+	GetTimeout() time.Duration
 }
 
 // ExpectedResult is an expected result that a plugin will submit.  This is so
@@ -93,6 +103,17 @@ type Result struct {
 	Filename   string
 	Body       io.Reader
 	Error      string
+
+	// Attempt is set on the error from a failed attempt to run the plugin which is going to
+	// be retried. Such results are saved for debugging but are not the plugin's result.
+	Attempt int
+
+	// Digest is the hex encoded SHA-256 digest of the Body as sent by the worker, if known.
+	Digest string
+
+	// FileDigests are the hex encoded SHA-256 digests of the files inside a tarball result,
+	// keyed by their path within the tarball, if known.
+	FileDigests map[string]string
 }
 
 // ProgressUpdate is the structure that the Sonobuoy worker sends to the aggregator
@@ -128,12 +149,20 @@ func (r *Result) IsTimeout() bool {
 // this Result should be stored, not including a file extension.
 func (r *Result) Path() string {
 	if !r.IsSuccess() {
+		if r.Attempt > 0 {
+			return path.Join(r.ResultType, "errors", AttemptDir(r.Attempt), r.NodeName)
+		}
 		return path.Join(r.ResultType, "errors", r.NodeName)
 	}
 
 	return path.Join(r.ResultType, "results", r.NodeName)
 }
 
+// AttemptDir returns the name of the directory holding the errors from the given attempt.
+func AttemptDir(attempt int) string {
+	return fmt.Sprintf("%v%d", AttemptDirPrefix, attempt)
+}
+
 // Selection is the user specified input to load and initialize plugins
 type Selection struct {
 	Name string `json:"name"`
@@ -145,6 +174,26 @@ type AggregationConfig struct {
 	BindPort         int    `json:"bindport"`
 	AdvertiseAddress string `json:"advertiseaddress"`
 	TimeoutSeconds   int    `json:"timeoutseconds"`
+
+	// Resumable causes the aggregator to checkpoint its state to the results directory so that,
+	// if the aggregator pod is restarted, it can resume the run rather than starting over.
+	Resumable bool `json:"resumable,omitempty"`
+
+	// StorageSize is the size of the volume claimed for the results of resumable runs. Defaults
+	// to 1Gi.
+	StorageSize string `json:"storagesize,omitempty"`
+
+	// StorageClassName is the storage class of the volume claimed for the results of resumable
+	// runs. The default storage class of the cluster is used if it isn't set.
+	StorageClassName string `json:"storageclassname,omitempty"`
+
+	// MetricsPort, if set, is the port on which the aggregator serves Prometheus metrics about the
+	// run over plain HTTP.
+	MetricsPort int `json:"metricsport,omitempty"`
+
+	// ResultsPort, if set, is the port on which the aggregator serves the results over HTTPS once
+	// they are ready, so that they can be retrieved without exec'ing into its pod.
+	ResultsPort int `json:"resultsport,omitempty"`
 }
 
 // WorkerConfig is the file given to the sonobuoy worker to configure it to phone home.
@@ -18,6 +18,7 @@ package manifest
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kuberuntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -43,6 +44,10 @@ type SonobuoyConfig struct {
 	// to avoid automatically targeting other files or failing to target this one due to heuristics.
 	ResultFiles []string `json:"result-files,omitempty"`
 
+	// ResultProcessor is the command which processes the results of a plugin whose ResultFormat
+	// is external. If unset, the plugin is expected to process its own results (e.g. in a sidecar).
+	ResultProcessor *ResultProcessor `json:"result-processor,omitempty"`
+
 	// Description is an optional, human-readable description for the plugin.
 	Description string `json:"description,omitempty"`
 
@@ -50,18 +55,63 @@ type SonobuoyConfig struct {
 	// to the plugin source would be kept.
 	SourceURL string `json:"source-url,omitempty"`
 
+	// DependsOn is an optional list of plugin names which must report results before
+	// this plugin is started. If any of them fail, this plugin will not be run.
+	DependsOn []string `json:"depends-on,omitempty"`
+
+	// Timeout is an optional duration (e.g. "30m") after which the aggregator will stop waiting
+	// for results from this plugin. If unset, the aggregator's global timeout is used.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Retries is the number of times a failing pod for this plugin will be replaced before
+	// the failure is reported as the plugin's result. For DaemonSet plugins, this applies
+	// to each node separately.
+	Retries int `json:"retries,omitempty"`
+
+	// Backoff is how long to wait before replacing a failed pod. It doubles after each retry,
+	// up to an hour.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
 	objectKind
 }
 
 // DeepCopy makes a deep copy (needed by DeepCopyObject)
 func (s *SonobuoyConfig) DeepCopy() *SonobuoyConfig {
 	return &SonobuoyConfig{
-		Driver:       s.Driver,
-		PluginName:   s.PluginName,
-		ResultFormat: s.ResultFormat,
-		ResultFiles:  s.ResultFiles,
-		SkipCleanup:  s.SkipCleanup,
-		objectKind:   objectKind{s.objectKind.gvk},
+		Driver:          s.Driver,
+		PluginName:      s.PluginName,
+		ResultFormat:    s.ResultFormat,
+		ResultFiles:     s.ResultFiles,
+		ResultProcessor: s.ResultProcessor.DeepCopy(),
+		SkipCleanup:     s.SkipCleanup,
+		DependsOn:       append([]string(nil), s.DependsOn...),
+		Timeout:         s.Timeout.DeepCopy(),
+		Retries:         s.Retries,
+		Backoff:         s.Backoff.DeepCopy(),
+		objectKind:      objectKind{s.objectKind.gvk},
+	}
+}
+
+// ResultProcessor is a command, run by the aggregator, which turns the raw results of a plugin
+// into the Sonobuoy results format.
+type ResultProcessor struct {
+	// Command is the command and its arguments. It must exist in the aggregator image. The
+	// directory holding the raw results is appended as the last argument and the results are
+	// read from its stdout.
+	Command []string `json:"command"`
+
+	// Timeout is how long the command may run. If unset, a default of 5 minutes is used.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// DeepCopy makes a deep copy of the ResultProcessor.
+func (r *ResultProcessor) DeepCopy() *ResultProcessor {
+	if r == nil {
+		return nil
+	}
+	return &ResultProcessor{
+		Command: append([]string(nil), r.Command...),
+		Timeout: r.Timeout.DeepCopy(),
 	}
 }
 
@@ -0,0 +1,44 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSonobuoyConfigDeepCopy(t *testing.T) {
+	original := &SonobuoyConfig{
+		PluginName:      "e2e",
+		DependsOn:       []string{"setup", "prepare"},
+		ResultProcessor: &ResultProcessor{Command: []string{"process"}},
+	}
+	copied := original.DeepCopy()
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("Expected copy %+v to equal the original %+v", copied, original)
+	}
+
+	copied.DependsOn[0] = "other"
+	copied.DependsOn = append(copied.DependsOn, "more")
+	copied.ResultProcessor.Command[0] = "other"
+	if want := []string{"setup", "prepare"}; !reflect.DeepEqual(original.DependsOn, want) {
+		t.Errorf("Expected the original to depend on %v after changing the copy, got %v", want, original.DependsOn)
+	}
+	if want := []string{"process"}; !reflect.DeepEqual(original.ResultProcessor.Command, want) {
+		t.Errorf("Expected the original result processor %v after changing the copy, got %v", want, original.ResultProcessor.Command)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package signature signs and verifies Sonobuoy results. Signatures are made over the
+// SHA-256 digest of the data so that large archives can be streamed rather than held in
+// memory, and are stored base64 encoded in detached signature files.
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// FileSuffix is appended to the name of a file to get the name of its detached signature.
+const FileSuffix = ".sig"
+
+// ParsePrivateKey parses a PEM encoded private key. PKCS #8 (including Ed25519), EC and
+// PKCS #1 RSA keys are supported.
+func ParsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found in private key")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse private key")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// ParsePublicKey parses a PEM encoded public key, certificate or private key and returns the
+// public key to verify signatures with.
+func ParsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found in public key")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return key, errors.Wrap(err, "couldn't parse public key")
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't parse certificate")
+		}
+		return cert.PublicKey, nil
+	default:
+		signer, err := ParsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+}
+
+// Sign returns the base64 encoded signature of the data read from r.
+func Sign(key crypto.Signer, r io.Reader) ([]byte, error) {
+	digest, err := digestOf(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		// Ed25519 signs the message itself, which here is the digest.
+		opts = crypto.Hash(0)
+	}
+	sig, err := key.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't sign data")
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
+	base64.StdEncoding.Encode(encoded, sig)
+	return append(encoded, '\n'), nil
+}
+
+// Verify checks the base64 encoded signature of the data read from r.
+func Verify(key crypto.PublicKey, r io.Reader, encodedSig []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encodedSig)))
+	if err != nil {
+		return errors.Wrap(err, "couldn't decode signature")
+	}
+	digest, err := digestOf(r)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(k, digest, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, digest, sig)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return errors.New("signature is not valid")
+	}
+	return nil
+}
+
+func digestOf(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, errors.Wrap(err, "couldn't read data to sign")
+	}
+	return h.Sum(nil), nil
+}
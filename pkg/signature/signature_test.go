@@ -0,0 +1,154 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func encodeKey(t *testing.T, pemType string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Could not marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecDER, ecErr := x509.MarshalECPrivateKey(ecKey)
+	pkcs8EC, pkcs8ECErr := x509.MarshalPKCS8PrivateKey(ecKey)
+	pkcs8ED, pkcs8EDErr := x509.MarshalPKCS8PrivateKey(edKey)
+
+	testCases := []struct {
+		desc   string
+		key    crypto.Signer
+		keyPEM []byte
+	}{
+		{
+			desc:   "EC key",
+			key:    ecKey,
+			keyPEM: encodeKey(t, "EC PRIVATE KEY", ecDER, ecErr),
+		}, {
+			desc:   "PKCS #8 EC key",
+			key:    ecKey,
+			keyPEM: encodeKey(t, "PRIVATE KEY", pkcs8EC, pkcs8ECErr),
+		}, {
+			desc:   "RSA key",
+			key:    rsaKey,
+			keyPEM: encodeKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), nil),
+		}, {
+			desc:   "PKCS #8 Ed25519 key",
+			key:    edKey,
+			keyPEM: encodeKey(t, "PRIVATE KEY", pkcs8ED, pkcs8EDErr),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			signer, err := ParsePrivateKey(tc.keyPEM)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing private key: %v", err)
+			}
+
+			sig, err := Sign(signer, strings.NewReader("results"))
+			if err != nil {
+				t.Fatalf("Unexpected error signing: %v", err)
+			}
+
+			pubDER, err := x509.MarshalPKIXPublicKey(tc.key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := ParsePublicKey(encodeKey(t, "PUBLIC KEY", pubDER, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error parsing public key: %v", err)
+			}
+
+			if err := Verify(pub, strings.NewReader("results"), sig); err != nil {
+				t.Errorf("Expected signature to verify but got %v", err)
+			}
+			if err := Verify(pub, strings.NewReader("tampered"), sig); err == nil {
+				t.Error("Expected signature of different data not to verify")
+			}
+
+			// The public key can also be taken from the private key.
+			fromPrivate, err := ParsePublicKey(tc.keyPEM)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing public key from private key: %v", err)
+			}
+			if err := Verify(fromPrivate, strings.NewReader("results"), sig); err != nil {
+				t.Errorf("Expected signature to verify with public key from private key but got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerify_wrongKey(t *testing.T) {
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := Sign(signer, strings.NewReader("results"))
+	if err != nil {
+		t.Fatalf("Unexpected error signing: %v", err)
+	}
+	if err := Verify(other.Public(), strings.NewReader("results"), sig); err == nil {
+		t.Error("Expected signature not to verify with a different key")
+	}
+	if err := Verify(signer.Public(), strings.NewReader("results"), []byte("not base64!")); err == nil {
+		t.Error("Expected invalid signature encoding to fail")
+	}
+}
+
+func TestParseKeys_invalid(t *testing.T) {
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("Expected error parsing non-PEM private key")
+	}
+	if _, err := ParsePublicKey([]byte("not a key")); err == nil {
+		t.Error("Expected error parsing non-PEM public key")
+	}
+	if _, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")})); err == nil {
+		t.Error("Expected error parsing invalid private key")
+	}
+}
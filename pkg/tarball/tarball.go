@@ -19,6 +19,8 @@ package tarball
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
 	"path"
@@ -89,6 +91,40 @@ func DecodeTarball(reader io.Reader, baseDir string) error {
 	return nil
 }
 
+// FileDigests reads a gzipped tarball and returns the hex encoded SHA-256 digest of each regular
+// file in it, keyed by the cleaned path of the file within the tarball. These are the same paths
+// DecodeTarball extracts the files to, relative to its base directory.
+func FileDigests(reader io.Reader) (map[string]string, error) {
+	gzStream, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't uncompress reader")
+	}
+	defer gzStream.Close()
+
+	digests := map[string]string{}
+	tarchive := tar.NewReader(gzStream)
+	for {
+		header, err := tarchive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't read tarball")
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		h := sha256.New()
+		if _, err := io.CopyN(h, tarchive, header.Size); err != nil {
+			return nil, errors.Wrapf(err, "couldn't read %v from tarball", header.Name)
+		}
+		digests[path.Clean(header.Name)] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return digests, nil
+}
+
 // noTraversal is an ultra-slimmed down function to
 // avoid traversals outside the destination folder.
 // moby and other repos have much more complex versions
@@ -20,6 +20,8 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"io/ioutil"
 	"os"
@@ -153,3 +155,28 @@ func TestDecodeTarball(t *testing.T) {
 		})
 	}
 }
+
+func TestFileDigests(t *testing.T) {
+	file, err := os.Open("testdata/archive.tar.gz")
+	if err != nil {
+		t.Fatalf("couldn't open archive: %v", err)
+	}
+	defer file.Close()
+
+	digests, err := FileDigests(file)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(stoppingByTheWoods))
+	expected := map[string]string{
+		"testdirname/stoppingByTheWoods": hex.EncodeToString(sum[:]),
+	}
+	if !reflect.DeepEqual(digests, expected) {
+		t.Errorf("Expected digests %v but got %v", expected, digests)
+	}
+
+	if _, err := FileDigests(bytes.NewReader([]byte("not a tarball"))); err == nil {
+		t.Error("Expected an error reading an invalid tarball but got none")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/tarball"
+)
+
+const (
+	gzipMimeType = "application/gzip"
+
+	// maxFileDigestsSize bounds the size of the headers giving the digests of the files in a
+	// tarball result so that tarballs with many files don't exceed the aggregator's header
+	// limits. The digest of the whole result is always sent.
+	maxFileDigestsSize = 256 << 10
+)
+
+// resultDigests returns the hex encoded SHA-256 digest of the result file and, for tarballs,
+// the digests of each of the files in it.
+func resultDigests(path, mimeType string) (string, map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "couldn't open result file %q", path)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", nil, errors.Wrapf(err, "couldn't compute digest of result file %q", path)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if mimeType != gzipMimeType {
+		return digest, nil, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", nil, errors.Wrapf(err, "couldn't read result file %q", path)
+	}
+	fileDigests, err := tarball.FileDigests(f)
+	if err != nil {
+		// The aggregator will reject the tarball anyway but that is for it to report.
+		logrus.Warningf("Couldn't compute digests of the files in %v: %v", path, err)
+		return digest, nil, nil
+	}
+	return digest, fileDigests, nil
+}
+
+// addFileDigestHeaders adds a header for the digest of each of the files in a tarball result.
+// If there are too many files, none are added and the aggregator only checks the digest of the
+// result as a whole.
+func addFileDigestHeaders(header http.Header, fileDigests map[string]string) {
+	names := make([]string, 0, len(fileDigests))
+	size := 0
+	for name, digest := range fileDigests {
+		names = append(names, name)
+		size += len(name) + len(digest) + len(aggregation.ResultFileDigestHeader) + 4
+	}
+	if size > maxFileDigestsSize {
+		logrus.Warningf("Not sending digests of the %v files in the result, they would exceed %v bytes", len(fileDigests), maxFileDigestsSize)
+		return
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		header.Add(aggregation.ResultFileDigestHeader, fmt.Sprintf("%v %v", fileDigests[name], name))
+	}
+}
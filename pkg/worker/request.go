@@ -34,6 +34,12 @@ import (
 // don't result in the server waiting forever for results that will never
 // come.)
 func DoRequest(url string, client *http.Client, callback func() (io.Reader, string, string, error)) error {
+	return doRequest(url, client, nil, callback)
+}
+
+// doRequest is DoRequest with additional headers which are only sent along with the results,
+// not with the error message if the callback fails.
+func doRequest(url string, client *http.Client, header http.Header, callback func() (io.Reader, string, string, error)) error {
 	// Create a client with retry logic. Manually log each error as they occur rather than
 	// saving them internal to the client. This helps debug issues which may have relied on retries.
 	pesterClient := pester.NewExtendedClient(client)
@@ -89,6 +95,9 @@ func DoRequest(url string, client *http.Client, callback func() (io.Reader, stri
 	if err != nil {
 		return errors.Wrapf(err, "error constructing aggregator request to %v", url)
 	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
 	req.Header.Add("content-type", mimeType)
 	if len(filename) > 0 {
 		req.Header.Add("content-disposition", fmt.Sprintf("attachment;filename=%v", filename))
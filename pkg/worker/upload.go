@@ -0,0 +1,243 @@
+/*
+Copyright the Sonobuoy contributors 2021
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package worker
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+)
+
+var (
+	// chunkedUploadThreshold is the size, in bytes, above which results are uploaded in chunks.
+	chunkedUploadThreshold int64 = 32 << 20
+
+	// uploadChunkSize is the size, in bytes, of each chunk of a chunked upload.
+	uploadChunkSize int64 = 8 << 20
+
+	// uploadAttempts is how many times in a row a chunked upload may fail to make progress
+	// before it is abandoned. It is also how many times the last chunk may fail in total.
+	uploadAttempts = 5
+
+	// uploadBackoff is how long to wait after the first failure of a chunked upload. It is
+	// increased after each further failure.
+	uploadBackoff = 2 * time.Second
+)
+
+// errUploadRejected is returned when the aggregator will not accept the result at all so
+// retrying the upload is pointless.
+var errUploadRejected = errors.New("upload rejected by aggregator")
+
+// UploadFile sends the file at the given path to the aggregator using a chunked upload. If the
+// connection to the aggregator is lost, the upload is resumed from the last chunk the aggregator
+// received rather than starting over. The aggregator checks the SHA-256 digest of the file once
+// it has all of it.
+func UploadFile(url string, client *http.Client, path, mimeType string) error {
+	digest, fileDigests, err := resultDigests(path, mimeType)
+	if err != nil {
+		return err
+	}
+	return uploadFile(url, client, path, mimeType, digest, fileDigests)
+}
+
+// uploadFile is UploadFile for a file whose digests have already been computed.
+func uploadFile(url string, client *http.Client, path, mimeType, digest string, fileDigests map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't open result file %q", path)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "couldn't stat result file %q", path)
+	}
+
+	u := &uploader{
+		url:         url,
+		client:      client,
+		file:        f,
+		filename:    filepath.Base(path),
+		mimeType:    mimeType,
+		size:        info.Size(),
+		digest:      digest,
+		fileDigests: fileDigests,
+	}
+	return u.upload()
+}
+
+// uploader tracks the state of a single chunked upload.
+type uploader struct {
+	url      string
+	client   *http.Client
+	file     io.ReaderAt
+	filename string
+	mimeType string
+	size     int64
+
+	digest      string
+	fileDigests map[string]string
+}
+
+func (u *uploader) upload() error {
+	// The aggregator checks the file once it has all of it and, if that fails, discards it so
+	// that the upload starts over. Failures of the last chunk are therefore counted separately
+	// and never reset by progress, or a file the aggregator won't take would be sent forever.
+	failures, finalFailures := 0, 0
+	offset, err := u.start()
+	for {
+		if err == errUploadRejected {
+			return err
+		}
+		if err != nil {
+			failures++
+			errlog.LogError(errors.Wrapf(err, "chunked upload to %v failed (attempt %v of %v)", u.url, failures, uploadAttempts))
+			if failures >= uploadAttempts {
+				return errors.Wrapf(err, "giving up on chunked upload to %v", u.url)
+			}
+			time.Sleep(uploadBackoff * time.Duration(failures))
+
+			// Ask the aggregator where to continue from; anything it already has is kept.
+			offset, err = u.start()
+			continue
+		}
+
+		var done bool
+		var next int64
+		next, done, err = u.send(offset)
+		if err != nil {
+			if offset+uploadChunkSize >= u.size {
+				finalFailures++
+				if finalFailures >= uploadAttempts {
+					return errors.Wrapf(err, "giving up on chunked upload to %v after the aggregator failed to complete it %v times", u.url, finalFailures)
+				}
+			}
+			continue
+		}
+		if done {
+			return nil
+		}
+		if next > offset {
+			failures = 0
+		}
+		offset = next
+	}
+}
+
+// start starts or resumes the upload and returns the offset to continue it from.
+func (u *uploader) start() (int64, error) {
+	req, err := http.NewRequest(http.MethodPost, u.url, nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error constructing aggregator request to %v", u.url)
+	}
+	u.addHeaders(req)
+	req.Header.Set(aggregation.UploadLengthHeader, strconv.FormatInt(u.size, 10))
+	req.Header.Set(aggregation.UploadDigestHeader, u.digest)
+	addFileDigestHeaders(req.Header, u.fileDigests)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error encountered dialing aggregator at %v", u.url)
+	}
+	defer resp.Body.Close()
+
+	if err := checkUploadResponse(resp); err != nil {
+		return 0, err
+	}
+	return responseOffset(resp)
+}
+
+// send sends the chunk of the file starting at offset. It returns the offset to continue
+// from and whether or not the upload is complete.
+func (u *uploader) send(offset int64) (int64, bool, error) {
+	n := u.size - offset
+	if n > uploadChunkSize {
+		n = uploadChunkSize
+	}
+	req, err := http.NewRequest(http.MethodPatch, u.url, io.NewSectionReader(u.file, offset, n))
+	if err != nil {
+		return offset, false, errors.Wrapf(err, "error constructing aggregator request to %v", u.url)
+	}
+	req.ContentLength = n
+	u.addHeaders(req)
+	req.Header.Set(aggregation.UploadOffsetHeader, strconv.FormatInt(offset, 10))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return offset, false, errors.Wrapf(err, "error encountered dialing aggregator at %v", u.url)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict && resp.Header.Get(aggregation.UploadOffsetHeader) != "":
+		// The aggregator has a different amount of the file than expected; continue from there.
+		next, err := responseOffset(resp)
+		return next, false, err
+	case resp.StatusCode == http.StatusConflict:
+		// 409 without an offset indicates we've already submitted results. As with single
+		// requests, this isn't useful to error on.
+		errlog.LogError(errors.Errorf("got a %v response when dialing aggregator to %v. Logging and proceeding as normal.", resp.StatusCode, u.url))
+		return offset, true, nil
+	}
+	if err := checkUploadResponse(resp); err != nil {
+		return offset, false, err
+	}
+
+	next, err := responseOffset(resp)
+	if err != nil {
+		return offset, false, err
+	}
+	if next >= u.size {
+		logrus.WithField("bytes", u.size).Infof("Completed chunked upload to %v", u.url)
+		return next, true, nil
+	}
+	return next, false, nil
+}
+
+func (u *uploader) addHeaders(req *http.Request) {
+	req.Header.Set("content-type", u.mimeType)
+	req.Header.Set("content-disposition", fmt.Sprintf("attachment;filename=%v", u.filename))
+}
+
+// checkUploadResponse returns an error for any unsuccessful response, using errUploadRejected
+// if the aggregator doesn't expect the result at all.
+func checkUploadResponse(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errUploadRejected
+	default:
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("got a %v response from aggregator: %s", resp.StatusCode, body)
+	}
+}
+
+func responseOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get(aggregation.UploadOffsetHeader), 10, 64)
+	return offset, errors.Wrapf(err, "invalid %v header in aggregator response", aggregation.UploadOffsetHeader)
+}
@@ -28,6 +28,8 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/sonobuoy/pkg/errlog"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
 )
 
 const (
@@ -83,7 +85,11 @@ func relayProgress(aggregatorURL string, client *http.Client) func(w http.Respon
 // 1. Output data will be placed into an agreed upon results directory.
 // 2. The Job will wait for a done file
 // 3. The done file contains a single string of the results to be sent to the aggregator
-func GatherResults(waitfile string, url string, client *http.Client, stopc <-chan struct{}) error {
+//
+// Results larger than chunkedUploadThreshold are sent in chunks to the uploadURL so that they
+// can be resumed if the connection to the aggregator is lost. If uploadURL is empty, results
+// are always sent in a single request to the url.
+func GatherResults(waitfile string, url, uploadURL string, client *http.Client, stopc <-chan struct{}) error {
 	logrus.WithField("waitfile", waitfile).Info("Waiting for waitfile")
 	ticker := time.NewTicker(time.Duration(1) * time.Second)
 	// TODO(chuckha) evaluate wait.Until [https://github.com/kubernetes/apimachinery/blob/e9ff529c66f83aeac6dff90f11ea0c5b7c4d626a/pkg/util/wait/wait.go]
@@ -93,7 +99,7 @@ func GatherResults(waitfile string, url string, client *http.Client, stopc <-cha
 			if resultFile, err := ioutil.ReadFile(waitfile); err == nil {
 				resultFile = bytes.TrimSpace(resultFile)
 				logrus.WithField("resultFile", string(resultFile)).Info("Detected done file, transmitting result file")
-				return handleWaitFile(string(resultFile), url, client)
+				return handleWaitFile(string(resultFile), url, uploadURL, client)
 			}
 		case <-stopc:
 			logrus.Info("Did not receive plugin results in time. Shutting down worker.")
@@ -102,7 +108,7 @@ func GatherResults(waitfile string, url string, client *http.Client, stopc <-cha
 	}
 }
 
-func handleWaitFile(resultFile, url string, client *http.Client) error {
+func handleWaitFile(resultFile, url, uploadURL string, client *http.Client) error {
 	var outfile *os.File
 	var err error
 
@@ -110,14 +116,30 @@ func handleWaitFile(resultFile, url string, client *http.Client) error {
 	extension := filepath.Ext(resultFile)
 	mimeType := mime.TypeByExtension(extension)
 
-	defer func() {
-		if outfile != nil {
-			outfile.Close()
+	// The aggregator checks the result against its digest. If the digest can't be computed, the
+	// result is still sent; reading the file is likely to fail too and the error is sent instead.
+	digest, fileDigests, err := resultDigests(resultFile, mimeType)
+	if err != nil {
+		errlog.LogError(errors.Wrap(err, "couldn't compute digest of result"))
+	}
+
+	if info, statErr := os.Stat(resultFile); statErr == nil && len(digest) > 0 && len(uploadURL) > 0 && info.Size() > chunkedUploadThreshold {
+		logrus.WithField("bytes", info.Size()).Info("Result file is large, transmitting it in chunks")
+		err = uploadFile(uploadURL, client, resultFile, mimeType, digest, fileDigests)
+		if err == nil {
+			return nil
 		}
-	}()
+		errlog.LogError(errors.Wrap(err, "chunked upload failed, falling back to a single request"))
+	}
+
+	header := http.Header{}
+	if len(digest) > 0 {
+		header.Set(aggregation.ResultDigestHeader, digest)
+		addFileDigestHeaders(header, fileDigests)
+	}
 
 	// transmit back the results file.
-	return DoRequest(url, client, func() (io.Reader, string, string, error) {
+	return doRequest(url, client, header, func() (io.Reader, string, string, error) {
 		outfile, err = os.Open(resultFile)
 		return outfile, filepath.Base(resultFile), mimeType, errors.WithStack(err)
 	})
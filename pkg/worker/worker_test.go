@@ -17,6 +17,8 @@ limitations under the License.
 package worker
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -24,12 +26,16 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmware-tanzu/sonobuoy/pkg/backplane/ca/authtest"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
 	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/aggregation"
+	"github.com/vmware-tanzu/sonobuoy/pkg/tarball"
 )
 
 func TestRun(t *testing.T) {
@@ -54,7 +60,7 @@ func TestRun(t *testing.T) {
 			withTempDir(t, func(tmpdir string) {
 				ioutil.WriteFile(filepath.Join(tmpdir, "systemd_logs"), []byte("{}"), 0755)
 				ioutil.WriteFile(filepath.Join(tmpdir, "done"), []byte(filepath.Join(tmpdir, "systemd_logs")), 0755)
-				err := GatherResults(filepath.Join(tmpdir, "done"), URL, srv.Client(), nil)
+				err := GatherResults(filepath.Join(tmpdir, "done"), URL, "", srv.Client(), nil)
 				if err != nil {
 					t.Fatalf("Got error running agent: %v", err)
 				}
@@ -81,7 +87,7 @@ func TestRunGlobal(t *testing.T) {
 		withTempDir(t, func(tmpdir string) {
 			ioutil.WriteFile(filepath.Join(tmpdir, "systemd_logs.json"), []byte("{}"), 0755)
 			ioutil.WriteFile(filepath.Join(tmpdir, "done"), []byte(filepath.Join(tmpdir, "systemd_logs.json")), 0755)
-			err := GatherResults(filepath.Join(tmpdir, "done"), url, srv.Client(), nil)
+			err := GatherResults(filepath.Join(tmpdir, "done"), url, "", srv.Client(), nil)
 			if err != nil {
 				t.Fatalf("Got error running agent: %v", err)
 			}
@@ -106,7 +112,7 @@ func TestRunGlobal_noExtension(t *testing.T) {
 		withTempDir(t, func(tmpdir string) {
 			ioutil.WriteFile(filepath.Join(tmpdir, "systemd_logs"), []byte("{}"), 0755)
 			ioutil.WriteFile(filepath.Join(tmpdir, "done"), []byte(filepath.Join(tmpdir, "systemd_logs")), 0755)
-			err := GatherResults(filepath.Join(tmpdir, "done"), url, srv.Client(), nil)
+			err := GatherResults(filepath.Join(tmpdir, "done"), url, "", srv.Client(), nil)
 			if err != nil {
 				t.Fatalf("Got error running agent: %v", err)
 			}
@@ -131,7 +137,7 @@ func TestRunGlobalCleanup(t *testing.T) {
 		}
 
 		withTempDir(t, func(tmpdir string) {
-			err := GatherResults(filepath.Join(tmpdir, "done"), url, srv.Client(), stopc)
+			err := GatherResults(filepath.Join(tmpdir, "done"), url, "", srv.Client(), stopc)
 			if err != nil {
 				t.Fatalf("Got error running agent: %v", err)
 			}
@@ -139,6 +145,192 @@ func TestRunGlobalCleanup(t *testing.T) {
 	})
 }
 
+func TestRunChunked(t *testing.T) {
+	defer func(threshold, chunkSize int64, backoff time.Duration) {
+		chunkedUploadThreshold, uploadChunkSize, uploadBackoff = threshold, chunkSize, backoff
+	}(chunkedUploadThreshold, uploadChunkSize, uploadBackoff)
+	chunkedUploadThreshold, uploadChunkSize, uploadBackoff = 10, 8, 0
+
+	expected := strings.Repeat("0123456789", 5)
+	tcs := []struct {
+		desc      string
+		dropChunk int
+	}{
+		{desc: "Large results are uploaded in chunks"},
+		{desc: "Upload resumes after a chunk is lost", dropChunk: 3},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			expectedResults := []plugin.ExpectedResult{
+				{ResultType: "systemd_logs", NodeName: plugin.GlobalResult},
+			}
+			withTempDir(t, func(outdir string) {
+				aggr := aggregation.NewAggregator(filepath.Join(outdir, "plugins"), expectedResults)
+				handler := aggregation.NewHandler(aggr.HandleHTTPResult, aggr.HandleHTTPProgressUpdate, aggr.HandleHTTPUpload)
+
+				// Lose a chunk part way through the upload by only passing on part of it.
+				chunks := 0
+				srv := authtest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Method == http.MethodPatch {
+						chunks++
+						if chunks == tc.dropChunk {
+							r.Body = ioutil.NopCloser(io.LimitReader(r.Body, 3))
+							handler.ServeHTTP(httptest.NewRecorder(), r)
+							w.WriteHeader(http.StatusBadGateway)
+							return
+						}
+					}
+					handler.ServeHTTP(w, r)
+				}), t)
+				defer srv.Close()
+
+				url, err := aggregation.GlobalResultURL(srv.URL, "systemd_logs")
+				if err != nil {
+					t.Fatalf("unexpected error getting global result url %v", err)
+				}
+				uploadURL, err := aggregation.GlobalUploadURL(srv.URL, "systemd_logs")
+				if err != nil {
+					t.Fatalf("unexpected error getting global upload url %v", err)
+				}
+
+				withTempDir(t, func(tmpdir string) {
+					ioutil.WriteFile(filepath.Join(tmpdir, "systemd_logs.json"), []byte(expected), 0755)
+					ioutil.WriteFile(filepath.Join(tmpdir, "done"), []byte(filepath.Join(tmpdir, "systemd_logs.json")), 0755)
+					err := GatherResults(filepath.Join(tmpdir, "done"), url, uploadURL, srv.Client(), nil)
+					if err != nil {
+						t.Fatalf("Got error running agent: %v", err)
+					}
+				})
+
+				if chunks <= 1 {
+					t.Errorf("Expected result to be sent in several chunks but got %v", chunks)
+				}
+				b, err := ioutil.ReadFile(filepath.Join(aggr.OutputDir, "systemd_logs", "results", plugin.GlobalResult, "systemd_logs.json"))
+				if err != nil {
+					t.Fatalf("Expected result to be saved: %v", err)
+				}
+				if string(b) != expected {
+					t.Errorf("Expected result %q but got %q", expected, string(b))
+				}
+			})
+		})
+	}
+}
+
+func TestUploadFileFinalChunkFails(t *testing.T) {
+	defer func(chunkSize int64, backoff time.Duration) {
+		uploadChunkSize, uploadBackoff = chunkSize, backoff
+	}(uploadChunkSize, uploadBackoff)
+	uploadChunkSize, uploadBackoff = 8, 0
+
+	content := strings.Repeat("0123456789", 5)
+
+	// The aggregator accepts every chunk but fails the upload once it has all of it, discarding
+	// what it received as it does when the digest doesn't match.
+	var received int64
+	finals := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set(aggregation.UploadOffsetHeader, strconv.FormatInt(received, 10))
+		case http.MethodPatch:
+			n, _ := io.Copy(ioutil.Discard, r.Body)
+			received += n
+			if received >= int64(len(content)) {
+				finals++
+				received = 0
+				http.Error(w, "digest mismatch", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set(aggregation.UploadOffsetHeader, strconv.FormatInt(received, 10))
+		}
+	}))
+	defer srv.Close()
+
+	withTempDir(t, func(tmpdir string) {
+		path := filepath.Join(tmpdir, "systemd_logs.json")
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write result: %v", err)
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- UploadFile(srv.URL, srv.Client(), path, "application/json") }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatal("Expected the upload to fail, got nil")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Expected the upload to give up but it was still going")
+		}
+	})
+
+	if finals != uploadAttempts {
+		t.Errorf("Expected the whole file to be sent %v times but it was sent %v times", uploadAttempts, finals)
+	}
+}
+
+func TestResultDigestHeaders(t *testing.T) {
+	withTempDir(t, func(tmpdir string) {
+		resultsDir := filepath.Join(tmpdir, "results")
+		os.MkdirAll(resultsDir, 0755)
+		ioutil.WriteFile(filepath.Join(resultsDir, "junit.xml"), []byte("<testsuites/>"), 0644)
+		ioutil.WriteFile(filepath.Join(tmpdir, "plain.txt"), []byte("plain"), 0644)
+		tarPath := filepath.Join(tmpdir, "results.tar.gz")
+		if err := tarball.DirToTarball(resultsDir, tarPath, true); err != nil {
+			t.Fatalf("Failed to create tarball: %v", err)
+		}
+		tarBytes, err := ioutil.ReadFile(tarPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tcs := []struct {
+			desc                string
+			file                string
+			expectedDigest      string
+			expectedFileDigests []string
+		}{
+			{
+				desc:           "Digest of plain file is sent",
+				file:           filepath.Join(tmpdir, "plain.txt"),
+				expectedDigest: digestOf("plain"),
+			}, {
+				desc:                "Digests of files in tarballs are sent",
+				file:                tarPath,
+				expectedDigest:      digestOf(string(tarBytes)),
+				expectedFileDigests: []string{digestOf("<testsuites/>") + " junit.xml"},
+			},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				var header http.Header
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					header = r.Header
+				}))
+				defer srv.Close()
+
+				if err := handleWaitFile(tc.file, srv.URL, "", srv.Client()); err != nil {
+					t.Fatalf("Unexpected error sending result: %v", err)
+				}
+				if got := header.Get(aggregation.ResultDigestHeader); got != tc.expectedDigest {
+					t.Errorf("Expected digest %q but got %q", tc.expectedDigest, got)
+				}
+				if got := header.Values(aggregation.ResultFileDigestHeader); !reflect.DeepEqual(got, tc.expectedFileDigests) {
+					t.Errorf("Expected file digests %q but got %q", tc.expectedFileDigests, got)
+				}
+			})
+		}
+	})
+}
+
+func digestOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
 func TestRelayProgress(t *testing.T) {
 	tcs := []struct {
 		desc           string
@@ -215,7 +407,7 @@ func withAggregator(t *testing.T, expectedResults []plugin.ExpectedResult, callb
 
 		// Configure the aggregator
 		aggr := aggregation.NewAggregator(tmpdir, expectedResults)
-		handler := aggregation.NewHandler(aggr.HandleHTTPResult, aggr.HandleHTTPProgressUpdate)
+		handler := aggregation.NewHandler(aggr.HandleHTTPResult, aggr.HandleHTTPProgressUpdate, aggr.HandleHTTPUpload)
 		srv := authtest.NewTLSServer(handler, t)
 		defer srv.Close()
 
@@ -12,6 +12,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -32,6 +33,9 @@ var (
 	sonobuoy string
 
 	update = flag.Bool("update", false, "update .golden files")
+
+	// randomUUID matches the run IDs generated by gen.
+	randomUUID = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
 )
 
 func findSonobuoyCLI() (string, error) {
@@ -457,7 +461,8 @@ func pwd(t *testing.T) string {
 }
 
 // TestExactOutput is to test things which can expect exact output; so do not use it
-// for things like configs which include timestamps or UUIDs.
+// for things like configs which include timestamps. Generated run IDs are replaced by
+// *RANDOM_UUID* so that it can still be checked they are set.
 func TestExactOutput(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTestTimeout)
 	defer cancel()
@@ -493,7 +498,7 @@ func TestExactOutput(t *testing.T) {
 				"-p testdata/hello-world.yaml -p testdata/variable-image.yaml",
 			expectFile: "testdata/gen-variable-image.golden",
 		}, {
-			desc:       "gen doesnt provide UUID",
+			desc:       "gen generates a run ID if not given one",
 			cmdLine:    "gen",
 			expectFile: "testdata/gen-no-uuid.golden",
 		}, {
@@ -523,6 +528,7 @@ func TestExactOutput(t *testing.T) {
 			binaryVer := strings.TrimSpace(binaryVersion.String())
 
 			outString := strings.ReplaceAll(output.String(), binaryVer, "*STATIC_FOR_TESTING*")
+			outString = randomUUID.ReplaceAllString(outString, "*RANDOM_UUID*")
 			if *update {
 				if err := os.WriteFile(tc.expectFile, []byte(outString), 0666); err != nil {
 					t.Fatalf("Failed to update goldenfile: %v", err)